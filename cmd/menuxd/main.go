@@ -3,29 +3,46 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"time"
 
 	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 	_ "github.com/joho/godotenv/autoload"
 
 	"gitlab.com/menuxd/api-rest/internal/server"
 	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/internal/storage/memory"
 )
 
 func main() {
 	debug := flag.Bool("debug", false, "Debug mode activation")
+	storageKind := flag.String("storage", "database", "Storage backend: database, of DATABASE_URL, or memory")
 	flag.Parse()
 
+	if flag.Arg(0) == "migrate" {
+		err := migrate(flag.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	b, err := newBackend(*storageKind)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	var port string
 	if port = os.Getenv("PORT"); port == "" {
 		port = "1323"
 	}
 
 	server.SetConfig(port, *debug)
-	srv, err := server.New()
+	srv, err := server.New(b)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -49,3 +66,36 @@ func main() {
 	defer cancel()
 	srv.Shutdown(ctx)
 }
+
+// newBackend returns the storage backend of the kind given. The database
+// must have no pending migrations, that "menuxd migrate up" applies. The
+// memory one starts empty, with an admin from XD_ADMIN_EMAIL and
+// XD_ADMIN_PASSWORD if they are set.
+func newBackend(kind string) (storage.Backend, error) {
+	switch kind {
+	case "database":
+		err := storage.Connect(os.Getenv("DATABASE_URL"))
+		if err != nil {
+			return storage.Backend{}, err
+		}
+
+		return storage.NewBackend()
+	case "memory":
+		b := memory.New()
+
+		address := os.Getenv("XD_ADMIN_EMAIL")
+		if address == "" {
+			log.Print("Memory storage without admin, set XD_ADMIN_EMAIL and XD_ADMIN_PASSWORD to add one")
+			return b, nil
+		}
+
+		_, err := b.Users.(memory.UserStorage).Admin(address, os.Getenv("XD_ADMIN_PASSWORD"))
+		if err != nil {
+			return storage.Backend{}, err
+		}
+
+		return b, nil
+	}
+
+	return storage.Backend{}, fmt.Errorf("unknown storage %q", kind)
+}
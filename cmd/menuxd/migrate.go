@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+)
+
+// migrate runs a migration command on the database of DATABASE_URL: up
+// applies the pending migrations, down reverts the last one and status
+// lists them.
+func migrate(cmd string) error {
+	err := storage.Connect(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	switch cmd {
+	case "up":
+		applied, err := storage.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("Applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return err
+	case "down":
+		m, err := storage.MigrateDown()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted %d %s\n", m.Version, m.Name)
+		return nil
+	case "status":
+		states, err := storage.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d %-20s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown migrate command %q, use up, down or status", cmd)
+}
@@ -3,7 +3,7 @@ module gitlab.com/menuxd/api-rest
 go 1.12
 
 require (
-	github.com/dgrijalva/jwt-go v0.0.0-20180308231308-06ea1031745c // indirect
+	github.com/dgrijalva/jwt-go v0.0.0-20180308231308-06ea1031745c
 	github.com/fatih/color v1.9.0 // indirect
 	github.com/githubnemo/CompileDaemon v1.0.0 // indirect
 	github.com/go-chi/chi v4.0.0+incompatible
@@ -14,6 +14,7 @@ require (
 	github.com/jinzhu/gorm v1.9.8
 	github.com/joho/godotenv v1.3.0
 	github.com/mailjet/mailjet-apiv3-go v0.0.0-20190724151621-55e56f74078c
+	github.com/mattn/go-sqlite3 v1.11.0
 	github.com/sethvargo/go-password v0.0.0-20181008190013-bc15c697eeda
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/crypto v0.0.0-20190325154230-a5d413f7728c
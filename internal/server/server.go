@@ -10,6 +10,7 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	v1 "gitlab.com/menuxd/api-rest/internal/server/v1"
+	"gitlab.com/menuxd/api-rest/internal/storage"
 )
 
 type config struct {
@@ -28,7 +29,7 @@ func SetConfig(port string, debug bool) {
 
 var basePath = os.Getenv("XD_BASE_PATH")
 
-func getRoutes() (http.Handler, error) {
+func getRoutes(b storage.Backend) (http.Handler, error) {
 	cors := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{
@@ -60,7 +61,7 @@ func getRoutes() (http.Handler, error) {
 	r.Handle("/public/*", http.StripPrefix(
 		"/public", http.FileServer(http.Dir(basePath+"public")),
 	))
-	v1Routes, err := v1.NewAPI()
+	v1Routes, err := v1.NewAPI(b)
 	if err != nil {
 		return nil, err
 	}
@@ -75,9 +76,10 @@ func getRoutes() (http.Handler, error) {
 	return r, nil
 }
 
-// New inicialize a new server with configuration.
-func New() (*http.Server, error) {
-	r, err := getRoutes()
+// New inicialize a new server with configuration, on the storage of the
+// backend given.
+func New(b storage.Backend) (*http.Server, error) {
+	r, err := getRoutes(b)
 	if err != nil {
 		return nil, err
 	}
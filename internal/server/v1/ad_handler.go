@@ -7,6 +7,8 @@ import (
 
 	"github.com/go-chi/chi"
 	"gitlab.com/menuxd/api-rest/pkg/ad"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 )
 
 // AdRouter is a router to the ads.
@@ -77,6 +79,11 @@ func (ar AdRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Allowed(r, a.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = ar.storage.Create(a)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -185,14 +192,16 @@ func NewAdRouter(s ad.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	ar := AdRouter{storage: s}
 
+	r.Use(tenant.Resolve(tenants))
+
 	// Set endpoints.
-	r.Get("/client/{clientId}", ar.getAllHandler)
-	r.Post("/", ar.createHandler)
-	r.Post("/add-click/{id}", ar.addClickHandler)
-	r.Get("/{id}", ar.getOneHandler)
-	r.Put("/{id}", ar.updateHandler)
-	r.Patch("/{id}", ar.patchHandler)
-	r.Delete("/{id}", ar.deleteHandler)
+	r.With(auth.Require(auth.ReadMenu), tenant.Client("clientId")).Get("/client/{clientId}", ar.getAllHandler)
+	r.With(auth.Require(auth.ManageMenu)).Post("/", ar.createHandler)
+	r.With(auth.Require(auth.SendFeedback), tenant.Owned("id", tenant.Ads)).Post("/add-click/{id}", ar.addClickHandler)
+	r.With(auth.Require(auth.ReadMenu), tenant.Owned("id", tenant.Ads)).Get("/{id}", ar.getOneHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Ads)).Put("/{id}", ar.updateHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Ads)).Patch("/{id}", ar.patchHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Ads)).Delete("/{id}", ar.deleteHandler)
 
 	return r
 }
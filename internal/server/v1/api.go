@@ -2,97 +2,149 @@ package v1
 
 import (
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/jwtauth"
 	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/token"
 )
 
-// NewAPI returns the API V1 Handler with configuration.
-func NewAPI() (http.Handler, error) {
-	if err := storage.InitData(); err != nil {
+// tenants resolves the clients a token reaches and the client owning each
+// resource.
+var tenants tenant.Storage = storage.TenantStorage{}
+
+// tokens signs and verifies the tokens of every router.
+var tokens *token.Service
+
+// NewAPI returns the API V1 Handler with configuration, on the storage of
+// the backend given.
+func NewAPI(b storage.Backend) (http.Handler, error) {
+	tenants = b.Tenants
+
+	var err error
+	tokens, err = token.FromEnv()
+	if err != nil {
 		return nil, err
 	}
 
-	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("XD_SIGNING_STRING")), nil)
+	tokens.AcceptKeys(apikey.Resolver{Storage: b.APIKeys})
+
 	r := chi.NewRouter()
+	r.Get("/.well-known/jwks.json", tokens.JWKSHandler)
 
-	um, ur := NewUserRouter(storage.UserStorage{})
+	um, ur := NewUserRouter(b.Users, b.Logins)
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
 		Post("/login", ur.LoginHandler)
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		Post("/login/2fa", ur.TwoFactorLoginHandler)
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		Post("/login/2fa/setup", ur.TwoFactorSetupHandler)
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		Post("/refresh", ur.RefreshHandler)
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		With(tokens.Verifier).
+		Post("/logout", ur.LogoutHandler)
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
 		Put("/forgot-password/", ur.ForgotPasswordHandler)
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		Put("/reset-password/", ur.ResetPasswordHandler)
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
 		Mount("/users", um)
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		Mount("/dishes", NewDishRouter(storage.DishStorage{}))
+		Mount("/dishes", NewDishRouter(b.Dishes))
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		Mount("/clients", NewClientRouter(storage.ClientStorage{}))
+		Mount("/clients", NewClientRouter(b.Clients))
 
 	r.Mount("/orders", NewOrderRouter(
-		storage.OrderStorage{},
-		storage.TableStorage{},
-		storage.DishStorage{},
+		b.Orders,
+		b.Tables,
+		b.Dishes,
+		b.Notifications,
+		b.Clients,
 	))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		Mount("/categories", NewCategoryRouter(storage.CategoryStorage{}))
+		Mount("/categories", NewCategoryRouter(b.Categories))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/waiters", NewWaiterRouter(storage.WaiterStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/waiters", NewWaiterRouter(b.Waiters))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
+		With(tokens.Verifier).With(jwtauth.Authenticator).
 		Mount("/pictures", NewPictureRouter())
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/tables", NewTableRouter(storage.TableStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/tables", NewTableRouter(b.Tables))
+
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		Mount("/devices", NewDeviceRouter(b.Devices))
+
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		Mount("/api-keys", NewAPIKeyRouter(b.APIKeys))
+
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/bills", NewBillRouter(b.Bills, b.Orders, b.Clients))
+
+	r.With(middleware.DefaultCompress).
+		With(middleware.Timeout(10*time.Second)).
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/payments", NewPaymentRouter(b.Payments))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/bills", NewBillRouter(storage.BillStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/registers", NewRegisterRouter(b.Registers))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/promotions", NewPromotionRouter(storage.PromotionStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/promotions", NewPromotionRouter(b.Promotions))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/ads", NewAdRouter(storage.AdStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/ads", NewAdRouter(b.Ads))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/ratings", NewRatingRouter(storage.RatingStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/ratings", NewRatingRouter(b.Ratings))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/questions", NewQuestionRouter(storage.QuestionStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/questions", NewQuestionRouter(b.Questions))
 
 	r.With(middleware.DefaultCompress).
 		With(middleware.Timeout(10*time.Second)).
-		With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Mount("/stay", NewStayRouter(storage.StayStorage{}))
+		With(tokens.Verifier).With(jwtauth.Authenticator).
+		Mount("/stay", NewStayRouter(b.Stays))
 
 	return r, nil
 }
@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+)
+
+// APIKeyRouter is a router of the API keys of the integrations.
+type APIKeyRouter struct {
+	storage apikey.Storage
+}
+
+// newKey is the body to create an API key.
+type newKey struct {
+	Name   string           `json:"name"`
+	Scopes apikey.ScopeList `json:"scopes"`
+}
+
+// getAllHandler response all the API keys from a client, without the keys.
+func (kr APIKeyRouter) getAllHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keys, err := kr.storage.GetAll(uint(clientID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(keys)
+	if err != nil {
+		http.Error(w, "Failed to parse API keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// createHandler creates an API key of a client and responds it with the
+// key, the only time it is shown.
+func (kr APIKeyRouter) createHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nk := newKey{}
+	err = json.NewDecoder(r.Body).Decode(&nk)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	k, err := apikey.New(uint(clientID), nk.Name, nk.Scopes)
+	if err == apikey.ErrNoScopes || err == apikey.ErrInvalidScope {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = kr.storage.Create(k)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	j, err := json.Marshal(k)
+	if err != nil {
+		http.Error(w, "Failed to parse API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(j)
+}
+
+// revokeHandler revokes an API key by ID, it stops being accepted.
+func (kr APIKeyRouter) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = kr.storage.Revoke(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// NewAPIKeyRouter inicialize a new router with each endpoint
+func NewAPIKeyRouter(s apikey.Storage) *chi.Mux {
+	r := chi.NewRouter()
+	kr := APIKeyRouter{storage: s}
+
+	r.Use(tokens.Verifier, tenant.Resolve(tenants))
+
+	// Set endpoints
+	r.With(auth.Require(auth.ManageKeys), tenant.Client("clientId")).Get("/client/{clientId}", kr.getAllHandler)
+	r.With(auth.Require(auth.ManageKeys), tenant.Client("clientId")).Post("/client/{clientId}", kr.createHandler)
+	r.With(auth.Require(auth.ManageKeys), tenant.Owned("id", tenant.APIKeys)).Delete("/{id}", kr.revokeHandler)
+
+	return r
+}
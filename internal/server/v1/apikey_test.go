@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/token"
+)
+
+// fakeAPIKeys keeps the API keys in memory, as the database without the
+// keys.
+type fakeAPIKeys struct {
+	keys map[uint]*apikey.Key
+}
+
+func (f *fakeAPIKeys) Create(k *apikey.Key) error {
+	k.ID = uint(len(f.keys) + 1)
+	stored := *k
+	stored.Key = ""
+	f.keys[k.ID] = &stored
+	return nil
+}
+
+func (f *fakeAPIKeys) Revoke(id uint) error {
+	if k, ok := f.keys[id]; ok && k.RevokedAt == nil {
+		now := time.Now()
+		k.RevokedAt = &now
+	}
+
+	return nil
+}
+
+func (f *fakeAPIKeys) GetAll(clientID uint) (apikey.Keys, error) {
+	keys := apikey.Keys{}
+	for _, k := range f.keys {
+		if k.ClientID == clientID {
+			keys = append(keys, *k)
+		}
+	}
+
+	return keys, nil
+}
+
+func (f *fakeAPIKeys) GetByKey(key string) (apikey.Key, error) {
+	for _, k := range f.keys {
+		if k.KeyHash == apikey.HashKey(key) {
+			return *k, nil
+		}
+	}
+
+	return apikey.Key{}, storage.ErrNotFound
+}
+
+func (f *fakeAPIKeys) Touch(id uint, now time.Time) error {
+	f.keys[id].LastUsedAt = &now
+	return nil
+}
+
+// fakeDishes has no dishes.
+type fakeDishes struct {
+	dish.Storage
+}
+
+func (fakeDishes) GetAll(clientID uint) ([]dish.Dish, error) {
+	return []dish.Dish{}, nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	stored, storedTokens := tenants, tokens
+	tenants = fakeTenants{}
+	tokens = token.NewHMAC([]byte("secret"))
+	defer func() { tenants, tokens = stored, storedTokens }()
+
+	keys := &fakeAPIKeys{keys: map[uint]*apikey.Key{}}
+	tokens.AcceptKeys(apikey.Resolver{Storage: keys})
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, owner, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "id": "1", "role": "client", "sid": "1"})
+	_, manager, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "role": "manager", "client_id": "1"})
+
+	kh := NewAPIKeyRouter(keys)
+	do := func(h http.Handler, method, path, body string, header, value string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set(header, value)
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// Only the owners manage the keys of their clients.
+	assert.Equal(t, http.StatusBadRequest, do(kh, "POST", "/client/1", `{"scopes":["menu:write"]}`, "Authorization", "Bearer "+owner).Code)
+	assert.Equal(t, http.StatusBadRequest, do(kh, "POST", "/client/1", `{"scopes":[]}`, "Authorization", "Bearer "+owner).Code)
+	assert.Equal(t, http.StatusForbidden, do(kh, "POST", "/client/3", `{"scopes":["menu:read"]}`, "Authorization", "Bearer "+owner).Code)
+	assert.Equal(t, http.StatusForbidden, do(kh, "POST", "/client/1", `{"scopes":["menu:read"]}`, "Authorization", "Bearer "+manager).Code)
+
+	w := do(kh, "POST", "/client/1", `{"name":"POS","scopes":["menu:read"]}`, "Authorization", "Bearer "+owner)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	created := apikey.Key{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Key, apikey.Prefix))
+	assert.NotContains(t, w.Body.String(), keys.keys[created.ID].KeyHash)
+
+	// The list doesn't show the keys again.
+	w = do(kh, "GET", "/client/1", "", "Authorization", "Bearer "+owner)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Key)
+
+	// The key reaches its scopes of its client, and nothing else.
+	dishes := NewDishRouter(fakeDishes{})
+	orders := NewOrderRouter(nil, nil, nil, nil, nil)
+	assert.Equal(t, http.StatusOK, do(dishes, "GET", "/client/1", "", token.APIKeyHeader, created.Key).Code)
+	assert.NotNil(t, keys.keys[created.ID].LastUsedAt)
+	assert.Equal(t, http.StatusForbidden, do(dishes, "GET", "/client/3", "", token.APIKeyHeader, created.Key).Code)
+	assert.Equal(t, http.StatusForbidden, do(dishes, "POST", "/", `{"client_id":1}`, token.APIKeyHeader, created.Key).Code)
+	assert.Equal(t, http.StatusForbidden, do(orders, "GET", "/client/1", "", token.APIKeyHeader, created.Key).Code)
+	assert.Equal(t, http.StatusForbidden, do(kh, "GET", "/client/1", "", token.APIKeyHeader, created.Key).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(dishes, "GET", "/client/1", "", token.APIKeyHeader, apikey.Prefix+"unknown").Code)
+
+	// Revoked keys are rejected.
+	assert.Equal(t, http.StatusOK, do(kh, "DELETE", "/1", "", "Authorization", "Bearer "+owner).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(dishes, "GET", "/client/1", "", token.APIKeyHeader, created.Key).Code)
+}
@@ -7,11 +7,17 @@ import (
 
 	"github.com/go-chi/chi"
 	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/order"
 )
 
 // BillRouter is the router of bills.
 type BillRouter struct {
-	storage bill.Storage
+	storage       bill.Storage
+	orderStorage  order.Storage
+	clientStorage client.Storage
 }
 
 // getAllHandler response all the bills from a client.
@@ -66,35 +72,103 @@ func (br BillRouter) getOneHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
-// createHandler Create a new bill.
+// createHandler Create a new bill from the orders of a table.
 func (br BillRouter) createHandler(w http.ResponseWriter, r *http.Request) {
-	b := bill.New()
-	err := json.NewDecoder(r.Body).Decode(b)
+	req := bill.Request{}
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		http.Error(w, "Failed to parse the bill", http.StatusBadRequest)
 		return
 	}
 
+	defer r.Body.Close()
+
+	if req.TableID != 0 && !tenant.Owns(r, tenant.Tables, req.TableID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	orders := []order.Order{}
+	if len(req.OrderIDs) == 0 {
+		orders, err = br.orderStorage.GetUnbilled(req.TableID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	if len(req.OrderIDs) > 0 {
+		orders, err = br.orderStorage.GetByIDs(req.OrderIDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	for _, o := range orders {
+		if !tenant.Allowed(r, o.ClientID) {
+			http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	c := client.Client{}
+	if len(orders) > 0 {
+		c, err = br.clientStorage.GetByID(orders[0].ClientID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	b, err := bill.Compute(orders, c)
+	switch err {
+	case nil:
+	case bill.ErrAlreadyBilled:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.TableID != 0 && b.TableID != req.TableID {
+		http.Error(w, bill.ErrMixedOrders.Error(), http.StatusBadRequest)
+		return
+	}
+
 	err = br.storage.Create(b)
+	if err == bill.ErrAlreadyBilled {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
-}
+	j, err := json.Marshal(b)
+	if err != nil {
+		http.Error(w, "Failed to parse bills", http.StatusInternalServerError)
+		return
+	}
 
-// updateHandler update a stored bill by id.
-func (br BillRouter) updateHandler(w http.ResponseWriter, r *http.Request) {
-	b := bill.New()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(j)
+}
 
-	err := json.NewDecoder(r.Body).Decode(b)
+// splitHandler split a bill by items, evenly or by custom amounts.
+func (br BillRouter) splitHandler(w http.ResponseWriter, r *http.Request) {
+	req := bill.SplitRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Failed to parse the bill", http.StatusBadRequest)
+		http.Error(w, "Failed to parse the split", http.StatusBadRequest)
 		return
 	}
 
+	defer r.Body.Close()
+
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -102,14 +176,47 @@ func (br BillRouter) updateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = br.storage.Update(uint(id), b)
+	b, err := br.storage.GetByID(uint(id))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
+	parts, err := b.Split(req)
+	if err == bill.ErrAlreadyPaid || err == bill.ErrHasPayments {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = br.storage.Split(uint(id), parts)
+	if err == bill.ErrAlreadyPaid || err == bill.ErrHasPayments {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err = br.storage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(b)
+	if err != nil {
+		http.Error(w, "Failed to parse bills", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
+	w.Write(j)
 }
 
 // deleteHandler Remove a bill by ID.
@@ -122,6 +229,10 @@ func (br BillRouter) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = br.storage.Delete(uint(id))
+	if err == bill.ErrHasPayments {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -132,16 +243,18 @@ func (br BillRouter) deleteHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // NewBillRouter inicialize a new router with each endpoint.
-func NewBillRouter(s bill.Storage) *chi.Mux {
+func NewBillRouter(s bill.Storage, ors order.Storage, cs client.Storage) *chi.Mux {
 	r := chi.NewRouter()
-	br := BillRouter{storage: s}
+	br := BillRouter{storage: s, orderStorage: ors, clientStorage: cs}
+
+	r.Use(tenant.Resolve(tenants))
 
 	// Set endpoints
-	r.Get("/client/{clientId}", br.getAllHandler)
-	r.Post("/", br.createHandler)
-	r.Get("/{id}", br.getOneHandler)
-	r.Put("/{id}", br.updateHandler)
-	r.Delete("/{id}", br.deleteHandler)
+	r.With(auth.Require(auth.ReadBills), tenant.Client("clientId")).Get("/client/{clientId}", br.getAllHandler)
+	r.With(auth.Require(auth.WriteBills)).Post("/", br.createHandler)
+	r.With(auth.Require(auth.ReadBills), tenant.Owned("id", tenant.Bills)).Get("/{id}", br.getOneHandler)
+	r.With(auth.Require(auth.WriteBills), tenant.Owned("id", tenant.Bills)).Delete("/{id}", br.deleteHandler)
+	r.With(auth.Require(auth.WriteBills), tenant.Owned("id", tenant.Bills)).Post("/{id}/split", br.splitHandler)
 
 	return r
 }
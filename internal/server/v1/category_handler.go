@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
 	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 )
 
 // CategoryRouter is a router to Categories.
@@ -131,9 +132,14 @@ func (cr CategoryRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Allowed(r, c.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = cr.storage.Create(c)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -168,7 +174,7 @@ func (cr CategoryRouter) createManyHandler(w http.ResponseWriter, r *http.Reques
 
 	err = cr.storage.CreateMany(uint(clientID), categories)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -186,6 +192,13 @@ func (cr CategoryRouter) updatePositionHandler(w http.ResponseWriter, r *http.Re
 	}
 	defer r.Body.Close()
 
+	for _, c := range categories {
+		if !tenant.Owns(r, tenant.Categories, c.ID) {
+			http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
 	err = cr.storage.UpdatePositions(categories)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -214,7 +227,7 @@ func (cr CategoryRouter) updateHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = cr.storage.Update(uint(id), c)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -241,7 +254,7 @@ func (cr CategoryRouter) patchHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = cr.storage.Patch(uint(id), c)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -272,28 +285,28 @@ func (cr CategoryRouter) deleteHandler(w http.ResponseWriter, r *http.Request) {
 func NewCategoryRouter(s category.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	cr := CategoryRouter{storage: s}
-	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("XD_SIGNING_STRING")), nil)
+	r.Use(tokens.Verifier, tenant.Resolve(tenants))
 
 	// Set endpoints.
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Get("/client/{clientId}", cr.getAllActiveHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Get("/client/{clientId}/admin", cr.getAllHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ReadMenu), tenant.Client("clientId")).Get("/client/{clientId}", cr.getAllActiveHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ManageMenu), tenant.Client("clientId")).Get("/client/{clientId}/admin", cr.getAllHandler)
 	r.Get("/client/{clientId}/categories.json", cr.getAllByBackupHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Post("/client/{clientId}", cr.createManyHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Post("/", cr.createHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Get("/{id}", cr.getOneHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Put("/{id}", cr.updateHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Delete("/{id}", cr.deleteHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Patch("/{id}", cr.patchHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Put("/position/", cr.updatePositionHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ManageMenu), tenant.Client("clientId")).Post("/client/{clientId}", cr.createManyHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ManageMenu)).Post("/", cr.createHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ReadMenu), tenant.Owned("id", tenant.Categories)).Get("/{id}", cr.getOneHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Categories)).Put("/{id}", cr.updateHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Categories)).Delete("/{id}", cr.deleteHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Categories)).Patch("/{id}", cr.patchHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ManageMenu)).Put("/position/", cr.updatePositionHandler)
 
 	return r
 }
@@ -3,13 +3,13 @@ package v1
 import (
 	"encoding/json"
 	"net/http"
-	"os"
 	"strconv"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
 	"gitlab.com/menuxd/api-rest/pkg/client"
 	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 )
 
 // ClientRouter is the router of clients.
@@ -140,38 +140,38 @@ func NewClientRouter(s client.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	cr := ClientRouter{storage: s}
 
-	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("XD_SIGNING_STRING")), nil)
+	r.Use(tokens.Verifier, tenant.Resolve(tenants))
 
 	// Set endpoints.
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
 		auth.Authenticator("admin"),
 	).Post("/", cr.createHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/{id}", cr.getOneHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadClient), tenant.Client("id")).Get("/{id}", cr.getOneHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
 		auth.Authenticator("admin"),
 	).Put("/{id}", cr.updateHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
 		auth.Authenticator("admin"),
 	).Delete("/{id}", cr.deleteHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/user/{userId}", cr.getAllHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadClient), tenant.User("userId")).Get("/user/{userId}", cr.getAllHandler)
 
 	return r
 }
@@ -0,0 +1,211 @@
+package v1
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/device"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/ratelimit"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// Pairing limits.
+const (
+	// pairAttempts are the codes an address can try each pairWindow.
+	pairAttempts = 10
+	pairWindow   = time.Minute
+)
+
+// DeviceRouter is a router of the table devices.
+type DeviceRouter struct {
+	storage device.Storage
+	limiter *ratelimit.Limiter
+}
+
+// pairing is the body to pair a device.
+type pairing struct {
+	Code string `json:"code"`
+}
+
+// remoteHost returns the address of the request without its port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// getAllHandler response all the devices from a client.
+func (dr DeviceRouter) getAllHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	devices, err := dr.storage.GetAll(uint(clientID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(devices)
+	if err != nil {
+		http.Error(w, "Failed to parse devices", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// createHandler creates a device of a table and responds its pairing code,
+// the only time it is shown.
+func (dr DeviceRouter) createHandler(w http.ResponseWriter, r *http.Request) {
+	tableIDStr := chi.URLParam(r, "tableId")
+	tableID, err := strconv.Atoi(tableIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d := device.Device{}
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&d)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer r.Body.Close()
+	}
+
+	d.TableID = uint(tableID)
+	err = dr.storage.Create(&d)
+	if err == storage.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	j, err := json.Marshal(d)
+	if err != nil {
+		http.Error(w, "Failed to parse device", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(j)
+}
+
+// pairHandler exchanges a pairing code for the token of the device. The
+// attempts are limited by address.
+func (dr DeviceRouter) pairHandler(w http.ResponseWriter, r *http.Request) {
+	p := pairing{}
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil {
+		http.Error(w, "Invalid pairing", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	key := "pair:" + remoteHost(r)
+	ok, wait := dr.limiter.Allow(key)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	d, err := dr.storage.Pair(p.Code)
+	if err != nil {
+		http.Error(w, device.ErrInvalidCode.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	dr.limiter.Reset(key)
+
+	expiresAt := time.Now().Add(device.TokenTTL)
+	claims := jwtauth.Claims{
+		"role":      user.Table,
+		"client_id": strconv.Itoa(int(d.ClientID)),
+		"table_id":  strconv.Itoa(int(d.TableID)),
+		"device_id": strconv.Itoa(int(d.ID)),
+	}
+	claims.SetExpiry(expiresAt)
+
+	token, err := tokens.Encode(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"token":      token,
+		"device":     d,
+		"expires_at": expiresAt.UTC(),
+	}
+
+	j, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// revokeHandler revokes a device by ID, its token stops working.
+func (dr DeviceRouter) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = dr.storage.Revoke(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// NewDeviceRouter inicialize a new router with each endpoint
+func NewDeviceRouter(s device.Storage) *chi.Mux {
+	r := chi.NewRouter()
+	dr := DeviceRouter{storage: s, limiter: ratelimit.New(pairAttempts, pairWindow)}
+
+	r.Use(tokens.Verifier, tenant.Resolve(tenants))
+
+	// Set endpoints
+	r.Post("/pair", dr.pairHandler)
+	r.With(auth.Require(auth.ManageTables), tenant.Client("clientId")).Get("/client/{clientId}", dr.getAllHandler)
+	r.With(auth.Require(auth.ManageTables), tenant.Owned("tableId", tenant.Tables)).Post("/table/{tableId}", dr.createHandler)
+	r.With(auth.Require(auth.ManageTables), tenant.Owned("id", tenant.Devices)).Delete("/{id}", dr.revokeHandler)
+
+	return r
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/device"
+)
+
+// fakeDevices pairs the device 1 of the table 1 of the client 1 with the
+// code ABCD2345.
+type fakeDevices struct {
+	device.Storage
+}
+
+func (fakeDevices) Pair(code string) (device.Device, error) {
+	if device.HashCode(code) != device.HashCode("ABCD2345") {
+		return device.Device{}, device.ErrInvalidCode
+	}
+
+	d := device.Device{ClientID: 1, TableID: 1}
+	d.ID = 1
+	return d, nil
+}
+
+func TestDevicePair(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	h := NewDeviceRouter(fakeDevices{})
+	pair := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/pair", strings.NewReader(body))
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := pair(`{"code":"abcd2345"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	res := struct {
+		Token string `json:"token"`
+	}{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &res))
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	token, err := tokenAuth.Decode(res.Token)
+	assert.Nil(t, err)
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, "table", claims["role"])
+	assert.Equal(t, "1", claims["table_id"])
+	assert.Equal(t, "1", claims["device_id"])
+	assert.NotNil(t, claims["exp"])
+
+	for i := 0; i < pairAttempts; i++ {
+		assert.Equal(t, http.StatusUnauthorized, pair(`{"code":"WRONG234"}`).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, pair(`{"code":"ABCD2345"}`).Code)
+}
+
+// TestDeviceScope checks that a device only reaches the menu and its table,
+// and that revoked devices are rejected.
+func TestDeviceScope(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, paired, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "role": "table", "client_id": "1", "device_id": "1"})
+	_, revoked, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "role": "table", "client_id": "1", "device_id": "2"})
+
+	mounted := func(h http.Handler) http.Handler {
+		return jwtauth.Verifier(tokenAuth)(jwtauth.Authenticator(h))
+	}
+
+	orders := NewOrderRouter(nil, nil, nil, nil, nil)
+
+	tt := []struct {
+		name    string
+		handler http.Handler
+		token   string
+		method  string
+		path    string
+		body    string
+		code    int
+	}{
+		{"other table order", orders, paired, "POST", "/", `{"client_id":1,"table_id":11}`, http.StatusForbidden},
+		{"order without table", orders, paired, "POST", "/", `{"client_id":1}`, http.StatusForbidden},
+		{"other table in body", orders, paired, "POST", "/", `{"client_id":1,"table_id":1,"table":{"id":11}}`, http.StatusForbidden},
+		{"call for other table", orders, paired, "GET", "/call/11/waiter", "", http.StatusForbidden},
+		{"bill of other table", orders, paired, "GET", "/call/11/bill", "", http.StatusForbidden},
+		{"update other table order", orders, paired, "PUT", "/11", `{}`, http.StatusForbidden},
+		{"update own order", orders, paired, "PUT", "/1", `{}`, http.StatusForbidden},
+		{"cancel own item", orders, paired, "PATCH", "/item/1", `{"active":false}`, http.StatusForbidden},
+		{"read orders", orders, paired, "GET", "/client/1", "", http.StatusForbidden},
+		{"subscribe", orders, paired, "GET", "/1/ws?jwt=" + paired, "", http.StatusForbidden},
+		{"list tables", mounted(NewTableRouter(nil)), paired, "GET", "/client/1", "", http.StatusForbidden},
+		{"waiter login", mounted(NewWaiterRouter(nil)), paired, "POST", "/login", `{"client_id":1}`, http.StatusForbidden},
+		{"manage menu", NewDishRouter(nil), paired, "POST", "/", `{"client_id":1}`, http.StatusForbidden},
+		{"create device", NewDeviceRouter(nil), paired, "POST", "/table/1", "", http.StatusForbidden},
+		{"revoked", NewDishRouter(nil), revoked, "GET", "/client/1", "", http.StatusUnauthorized},
+		{"revoked order", orders, revoked, "POST", "/", `{"client_id":1,"table_id":1}`, http.StatusUnauthorized},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+
+			tc.handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.code, w.Code)
+		})
+	}
+}
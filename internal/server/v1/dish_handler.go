@@ -4,14 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/internal/storage"
 	"gitlab.com/menuxd/api-rest/pkg/dish"
 	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 )
 
 // DishRouter is a router to dishes.
@@ -166,6 +167,15 @@ func (dr DishRouter) getOneHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// menuErrorStatus returns the HTTP status of a menu storage error.
+func menuErrorStatus(err error) int {
+	if err == storage.ErrForeignReference {
+		return http.StatusForbidden
+	}
+
+	return http.StatusNotFound
+}
+
 // createHandler Create a new dish.
 func (dr DishRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 	d := &dish.Dish{}
@@ -175,9 +185,14 @@ func (dr DishRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Allowed(r, d.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = dr.storage.Create(d)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -212,7 +227,7 @@ func (dr DishRouter) createManyHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = dr.storage.CreateMany(uint(clientID), dishes)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -238,7 +253,7 @@ func (dr DishRouter) updateHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = dr.storage.Update(uint(id), d)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -343,74 +358,73 @@ func NewDishRouter(s dish.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	dr := DishRouter{storage: s}
 
-	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("XD_SIGNING_STRING")), nil)
-	jwtauth.Verifier(tokenAuth)
+	r.Use(tokens.Verifier, tenant.Resolve(tenants))
 	// Set endpoints.
 	r.Get("/client/{clientId}/dishes.json", dr.getAllByBackupHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/client/{clientId}", dr.getAllHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadMenu), tenant.Client("clientId")).Get("/client/{clientId}", dr.getAllHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/client/{clientId}/{page:[0-9]+}", dr.getAllPaginateHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadMenu), tenant.Client("clientId")).Get("/client/{clientId}/{page:[0-9]+}", dr.getAllPaginateHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
-	).With(auth.Authenticator("client")).Post("/client/{clientId}", dr.createManyHandler)
+		tokens.Verifier,
+	).With(jwtauth.Authenticator).With(auth.Require(auth.ManageMenu), tenant.Client("clientId")).Post("/client/{clientId}", dr.createManyHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/category/{categoryId}", dr.getByCategory)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadMenu), tenant.Owned("categoryId", tenant.Categories)).Get("/category/{categoryId}", dr.getByCategory)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Post("/", dr.createHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ManageMenu)).Post("/", dr.createHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/{id}", dr.getOneHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadMenu), tenant.Owned("id", tenant.Dishes)).Get("/{id}", dr.getOneHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Put("/{id}", dr.updateHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Dishes)).Put("/{id}", dr.updateHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Delete("/{id}", dr.deleteHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Dishes)).Delete("/{id}", dr.deleteHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/suggested/{categoryId}", dr.getSuggestedHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadMenu), tenant.Owned("categoryId", tenant.Categories)).Get("/suggested/{categoryId}", dr.getSuggestedHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Post("/add-click/{id}", dr.addClickHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.SendFeedback), tenant.Owned("id", tenant.Dishes)).Post("/add-click/{id}", dr.addClickHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Post("/clicks/client/{clientId}", dr.getClicksHandler)
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadReports), tenant.Client("clientId")).Post("/clicks/client/{clientId}", dr.getClicksHandler)
 
 	return r
 }
@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/kds"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+)
+
+// kdsHandler response the pending tickets of a client, the oldest first,
+// optionally filtered by the station query param.
+func (or OrderRouter) kdsHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tables, err := or.OrderStorage.GetAllActive(uint(clientID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	orders := []order.Order{}
+	for _, t := range tables {
+		orders = append(orders, t...)
+	}
+
+	tickets := kds.Build(orders, r.URL.Query().Get("station"), time.Now())
+
+	j, err := json.Marshal(tickets)
+	if err != nil {
+		http.Error(w, "Failed to parse tickets", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// bumpItemHandler marks an item as ready.
+func (or OrderRouter) bumpItemHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	i, err := or.OrderStorage.AdvanceItem(uint(id), order.Ready)
+	if err != nil {
+		http.Error(w, err.Error(), transitionErrorStatus(err))
+		return
+	}
+
+	o, err := or.OrderStorage.GetByID(i.OrderID)
+	if err == nil {
+		go func() {
+			or.MessageStream <- statusNotification(o, &i)
+		}()
+	}
+
+	j, err := json.Marshal(i)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// bumpTicketHandler marks as ready every pending item of an order, optionally
+// only those of the station query param. The items are bumped all at once,
+// and pushed once they are.
+func (or OrderRouter) bumpTicketHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	o, err := or.OrderStorage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ids := []uint{}
+	for _, pending := range kds.Pending(o, r.URL.Query().Get("station")) {
+		ids = append(ids, pending.ID)
+	}
+
+	items, err := or.OrderStorage.AdvanceItems(ids, order.Ready)
+	if err != nil {
+		http.Error(w, err.Error(), transitionErrorStatus(err))
+		return
+	}
+
+	go func() {
+		for idx := range items {
+			or.MessageStream <- statusNotification(o, &items[idx])
+		}
+	}()
+
+	j, err := json.Marshal(items)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
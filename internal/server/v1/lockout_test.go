@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+)
+
+func TestLoginLockout(t *testing.T) {
+	logins := &fakeLogins{sessions: map[uint]*login.Session{}}
+	_, ur := NewUserRouter(fakeUsers{}, logins)
+
+	post := func(ip, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.RemoteAddr = ip + ":1234"
+		ur.LoginHandler(w, r)
+		return w
+	}
+
+	// Unknown addresses and wrong passwords get the same answer.
+	unknown := post("10.0.0.1", `{"email":"bob@menuxd.com","password":"secret"}`)
+	wrong := post("10.0.0.1", `{"email":"ana@menuxd.com","password":"wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, unknown.Code, wrong.Code)
+	assert.Equal(t, unknown.Body.String(), wrong.Body.String())
+
+	// A success forgets the failures of the account.
+	assert.Equal(t, http.StatusOK, post("10.0.0.1", `{"email":"ana@menuxd.com","password":"secret"}`).Code)
+
+	for i := 1; i < accountFailures; i++ {
+		w := post("10.0.0."+strconv.Itoa(i), `{"email":"Ana@menuxd.com","password":"wrong"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	assert.Empty(t, logins.lockouts)
+
+	// The last failure locks the account, from any IP and with the right
+	// password.
+	post("10.0.1.1", `{"email":"ana@menuxd.com","password":"wrong"}`)
+	w := post("10.0.2.1", `{"email":"ana@menuxd.com","password":"secret"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	assert.Len(t, logins.lockouts, 1)
+	assert.Equal(t, login.LockedAccount, logins.lockouts[0].Reason)
+	assert.Equal(t, "10.0.1.1", logins.lockouts[0].IP)
+
+	// An IP failing with many accounts is locked too.
+	for i := 0; i < ipFailures; i++ {
+		post("10.0.3.1", `{"email":"user`+strconv.Itoa(i)+`@menuxd.com","password":"wrong"}`)
+	}
+
+	w = post("10.0.3.1", `{"email":"bob@menuxd.com","password":"wrong"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, login.LockedIP, logins.lockouts[len(logins.lockouts)-1].Reason)
+}
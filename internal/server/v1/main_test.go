@@ -0,0 +1,14 @@
+package v1
+
+import (
+	"os"
+	"testing"
+
+	"gitlab.com/menuxd/api-rest/pkg/token"
+)
+
+// TestMain signs the tokens of the tests with HS256 and the secret "secret".
+func TestMain(m *testing.M) {
+	tokens = token.NewHMAC([]byte("secret"))
+	os.Exit(m.Run())
+}
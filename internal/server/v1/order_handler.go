@@ -3,14 +3,19 @@ package v1
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/client"
 	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/hub"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/notification"
 	"gitlab.com/menuxd/api-rest/pkg/order"
 	"gitlab.com/menuxd/api-rest/pkg/table"
@@ -19,22 +24,50 @@ import (
 
 var tableTypes map[string]string = map[string]string{"table": "Mesa", "bar": "Bar"}
 
-// OrderRouter is a router to orders.
-type OrderRouter struct {
-	OrderStorage  order.Storage
-	TableStorage  table.Storage
-	DishStorage   dish.Storage
-	MessageStream chan notification.Notification
+var statusNames map[order.Status]string = map[order.Status]string{
+	order.Received:  "recibida",
+	order.Accepted:  "aceptada",
+	order.Preparing: "en preparación",
+	order.Ready:     "lista",
+	order.Served:    "servida",
+	order.Paid:      "pagada",
+	order.Cancelled: "cancelada",
+	order.Rejected:  "rechazada",
 }
 
-func ordersHandler(m *melody.Melody) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		err := m.HandleRequest(w, r)
-		if err != nil {
-			http.Error(w, "Websocket connection failed", http.StatusInternalServerError)
-			return
-		}
-	}
+// transition is the body of a status change request.
+type transition struct {
+	Status order.Status `json:"status"`
+}
+
+// statusPermissions are the permissions to move orders and items to each
+// status: the kitchen prepares them and the floor serves and cancels them.
+var statusPermissions = map[order.Status]auth.Permission{
+	order.Accepted:  auth.PrepareOrders,
+	order.Preparing: auth.PrepareOrders,
+	order.Ready:     auth.PrepareOrders,
+	order.Rejected:  auth.PrepareOrders,
+	order.Served:    auth.ServeTables,
+	order.Paid:      auth.ServeTables,
+	order.Cancelled: auth.ServeTables,
+}
+
+// canMoveTo checks that the token of the request can move orders and items
+// to the status. Unknown statuses are left to the storage to reject.
+func canMoveTo(r *http.Request, s order.Status) bool {
+	p, ok := statusPermissions[s]
+	return !ok || auth.Has(r, p)
+}
+
+// OrderRouter is a router to orders.
+type OrderRouter struct {
+	OrderStorage        order.Storage
+	TableStorage        table.Storage
+	DishStorage         dish.Storage
+	NotificationStorage notification.Storage
+	ClientStorage       client.Storage
+	MessageStream       chan notification.Notification
+	Hub                 *hub.Hub
 }
 
 func (or OrderRouter) callWaiter(w http.ResponseWriter, r *http.Request) {
@@ -111,6 +144,23 @@ func (or OrderRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
+	// The table of the body is the one stored, so it is the one checked.
+	if o.Table != nil {
+		o.TableID = o.Table.ID
+		o.Table = nil
+	}
+
+	// Devices only order for their table.
+	if !tenant.Allowed(r, o.ClientID) ||
+		(o.TableID == 0 && tenant.Table(r) != 0) ||
+		(o.TableID != 0 && !tenant.Owns(r, tenant.Tables, o.TableID)) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	// The waiter is the one of the token, never the one of the body.
+	o.WaiterID = waiterOf(r)
+
 	o, err = or.OrderStorage.Create(&o)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -153,6 +203,14 @@ func (or OrderRouter) addItemHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	err = or.OrderStorage.Add(uint(id), items)
+	if err == order.ErrInvalidTransition {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == storage.ErrForeignReference {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -194,6 +252,8 @@ func (or OrderRouter) addItemHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
 
+// updateHandler cancels an order, as the old clients do by setting
+// canceled. It is a transition to cancelled, pushed as the others.
 func (or OrderRouter) updateHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
@@ -211,16 +271,36 @@ func (or OrderRouter) updateHandler(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
-	err = or.OrderStorage.Update(uint(id), &o)
+	stored, err := or.OrderStorage.GetByID(uint(id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
+	if stored.Canceled != o.Canceled {
+		if !o.Canceled {
+			http.Error(w, order.ErrInvalidTransition.Error(), http.StatusConflict)
+			return
+		}
+
+		moved, err := or.OrderStorage.TransitionOrder(uint(id), order.Cancelled)
+		if err != nil {
+			http.Error(w, err.Error(), transitionErrorStatus(err))
+			return
+		}
+
+		moved.Table = stored.Table
+		go func() {
+			or.MessageStream <- statusNotification(moved, nil)
+		}()
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
 
+// updateItemHandler cancels an item, as the old clients do by patching
+// active to false. It is a transition to cancelled, pushed as the others.
 func (or OrderRouter) updateItemHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
@@ -238,16 +318,175 @@ func (or OrderRouter) updateItemHandler(w http.ResponseWriter, r *http.Request)
 
 	defer r.Body.Close()
 
-	err = or.OrderStorage.PatchItem(uint(id), m)
+	cancels, err := order.PatchCancels(m)
+	if err == order.ErrInvalidTransition {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if cancels {
+		i, err := or.OrderStorage.TransitionItem(uint(id), order.Cancelled)
+		if err != nil {
+			http.Error(w, err.Error(), transitionErrorStatus(err))
+			return
+		}
+
+		or.pushItem(i)
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
 
+// transitionHandler moves an order, and the items that can follow it, to a
+// new status.
+func (or OrderRouter) transitionHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t := transition{}
+	err = json.NewDecoder(r.Body).Decode(&t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !canMoveTo(r, t.Status) {
+		http.Error(w, auth.ErrInsufficientPrivileges.Error(), http.StatusForbidden)
+		return
+	}
+
+	o, err := or.OrderStorage.TransitionOrder(uint(id), t.Status)
+	if err != nil {
+		http.Error(w, err.Error(), transitionErrorStatus(err))
+		return
+	}
+
+	storedOrder, err := or.OrderStorage.GetByID(o.ID)
+	if err == nil {
+		o.Table = storedOrder.Table
+	}
+
+	go func() {
+		or.MessageStream <- statusNotification(o, nil)
+	}()
+
+	j, err := json.Marshal(o)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// transitionItemHandler moves an item to a new status.
+func (or OrderRouter) transitionItemHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t := transition{}
+	err = json.NewDecoder(r.Body).Decode(&t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !canMoveTo(r, t.Status) {
+		http.Error(w, auth.ErrInsufficientPrivileges.Error(), http.StatusForbidden)
+		return
+	}
+
+	i, err := or.OrderStorage.TransitionItem(uint(id), t.Status)
+	if err != nil {
+		http.Error(w, err.Error(), transitionErrorStatus(err))
+		return
+	}
+
+	or.pushItem(i)
+
+	j, err := json.Marshal(i)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// pushItem sends the new status of an item to the KDS and the websockets.
+func (or OrderRouter) pushItem(i order.Item) {
+	o, err := or.OrderStorage.GetByID(i.OrderID)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		or.MessageStream <- statusNotification(o, &i)
+	}()
+}
+
+// transitionErrorStatus returns the HTTP status for a failed transition.
+func transitionErrorStatus(err error) int {
+	switch err {
+	case order.ErrInvalidStatus:
+		return http.StatusBadRequest
+	case order.ErrInvalidTransition:
+		return http.StatusConflict
+	case storage.ErrNotFound:
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// statusNotification builds the notification of a status change of an order
+// or, when given, one of its items.
+func statusNotification(o order.Order, i *order.Item) notification.Notification {
+	n := notification.Notification{
+		Type:     notification.StatusChanged,
+		Date:     time.Now(),
+		ClientID: o.ClientID,
+		Active:   true,
+		Table:    o.Table,
+		OrderID:  o.ID,
+		Status:   string(o.Status),
+		Message:  fmt.Sprintf("Orden #%d %s", o.ID, statusNames[o.Status]),
+	}
+
+	if i != nil {
+		n.ItemID = i.ID
+		n.Status = string(i.Status)
+		n.Message = fmt.Sprintf("Orden #%d, item #%d %s", o.ID, i.ID, statusNames[i.Status])
+	}
+
+	if o.Table != nil {
+		n.Message += fmt.Sprintf(", %s #%d", tableTypes[o.Table.Type], o.Table.Number)
+	}
+
+	return n
+}
+
 func (or OrderRouter) getAllHandler(w http.ResponseWriter, r *http.Request) {
 	clientIDStr := chi.URLParam(r, "clientId")
 	clientID, err := strconv.Atoi(clientIDStr)
@@ -290,117 +529,170 @@ func (or OrderRouter) getActiveHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(j)
 }
 
+// ackHandler marks a notification as handled by the waiter of the token.
+func (or OrderRouter) ackHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var waiterID uint
+	if waiter := waiterOf(r); waiter != nil {
+		waiterID = *waiter
+	}
+
+	n, err := or.NotificationStorage.Ack(uint(id), waiterID)
+	switch err {
+	case nil:
+	case notification.ErrAlreadyAcked:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case storage.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go func() {
+		or.MessageStream <- n
+	}()
+
+	j, err := json.Marshal(n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// pendingHandler response the notifications of a client not acknowledged
+// yet, after the since query param.
+func (or OrderRouter) pendingHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notifications, err := or.NotificationStorage.GetPending(uint(clientID), since(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(notifications)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// since returns the notification cursor of the request, zero for all.
+func since(r *http.Request) uint {
+	cursor, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return uint(cursor)
+}
+
 // NewOrderRouter returns the order's handler with default configuration.
-func NewOrderRouter(s order.Storage, ts table.Storage, ds dish.Storage) *chi.Mux {
+func NewOrderRouter(
+	s order.Storage,
+	ts table.Storage,
+	ds dish.Storage,
+	ns notification.Storage,
+	cs client.Storage,
+) *chi.Mux {
 	ch := make(chan notification.Notification, 100)
 	or := OrderRouter{
-		OrderStorage:  s,
-		TableStorage:  ts,
-		DishStorage:   ds,
-		MessageStream: ch,
+		OrderStorage:        s,
+		TableStorage:        ts,
+		DishStorage:         ds,
+		NotificationStorage: ns,
+		ClientStorage:       cs,
+		MessageStream:       ch,
 	}
 
 	r := chi.NewRouter()
+	r.Use(tokens.Verifier, tenant.Resolve(tenants))
 
 	m := melody.New()
-	r.Get("/{clientId}/ws", ordersHandler(m))
+	m.Upgrader.Subprotocols = []string{tokenProtocol}
+	or.Hub = hub.New(m)
+	r.With(tokens.Verify(jwtauth.TokenFromQuery, tokenFromProtocol, jwtauth.TokenFromHeader)).
+		With(tenant.Resolve(tenants), auth.Require(auth.ReadOrders), tenant.Client("clientId")).Get("/{clientId}/ws", or.ordersHandler(m))
+	r.With(tokens.Verifier).With(auth.Authenticator("admin")).
+		Get("/ws/metrics", or.metricsHandler)
 
-	m.HandleMessage(messageHandler(m, ch))
-	m.HandleConnect(connectHandler(m))
+	m.HandleMessage(or.messageHandler())
+	m.HandleConnect(or.connectHandler())
+	m.HandleDisconnect(or.disconnectHandler())
 
 	go func() {
 
 		for {
 			n := <-or.MessageStream
-			j, err := json.Marshal(n)
-			if err != nil {
-				m.CloseWithMsg([]byte(err.Error()))
-				return
-			}
-
-			m.BroadcastFilter(j, func(ss *melody.Session) bool {
-				clientIDStr := chi.URLParam(ss.Request, "clientId")
-				clientID, err := strconv.Atoi(clientIDStr)
+			if n.NeedsAck() && n.ID == 0 {
+				err := or.NotificationStorage.Create(&n)
 				if err != nil {
-					return false
+					log.Printf("notification not stored: %v", err)
 				}
+			}
 
-				return uint(clientID) == n.ClientID
-			})
-		}
-	}()
-
-	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("XD_SIGNING_STRING")), nil)
-
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Get("/call/{tableId}/waiter", or.callWaiter)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Get("/call/{tableId}/bill", or.getBill)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Post("/", or.createHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Put("/{id}", or.updateHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Put("/add/{id}/client/{clientId}", or.addItemHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Patch("/item/{id}", or.updateItemHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Get("/client/{clientId}", or.getAllHandler)
-	r.With(jwtauth.Verifier(tokenAuth)).With(jwtauth.Authenticator).
-		Get("/active/{clientId}", or.getActiveHandler)
-
-	return r
-}
-
-func messageHandler(m *melody.Melody, MessageStream chan notification.Notification) func(*melody.Session, []byte) {
-	return func(s *melody.Session, _ []byte) {
-
-		n := <-MessageStream
-		j, err := json.Marshal(n)
-		if err != nil {
-			s.CloseWithMsg([]byte(err.Error()))
-			return
-		}
-
-		m.BroadcastFilter(j, func(ss *melody.Session) bool {
-			clientIDStr := chi.URLParam(ss.Request, "clientId")
-			clientID, err := strconv.Atoi(clientIDStr)
+			j, err := json.Marshal(n)
 			if err != nil {
-				return false
+				log.Printf("notification not sent: %v", err)
+				continue
 			}
 
-			return uint(clientID) == n.ClientID
-		})
-
-	}
-}
-
-func connectHandler(m *melody.Melody) func(*melody.Session) {
-	return func(s *melody.Session) {
-		clientIDStr := chi.URLParam(s.Request, "clientId")
-		clientID, err := strconv.Atoi(clientIDStr)
-		if err != nil {
-			s.CloseWithMsg([]byte(err.Error()))
-			return
-		}
-
-		n := notification.Notification{
-			Type:     notification.Connected,
-			Message:  "Connected",
-			Date:     time.Now(),
-			ClientID: uint(clientID),
-			Active:   true,
+			or.Hub.Broadcast(n.ClientID, j)
 		}
+	}()
 
-		j, err := json.Marshal(n)
-		if err != nil {
-			s.CloseWithMsg([]byte(err.Error()))
-			return
-		}
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.CallStaff), tenant.Owned("tableId", tenant.Tables)).Get("/call/{tableId}/waiter", or.callWaiter)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.CallStaff), tenant.Owned("tableId", tenant.Tables)).Get("/call/{tableId}/bill", or.getBill)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.TakeOrders)).Post("/", or.createHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ServeTables), tenant.Owned("id", tenant.Orders)).Put("/{id}", or.updateHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.TakeOrders), tenant.Owned("id", tenant.Orders), tenant.Client("clientId")).Put("/add/{id}/client/{clientId}", or.addItemHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ServeTables), tenant.Owned("id", tenant.Items)).Patch("/item/{id}", or.updateItemHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.RequireAny(auth.PrepareOrders, auth.ServeTables), tenant.Owned("id", tenant.Items)).Post("/item/{id}/status", or.transitionItemHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.RequireAny(auth.PrepareOrders, auth.ServeTables), tenant.Owned("id", tenant.Orders)).Post("/{id}/status", or.transitionHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ReadOrders), tenant.Client("clientId")).Get("/client/{clientId}", or.getAllHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ReadOrders), tenant.Client("clientId")).Get("/active/{clientId}", or.getActiveHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.ReadOrders), tenant.Client("clientId")).Get("/kds/{clientId}", or.kdsHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.PrepareOrders), tenant.Owned("id", tenant.Items)).Post("/kds/item/{id}/bump", or.bumpItemHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.PrepareOrders), tenant.Owned("id", tenant.Orders)).Post("/kds/ticket/{id}/bump", or.bumpTicketHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.AckNotifications), tenant.Client("clientId")).Get("/notifications/{clientId}", or.pendingHandler)
+	r.With(tokens.Verifier).With(jwtauth.Authenticator).
+		With(auth.Require(auth.AckNotifications), tenant.Owned("id", tenant.Notifications)).Post("/notifications/{id}/ack", or.ackHandler)
 
-		m.BroadcastFilter(j, func(ss *melody.Session) bool {
-			msgClientID := chi.URLParam(ss.Request, "clientId")
-			return clientIDStr == msgClientID
-		})
-	}
+	return r
 }
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage/memory"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/hub"
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+)
+
+// TestLegacyCancel checks that the cancellations of the old clients are
+// pushed as the other transitions, and that cancelled orders take no more
+// items.
+func TestLegacyCancel(t *testing.T) {
+	b := memory.New()
+	ch := make(chan notification.Notification, 10)
+	or := OrderRouter{OrderStorage: b.Orders, DishStorage: b.Dishes, MessageStream: ch}
+
+	c := client.Client{Name: "Bar", ExpireAt: time.Now().Add(time.Hour)}
+	if err := b.Clients.Create(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	tb := table.Table{ClientID: c.ID, Number: 1}
+	if err := b.Tables.Create(&tb); err != nil {
+		t.Fatal(err)
+	}
+
+	d := dish.Dish{ClientID: c.ID}
+	d.Name = "Empanada"
+	d.Pictures = []string{"empanada.png"}
+	if err := b.Dishes.Create(&d); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := b.Orders.Create(&order.Order{ClientID: c.ID, TableID: tb.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Orders.Add(o.ID, []order.Item{{DishID: d.ID}, {DishID: d.ID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err = b.Orders.GetByID(o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(h http.HandlerFunc, id uint, body string) int {
+		rc := chi.NewRouteContext()
+		rc.URLParams.Add("id", strconv.Itoa(int(id)))
+		rc.URLParams.Add("clientId", strconv.Itoa(int(c.ID)))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		h(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rc)))
+		return w.Code
+	}
+
+	pushed := func() notification.Notification {
+		select {
+		case n := <-ch:
+			return n
+		case <-time.After(time.Second):
+			t.Fatal("no notification pushed")
+		}
+
+		return notification.Notification{}
+	}
+
+	assert := assert.New(t)
+
+	item := o.Items[0]
+	assert.Equal(http.StatusOK, do(or.updateItemHandler, item.ID, `{"active":false}`))
+	n := pushed()
+	assert.Equal(item.ID, n.ItemID)
+	assert.Equal(string(order.Cancelled), n.Status)
+
+	assert.Equal(http.StatusOK, do(or.updateHandler, o.ID, `{"canceled":true}`))
+	n = pushed()
+	assert.Equal(o.ID, n.OrderID)
+	assert.Zero(n.ItemID)
+	assert.Equal(string(order.Cancelled), n.Status)
+
+	assert.Equal(http.StatusConflict, do(or.addItemHandler, o.ID, fmt.Sprintf(`[{"dish_id":%d}]`, d.ID)))
+	assert.Equal(http.StatusConflict, do(or.updateHandler, o.ID, `{"canceled":false}`))
+}
+
+// TestSocketAck checks that websocket acks are ignored from connections
+// whose token may not acknowledge notifications, or of another client.
+func TestSocketAck(t *testing.T) {
+	b := memory.New()
+	ch := make(chan notification.Notification, 10)
+	or := OrderRouter{NotificationStorage: b.Notifications, MessageStream: ch}
+
+	n := notification.Notification{Type: notification.CallWaiter, ClientID: 1, Date: time.Now()}
+	if err := b.Notifications.Create(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte(fmt.Sprintf(`{"id":%d}`, n.ID))
+
+	assert := assert.New(t)
+
+	or.ack(hub.Conn{ClientID: 1, Role: "kitchen"}, msg)
+	or.ack(hub.Conn{ClientID: 2, Role: "waiter", CanAck: true}, msg)
+	assert.Len(ch, 0)
+
+	stored, err := b.Notifications.GetByID(n.ID)
+	assert.Nil(err)
+	assert.True(stored.Active)
+
+	or.ack(hub.Conn{ClientID: 1, Role: "waiter", WaiterID: 4, CanAck: true}, msg)
+	if assert.Len(ch, 1) {
+		acked := <-ch
+		assert.False(acked.Active)
+		if assert.NotNil(acked.AckedBy) {
+			assert.Equal(uint(4), *acked.AckedBy)
+		}
+	}
+}
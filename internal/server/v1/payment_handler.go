@@ -0,0 +1,182 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+)
+
+// PaymentRouter is the router of payments.
+type PaymentRouter struct {
+	storage payment.Storage
+}
+
+// paymentErrorStatus returns the HTTP status for a rejected payment.
+func paymentErrorStatus(err error) int {
+	switch err {
+	case payment.ErrOverpayment, payment.ErrRefundExceeded:
+		return http.StatusConflict
+	case storage.ErrNotFound:
+		return http.StatusNotFound
+	case storage.ErrNotInsert, storage.ErrNotUpdate:
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusBadRequest
+}
+
+// getAllByBillHandler response all the payments of a bill.
+func (pr PaymentRouter) getAllByBillHandler(w http.ResponseWriter, r *http.Request) {
+	billIDStr := chi.URLParam(r, "billId")
+	billID, err := strconv.Atoi(billIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payments, err := pr.storage.GetByBill(uint(billID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(payments)
+	if err != nil {
+		http.Error(w, "Failed to parse payments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// getOneHandler response one payment by id.
+func (pr PaymentRouter) getOneHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := pr.storage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, "Failed to parse payments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// createHandler records a payment against a bill.
+func (pr PaymentRouter) createHandler(w http.ResponseWriter, r *http.Request) {
+	p := payment.New()
+	err := json.NewDecoder(r.Body).Decode(p)
+	if err != nil {
+		http.Error(w, "Failed to parse the payment", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !tenant.Owns(r, tenant.Bills, p.BillID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	p.Kind = payment.Charge
+	// The waiter is the one of the token, never the one of the body.
+	p.WaiterID = waiterOf(r)
+	err = pr.storage.Create(p)
+	if err != nil {
+		http.Error(w, err.Error(), paymentErrorStatus(err))
+		return
+	}
+
+	j, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, "Failed to parse payments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(j)
+}
+
+// refundHandler gives back part or all of a payment by id.
+func (pr PaymentRouter) refundHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p := payment.New()
+	err = json.NewDecoder(r.Body).Decode(p)
+	if err != nil {
+		http.Error(w, "Failed to parse the refund", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	original, err := pr.storage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	refundOf := original.ID
+	p.Kind = payment.Refund
+	p.BillID = original.BillID
+	p.RefundOf = &refundOf
+	p.WaiterID = waiterOf(r)
+	err = pr.storage.Create(p)
+	if err != nil {
+		http.Error(w, err.Error(), paymentErrorStatus(err))
+		return
+	}
+
+	j, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, "Failed to parse payments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(j)
+}
+
+// NewPaymentRouter inicialize a new router with each endpoint.
+func NewPaymentRouter(s payment.Storage) *chi.Mux {
+	r := chi.NewRouter()
+	pr := PaymentRouter{storage: s}
+
+	r.Use(tenant.Resolve(tenants))
+
+	// Set endpoints
+	r.With(auth.Require(auth.ReadBills), tenant.Owned("billId", tenant.Bills)).Get("/bill/{billId}", pr.getAllByBillHandler)
+	r.With(auth.Require(auth.TakePayments)).Post("/", pr.createHandler)
+	r.With(auth.Require(auth.ReadBills), tenant.Owned("id", tenant.Payments)).Get("/{id}", pr.getOneHandler)
+	r.With(auth.Require(auth.RefundPayments), tenant.Owned("id", tenant.Payments)).Post("/{id}/refund", pr.refundHandler)
+
+	return r
+}
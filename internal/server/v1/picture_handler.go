@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/picture"
 )
 
@@ -48,8 +50,10 @@ func createHandler(w http.ResponseWriter, r *http.Request) {
 func NewPictureRouter() *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(tenant.Resolve(tenants))
+
 	// Set endpoints
-	r.Post("/{clientId}/{name}", createHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Client("clientId")).Post("/{clientId}/{name}", createHandler)
 
 	return r
 }
@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/promotion"
 )
 
@@ -102,9 +104,14 @@ func (pr PromotionRouter) createHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !tenant.Allowed(r, p.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = pr.storage.Create(p)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -131,7 +138,7 @@ func (pr PromotionRouter) updateHandler(w http.ResponseWriter, r *http.Request)
 
 	err = pr.storage.Update(uint(id), p)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), menuErrorStatus(err))
 		return
 	}
 
@@ -163,13 +170,15 @@ func NewPromotionRouter(s promotion.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	pr := PromotionRouter{storage: s}
 
+	r.Use(tenant.Resolve(tenants))
+
 	// Set endpoints
-	r.Get("/client/{clientId}", pr.getAllHandler)
-	r.Post("/", pr.createHandler)
-	r.Post("/add-click/{id}", pr.addClickHandler)
-	r.Get("/{id}", pr.getOneHandler)
-	r.Put("/{id}", pr.updateHandler)
-	r.Delete("/{id}", pr.deleteHandler)
+	r.With(auth.Require(auth.ReadMenu), tenant.Client("clientId")).Get("/client/{clientId}", pr.getAllHandler)
+	r.With(auth.Require(auth.ManageMenu)).Post("/", pr.createHandler)
+	r.With(auth.Require(auth.SendFeedback), tenant.Owned("id", tenant.Promotions)).Post("/add-click/{id}", pr.addClickHandler)
+	r.With(auth.Require(auth.ReadMenu), tenant.Owned("id", tenant.Promotions)).Get("/{id}", pr.getOneHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Promotions)).Put("/{id}", pr.updateHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Promotions)).Delete("/{id}", pr.deleteHandler)
 
 	return r
 }
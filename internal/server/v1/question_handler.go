@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/question"
 )
 
@@ -75,6 +77,11 @@ func (qr QuestionRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Allowed(r, q.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = qr.storage.Create(q)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -136,12 +143,14 @@ func NewQuestionRouter(s question.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	qr := QuestionRouter{storage: s}
 
+	r.Use(tenant.Resolve(tenants))
+
 	// Set endpoints
-	r.Get("/client/{clientId}", qr.getAllHandler)
-	r.Post("/", qr.createHandler)
-	r.Get("/{id}", qr.getOneHandler)
-	r.Put("/{id}", qr.updateHandler)
-	r.Delete("/{id}", qr.deleteHandler)
+	r.With(auth.Require(auth.ReadMenu), tenant.Client("clientId")).Get("/client/{clientId}", qr.getAllHandler)
+	r.With(auth.Require(auth.ManageMenu)).Post("/", qr.createHandler)
+	r.With(auth.Require(auth.ReadMenu), tenant.Owned("id", tenant.Questions)).Get("/{id}", qr.getOneHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Questions)).Put("/{id}", qr.updateHandler)
+	r.With(auth.Require(auth.ManageMenu), tenant.Owned("id", tenant.Questions)).Delete("/{id}", qr.deleteHandler)
 
 	return r
 }
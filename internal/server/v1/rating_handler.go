@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/rating"
 )
 
@@ -94,6 +96,11 @@ func (rr RatingRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Owns(r, tenant.Questions, ra.QuestionID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = rr.storage.Create(&ra)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -109,11 +116,13 @@ func NewRatingRouter(s rating.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	rr := RatingRouter{storage: s}
 
+	r.Use(tenant.Resolve(tenants))
+
 	// Set endpoints
-	r.Get("/", rr.getAllHandler)
-	r.Get("/question/{questionId}", rr.getAllByQuestionHandler)
-	r.Post("/", rr.createHandler)
-	r.Get("/{id}", rr.getOneHandler)
+	r.With(auth.Authenticator("admin")).Get("/", rr.getAllHandler)
+	r.With(auth.Require(auth.ReadReports), tenant.Owned("questionId", tenant.Questions)).Get("/question/{questionId}", rr.getAllByQuestionHandler)
+	r.With(auth.Require(auth.SendFeedback)).Post("/", rr.createHandler)
+	r.With(auth.Require(auth.ReadReports), tenant.Owned("id", tenant.Ratings)).Get("/{id}", rr.getOneHandler)
 
 	return r
 }
@@ -0,0 +1,247 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/register"
+)
+
+// RegisterRouter is the router of cash register sessions.
+type RegisterRouter struct {
+	storage register.Storage
+}
+
+// closing is the body to close a register session.
+type closing struct {
+	CountedCash money.Amount `json:"counted_cash"`
+	Note        string       `json:"note"`
+}
+
+// registerErrorStatus returns the HTTP status for a rejected register
+// operation.
+func registerErrorStatus(err error) int {
+	switch err {
+	case register.ErrAlreadyOpen, register.ErrAlreadyClosed:
+		return http.StatusConflict
+	case storage.ErrNotFound:
+		return http.StatusNotFound
+	case storage.ErrNotInsert, storage.ErrNotUpdate:
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusBadRequest
+}
+
+// writeReport response a Z report as JSON, or as printable text when the
+// format query param is text. The download query param makes it an
+// attachment.
+func writeReport(w http.ResponseWriter, r *http.Request, rep register.Report, status int) {
+	ext := "json"
+	var body []byte
+	if r.URL.Query().Get("format") == "text" {
+		ext = "txt"
+		body = []byte(rep.Text())
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		j, err := json.Marshal(rep)
+		if err != nil {
+			http.Error(w, "Failed to parse the report", http.StatusInternalServerError)
+			return
+		}
+		body = j
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	if r.URL.Query().Get("download") == "true" {
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"z-%d-%s.%s\"", rep.SessionID, rep.Date, ext),
+		)
+	}
+
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// getAllHandler response all the register sessions from a client.
+func (rr RegisterRouter) getAllHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := rr.storage.GetAll(uint(clientID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(sessions)
+	if err != nil {
+		http.Error(w, "Failed to parse sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// getOpenHandler response the open register session of a client.
+func (rr RegisterRouter) getOpenHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rs, err := rr.storage.GetOpen(uint(clientID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(rs)
+	if err != nil {
+		http.Error(w, "Failed to parse sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// getOneHandler response one register session by id.
+func (rr RegisterRouter) getOneHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rs, err := rr.storage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(rs)
+	if err != nil {
+		http.Error(w, "Failed to parse sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// openHandler opens a register session with the opening cash.
+func (rr RegisterRouter) openHandler(w http.ResponseWriter, r *http.Request) {
+	rs := register.Session{}
+	err := json.NewDecoder(r.Body).Decode(&rs)
+	if err != nil {
+		http.Error(w, "Failed to parse the session", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !tenant.Allowed(r, rs.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = rr.storage.Open(&rs)
+	if err != nil {
+		http.Error(w, err.Error(), registerErrorStatus(err))
+		return
+	}
+
+	j, err := json.Marshal(rs)
+	if err != nil {
+		http.Error(w, "Failed to parse sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(j)
+}
+
+// closeHandler closes a register session with the cash counted and response
+// its Z report.
+func (rr RegisterRouter) closeHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := closing{}
+	err = json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		http.Error(w, "Failed to parse the closing", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	rep, err := rr.storage.Close(uint(id), c.CountedCash, c.Note)
+	if err != nil {
+		http.Error(w, err.Error(), registerErrorStatus(err))
+		return
+	}
+
+	writeReport(w, r, rep, http.StatusOK)
+}
+
+// reportHandler response the Z report of a register session.
+func (rr RegisterRouter) reportHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rep, err := rr.storage.Report(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), registerErrorStatus(err))
+		return
+	}
+
+	writeReport(w, r, rep, http.StatusOK)
+}
+
+// NewRegisterRouter inicialize a new router with each endpoint.
+func NewRegisterRouter(s register.Storage) *chi.Mux {
+	r := chi.NewRouter()
+	rr := RegisterRouter{storage: s}
+
+	r.Use(tenant.Resolve(tenants))
+
+	// Set endpoints
+	r.With(auth.Require(auth.ReadRegister), tenant.Client("clientId")).Get("/client/{clientId}", rr.getAllHandler)
+	r.With(auth.Require(auth.ReadRegister), tenant.Client("clientId")).Get("/client/{clientId}/open", rr.getOpenHandler)
+	r.With(auth.Require(auth.OperateRegister)).Post("/", rr.openHandler)
+	r.With(auth.Require(auth.ReadRegister), tenant.Owned("id", tenant.Registers)).Get("/{id}", rr.getOneHandler)
+	r.With(auth.Require(auth.OperateRegister), tenant.Owned("id", tenant.Registers)).Post("/{id}/close", rr.closeHandler)
+	r.With(auth.Require(auth.ReadRegister), tenant.Owned("id", tenant.Registers)).Get("/{id}/report", rr.reportHandler)
+
+	return r
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// fakeResets resets the password of ana@menuxd.com with the token good.
+type fakeResets struct {
+	fakeUsers
+	requested []string
+}
+
+func (f *fakeResets) RequestReset(address string) error {
+	if address != "ana@menuxd.com" {
+		return storage.ErrNotFound
+	}
+
+	f.requested = append(f.requested, address)
+	return nil
+}
+
+func (f *fakeResets) ResetPassword(p user.NewPassword) (uint, error) {
+	err := p.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	if p.Token != "good" {
+		return 0, user.ErrInvalidReset
+	}
+
+	return 1, nil
+}
+
+func TestPasswordReset(t *testing.T) {
+	resets := &fakeResets{}
+	logins := &fakeLogins{sessions: map[uint]*login.Session{}}
+	s, _ := login.New(1, "", time.Now())
+	logins.Create(s)
+
+	_, ur := NewUserRouter(resets, logins)
+	put := func(h http.HandlerFunc, ip, body string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		r.RemoteAddr = ip + ":1234"
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Known and unknown addresses get the same answer.
+	assert.Equal(t, http.StatusOK, put(ur.ForgotPasswordHandler, "10.0.0.1", `{"email":"ana@menuxd.com"}`))
+	assert.Equal(t, http.StatusOK, put(ur.ForgotPasswordHandler, "10.0.0.1", `{"email":"nobody@menuxd.com"}`))
+	assert.Equal(t, []string{"ana@menuxd.com"}, resets.requested)
+
+	// The address is limited from any IP.
+	for i := 1; i < resetAddressAttempts; i++ {
+		assert.Equal(t, http.StatusOK, put(ur.ForgotPasswordHandler, "10.0.0.2", `{"email":"ANA@menuxd.com"}`))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, put(ur.ForgotPasswordHandler, "10.0.0.3", `{"email":"ana@menuxd.com"}`))
+
+	assert.Equal(t, http.StatusBadRequest, put(ur.ResetPasswordHandler, "10.0.0.1", `{"token":"bad","password":"Dk123456","confirm_password":"Dk123456"}`))
+	assert.Equal(t, http.StatusBadRequest, put(ur.ResetPasswordHandler, "10.0.0.1", `{"token":"good","password":"Dk1","confirm_password":"Dk1"}`))
+	assert.True(t, logins.sessions[1].IsActive(time.Now()))
+
+	assert.Equal(t, http.StatusOK, put(ur.ResetPasswordHandler, "10.0.0.1", `{"token":"good","password":"Dk123456","confirm_password":"Dk123456"}`))
+	assert.False(t, logins.sessions[1].IsActive(time.Now()))
+
+	// The IP is limited across both steps.
+	for i := 0; i < resetIPAttempts; i++ {
+		put(ur.ResetPasswordHandler, "10.0.0.4", `{"token":"bad"}`)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, put(ur.ForgotPasswordHandler, "10.0.0.4", `{"email":"nobody@menuxd.com"}`))
+}
@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStaffRoles checks that the staff of client 1 only reaches the routes
+// of its role.
+func TestStaffRoles(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	token := func(role string) string {
+		_, t, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "id": "7", "role": role, "client_id": "1", "sid": "1"})
+		return t
+	}
+
+	mounted := func(h http.Handler) http.Handler {
+		return jwtauth.Verifier(tokenAuth)(jwtauth.Authenticator(h))
+	}
+
+	um, _ := NewUserRouter(nil, nil)
+
+	tt := []struct {
+		name    string
+		handler http.Handler
+		role    string
+		method  string
+		path    string
+		body    string
+		code    int
+	}{
+		{"kitchen creates table", mounted(NewTableRouter(nil)), "kitchen", "POST", "/", `{"client_id":1}`, http.StatusForbidden},
+		{"waiter deletes table", mounted(NewTableRouter(nil)), "waiter", "DELETE", "/1", "", http.StatusForbidden},
+		{"table edits dish", NewDishRouter(nil), "table", "PUT", "/1", `{}`, http.StatusForbidden},
+		{"cashier takes order", NewOrderRouter(nil, nil, nil, nil, nil), "cashier", "POST", "/", `{"client_id":1}`, http.StatusForbidden},
+		{"waiter bumps item", NewOrderRouter(nil, nil, nil, nil, nil), "waiter", "POST", "/kds/item/1/bump", "", http.StatusForbidden},
+		{"waiter prepares item", NewOrderRouter(nil, nil, nil, nil, nil), "waiter", "POST", "/item/1/status", `{"status":"preparing"}`, http.StatusForbidden},
+		{"kitchen serves order", NewOrderRouter(nil, nil, nil, nil, nil), "kitchen", "POST", "/1/status", `{"status":"served"}`, http.StatusForbidden},
+		{"kitchen charges", mounted(NewPaymentRouter(nil)), "kitchen", "POST", "/", `{"bill_id":1}`, http.StatusForbidden},
+		{"waiter refunds", mounted(NewPaymentRouter(nil)), "waiter", "POST", "/1/refund", `{}`, http.StatusForbidden},
+		{"manager invites", um, "manager", "POST", "/client/1", `{"email":"a@b.co","role":"waiter"}`, http.StatusForbidden},
+		{"owner invites admin", um, "owner", "POST", "/client/1", `{"email":"a@b.co","role":"admin"}`, http.StatusBadRequest},
+		{"owner invites to other", um, "owner", "POST", "/client/3", `{"email":"a@b.co","role":"waiter"}`, http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Authorization", "Bearer "+token(tc.role))
+
+			tc.handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.code, w.Code)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// fakeUsers has the user 1, ana@menuxd.com with the password secret.
+type fakeUsers struct {
+	user.Storage
+}
+
+func (fakeUsers) GetByID(id uint) (user.User, error) {
+	if id != 1 {
+		return user.User{}, storage.ErrNotFound
+	}
+
+	u := user.User{Email: "ana@menuxd.com", Role: "client"}
+	u.ID = 1
+	u.Password = "secret"
+	u.PreparePass()
+	return u, nil
+}
+
+func (f fakeUsers) GetByEmail(email string) (user.User, error) {
+	if email != "ana@menuxd.com" {
+		return user.User{}, storage.ErrNotFound
+	}
+
+	return f.GetByID(1)
+}
+
+func (fakeUsers) TwoFactorRequired(role string) (bool, error) {
+	return false, nil
+}
+
+// fakeLogins keeps the sessions in memory.
+type fakeLogins struct {
+	sessions map[uint]*login.Session
+	lockouts login.Lockouts
+}
+
+func (f *fakeLogins) Create(s *login.Session) error {
+	s.ID = uint(len(f.sessions) + 1)
+	f.sessions[s.ID] = s
+	return nil
+}
+
+func (f *fakeLogins) Rotate(old login.Session, next *login.Session) error {
+	if f.sessions[old.ID].RevokedAt != nil {
+		return login.ErrReused
+	}
+
+	f.Revoke(old.ID)
+	return f.Create(next)
+}
+
+func (f *fakeLogins) Revoke(id uint) error {
+	if s, ok := f.sessions[id]; ok && s.RevokedAt == nil {
+		now := time.Now()
+		s.RevokedAt = &now
+	}
+
+	return nil
+}
+
+func (f *fakeLogins) RevokeFamily(family string) error {
+	for _, s := range f.sessions {
+		if s.Family == family {
+			f.Revoke(s.ID)
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeLogins) RevokeAll(userID uint) error {
+	for _, s := range f.sessions {
+		if s.UserID == userID {
+			f.Revoke(s.ID)
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeLogins) GetByID(id uint) (login.Session, error) {
+	if s, ok := f.sessions[id]; ok {
+		return *s, nil
+	}
+
+	return login.Session{}, storage.ErrNotFound
+}
+
+func (f *fakeLogins) GetByToken(token string) (login.Session, error) {
+	for _, s := range f.sessions {
+		if s.TokenHash == login.HashToken(token) {
+			return *s, nil
+		}
+	}
+
+	return login.Session{}, storage.ErrNotFound
+}
+
+func (f *fakeLogins) GetActive(userID uint) (login.Sessions, error) {
+	sessions := login.Sessions{}
+	for _, s := range f.sessions {
+		if s.UserID == userID && s.IsActive(time.Now()) {
+			sessions = append(sessions, *s)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (f *fakeLogins) RecordLockout(l *login.Lockout) error {
+	f.lockouts = append(f.lockouts, *l)
+	return nil
+}
+
+func (f *fakeLogins) GetLockouts(since time.Time) (login.Lockouts, error) {
+	return f.lockouts, nil
+}
+
+func TestSessions(t *testing.T) {
+	logins := &fakeLogins{sessions: map[uint]*login.Session{}}
+	_, ur := NewUserRouter(fakeUsers{}, logins)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+
+	post := func(h http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		jwtauth.Verifier(tokenAuth)(h).ServeHTTP(w, r)
+		return w
+	}
+
+	type response struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	w := post(ur.LoginHandler, `{"email":"ana@menuxd.com","password":"secret"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	first := response{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &first))
+	assert.NotEmpty(t, first.RefreshToken)
+
+	token, err := tokenAuth.Decode(first.Token)
+	assert.Nil(t, err)
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, "1", claims["sid"])
+	assert.NotNil(t, claims["exp"])
+
+	// The refresh rotates the session.
+	w = post(ur.RefreshHandler, `{"refresh_token":"`+first.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	second := response{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &second))
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.False(t, logins.sessions[1].IsActive(time.Now()))
+	assert.True(t, logins.sessions[2].IsActive(time.Now()))
+
+	// Using the first refresh token again ends its whole family.
+	w = post(ur.RefreshHandler, `{"refresh_token":"`+first.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, logins.sessions[2].IsActive(time.Now()))
+
+	// Logout ends the session of the refresh token.
+	w = post(ur.LoginHandler, `{"email":"ana@menuxd.com","password":"secret"}`)
+	third := response{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &third))
+
+	w = post(ur.LogoutHandler, `{"refresh_token":"`+third.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, logins.sessions[3].IsActive(time.Now()))
+
+	w = post(ur.RefreshHandler, `{"refresh_token":"`+third.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestSessionRoutes(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	logins := &fakeLogins{sessions: map[uint]*login.Session{}}
+	for _, userID := range []uint{1, 2} {
+		s, _ := login.New(userID, "", time.Now())
+		logins.Create(s)
+	}
+
+	um, _ := NewUserRouter(fakeUsers{}, logins)
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, owner, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "id": "1", "role": "client", "sid": "1"})
+	_, admin, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "id": "9", "role": "admin", "sid": "1"})
+	_, revoked, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "id": "1", "role": "client", "sid": "2"})
+
+	tt := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		code   int
+	}{
+		{"own sessions", owner, "GET", "/1/sessions", http.StatusOK},
+		{"other sessions", owner, "GET", "/2/sessions", http.StatusForbidden},
+		{"revoked session", revoked, "GET", "/1/sessions", http.StatusUnauthorized},
+		{"other session of user", admin, "DELETE", "/1/sessions/2", http.StatusNotFound},
+		{"admin kills session", admin, "DELETE", "/2/sessions/2", http.StatusOK},
+		{"admin kills all", admin, "DELETE", "/1/sessions", http.StatusOK},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+
+			um.ServeHTTP(w, r)
+			assert.Equal(t, tc.code, w.Code)
+		})
+	}
+
+	assert.False(t, logins.sessions[1].IsActive(time.Now()))
+	assert.False(t, logins.sessions[2].IsActive(time.Now()))
+}
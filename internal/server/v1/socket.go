@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/pkg/hub"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+	melody "gopkg.in/olahol/melody.v1"
+)
+
+// tokenProtocol is the websocket subprotocol that carries the token in the
+// next one: "Sec-WebSocket-Protocol: access_token, T".
+const tokenProtocol = "access_token"
+
+// tokenFromProtocol tries to retrieve the token string from the websocket
+// subprotocols, for browsers that can't set headers on the handshake.
+func tokenFromProtocol(r *http.Request) string {
+	protocols := strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",")
+	for i := 0; i < len(protocols)-1; i++ {
+		if strings.TrimSpace(protocols[i]) == tokenProtocol {
+			return strings.TrimSpace(protocols[i+1])
+		}
+	}
+
+	return ""
+}
+
+// expiry is the timer that closes a websocket session when its token
+// expires.
+type expiry struct {
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// tokenExpiry returns when the token of the claims expires, zero if never.
+func tokenExpiry(claims jwtauth.Claims) time.Time {
+	exp, err := strconv.ParseInt(tenant.Claim(claims, "exp"), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(exp, 0)
+}
+
+// ordersHandler upgrades to websocket the requests to the client of the URL,
+// keeping the connection details of the token.
+func (or OrderRouter) ordersHandler(m *melody.Melody) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		clientIDStr := chi.URLParam(r, "clientId")
+		clientID, err := strconv.Atoi(clientIDStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		c, err := or.ClientStorage.GetByID(uint(clientID))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		conn := hub.Conn{
+			ClientID:  c.ID,
+			Role:      tenant.Claim(claims, "role"),
+			CanAck:    auth.Has(r, auth.AckNotifications),
+			ExpiresAt: tokenExpiry(claims),
+		}
+
+		if waiterID := waiterOf(r); waiterID != nil {
+			conn.WaiterID = *waiterID
+		}
+
+		keys := map[string]interface{}{
+			"conn":   conn,
+			"expiry": &expiry{},
+		}
+
+		err = m.HandleRequestWithKeys(w, r, keys)
+		if err != nil {
+			http.Error(w, "Websocket connection failed", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// connectHandler joins the session to the hub of its client, announces it
+// and replays to it the notifications not acknowledged yet, after the since
+// query param. The session is closed when its token expires.
+func (or OrderRouter) connectHandler() func(*melody.Session) {
+	return func(s *melody.Session) {
+		conn, ok := s.MustGet("conn").(hub.Conn)
+		if !ok {
+			s.Close()
+			return
+		}
+
+		or.Hub.Join(s, conn)
+
+		if !conn.ExpiresAt.IsZero() {
+			e := s.MustGet("expiry").(*expiry)
+			e.mu.Lock()
+			e.timer = time.AfterFunc(time.Until(conn.ExpiresAt), func() {
+				or.Hub.Leave(s)
+				s.CloseWithMsg(melody.FormatCloseMessage(melody.ClosePolicyViolation, "token expired"))
+			})
+			e.mu.Unlock()
+		}
+
+		n := notification.Notification{
+			Type:     notification.Connected,
+			Message:  "Connected",
+			Date:     time.Now(),
+			ClientID: conn.ClientID,
+			Active:   true,
+		}
+
+		j, err := json.Marshal(n)
+		if err != nil {
+			return
+		}
+
+		or.Hub.Broadcast(conn.ClientID, j)
+
+		pending, err := or.NotificationStorage.GetPending(conn.ClientID, since(s.Request))
+		if err != nil {
+			return
+		}
+
+		for _, p := range pending {
+			j, err := json.Marshal(p)
+			if err != nil {
+				continue
+			}
+
+			or.Hub.Send(s, j)
+		}
+	}
+}
+
+// disconnectHandler removes the session from the hub and stops its
+// expiration timer.
+func (or OrderRouter) disconnectHandler() func(*melody.Session) {
+	return func(s *melody.Session) {
+		or.Hub.Leave(s)
+
+		e, ok := s.MustGet("expiry").(*expiry)
+		if !ok {
+			return
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+}
+
+// messageHandler acknowledges the notification of a websocket message, and
+// broadcasts it as handled.
+func (or OrderRouter) messageHandler() func(*melody.Session, []byte) {
+	return func(s *melody.Session, msg []byte) {
+		conn, ok := or.Hub.Conn(s)
+		if !ok {
+			return
+		}
+
+		if conn.IsExpired(time.Now()) {
+			or.Hub.Leave(s)
+			s.CloseWithMsg(melody.FormatCloseMessage(melody.ClosePolicyViolation, "token expired"))
+			return
+		}
+
+		or.ack(conn, msg)
+	}
+}
+
+// ack acknowledges the notification of a websocket message, if the token of
+// the connection may and it belongs to the client of the connection.
+func (or OrderRouter) ack(conn hub.Conn, msg []byte) {
+	if !conn.CanAck {
+		return
+	}
+
+	a := notification.Ack{}
+	err := json.Unmarshal(msg, &a)
+	if err != nil || a.ID == 0 {
+		return
+	}
+
+	n, err := or.NotificationStorage.GetByID(a.ID)
+	if err != nil || n.ClientID != conn.ClientID {
+		return
+	}
+
+	n, err = or.NotificationStorage.Ack(a.ID, conn.WaiterID)
+	if err != nil {
+		return
+	}
+
+	or.MessageStream <- n
+}
+
+// metricsHandler response the sessions connected by client and the
+// counters of the hub.
+func (or OrderRouter) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	j, err := json.Marshal(or.Hub.Metrics())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
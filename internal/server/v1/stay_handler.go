@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/stay"
 )
 
@@ -94,6 +96,11 @@ func (sr StayRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Allowed(r, st.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = sr.storage.Create(st)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -109,11 +116,13 @@ func NewStayRouter(s stay.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	sr := StayRouter{storage: s}
 
+	r.Use(tenant.Resolve(tenants))
+
 	// Set endpoints
-	r.Get("/", sr.getAllHandler)
-	r.Get("/client/{clientId}", sr.getByClientHandler)
-	r.Post("/", sr.createHandler)
-	r.Get("/{id}", sr.getOneHandler)
+	r.With(auth.Authenticator("admin")).Get("/", sr.getAllHandler)
+	r.With(auth.Require(auth.ReadReports), tenant.Client("clientId")).Get("/client/{clientId}", sr.getByClientHandler)
+	r.With(auth.Require(auth.SendFeedback)).Post("/", sr.createHandler)
+	r.With(auth.Require(auth.ReadReports), tenant.Owned("id", tenant.Stays)).Get("/{id}", sr.getOneHandler)
 
 	return r
 }
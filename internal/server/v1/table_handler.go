@@ -6,6 +6,8 @@ import (
 	"strconv"
 
 	"github.com/go-chi/chi"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/table"
 )
 
@@ -75,6 +77,11 @@ func (tr TableRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Allowed(r, t.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = tr.storage.Create(t)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -136,12 +143,14 @@ func NewTableRouter(s table.Storage) *chi.Mux {
 	r := chi.NewRouter()
 	tr := TableRouter{storage: s}
 
+	r.Use(tenant.Resolve(tenants))
+
 	// Set endpoints
-	r.Get("/client/{clientId}", tr.getAllHandler)
-	r.Post("/", tr.createHandler)
-	r.Get("/{id}", tr.getOneHandler)
-	r.Put("/{id}", tr.updateHandler)
-	r.Delete("/{id}", tr.deleteHandler)
+	r.With(auth.Require(auth.ReadTables), tenant.Client("clientId")).Get("/client/{clientId}", tr.getAllHandler)
+	r.With(auth.Require(auth.ManageTables)).Post("/", tr.createHandler)
+	r.With(auth.Require(auth.ReadTables), tenant.Owned("id", tenant.Tables)).Get("/{id}", tr.getOneHandler)
+	r.With(auth.Require(auth.ServeTables), tenant.Owned("id", tenant.Tables)).Put("/{id}", tr.updateHandler)
+	r.With(auth.Require(auth.ManageTables), tenant.Owned("id", tenant.Tables)).Delete("/{id}", tr.deleteHandler)
 
 	return r
 }
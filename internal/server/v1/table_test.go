@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage/memory"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+)
+
+// TestTableRouter runs the table router on the memory storage, with a
+// manager of the first of two clients.
+func TestTableRouter(t *testing.T) {
+	b := memory.New()
+
+	stored := tenants
+	tenants = b.Tenants
+	defer func() { tenants = stored }()
+
+	clients := []client.Client{
+		{Name: "Bar", ExpireAt: time.Now().Add(time.Hour)},
+		{Name: "Café", ExpireAt: time.Now().Add(time.Hour)},
+	}
+	for i := range clients {
+		if err := b.Clients.Create(&clients[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, manager, _ := tokenAuth.Encode(jwtauth.Claims{
+		"exp":       jwtauth.ExpireIn(time.Hour),
+		"role":      "manager",
+		"client_id": fmt.Sprint(clients[0].ID),
+	})
+	h := tokens.Verifier(jwtauth.Authenticator(NewTableRouter(b.Tables)))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+manager)
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	assert := assert.New(t)
+
+	body := fmt.Sprintf(`{"number":1,"client_id":%d}`, clients[0].ID)
+	assert.Equal(http.StatusOK, do(http.MethodPost, "/", body).Code)
+
+	body = fmt.Sprintf(`{"number":1,"client_id":%d}`, clients[1].ID)
+	assert.Equal(http.StatusForbidden, do(http.MethodPost, "/", body).Code)
+
+	w := do(http.MethodGet, fmt.Sprintf("/client/%d", clients[0].ID), "")
+	assert.Equal(http.StatusOK, w.Code)
+
+	tables := []table.Table{}
+	assert.Nil(json.NewDecoder(w.Body).Decode(&tables))
+	assert.Len(tables, 1)
+	assert.Equal("table", tables[0].Type)
+	assert.True(tables[0].Available)
+
+	path := fmt.Sprintf("/%d", tables[0].ID)
+	assert.Equal(http.StatusOK, do(http.MethodDelete, path, "").Code)
+	assert.Equal(http.StatusNotFound, do(http.MethodGet, path, "").Code)
+}
@@ -0,0 +1,299 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage/memory"
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+)
+
+// fakeTenants gives user 1 the client 1, and each resource to the client
+// and table with its same ID, but from 10 on to client 1. Device 1 is of
+// table 1 and the rest were revoked, as the sessions but 1.
+type fakeTenants struct{}
+
+func (fakeTenants) Clients(userID uint) ([]uint, error) {
+	if userID == 1 {
+		return []uint{1}, nil
+	}
+
+	return []uint{}, nil
+}
+
+func (fakeTenants) Owner(res tenant.Resource, id uint) (tenant.Ownership, error) {
+	if id == 0 {
+		return tenant.Ownership{}, errors.New("not found")
+	}
+
+	if id >= 10 {
+		return tenant.Ownership{ClientID: 1, TableID: id}, nil
+	}
+
+	return tenant.Ownership{ClientID: id, TableID: id}, nil
+}
+
+func (fakeTenants) Session(id uint) error {
+	if id != 1 {
+		return tenant.ErrRevoked
+	}
+
+	return nil
+}
+
+func (fakeTenants) Device(id uint) (tenant.Ownership, error) {
+	if id != 1 {
+		return tenant.Ownership{}, tenant.ErrRevoked
+	}
+
+	return tenant.Ownership{ClientID: 1, TableID: 1}, nil
+}
+
+type tenantCase struct {
+	method string
+	path   string
+	body   string
+	code   int
+}
+
+// TestForeignClient checks that the user of client 1 can't reach the data
+// of client 3 through any router.
+func TestForeignClient(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "id": "1", "role": "client", "sid": "1"})
+
+	// mounted wraps the routers that api.go mounts behind the verifier.
+	mounted := func(h http.Handler) http.Handler {
+		return jwtauth.Verifier(tokenAuth)(jwtauth.Authenticator(h))
+	}
+
+	forbidden := func(method, path, body string) tenantCase {
+		return tenantCase{method, path, body, http.StatusForbidden}
+	}
+
+	um, _ := NewUserRouter(nil, nil)
+
+	tt := []struct {
+		name    string
+		handler http.Handler
+		cases   []tenantCase
+	}{
+		{"tables", mounted(NewTableRouter(nil)), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("DELETE", "/3", ""),
+		}},
+		{"waiters", mounted(NewWaiterRouter(nil)), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("DELETE", "/3", ""),
+		}},
+		{"questions", mounted(NewQuestionRouter(nil)), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("DELETE", "/3", ""),
+		}},
+		{"promotions", mounted(NewPromotionRouter(nil)), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("POST", "/add-click/3", ""),
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("DELETE", "/3", ""),
+		}},
+		{"ads", mounted(NewAdRouter(nil)), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("POST", "/add-click/3", ""),
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("PATCH", "/3", `{}`),
+			forbidden("DELETE", "/3", ""),
+		}},
+		{"stays", mounted(NewStayRouter(nil)), []tenantCase{
+			{"GET", "/", "", http.StatusUnauthorized},
+			forbidden("GET", "/client/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("GET", "/3", ""),
+		}},
+		{"ratings", mounted(NewRatingRouter(nil)), []tenantCase{
+			{"GET", "/", "", http.StatusUnauthorized},
+			forbidden("GET", "/question/3", ""),
+			forbidden("POST", "/", `{"question_id":3}`),
+			forbidden("GET", "/3", ""),
+		}},
+		{"registers", mounted(NewRegisterRouter(nil)), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("GET", "/client/3/open", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("POST", "/3/close", `{}`),
+			forbidden("GET", "/3/report", ""),
+		}},
+		{"bills", mounted(NewBillRouter(nil, nil, nil)), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("POST", "/", `{"table_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("DELETE", "/3", ""),
+			forbidden("POST", "/3/split", `{}`),
+		}},
+		{"payments", mounted(NewPaymentRouter(nil)), []tenantCase{
+			forbidden("GET", "/bill/3", ""),
+			forbidden("POST", "/", `{"bill_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("POST", "/3/refund", `{}`),
+		}},
+		{"pictures", mounted(NewPictureRouter()), []tenantCase{
+			forbidden("POST", "/3/logo.png", ""),
+		}},
+		{"categories", NewCategoryRouter(nil), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("GET", "/client/3/admin", ""),
+			forbidden("POST", "/client/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("PATCH", "/3", `{}`),
+			forbidden("DELETE", "/3", ""),
+			forbidden("PUT", "/position/", `[{"id":1},{"id":3}]`),
+		}},
+		{"dishes", NewDishRouter(nil), []tenantCase{
+			forbidden("GET", "/client/3", ""),
+			forbidden("GET", "/client/3/1", ""),
+			forbidden("POST", "/client/3", ""),
+			forbidden("GET", "/category/3", ""),
+			forbidden("GET", "/suggested/3", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("DELETE", "/3", ""),
+			forbidden("POST", "/add-click/3", ""),
+			forbidden("POST", "/clicks/client/3", ""),
+		}},
+		{"clients", NewClientRouter(nil), []tenantCase{
+			forbidden("GET", "/3", ""),
+			forbidden("GET", "/user/3", ""),
+		}},
+		{"users", um, []tenantCase{
+			forbidden("GET", "/3", ""),
+			forbidden("PUT", "/change-password/3", `{}`),
+		}},
+		{"orders", NewOrderRouter(nil, nil, nil, nil, nil), []tenantCase{
+			forbidden("GET", "/3/ws", ""),
+			forbidden("GET", "/call/3/waiter", ""),
+			forbidden("GET", "/call/3/bill", ""),
+			forbidden("POST", "/", `{"client_id":3}`),
+			forbidden("POST", "/", `{"client_id":1,"table_id":3}`),
+			forbidden("POST", "/", `{"client_id":1,"table":{"id":3}}`),
+			forbidden("POST", "/", `{"client_id":1,"table_id":10,"table":{"id":3}}`),
+			forbidden("PUT", "/3", `{}`),
+			forbidden("PUT", "/add/3/client/1", `[]`),
+			forbidden("PUT", "/add/1/client/3", `[]`),
+			forbidden("PATCH", "/item/3", `{}`),
+			forbidden("POST", "/item/3/status", `{}`),
+			forbidden("POST", "/3/status", `{}`),
+			forbidden("GET", "/client/3", ""),
+			forbidden("GET", "/active/3", ""),
+			forbidden("GET", "/kds/3", ""),
+			forbidden("POST", "/kds/item/3/bump", ""),
+			forbidden("POST", "/kds/ticket/3/bump", ""),
+			forbidden("GET", "/notifications/3", ""),
+			forbidden("POST", "/notifications/3/ack", `{}`),
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, c := range tc.cases {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+				r.Header.Set("Authorization", "Bearer "+token)
+
+				tc.handler.ServeHTTP(w, r)
+				assert.Equal(t, c.code, w.Code, c.method+" "+c.path)
+			}
+		})
+	}
+}
+
+// TestForeignReferences checks that the menu of a client can't point to the
+// dishes of another one.
+func TestForeignReferences(t *testing.T) {
+	b := memory.New()
+
+	stored := tenants
+	tenants = b.Tenants
+	defer func() { tenants = stored }()
+
+	clients := []client.Client{
+		{Name: "Bar", ExpireAt: time.Now().Add(time.Hour)},
+		{Name: "Café", ExpireAt: time.Now().Add(time.Hour)},
+	}
+	dishes := make([]dish.Dish, len(clients))
+	for i := range clients {
+		if err := b.Clients.Create(&clients[i]); err != nil {
+			t.Fatal(err)
+		}
+
+		dishes[i].ClientID = clients[i].ID
+		dishes[i].Name = "Empanada"
+		dishes[i].Pictures = []string{"empanada.png"}
+		if err := b.Dishes.Create(&dishes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := category.Category{ClientID: clients[0].ID}
+	c.Title = "Pizzas"
+	c.Picture = "pizzas.png"
+	if err := b.Categories.Create(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, manager, _ := tokenAuth.Encode(jwtauth.Claims{
+		"exp":       jwtauth.ExpireIn(time.Hour),
+		"role":      "manager",
+		"client_id": fmt.Sprint(clients[0].ID),
+	})
+
+	do := func(h http.Handler, method, path, body string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+manager)
+		tokens.Verifier(jwtauth.Authenticator(h)).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert := assert.New(t)
+
+	categories := NewCategoryRouter(b.Categories)
+	path := fmt.Sprintf("/%d", c.ID)
+	body := `{"title":"Pizzas","picture":"pizzas.png","suggested1":%d}`
+	assert.Equal(http.StatusForbidden, do(categories, "PUT", path, fmt.Sprintf(body, dishes[1].ID)))
+	assert.Equal(http.StatusOK, do(categories, "PUT", path, fmt.Sprintf(body, dishes[0].ID)))
+
+	promotions := NewPromotionRouter(b.Promotions)
+	body = `{"title":"2x1","picture":"2x1.png","start_at":"10:00","end_at":"12:00","client_id":%d,"dish_id":%d}`
+	assert.Equal(http.StatusForbidden, do(promotions, "POST", "/", fmt.Sprintf(body, clients[0].ID, dishes[1].ID)))
+}
@@ -0,0 +1,483 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/totp"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// Two factor settings.
+const (
+	// challengeTTL is how long the second step of a login can take.
+	challengeTTL = 5 * time.Minute
+	// challengePurpose marks the tokens of the second step, which are not
+	// access tokens.
+	challengePurpose = "2fa"
+	// codeAttempts are the codes a user can try each codeWindow.
+	codeAttempts = 5
+	codeWindow   = 5 * time.Minute
+)
+
+// errInvalidChallenge is returned for challenges not valid or expired.
+var errInvalidChallenge = errors.New("the login challenge is invalid or expired")
+
+// twoFactorLogin is the body of the second step of a login.
+type twoFactorLogin struct {
+	Challenge    string `json:"challenge"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// writeChallenge responds the challenge of the second step of the login of
+// the user, who first has to enroll if setup is true.
+func (ur UserRouter) writeChallenge(w http.ResponseWriter, u user.User) {
+	id, err := user.NewChallengeID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt := time.Now().Add(challengeTTL)
+	claims := jwtauth.Claims{
+		"sub":     strconv.Itoa(int(u.ID)),
+		"jti":     id,
+		"purpose": challengePurpose,
+	}
+	claims.SetExpiry(expiresAt)
+
+	token, err := tokens.Encode(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"two_factor": true,
+		"setup":      !u.TOTPEnabled,
+		"challenge":  token,
+		"expires_at": expiresAt.UTC(),
+	}
+
+	j, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// challengeUser returns the user of a challenge, and the challenge to
+// record as used once it is exchanged.
+func (ur UserRouter) challengeUser(challenge string) (user.User, user.UsedChallenge, error) {
+	t, err := tokens.Decode(challenge)
+	if err != nil {
+		return user.User{}, user.UsedChallenge{}, errInvalidChallenge
+	}
+
+	claims, _ := t.Claims.(jwt.MapClaims)
+	if claims["purpose"] != challengePurpose {
+		return user.User{}, user.UsedChallenge{}, errInvalidChallenge
+	}
+
+	id, err := strconv.Atoi(tenant.Claim(jwtauth.Claims(claims), "sub"))
+	if err != nil {
+		return user.User{}, user.UsedChallenge{}, errInvalidChallenge
+	}
+
+	exp, err := strconv.ParseInt(tenant.Claim(jwtauth.Claims(claims), "exp"), 10, 64)
+	jti := tenant.Claim(jwtauth.Claims(claims), "jti")
+	if err != nil || jti == "" {
+		return user.User{}, user.UsedChallenge{}, errInvalidChallenge
+	}
+
+	u, err := ur.storage.GetByID(uint(id))
+	if err != nil {
+		return user.User{}, user.UsedChallenge{}, err
+	}
+
+	return u, user.UsedChallenge{ID: jti, ExpiresAt: time.Unix(exp, 0)}, nil
+}
+
+// allowCode checks the code attempts of the user, and answers 429 if they
+// are exceeded.
+func (ur UserRouter) allowCode(w http.ResponseWriter, userID uint) bool {
+	ok, wait := ur.codes.Allow("2fa:" + strconv.Itoa(int(userID)))
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
+
+// useChallenge records the challenge of a login as used, and answers 401 if
+// it already was, so each challenge starts a single session.
+func (ur UserRouter) useChallenge(w http.ResponseWriter, c user.UsedChallenge) bool {
+	err := ur.storage.UseChallenge(c)
+	if err == user.ErrChallengeUsed {
+		http.Error(w, errInvalidChallenge.Error(), http.StatusUnauthorized)
+		return false
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// verifyTOTP checks a code of the authenticator of the user and uses up its
+// time step, so neither the code nor those before it are accepted again.
+func (ur UserRouter) verifyTOTP(u user.User, code string) bool {
+	last, err := ur.storage.TOTPStep(u.ID)
+	if err != nil {
+		return false
+	}
+
+	step, ok := totp.Verify(u.TOTPSecret, code, time.Now(), last)
+	return ok && ur.storage.UseTOTPStep(u.ID, step) == nil
+}
+
+// checkCode checks a code of the authenticator of the user, or else one of
+// its recovery codes. Either is used up.
+func (ur UserRouter) checkCode(u user.User, code, recoveryCode string) bool {
+	if code != "" && ur.verifyTOTP(u, code) {
+		return true
+	}
+
+	return recoveryCode != "" && ur.storage.UseRecoveryCode(u.ID, recoveryCode) == nil
+}
+
+// enableTOTP enables two factor authentication for the user and returns its
+// new recovery codes.
+func (ur UserRouter) enableTOTP(userID uint) ([]string, error) {
+	codes, err := user.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ur.storage.EnableTOTP(userID, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// enroll stores a new secret for the user and responds it with its
+// provisioning URI, to be shown as a QR code.
+func (ur UserRouter) enroll(w http.ResponseWriter, u user.User) {
+	if u.TOTPEnabled {
+		http.Error(w, user.ErrTwoFactorEnabled.Error(), http.StatusConflict)
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ur.storage.SetTOTPSecret(u.ID, secret)
+	if err == user.ErrTwoFactorEnabled {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"secret": secret,
+		"uri":    totp.URI(user.Issuer, u.Email, secret),
+	}
+
+	j, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// TwoFactorLoginHandler is the second step of a login: it exchanges the
+// challenge and a code for the tokens of a new session. Users whose role
+// requires two factor enable it here, with the first code of the secret
+// they enrolled, and get their recovery codes.
+func (ur UserRouter) TwoFactorLoginHandler(w http.ResponseWriter, r *http.Request) {
+	l := twoFactorLogin{}
+	err := json.NewDecoder(r.Body).Decode(&l)
+	if err != nil {
+		http.Error(w, "Invalid login", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	u, used, err := ur.challengeUser(l.Challenge)
+	if err != nil {
+		http.Error(w, errInvalidChallenge.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !ur.allowCode(w, u.ID) {
+		return
+	}
+
+	if u.TOTPEnabled {
+		if !ur.checkCode(u, l.Code, l.RecoveryCode) {
+			http.Error(w, user.ErrInvalidCode.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if !ur.useChallenge(w, used) {
+			return
+		}
+
+		ur.codes.Reset("2fa:" + strconv.Itoa(int(u.ID)))
+		ur.startSession(w, r, u, nil)
+		return
+	}
+
+	if u.TOTPSecret == "" {
+		http.Error(w, user.ErrTwoFactorDisabled.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !ur.verifyTOTP(u, l.Code) {
+		http.Error(w, user.ErrInvalidCode.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !ur.useChallenge(w, used) {
+		return
+	}
+
+	codes, err := ur.enableTOTP(u.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ur.codes.Reset("2fa:" + strconv.Itoa(int(u.ID)))
+	u.TOTPEnabled = true
+	ur.startSession(w, r, u, map[string]interface{}{"recovery_codes": codes})
+}
+
+// TwoFactorSetupHandler enrolls, with the challenge of its login, a user
+// whose role requires two factor authentication.
+func (ur UserRouter) TwoFactorSetupHandler(w http.ResponseWriter, r *http.Request) {
+	l := twoFactorLogin{}
+	err := json.NewDecoder(r.Body).Decode(&l)
+	if err != nil {
+		http.Error(w, "Invalid login", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	u, _, err := ur.challengeUser(l.Challenge)
+	if err != nil {
+		http.Error(w, errInvalidChallenge.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	ur.enroll(w, u)
+}
+
+// enrollHandler starts the enrollment of a user in two factor
+// authentication.
+func (ur UserRouter) enrollHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := ur.storage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ur.enroll(w, u)
+}
+
+// verifyHandler enables two factor authentication for a user with the first
+// code of the secret enrolled, and responds the recovery codes.
+func (ur UserRouter) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	l := twoFactorLogin{}
+	err = json.NewDecoder(r.Body).Decode(&l)
+	if err != nil {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	u, err := ur.storage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if u.TOTPEnabled {
+		http.Error(w, user.ErrTwoFactorEnabled.Error(), http.StatusConflict)
+		return
+	}
+
+	if u.TOTPSecret == "" {
+		http.Error(w, user.ErrTwoFactorDisabled.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !ur.allowCode(w, u.ID) {
+		return
+	}
+
+	if !ur.verifyTOTP(u, l.Code) {
+		http.Error(w, user.ErrInvalidCode.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := ur.enableTOTP(u.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	j, err := json.Marshal(map[string]interface{}{"recovery_codes": codes})
+	if err != nil {
+		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// disableHandler disables two factor authentication for a user. Users need
+// a code, admins don't, so they can help who lost the authenticator.
+func (ur UserRouter) disableHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	l := twoFactorLogin{}
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&l)
+		if err != nil {
+			http.Error(w, "Invalid code", http.StatusBadRequest)
+			return
+		}
+
+		defer r.Body.Close()
+	}
+
+	u, err := ur.storage.GetByID(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	sc, _ := tenant.FromContext(r.Context())
+	if !sc.All {
+		if !ur.allowCode(w, u.ID) {
+			return
+		}
+
+		if !u.TOTPEnabled || !ur.checkCode(u, l.Code, l.RecoveryCode) {
+			http.Error(w, user.ErrInvalidCode.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	err = ur.storage.DisableTOTP(u.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// getPoliciesHandler response the two factor policies of the roles.
+func (ur UserRouter) getPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	policies, err := ur.storage.GetTwoFactorPolicies()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(policies)
+	if err != nil {
+		http.Error(w, "Failed to parse policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// setPolicyHandler sets whether the users of a role must use two factor
+// authentication.
+func (ur UserRouter) setPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	role := chi.URLParam(r, "role")
+	if _, ok := auth.Roles[role]; !ok && role != user.Admin {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	p := user.TwoFactorPolicy{}
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil {
+		http.Error(w, "Invalid policy", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	p.Role = role
+	err = ur.storage.SetTwoFactorPolicy(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
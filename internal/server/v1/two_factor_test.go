@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+	"gitlab.com/menuxd/api-rest/pkg/totp"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// fakeTwoFactor keeps the two factor state of the user 1.
+type fakeTwoFactor struct {
+	fakeUsers
+	required bool
+	secret   string
+	enabled  bool
+	codes    map[string]bool
+	step     int64
+	used     map[string]bool
+}
+
+func (f *fakeTwoFactor) GetByID(id uint) (user.User, error) {
+	u, err := f.fakeUsers.GetByID(id)
+	u.TOTPSecret = f.secret
+	u.TOTPEnabled = f.enabled
+	return u, err
+}
+
+func (f *fakeTwoFactor) GetByEmail(email string) (user.User, error) {
+	u, err := f.fakeUsers.GetByEmail(email)
+	u.TOTPSecret = f.secret
+	u.TOTPEnabled = f.enabled
+	return u, err
+}
+
+func (f *fakeTwoFactor) TwoFactorRequired(role string) (bool, error) {
+	return f.required, nil
+}
+
+func (f *fakeTwoFactor) SetTOTPSecret(id uint, secret string) error {
+	f.secret = secret
+	return nil
+}
+
+func (f *fakeTwoFactor) EnableTOTP(id uint, codes []string) error {
+	f.enabled = true
+	f.codes = map[string]bool{}
+	for _, c := range codes {
+		f.codes[c] = true
+	}
+
+	return nil
+}
+
+func (f *fakeTwoFactor) UseRecoveryCode(id uint, code string) error {
+	if !f.codes[code] {
+		return user.ErrInvalidCode
+	}
+
+	delete(f.codes, code)
+	return nil
+}
+
+func (f *fakeTwoFactor) TOTPStep(id uint) (int64, error) {
+	return f.step, nil
+}
+
+func (f *fakeTwoFactor) UseTOTPStep(id uint, step int64) error {
+	if step <= f.step {
+		return user.ErrCodeUsed
+	}
+
+	f.step = step
+	return nil
+}
+
+func (f *fakeTwoFactor) UseChallenge(c user.UsedChallenge) error {
+	if f.used[c.ID] {
+		return user.ErrChallengeUsed
+	}
+
+	f.used[c.ID] = true
+	return nil
+}
+
+// twoFactorResponse are the fields of the responses of the login steps.
+type twoFactorResponse struct {
+	TwoFactor     bool     `json:"two_factor"`
+	Setup         bool     `json:"setup"`
+	Challenge     string   `json:"challenge"`
+	Secret        string   `json:"secret"`
+	Token         string   `json:"token"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	users := &fakeTwoFactor{required: true, used: map[string]bool{}}
+	um, ur := NewUserRouter(users, &fakeLogins{sessions: map[uint]*login.Session{}})
+
+	post := func(h http.HandlerFunc, body string) (int, twoFactorResponse) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		h.ServeHTTP(w, r)
+
+		res := twoFactorResponse{}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res
+	}
+
+	// The role requires two factor, so the user enrolls to log in.
+	code, res := post(ur.LoginHandler, `{"email":"ana@menuxd.com","password":"secret"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, res.TwoFactor)
+	assert.True(t, res.Setup)
+	assert.Empty(t, res.Token)
+	challenge := res.Challenge
+
+	// The challenge is not an access token.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/1", nil)
+	r.Header.Set("Authorization", "Bearer "+challenge)
+	um.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	code, res = post(ur.TwoFactorSetupHandler, `{"challenge":"`+challenge+`"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, users.secret, res.Secret)
+
+	code, _ = post(ur.TwoFactorLoginHandler, `{"challenge":"`+challenge+`","code":"000000x"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	c, _ := totp.Code(users.secret, time.Now())
+	code, res = post(ur.TwoFactorLoginHandler, `{"challenge":"`+challenge+`","code":"`+c+`"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, res.Token)
+	assert.Len(t, res.RecoveryCodes, user.RecoveryCodes)
+	assert.True(t, users.enabled)
+	recovery, other := res.RecoveryCodes[0], res.RecoveryCodes[1]
+
+	// Once enabled, a recovery code logs in only once.
+	_, res = post(ur.LoginHandler, `{"email":"ana@menuxd.com","password":"secret"}`)
+	assert.False(t, res.Setup)
+	body := `{"challenge":"` + res.Challenge + `","recovery_code":"` + recovery + `"}`
+	code, res = post(ur.TwoFactorLoginHandler, body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, res.Token)
+
+	// Neither the code nor the challenge are accepted twice.
+	_, res = post(ur.LoginHandler, `{"email":"ana@menuxd.com","password":"secret"}`)
+	code, _ = post(ur.TwoFactorLoginHandler, `{"challenge":"`+res.Challenge+`","code":"`+c+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	reused := strings.Replace(body, recovery, other, 1)
+	code, _ = post(ur.TwoFactorLoginHandler, reused)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = post(ur.TwoFactorLoginHandler, body)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	for i := 0; i < codeAttempts; i++ {
+		post(ur.TwoFactorLoginHandler, body)
+	}
+	code, _ = post(ur.TwoFactorLoginHandler, body)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+}
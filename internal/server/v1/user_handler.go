@@ -2,19 +2,63 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/login"
 	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/ratelimit"
 	"gitlab.com/menuxd/api-rest/pkg/user"
 )
 
+// Password reset limits, for each address and IP, in resetWindow.
+const (
+	resetAddressAttempts = 3
+	resetIPAttempts      = 20
+	resetWindow          = 15 * time.Minute
+)
+
+// Login lockouts: after the failures of an account or IP, they are locked
+// for lockoutBase, doubling with each failure up to lockoutLimit.
+const (
+	accountFailures = 5
+	ipFailures      = 20
+	lockoutBase     = time.Minute
+	lockoutLimit    = time.Hour
+)
+
+// lockoutHistory is how far back the admins review the lockouts.
+const lockoutHistory = 7 * 24 * time.Hour
+
+// errInvalidLogin is the answer to every failed login, so it doesn't tell
+// which accounts exist.
+var errInvalidLogin = errors.New("Invalid email or password")
+
+// dummyHash is compared with the passwords of unknown addresses.
+const dummyHash = "$2a$10$QD0ehzR/AwNsqQU1Tt9cuOfG4ylL5SfB.kuB8g7A/In0Zngu1shOO"
+
 // UserRouter is the router of the users.
 type UserRouter struct {
-	storage user.Storage
+	storage        user.Storage
+	sessions       login.Storage
+	resetAddresses *ratelimit.Limiter
+	resetIPs       *ratelimit.Limiter
+	codes          *ratelimit.Limiter
+	accounts       *ratelimit.Backoff
+	ips            *ratelimit.Backoff
+}
+
+// refreshing is the body to refresh or end a login session.
+type refreshing struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // getAllHandler response all the users
@@ -120,6 +164,12 @@ func (ur UserRouter) deleteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = ur.sessions.RevokeAll(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	err = ur.storage.Delete(uint(id))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -130,7 +180,154 @@ func (ur UserRouter) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
 
-// LoginHandler handle login, returns a json with token.
+// getStaffHandler response the staff of a client.
+func (ur UserRouter) getStaffHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, err := ur.storage.GetByClient(uint(clientID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(users)
+	if err != nil {
+		http.Error(w, "Failed to parse users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// inviteHandler creates a user of the staff of a client with the role
+// given, who receives the password by email.
+func (ur UserRouter) inviteHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u := user.New()
+	err = json.NewDecoder(r.Body).Decode(u)
+	if err != nil {
+		http.Error(w, "Failed to parse user", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !user.IsStaff(u.Role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	u.ClientID = uint(clientID)
+	u.Confirmed = false
+	err = ur.storage.Create(u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u.CleanPass()
+	j, err := json.Marshal(u)
+	if err != nil {
+		http.Error(w, "Failed to parse user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(j)
+}
+
+// removeStaffHandler removes a user from the staff of a client.
+func (ur UserRouter) removeStaffHandler(w http.ResponseWriter, r *http.Request) {
+	clientIDStr := chi.URLParam(r, "clientId")
+	clientID, err := strconv.Atoi(clientIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := ur.storage.GetByID(uint(id))
+	if err != nil || u.ClientID != uint(clientID) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	err = ur.sessions.RevokeAll(u.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ur.storage.Delete(u.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// allowLogin checks that neither the account nor the IP are locked out, and
+// answers 429 if one is.
+func (ur UserRouter) allowLogin(w http.ResponseWriter, account, ip string) bool {
+	locked, wait := ur.accounts.Locked(account)
+	if !locked {
+		locked, wait = ur.ips.Locked(ip)
+	}
+
+	if locked {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
+
+// failLogin counts a failed login of the account and the IP, and records
+// the lockouts it causes.
+func (ur UserRouter) failLogin(email, host, account, ip string) {
+	now := time.Now()
+
+	if locked, wait := ur.accounts.Fail(account); locked {
+		l := login.Lockout{Email: email, IP: host, Reason: login.LockedAccount, Until: now.Add(wait)}
+		if err := ur.sessions.RecordLockout(&l); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if locked, wait := ur.ips.Fail(ip); locked {
+		l := login.Lockout{Email: email, IP: host, Reason: login.LockedIP, Until: now.Add(wait)}
+		if err := ur.sessions.RecordLockout(&l); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// LoginHandler handle login, returns a json with an access token and the
+// refresh token of a new session. Users with two factor authentication, or
+// whose role requires it, get a challenge for the second step instead.
 func (ur UserRouter) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	u := user.New()
 	err := json.NewDecoder(r.Body).Decode(u)
@@ -139,33 +336,197 @@ func (ur UserRouter) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	account := "account:" + strings.ToLower(strings.TrimSpace(u.Email))
+	ip := "ip:" + remoteHost(r)
+	if !ur.allowLogin(w, account, ip) {
+		return
+	}
+
+	// Unknown addresses also compare a hash, so they take the same time
+	// and get the same answer as wrong passwords.
 	storedUser, err := ur.storage.GetByEmail(u.Email)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		storedUser = user.User{HashPassword: dummyHash}
+	}
+
+	if !storedUser.ComparePass(u.Password) || storedUser.ID == 0 {
+		ur.failLogin(u.Email, remoteHost(r), account, ip)
+		http.Error(w, errInvalidLogin.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	if !storedUser.ComparePass(u.Password) {
-		http.Error(w, "Passwords don't match", http.StatusUnauthorized)
+	ur.accounts.Reset(account)
+
+	required, err := ur.storage.TwoFactorRequired(storedUser.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	storedUser.CleanPass()
-	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("XD_SIGNING_STRING")), nil)
+	if storedUser.TOTPEnabled || required {
+		ur.writeChallenge(w, storedUser)
+		return
+	}
+
+	ur.startSession(w, r, storedUser, nil)
+}
+
+// startSession creates a session of the user and responds its tokens.
+func (ur UserRouter) startSession(w http.ResponseWriter, r *http.Request, u user.User, extra map[string]interface{}) {
+	ls, err := login.New(u.ID, "", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ls.UserAgent = r.UserAgent()
+	ls.IP = remoteHost(r)
+	err = ur.sessions.Create(ls)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ur.writeSession(w, u, ls, extra)
+}
+
+// RefreshHandler exchanges a refresh token for a new access token and the
+// next refresh token of its session. A refresh token used twice ends every
+// session of its family.
+func (ur UserRouter) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	rf := refreshing{}
+	err := json.NewDecoder(r.Body).Decode(&rf)
+	if err != nil {
+		http.Error(w, "Invalid refresh token", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	old, err := ur.sessions.GetByToken(rf.RefreshToken)
+	if err != nil {
+		http.Error(w, login.ErrInvalidToken.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if old.RevokedAt != nil {
+		ur.sessions.RevokeFamily(old.Family)
+		http.Error(w, login.ErrReused.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	if !old.IsActive(now) {
+		http.Error(w, login.ErrInvalidToken.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	u, err := ur.storage.GetByID(old.UserID)
+	if err != nil {
+		http.Error(w, login.ErrInvalidToken.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	next, err := login.New(u.ID, old.Family, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	next.UserAgent = r.UserAgent()
+	next.IP = remoteHost(r)
+	err = ur.sessions.Rotate(old, next)
+	if err == login.ErrReused {
+		ur.sessions.RevokeFamily(old.Family)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	u.CleanPass()
+	ur.writeSession(w, u, next, nil)
+}
+
+// LogoutHandler ends the session of the refresh token given, or else of
+// the access token of the request.
+func (ur UserRouter) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	rf := refreshing{}
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&rf)
+		if err != nil {
+			http.Error(w, "Invalid refresh token", http.StatusBadRequest)
+			return
+		}
+
+		defer r.Body.Close()
+	}
+
+	var sessionID int
+	if rf.RefreshToken != "" {
+		ls, err := ur.sessions.GetByToken(rf.RefreshToken)
+		if err != nil {
+			http.Error(w, login.ErrInvalidToken.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		sessionID = int(ls.ID)
+	} else {
+		token, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil || token == nil || !token.Valid {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		sessionID, err = strconv.Atoi(tenant.Claim(claims, "sid"))
+		if err != nil {
+			http.Error(w, "The token has no session", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := ur.sessions.Revoke(uint(sessionID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// writeSession responds a new access token of the user for the session,
+// with its refresh token and the extra fields given.
+func (ur UserRouter) writeSession(w http.ResponseWriter, u user.User, ls *login.Session, extra map[string]interface{}) {
+	expiresAt := time.Now().Add(login.AccessTTL)
 	claims := jwtauth.Claims{
-		"id":   strconv.Itoa(int(storedUser.ID)),
-		"role": storedUser.Role,
+		"id":   strconv.Itoa(int(u.ID)),
+		"role": u.Role,
+		"sid":  strconv.Itoa(int(ls.ID)),
 	}
+	if u.ClientID != 0 {
+		claims["client_id"] = strconv.Itoa(int(u.ClientID))
+	}
+	claims.SetExpiry(expiresAt)
 
-	_, token, err := tokenAuth.Encode(claims)
+	token, err := tokens.Encode(claims)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	data := map[string]interface{}{
-		"token": token,
-		"user":  storedUser,
+		"token":         token,
+		"expires_at":    expiresAt.UTC(),
+		"refresh_token": ls.Token,
+		"user":          u,
+	}
+	for k, v := range extra {
+		data[k] = v
 	}
 
 	j, err := json.Marshal(data)
@@ -174,8 +535,105 @@ func (ur UserRouter) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// getSessionsHandler response the active sessions of a user.
+func (ur UserRouter) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := ur.sessions.GetActive(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(sessions)
+	if err != nil {
+		http.Error(w, "Failed to parse sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// revokeSessionsHandler ends every session of a user.
+func (ur UserRouter) revokeSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = ur.sessions.RevokeAll(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// revokeSessionHandler ends a session of a user.
+func (ur UserRouter) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessionIDStr := chi.URLParam(r, "sessionId")
+	sessionID, err := strconv.Atoi(sessionIDStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ls, err := ur.sessions.GetByID(uint(sessionID))
+	if err != nil || ls.UserID != uint(id) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	err = ur.sessions.Revoke(ls.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// getLockoutsHandler response the lockouts of accounts and IPs in the
+// last week.
+func (ur UserRouter) getLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := ur.sessions.GetLockouts(time.Now().Add(-lockoutHistory))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	j, err := json.Marshal(lockouts)
+	if err != nil {
+		http.Error(w, "Failed to parse lockouts", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	w.Write(j)
 }
 
@@ -217,7 +675,25 @@ func (ur UserRouter) confirmUserHandler(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
 
-// ForgotPasswordHandler update user, set confirm to true.
+// allowReset checks the reset limits of the IP of the request and of the
+// address, if any, and answers 429 if one is exceeded.
+func (ur UserRouter) allowReset(w http.ResponseWriter, r *http.Request, address string) bool {
+	ok, wait := ur.resetIPs.Allow("reset:" + remoteHost(r))
+	if ok && address != "" {
+		ok, wait = ur.resetAddresses.Allow("reset:" + strings.ToLower(address))
+	}
+
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
+
+// ForgotPasswordHandler emails a link to reset the password. It answers the
+// same whether the address has a user or not.
 func (ur UserRouter) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	u := user.New()
 	err := json.NewDecoder(r.Body).Decode(u)
@@ -226,59 +702,176 @@ func (ur UserRouter) ForgotPasswordHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = ur.storage.RecoverPassword(u.Email)
+	defer r.Body.Close()
+
+	if !ur.allowReset(w, r, u.Email) {
+		return
+	}
+
+	err = ur.storage.RequestReset(u.Email)
+	if err != nil && err != storage.ErrNotFound {
+		log.Println(err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+// ResetPasswordHandler sets a new password with the token of a reset link,
+// and ends the sessions of the user.
+func (ur UserRouter) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	p := user.NewPassword{}
+	err := json.NewDecoder(r.Body).Decode(&p)
+	if err != nil {
+		http.Error(w, "Failed to parse password", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !ur.allowReset(w, r, "") {
+		return
+	}
+
+	userID, err := ur.storage.ResetPassword(p)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	err = ur.sessions.RevokeAll(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
 
 // NewUserRouter inicialize a new user router with each endpoint.
-func NewUserRouter(s user.Storage) (*chi.Mux, UserRouter) {
+func NewUserRouter(s user.Storage, ls login.Storage) (*chi.Mux, UserRouter) {
 	r := chi.NewRouter()
-	ur := UserRouter{storage: s}
+	ur := UserRouter{
+		storage:        s,
+		sessions:       ls,
+		resetAddresses: ratelimit.New(resetAddressAttempts, resetWindow),
+		resetIPs:       ratelimit.New(resetIPAttempts, resetWindow),
+		codes:          ratelimit.New(codeAttempts, codeWindow),
+		accounts:       ratelimit.NewBackoff(accountFailures, lockoutBase, lockoutLimit),
+		ips:            ratelimit.NewBackoff(ipFailures, lockoutBase, lockoutLimit),
+	}
 
-	tokenAuth := jwtauth.New("HS256", []byte(os.Getenv("XD_SIGNING_STRING")), nil)
+	r.Use(tokens.Verifier, tenant.Resolve(tenants))
 
 	// Set endpoints
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Put("/change-password/{id}", ur.confirmUserHandler)
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Put("/change-password/{id}", ur.confirmUserHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
 		auth.Authenticator("admin"),
 	).Get("/", ur.getAllHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
 		auth.Authenticator("admin"),
 	).Post("/", ur.createHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
-		auth.Authenticator("client"),
-	).Get("/{id}", ur.getOneHandler)
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Get("/{id}", ur.getOneHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
 		auth.Authenticator("admin"),
 	).Put("/{id}", ur.updateHandler)
 
 	r.With(
-		jwtauth.Verifier(tokenAuth),
+		tokens.Verifier,
 	).With(
 		auth.Authenticator("admin"),
 	).Delete("/{id}", ur.deleteHandler)
 
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ReadStaff), tenant.Client("clientId")).Get("/client/{clientId}", ur.getStaffHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ManageStaff), tenant.Client("clientId")).Post("/client/{clientId}", ur.inviteHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(auth.Require(auth.ManageStaff), tenant.Client("clientId")).Delete("/client/{clientId}/{id}", ur.removeStaffHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Get("/{id}/sessions", ur.getSessionsHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Delete("/{id}/sessions", ur.revokeSessionsHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Delete("/{id}/sessions/{sessionId}", ur.revokeSessionHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		auth.Authenticator("admin"),
+	).Get("/2fa/policies", ur.getPoliciesHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		auth.Authenticator("admin"),
+	).Get("/lockouts", ur.getLockoutsHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		auth.Authenticator("admin"),
+	).Put("/2fa/policies/{role}", ur.setPolicyHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Post("/{id}/2fa", ur.enrollHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Post("/{id}/2fa/verify", ur.verifyHandler)
+
+	r.With(
+		tokens.Verifier,
+	).With(
+		jwtauth.Authenticator,
+	).With(tenant.User("id")).Delete("/{id}/2fa", ur.disableHandler)
+
 	return r, ur
 }
@@ -4,14 +4,60 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/ratelimit"
+	"gitlab.com/menuxd/api-rest/pkg/user"
 	"gitlab.com/menuxd/api-rest/pkg/waiter"
 )
 
+// Waiter login settings.
+const (
+	// waiterTokenTTL is how long a waiter token lasts.
+	waiterTokenTTL = 30 * time.Minute
+	// pinAttempts are the PINs a client can try each pinWindow.
+	pinAttempts = 5
+	pinWindow   = time.Minute
+)
+
 // WaiterRouter is a router of the waiters.
 type WaiterRouter struct {
 	storage waiter.Storage
+	limiter *ratelimit.Limiter
+}
+
+// waiterErrorStatus returns the HTTP status of a waiter storage error.
+func waiterErrorStatus(err error) int {
+	switch err {
+	case waiter.ErrPINTaken:
+		return http.StatusConflict
+	case storage.ErrInvalidPIN, storage.ErrRequiredField:
+		return http.StatusBadRequest
+	}
+
+	return http.StatusNotFound
+}
+
+// waiterOf returns the waiter of the token of the request, nil if it is not
+// a waiter token.
+func waiterOf(r *http.Request) *uint {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return nil
+	}
+
+	id, err := strconv.Atoi(tenant.Claim(claims, "waiter_id"))
+	if err != nil || id <= 0 {
+		return nil
+	}
+
+	waiterID := uint(id)
+	return &waiterID
 }
 
 // getAllHandler response all the waiters from a client
@@ -75,9 +121,14 @@ func (wr WaiterRouter) createHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.Allowed(r, wt.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	err = wr.storage.Create(wt)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), waiterErrorStatus(err))
 		return
 	}
 
@@ -104,7 +155,7 @@ func (wr WaiterRouter) updateHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = wr.storage.Update(uint(id), wt)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, err.Error(), waiterErrorStatus(err))
 		return
 	}
 
@@ -131,17 +182,84 @@ func (wr WaiterRouter) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
 
+// loginHandler exchanges the PIN of a waiter, entered in a device of its
+// client, for a short-lived token of the waiter. The attempts are limited by
+// client and host, and successful ones count too, so a device can't guess
+// the PINs by logging in between the guesses.
+func (wr WaiterRouter) loginHandler(w http.ResponseWriter, r *http.Request) {
+	l := waiter.Login{}
+	err := json.NewDecoder(r.Body).Decode(&l)
+	if err != nil {
+		http.Error(w, "Invalid login", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if !tenant.Allowed(r, l.ClientID) {
+		http.Error(w, tenant.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
+	key := "pin:" + strconv.Itoa(int(l.ClientID)) + ":" + remoteHost(r)
+	ok, wait := wr.limiter.Allow(key)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	wt, err := wr.storage.GetByPIN(l.ClientID, l.PIN)
+	if err != nil {
+		http.Error(w, storage.ErrInvalidPIN.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	expiresAt := time.Now().Add(waiterTokenTTL)
+	claims := jwtauth.Claims{
+		"role":      user.Waiter,
+		"client_id": strconv.Itoa(int(wt.ClientID)),
+		"waiter_id": strconv.Itoa(int(wt.ID)),
+	}
+	claims.SetExpiry(expiresAt)
+
+	token, err := tokens.Encode(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"token":      token,
+		"waiter":     wt,
+		"expires_at": expiresAt.UTC(),
+	}
+
+	j, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
 // NewWaiterRouter inicialize a new router with each endpoint
 func NewWaiterRouter(s waiter.Storage) *chi.Mux {
 	r := chi.NewRouter()
-	wr := WaiterRouter{storage: s}
+	wr := WaiterRouter{storage: s, limiter: ratelimit.New(pinAttempts, pinWindow)}
+
+	r.Use(tenant.Resolve(tenants))
 
 	// Set endpoints
-	r.Get("/client/{clientId}", wr.getAllHandler)
-	r.Post("/", wr.createHandler)
-	r.Get("/{id}", wr.getOneHandler)
-	r.Put("/{id}", wr.updateHandler)
-	r.Delete("/{id}", wr.deleteHandler)
+	r.With(auth.Require(auth.ReadStaff), tenant.Client("clientId")).Get("/client/{clientId}", wr.getAllHandler)
+	r.With(auth.Require(auth.ManageStaff)).Post("/", wr.createHandler)
+	r.With(auth.Require(auth.LoginWaiters)).Post("/login", wr.loginHandler)
+	r.With(auth.Require(auth.ReadStaff), tenant.Owned("id", tenant.Waiters)).Get("/{id}", wr.getOneHandler)
+	r.With(auth.Require(auth.ManageStaff), tenant.Owned("id", tenant.Waiters)).Put("/{id}", wr.updateHandler)
+	r.With(auth.Require(auth.ManageStaff), tenant.Owned("id", tenant.Waiters)).Delete("/{id}", wr.deleteHandler)
 
 	return r
 }
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/waiter"
+)
+
+// fakeWaiters has the waiter 4 of the client 1, with the PIN 1234.
+type fakeWaiters struct {
+	waiter.Storage
+}
+
+func (fakeWaiters) GetByPIN(clientID uint, pin string) (waiter.Waiter, error) {
+	if clientID != 1 || pin != "1234" {
+		return waiter.Waiter{}, storage.ErrNotFound
+	}
+
+	w := waiter.Waiter{Name: "Ana", ClientID: 1}
+	w.ID = 4
+	return w, nil
+}
+
+func TestWaiterLogin(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, device, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "role": "manager", "client_id": "1"})
+	h := jwtauth.Verifier(tokenAuth)(jwtauth.Authenticator(NewWaiterRouter(fakeWaiters{})))
+
+	login := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+device)
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	loginFrom := func(host, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+device)
+		r.RemoteAddr = host + ":1234"
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	assert := assert.New(t)
+
+	w := login(`{"client_id":1,"pin":"1234"}`)
+	assert.Equal(http.StatusOK, w.Code)
+
+	data := struct {
+		Token string `json:"token"`
+	}{}
+	assert.Nil(json.NewDecoder(w.Body).Decode(&data))
+
+	token, err := tokenAuth.Decode(data.Token)
+	assert.Nil(err)
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal("4", claims["waiter_id"])
+	assert.Equal("waiter", claims["role"])
+	assert.Equal("1", claims["client_id"])
+	assert.NotNil(claims["exp"])
+
+	assert.Equal(http.StatusForbidden, login(`{"client_id":3,"pin":"1234"}`).Code)
+
+	// The successful login counts as one of the attempts.
+	for i := 1; i < pinAttempts; i++ {
+		assert.Equal(http.StatusUnauthorized, login(`{"client_id":1,"pin":"0000"}`).Code)
+	}
+
+	w = login(`{"client_id":1,"pin":"1234"}`)
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(w.Header().Get("Retry-After"))
+
+	assert.Equal(http.StatusOK, loginFrom("192.0.2.9", `{"client_id":1,"pin":"1234"}`).Code)
+}
+
+// fakePayments keeps the payments created, and has every other one of the
+// bill 10.
+type fakePayments struct {
+	payment.Storage
+	created *[]payment.Payment
+}
+
+func (s fakePayments) Create(p *payment.Payment) error {
+	*s.created = append(*s.created, *p)
+	return nil
+}
+
+func (fakePayments) GetByID(id uint) (payment.Payment, error) {
+	p := payment.Payment{BillID: 10, ClientID: 1}
+	p.ID = id
+	return p, nil
+}
+
+// TestWaiterFromToken checks that payments are recorded with the waiter of
+// the token, and with none when the token has no waiter.
+func TestWaiterFromToken(t *testing.T) {
+	stored := tenants
+	tenants = fakeTenants{}
+	defer func() { tenants = stored }()
+
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, manager, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "role": "manager", "client_id": "1"})
+	_, waiterToken, _ := tokenAuth.Encode(jwtauth.Claims{"exp": jwtauth.ExpireIn(time.Hour), "role": "waiter", "client_id": "1", "waiter_id": "4"})
+
+	created := []payment.Payment{}
+	h := jwtauth.Verifier(tokenAuth)(jwtauth.Authenticator(NewPaymentRouter(fakePayments{created: &created})))
+
+	do := func(token, path, body string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert := assert.New(t)
+
+	assert.Equal(http.StatusCreated, do(manager, "/", `{"bill_id":10,"amount":100,"waiter_id":4}`))
+	assert.Equal(http.StatusCreated, do(manager, "/10/refund", `{"amount":100,"waiter_id":4}`))
+	assert.Equal(http.StatusCreated, do(waiterToken, "/", `{"bill_id":10,"amount":100,"waiter_id":9}`))
+
+	if assert.Len(created, 3) {
+		assert.Nil(created[0].WaiterID)
+		assert.Nil(created[1].WaiterID)
+		if assert.NotNil(created[2].WaiterID) {
+			assert.Equal(uint(4), *created[2].WaiterID)
+		}
+	}
+}
@@ -10,14 +10,21 @@ import (
 type AdStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to AdStorage.
 func (s *AdStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the AdStorage joined to a unit of work.
+func (s AdStorage) WithTx(tx *Tx) AdStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new ad.
 func (s AdStorage) Create(a *ad.Ad) error {
 	s.setContext()
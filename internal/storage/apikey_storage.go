@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+)
+
+// APIKeyStorage storage to the API key model.
+type APIKeyStorage struct {
+	session *Session
+	db      *gorm.DB
+	tx      *Tx
+}
+
+// setContext initialize the context to APIKeyStorage.
+func (s *APIKeyStorage) setContext() {
+	s.session = sessionOf(s.tx)
+	s.db = s.session.Client
+}
+
+// WithTx returns the APIKeyStorage joined to a unit of work.
+func (s APIKeyStorage) WithTx(tx *Tx) APIKeyStorage {
+	s.tx = tx
+	return s
+}
+
+// Create stores a new API key.
+func (s APIKeyStorage) Create(k *apikey.Key) error {
+	s.setContext()
+
+	k.LastUsedAt = nil
+	k.RevokedAt = nil
+
+	err := s.db.Create(k).Error
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// Revoke stops an API key from being accepted.
+func (s APIKeyStorage) Revoke(id uint) error {
+	s.setContext()
+
+	err := s.db.Model(&apikey.Key{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
+
+// GetAll returns the API keys of a client.
+func (s APIKeyStorage) GetAll(clientID uint) (apikey.Keys, error) {
+	s.setContext()
+
+	keys := apikey.Keys{}
+	err := s.db.Order("id").Find(&keys, "client_id = ?", clientID).Error
+	if err != nil {
+		return apikey.Keys{}, ErrNotFound
+	}
+
+	return keys, nil
+}
+
+// GetByKey returns the API key with the hash of the key.
+func (s APIKeyStorage) GetByKey(key string) (apikey.Key, error) {
+	s.setContext()
+
+	k := apikey.Key{}
+	err := s.db.First(&k, "key_hash = ?", apikey.HashKey(key)).Error
+	if err != nil {
+		return apikey.Key{}, ErrNotFound
+	}
+
+	return k, nil
+}
+
+// Touch records the use of an API key, at most once each TouchEvery.
+func (s APIKeyStorage) Touch(id uint, now time.Time) error {
+	s.setContext()
+
+	err := s.db.Model(&apikey.Key{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-apikey.TouchEvery)).
+		Update("last_used_at", now).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
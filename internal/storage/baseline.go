@@ -0,0 +1,962 @@
+package storage
+
+// The baseline is the schema AutoMigrate created for the models before the
+// versioned migrations, and the one of a new database.
+
+const baselinePostgres = `
+CREATE TABLE "ads" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"title" text,
+	"active" boolean,
+	"picture" text,
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_ads_deleted_at ON "ads"(deleted_at);
+
+CREATE TABLE "bills" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"subtotal" numeric(14,2),
+	"service_charge" numeric(14,2),
+	"net" numeric(14,2),
+	"tax" numeric(14,2),
+	"gross" numeric(14,2),
+	"rounding" numeric(14,2),
+	"value" numeric(14,2),
+	"paid" boolean,
+	"table_id" integer,
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_bills_deleted_at ON "bills"(deleted_at);
+
+CREATE TABLE "lines" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"bill_id" integer,
+	"order_id" integer,
+	"item_id" integer,
+	"dish_id" integer,
+	"name" text,
+	"half" boolean,
+	"mount" integer,
+	"unit_price" numeric(14,2),
+	"extras" text,
+	"extras_unit" numeric(14,2),
+	"total" numeric(14,2),
+	"tax_rate" text,
+	"part_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_lines_deleted_at ON "lines"(deleted_at);
+
+CREATE TABLE "parts" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"bill_id" integer,
+	"label" text,
+	"value" numeric(14,2),
+	"paid" boolean,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_parts_deleted_at ON "parts"(deleted_at);
+
+CREATE TABLE "tax_lines" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"bill_id" integer,
+	"rate" text,
+	"net" numeric(14,2),
+	"tax" numeric(14,2),
+	"gross" numeric(14,2),
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_tax_lines_deleted_at ON "tax_lines"(deleted_at);
+
+CREATE TABLE "payments" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"bill_id" integer,
+	"part_id" integer,
+	"client_id" integer,
+	"kind" text DEFAULT 'charge',
+	"method" text,
+	"amount" numeric(14,2),
+	"tendered" numeric(14,2),
+	"change" numeric(14,2),
+	"tip" numeric(14,2),
+	"waiter_id" integer,
+	"refund_of" integer,
+	"note" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_payments_deleted_at ON "payments"(deleted_at);
+
+CREATE TABLE "register_sessions" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"client_id" integer,
+	"opened_at" timestamp with time zone,
+	"closed_at" timestamp with time zone,
+	"opening_cash" numeric(14,2),
+	"expected_cash" numeric(14,2),
+	"counted_cash" numeric(14,2),
+	"difference" numeric(14,2),
+	"note" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_register_sessions_deleted_at ON "register_sessions"(deleted_at);
+
+CREATE TABLE "categories" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"title" text,
+	"picture" text,
+	"active" boolean DEFAULT true,
+	"priority" boolean DEFAULT false,
+	"suggested1" integer DEFAULT null,
+	"suggested2" integer DEFAULT null,
+	"suggested3" integer DEFAULT null,
+	"position" integer DEFAULT 1,
+	"station" text DEFAULT 'kitchen',
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_categories_deleted_at ON "categories"(deleted_at);
+
+CREATE TABLE "clients" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"name" text,
+	"picture" text DEFAULT 'http://localhost:8080/public/client-default.png',
+	"active" boolean DEFAULT true,
+	"user_id" integer,
+	"timezone" text DEFAULT 'America/Asuncion',
+	"expire_at" timestamp with time zone,
+	"service_charge" integer DEFAULT 0,
+	"rounding_unit" numeric(14,2) DEFAULT 0,
+	"rounding_mode" text DEFAULT 'nearest',
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_clients_deleted_at ON "clients"(deleted_at);
+
+CREATE TABLE "dishes" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"name" text,
+	"description" text,
+	"available" boolean DEFAULT true,
+	"price" numeric(14,2),
+	"is_half" boolean DEFAULT false,
+	"half_price" numeric(14,2) DEFAULT null,
+	"pictures" text,
+	"suggested" boolean DEFAULT false,
+	"tax_rate" text DEFAULT 'vat10',
+	"category_id" integer,
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_dishes_deleted_at ON "dishes"(deleted_at);
+
+CREATE TABLE "ingredients" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"dish_id" integer,
+	"name" text,
+	"order_id" integer,
+	"active" boolean,
+	"price" numeric(14,2),
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_ingredients_deleted_at ON "ingredients"(deleted_at);
+
+CREATE TABLE "promotions" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"title" text,
+	"picture" text,
+	"start_at" text,
+	"end_at" text,
+	"days" text,
+	"dish_id" integer,
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_promotions_deleted_at ON "promotions"(deleted_at);
+
+CREATE TABLE "tables" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"number" integer,
+	"type" text DEFAULT 'table',
+	"available" boolean DEFAULT true,
+	"client_id" integer,
+	"calls_waiter" boolean,
+	"asks_for_bill" boolean,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_tables_deleted_at ON "tables"(deleted_at);
+
+CREATE TABLE "users" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"email" text,
+	"role" text DEFAULT 'client',
+	"client_id" integer,
+	"image_url" text,
+	"confirmed" boolean DEFAULT false,
+	"password" text,
+	"totp_secret" text,
+	"totp_enabled" boolean DEFAULT false,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_users_deleted_at ON "users"(deleted_at);
+CREATE UNIQUE INDEX uix_users_email ON "users"("email");
+
+CREATE TABLE "waiters" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"name" text,
+	"pin_hash" text,
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_waiters_pin_hash ON "waiters"(pin_hash);
+CREATE INDEX idx_waiters_deleted_at ON "waiters"(deleted_at);
+
+CREATE TABLE "orders" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"client_id" integer,
+	"table_id" integer,
+	"canceled" boolean,
+	"bill_id" integer,
+	"waiter_id" integer,
+	"status" text DEFAULT 'received',
+	"accepted_at" timestamp with time zone,
+	"preparing_at" timestamp with time zone,
+	"ready_at" timestamp with time zone,
+	"served_at" timestamp with time zone,
+	"paid_at" timestamp with time zone,
+	"cancelled_at" timestamp with time zone,
+	"rejected_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_orders_deleted_at ON "orders"(deleted_at);
+
+CREATE TABLE "items" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"order_id" integer,
+	"mount" integer,
+	"active" boolean DEFAULT true,
+	"ready" boolean DEFAULT true,
+	"dish_id" integer,
+	"takeaway" boolean,
+	"half" boolean DEFAULT false,
+	"status" text DEFAULT 'received',
+	"accepted_at" timestamp with time zone,
+	"preparing_at" timestamp with time zone,
+	"ready_at" timestamp with time zone,
+	"served_at" timestamp with time zone,
+	"paid_at" timestamp with time zone,
+	"cancelled_at" timestamp with time zone,
+	"rejected_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_items_deleted_at ON "items"(deleted_at);
+
+CREATE TABLE "ingredient_selecteds" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"item_id" integer,
+	"active" boolean,
+	"ingredient_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_ingredient_selecteds_deleted_at ON "ingredient_selecteds"(deleted_at);
+
+CREATE TABLE "clicks" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"type" integer,
+	"type_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_clicks_deleted_at ON "clicks"(deleted_at);
+
+CREATE TABLE "stays" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"time" numeric,
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_stays_deleted_at ON "stays"(deleted_at);
+
+CREATE TABLE "questions" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"text" text,
+	"main" boolean,
+	"client_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_questions_deleted_at ON "questions"(deleted_at);
+
+CREATE TABLE "ratings" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"question_id" integer,
+	"score" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_ratings_deleted_at ON "ratings"(deleted_at);
+
+CREATE TABLE "notifications" (
+	"id" serial,
+	"type" integer,
+	"message" text,
+	"picture" text,
+	"date" timestamp with time zone,
+	"client_id" integer,
+	"active" boolean,
+	"table_id" integer,
+	"order_id" integer,
+	"item_id" integer,
+	"status" text,
+	"acked_at" timestamp with time zone,
+	"acked_by" integer,
+	PRIMARY KEY ("id")
+);
+
+CREATE TABLE "devices" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"name" text,
+	"client_id" integer,
+	"table_id" integer,
+	"code_hash" text,
+	"code_expires_at" timestamp with time zone,
+	"paired_at" timestamp with time zone,
+	"revoked_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_devices_deleted_at ON "devices"(deleted_at);
+CREATE INDEX idx_devices_code_hash ON "devices"(code_hash);
+
+CREATE TABLE "api_keys" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"name" text,
+	"client_id" integer,
+	"key_hash" text,
+	"hint" text,
+	"scopes" text,
+	"last_used_at" timestamp with time zone,
+	"revoked_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_api_keys_deleted_at ON "api_keys"(deleted_at);
+CREATE INDEX idx_api_keys_client_id ON "api_keys"(client_id);
+CREATE UNIQUE INDEX uix_api_keys_key_hash ON "api_keys"(key_hash);
+
+CREATE TABLE "sessions" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"user_id" integer,
+	"family" text,
+	"token_hash" text,
+	"user_agent" text,
+	"ip" text,
+	"expires_at" timestamp with time zone,
+	"revoked_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_sessions_deleted_at ON "sessions"(deleted_at);
+CREATE INDEX idx_sessions_user_id ON "sessions"(user_id);
+CREATE INDEX idx_sessions_family ON "sessions"("family");
+CREATE UNIQUE INDEX uix_sessions_token_hash ON "sessions"(token_hash);
+
+CREATE TABLE "lockouts" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"email" text,
+	"ip" text,
+	"reason" text,
+	"until" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_lockouts_deleted_at ON "lockouts"(deleted_at);
+CREATE INDEX idx_lockouts_email ON "lockouts"("email");
+
+CREATE TABLE "resets" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"user_id" integer,
+	"token_hash" text,
+	"expires_at" timestamp with time zone,
+	"used_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_resets_deleted_at ON "resets"(deleted_at);
+CREATE INDEX idx_resets_user_id ON "resets"(user_id);
+CREATE UNIQUE INDEX uix_resets_token_hash ON "resets"(token_hash);
+
+CREATE TABLE "recovery_codes" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"user_id" integer,
+	"code_hash" text,
+	"used_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_recovery_codes_deleted_at ON "recovery_codes"(deleted_at);
+CREATE INDEX idx_recovery_codes_user_id ON "recovery_codes"(user_id);
+CREATE INDEX idx_recovery_codes_code_hash ON "recovery_codes"(code_hash);
+
+CREATE TABLE "two_factor_policies" (
+	"role" text,
+	"required" boolean,
+	PRIMARY KEY ("role")
+);
+`
+
+const baselineSQLite = `
+CREATE TABLE "ads" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"title" varchar(255),
+	"active" bool,
+	"picture" varchar(255),
+	"client_id" integer
+);
+CREATE INDEX idx_ads_deleted_at ON "ads"(deleted_at);
+
+CREATE TABLE "bills" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"subtotal" numeric(14,2),
+	"service_charge" numeric(14,2),
+	"net" numeric(14,2),
+	"tax" numeric(14,2),
+	"gross" numeric(14,2),
+	"rounding" numeric(14,2),
+	"value" numeric(14,2),
+	"paid" bool,
+	"table_id" integer,
+	"client_id" integer
+);
+CREATE INDEX idx_bills_deleted_at ON "bills"(deleted_at);
+
+CREATE TABLE "lines" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"bill_id" integer,
+	"order_id" integer,
+	"item_id" integer,
+	"dish_id" integer,
+	"name" varchar(255),
+	"half" bool,
+	"mount" integer,
+	"unit_price" numeric(14,2),
+	"extras" varchar(255),
+	"extras_unit" numeric(14,2),
+	"total" numeric(14,2),
+	"tax_rate" varchar(255),
+	"part_id" integer
+);
+CREATE INDEX idx_lines_deleted_at ON "lines"(deleted_at);
+
+CREATE TABLE "parts" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"bill_id" integer,
+	"label" varchar(255),
+	"value" numeric(14,2),
+	"paid" bool
+);
+CREATE INDEX idx_parts_deleted_at ON "parts"(deleted_at);
+
+CREATE TABLE "tax_lines" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"bill_id" integer,
+	"rate" varchar(255),
+	"net" numeric(14,2),
+	"tax" numeric(14,2),
+	"gross" numeric(14,2)
+);
+CREATE INDEX idx_tax_lines_deleted_at ON "tax_lines"(deleted_at);
+
+CREATE TABLE "payments" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"bill_id" integer,
+	"part_id" integer,
+	"client_id" integer,
+	"kind" varchar(255) DEFAULT 'charge',
+	"method" varchar(255),
+	"amount" numeric(14,2),
+	"tendered" numeric(14,2),
+	"change" numeric(14,2),
+	"tip" numeric(14,2),
+	"waiter_id" integer,
+	"refund_of" integer,
+	"note" varchar(255)
+);
+CREATE INDEX idx_payments_deleted_at ON "payments"(deleted_at);
+
+CREATE TABLE "register_sessions" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"client_id" integer,
+	"opened_at" datetime,
+	"closed_at" datetime,
+	"opening_cash" numeric(14,2),
+	"expected_cash" numeric(14,2),
+	"counted_cash" numeric(14,2),
+	"difference" numeric(14,2),
+	"note" varchar(255)
+);
+CREATE INDEX idx_register_sessions_deleted_at ON "register_sessions"(deleted_at);
+
+CREATE TABLE "categories" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"title" varchar(255),
+	"picture" varchar(255),
+	"active" bool DEFAULT true,
+	"priority" bool DEFAULT false,
+	"suggested1" integer DEFAULT null,
+	"suggested2" integer DEFAULT null,
+	"suggested3" integer DEFAULT null,
+	"position" integer DEFAULT 1,
+	"station" varchar(255) DEFAULT 'kitchen',
+	"client_id" integer
+);
+CREATE INDEX idx_categories_deleted_at ON "categories"(deleted_at);
+
+CREATE TABLE "clients" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"name" varchar(255),
+	"picture" varchar(255) DEFAULT 'http://localhost:8080/public/client-default.png',
+	"active" bool DEFAULT true,
+	"user_id" integer,
+	"timezone" varchar(255) DEFAULT 'America/Asuncion',
+	"expire_at" datetime,
+	"service_charge" integer DEFAULT 0,
+	"rounding_unit" numeric(14,2) DEFAULT 0,
+	"rounding_mode" varchar(255) DEFAULT 'nearest'
+);
+CREATE INDEX idx_clients_deleted_at ON "clients"(deleted_at);
+
+CREATE TABLE "dishes" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"name" varchar(255),
+	"description" varchar(255),
+	"available" bool DEFAULT true,
+	"price" numeric(14,2),
+	"is_half" bool DEFAULT false,
+	"half_price" numeric(14,2) DEFAULT null,
+	"pictures" varchar(255),
+	"suggested" bool DEFAULT false,
+	"tax_rate" varchar(255) DEFAULT 'vat10',
+	"category_id" integer,
+	"client_id" integer
+);
+CREATE INDEX idx_dishes_deleted_at ON "dishes"(deleted_at);
+
+CREATE TABLE "ingredients" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"dish_id" integer,
+	"name" varchar(255),
+	"order_id" integer,
+	"active" bool,
+	"price" numeric(14,2)
+);
+CREATE INDEX idx_ingredients_deleted_at ON "ingredients"(deleted_at);
+
+CREATE TABLE "promotions" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"title" varchar(255),
+	"picture" varchar(255),
+	"start_at" varchar(255),
+	"end_at" varchar(255),
+	"days" varchar(255),
+	"dish_id" integer,
+	"client_id" integer
+);
+CREATE INDEX idx_promotions_deleted_at ON "promotions"(deleted_at);
+
+CREATE TABLE "tables" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"number" integer,
+	"type" varchar(255) DEFAULT 'table',
+	"available" bool DEFAULT true,
+	"client_id" integer,
+	"calls_waiter" bool,
+	"asks_for_bill" bool
+);
+CREATE INDEX idx_tables_deleted_at ON "tables"(deleted_at);
+
+CREATE TABLE "users" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"email" varchar(255),
+	"role" varchar(255) DEFAULT 'client',
+	"client_id" integer,
+	"image_url" varchar(255),
+	"confirmed" bool DEFAULT false,
+	"password" varchar(255),
+	"totp_secret" varchar(255),
+	"totp_enabled" bool DEFAULT false
+);
+CREATE INDEX idx_users_deleted_at ON "users"(deleted_at);
+CREATE UNIQUE INDEX uix_users_email ON "users"("email");
+
+CREATE TABLE "waiters" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"name" varchar(255),
+	"pin_hash" varchar(255),
+	"client_id" integer
+);
+CREATE INDEX idx_waiters_deleted_at ON "waiters"(deleted_at);
+CREATE INDEX idx_waiters_pin_hash ON "waiters"(pin_hash);
+
+CREATE TABLE "orders" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"client_id" integer,
+	"table_id" integer,
+	"canceled" bool,
+	"bill_id" integer,
+	"waiter_id" integer,
+	"status" varchar(255) DEFAULT 'received',
+	"accepted_at" datetime,
+	"preparing_at" datetime,
+	"ready_at" datetime,
+	"served_at" datetime,
+	"paid_at" datetime,
+	"cancelled_at" datetime,
+	"rejected_at" datetime
+);
+CREATE INDEX idx_orders_deleted_at ON "orders"(deleted_at);
+
+CREATE TABLE "items" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"order_id" integer,
+	"mount" integer,
+	"active" bool DEFAULT true,
+	"ready" bool DEFAULT true,
+	"dish_id" integer,
+	"takeaway" bool,
+	"half" bool DEFAULT false,
+	"status" varchar(255) DEFAULT 'received',
+	"accepted_at" datetime,
+	"preparing_at" datetime,
+	"ready_at" datetime,
+	"served_at" datetime,
+	"paid_at" datetime,
+	"cancelled_at" datetime,
+	"rejected_at" datetime
+);
+CREATE INDEX idx_items_deleted_at ON "items"(deleted_at);
+
+CREATE TABLE "ingredient_selecteds" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"item_id" integer,
+	"active" bool,
+	"ingredient_id" integer
+);
+CREATE INDEX idx_ingredient_selecteds_deleted_at ON "ingredient_selecteds"(deleted_at);
+
+CREATE TABLE "clicks" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"type" integer,
+	"type_id" integer
+);
+CREATE INDEX idx_clicks_deleted_at ON "clicks"(deleted_at);
+
+CREATE TABLE "stays" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"time" real,
+	"client_id" integer
+);
+CREATE INDEX idx_stays_deleted_at ON "stays"(deleted_at);
+
+CREATE TABLE "questions" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"text" varchar(255),
+	"main" bool,
+	"client_id" integer
+);
+CREATE INDEX idx_questions_deleted_at ON "questions"(deleted_at);
+
+CREATE TABLE "ratings" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"question_id" integer,
+	"score" integer
+);
+CREATE INDEX idx_ratings_deleted_at ON "ratings"(deleted_at);
+
+CREATE TABLE "notifications" (
+	"id" integer primary key autoincrement,
+	"type" integer,
+	"message" varchar(255),
+	"picture" varchar(255),
+	"date" datetime,
+	"client_id" integer,
+	"active" bool,
+	"table_id" integer,
+	"order_id" integer,
+	"item_id" integer,
+	"status" varchar(255),
+	"acked_at" datetime,
+	"acked_by" integer
+);
+
+CREATE TABLE "devices" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"name" varchar(255),
+	"client_id" integer,
+	"table_id" integer,
+	"code_hash" varchar(255),
+	"code_expires_at" datetime,
+	"paired_at" datetime,
+	"revoked_at" datetime
+);
+CREATE INDEX idx_devices_deleted_at ON "devices"(deleted_at);
+CREATE INDEX idx_devices_code_hash ON "devices"(code_hash);
+
+CREATE TABLE "api_keys" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"name" varchar(255),
+	"client_id" integer,
+	"key_hash" varchar(255),
+	"hint" varchar(255),
+	"scopes" text,
+	"last_used_at" datetime,
+	"revoked_at" datetime
+);
+CREATE INDEX idx_api_keys_client_id ON "api_keys"(client_id);
+CREATE INDEX idx_api_keys_deleted_at ON "api_keys"(deleted_at);
+CREATE UNIQUE INDEX uix_api_keys_key_hash ON "api_keys"(key_hash);
+
+CREATE TABLE "sessions" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"user_id" integer,
+	"family" varchar(255),
+	"token_hash" varchar(255),
+	"user_agent" varchar(255),
+	"ip" varchar(255),
+	"expires_at" datetime,
+	"revoked_at" datetime
+);
+CREATE INDEX idx_sessions_deleted_at ON "sessions"(deleted_at);
+CREATE INDEX idx_sessions_user_id ON "sessions"(user_id);
+CREATE INDEX idx_sessions_family ON "sessions"("family");
+CREATE UNIQUE INDEX uix_sessions_token_hash ON "sessions"(token_hash);
+
+CREATE TABLE "lockouts" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"email" varchar(255),
+	"ip" varchar(255),
+	"reason" varchar(255),
+	"until" datetime
+);
+CREATE INDEX idx_lockouts_deleted_at ON "lockouts"(deleted_at);
+CREATE INDEX idx_lockouts_email ON "lockouts"("email");
+
+CREATE TABLE "resets" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"user_id" integer,
+	"token_hash" varchar(255),
+	"expires_at" datetime,
+	"used_at" datetime
+);
+CREATE INDEX idx_resets_deleted_at ON "resets"(deleted_at);
+CREATE INDEX idx_resets_user_id ON "resets"(user_id);
+CREATE UNIQUE INDEX uix_resets_token_hash ON "resets"(token_hash);
+
+CREATE TABLE "recovery_codes" (
+	"id" integer primary key autoincrement,
+	"created_at" datetime,
+	"updated_at" datetime,
+	"deleted_at" datetime,
+	"user_id" integer,
+	"code_hash" varchar(255),
+	"used_at" datetime
+);
+CREATE INDEX idx_recovery_codes_code_hash ON "recovery_codes"(code_hash);
+CREATE INDEX idx_recovery_codes_deleted_at ON "recovery_codes"(deleted_at);
+CREATE INDEX idx_recovery_codes_user_id ON "recovery_codes"(user_id);
+
+CREATE TABLE "two_factor_policies" (
+	"role" varchar(255),
+	"required" bool,
+	PRIMARY KEY ("role")
+);
+`
+
+const baselineDown = `
+DROP TABLE "two_factor_policies";
+DROP TABLE "recovery_codes";
+DROP TABLE "resets";
+DROP TABLE "lockouts";
+DROP TABLE "sessions";
+DROP TABLE "api_keys";
+DROP TABLE "devices";
+DROP TABLE "notifications";
+DROP TABLE "ratings";
+DROP TABLE "questions";
+DROP TABLE "stays";
+DROP TABLE "clicks";
+DROP TABLE "ingredient_selecteds";
+DROP TABLE "items";
+DROP TABLE "orders";
+DROP TABLE "waiters";
+DROP TABLE "users";
+DROP TABLE "tables";
+DROP TABLE "promotions";
+DROP TABLE "ingredients";
+DROP TABLE "dishes";
+DROP TABLE "clients";
+DROP TABLE "categories";
+DROP TABLE "register_sessions";
+DROP TABLE "payments";
+DROP TABLE "tax_lines";
+DROP TABLE "parts";
+DROP TABLE "lines";
+DROP TABLE "bills";
+DROP TABLE "ads";
+`
@@ -4,59 +4,241 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
 )
 
 // BillStorage storage to the bill model
 type BillStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to BillStorage
 func (s *BillStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
-// Create create a new bill
+// WithTx returns the BillStorage joined to a unit of work
+func (s BillStorage) WithTx(tx *Tx) BillStorage {
+	s.tx = tx
+	return s
+}
+
+// Create create a new bill with its lines and links the billed orders to
+// it, in a unit of work. It fails with bill.ErrAlreadyBilled, storing
+// nothing, if another bill took any of the orders first
 func (s BillStorage) Create(b *bill.Bill) error {
-	s.setContext()
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		ids := []uint{}
+		seen := map[uint]bool{}
+		for _, o := range b.Orders {
+			if !seen[o.ID] {
+				seen[o.ID] = true
+				ids = append(ids, o.ID)
+			}
+		}
+
+		err := s.db.Create(b).Error
+		if err != nil {
+			return ErrNotInsert
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		linked := s.db.Model(&order.Order{}).Where("id IN (?)", ids).
+			Where("bill_id IS NULL").Update("bill_id", b.ID)
+		if linked.Error != nil {
+			return ErrNotUpdate
+		}
+
+		if linked.RowsAffected != int64(len(ids)) {
+			return bill.ErrAlreadyBilled
+		}
+
+		return nil
+	})
+}
+
+// Split replaces the parts of a bill by ID, in a unit of work that locks
+// the bill
+func (s BillStorage) Split(id uint, parts []bill.Part) error {
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		err := s.lock(id)
+		if err != nil {
+			return err
+		}
+
+		b := bill.Bill{}
+		err = s.db.First(&b, "id = ?", id).Error
+		if err != nil {
+			return ErrNotFound
+		}
+
+		var payments int
+		err = s.db.Model(&payment.Payment{}).Where("bill_id = ?", id).
+			Count(&payments).Error
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if b.Paid {
+			return bill.ErrAlreadyPaid
+		}
+
+		if payments > 0 {
+			return bill.ErrHasPayments
+		}
+
+		err = s.db.Unscoped().Delete(&bill.Part{}, "bill_id = ?", id).Error
+		if err != nil {
+			return ErrNotDelete
+		}
+
+		err = s.db.Model(&bill.Line{}).Where("bill_id = ?", id).
+			Update("part_id", gorm.Expr("NULL")).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		for _, p := range parts {
+			p.ID = 0
+			p.BillID = id
+			err = s.db.Create(&p).Error
+			if err != nil {
+				return ErrNotInsert
+			}
+
+			ids := []uint{}
+			for _, l := range p.Lines {
+				ids = append(ids, l.ID)
+			}
+
+			if len(ids) == 0 {
+				continue
+			}
+
+			err = s.db.Model(&bill.Line{}).Where("bill_id = ?", id).
+				Where("id IN (?)", ids).Update("part_id", p.ID).Error
+			if err != nil {
+				return ErrNotUpdate
+			}
+		}
+
+		return nil
+	})
+}
+
+// lock locks the row of a bill until the unit of work ends, so the writes
+// that check its balance or its payments run one at a time. SQLite has a
+// single connection, that already runs the units of work one at a time
+func (s BillStorage) lock(id uint) error {
+	if s.db.Dialect().GetName() != Postgres {
+		return nil
+	}
 
-	err := s.db.Create(b).Error
+	err := s.db.Set("gorm:query_option", "FOR UPDATE").
+		First(&bill.Bill{}, "id = ?", id).Error
 	if err != nil {
-		return ErrNotInsert
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-// Update update bill by ID
-func (s BillStorage) Update(id uint, b *bill.Bill) error {
-	s.setContext()
-
-	updates := map[string]interface{}{
-		"value": b.Value,
-		"paid":  b.Paid,
+// reconcile persists whether a bill and its parts are paid, the served
+// orders of a bill that just got paid are moved to paid
+func (s BillStorage) reconcile(b bill.Bill, wasPaid bool) error {
+	for _, p := range b.Parts {
+		err := s.db.Model(&bill.Part{}).Where("id = ?", p.ID).
+			Update("paid", p.Paid).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
 	}
 
-	err := s.db.Model(&bill.Bill{}).Where("id = ?", id).Updates(updates).Error
+	err := s.db.Model(&bill.Bill{}).Where("id = ?", b.ID).
+		Update("paid", b.Paid).Error
 	if err != nil {
 		return ErrNotUpdate
 	}
 
+	if !b.Paid || wasPaid {
+		return nil
+	}
+
+	orders := []order.Order{}
+	err = s.db.Find(&orders, "bill_id = ?", b.ID).Error
+	if err != nil {
+		return ErrNotFound
+	}
+
+	ors := OrderStorage{}.WithTx(s.tx)
+	for _, o := range orders {
+		if !o.CanTransition(order.Paid) {
+			continue
+		}
+
+		_, err = ors.TransitionOrder(o.ID, order.Paid)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// Delete remove a bill by ID
+// Delete remove a bill by ID and releases its orders in a unit of work that
+// locks the bill, a bill with payments can't be removed
 func (s BillStorage) Delete(id uint) error {
-	s.setContext()
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
 
-	err := s.db.Delete(&bill.Bill{}, "id ").Error
-	if err != nil {
-		return ErrNotDelete
-	}
+		err := s.lock(id)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		var payments int
+		err = s.db.Model(&payment.Payment{}).Where("bill_id = ?", id).
+			Count(&payments).Error
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if payments > 0 {
+			return bill.ErrHasPayments
+		}
+
+		err = s.db.Delete(&bill.Bill{}, "id = ?", id).Error
+		if err != nil {
+			return ErrNotDelete
+		}
+
+		err = s.db.Delete(&bill.Part{}, "bill_id = ?", id).Error
+		if err != nil {
+			return ErrNotDelete
+		}
+
+		err = s.db.Model(&order.Order{}).Where("bill_id = ?", id).
+			Update("bill_id", gorm.Expr("NULL")).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		return nil
+	})
 }
 
 // GetAll returns all stored bills
@@ -72,7 +254,7 @@ func (s BillStorage) GetAll(clientId uint) (bill.Bills, error) {
 	return bills, nil
 }
 
-// GetByID returns a bill by ID
+// GetByID returns a bill by ID with its lines
 func (s BillStorage) GetByID(id uint) (bill.Bill, error) {
 	s.setContext()
 
@@ -82,5 +264,9 @@ func (s BillStorage) GetByID(id uint) (bill.Bill, error) {
 		return bill.Bill{}, ErrNotFound
 	}
 
+	s.db.Model(&b).Order("id ASC").Related(&b.Lines)
+	s.db.Model(&b).Order("id ASC").Related(&b.Parts)
+	s.db.Model(&b).Order("id ASC").Related(&b.Payments)
+
 	return b, nil
 }
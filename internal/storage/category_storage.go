@@ -4,20 +4,28 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 )
 
 // CategoryStorage storage to the category model.
 type CategoryStorage struct {
 	db      *gorm.DB
 	session *Session
+	tx      *Tx
 }
 
 // setContext initialize the context to CategoryStorage.
 func (s *CategoryStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the CategoryStorage joined to a unit of work.
+func (s CategoryStorage) WithTx(tx *Tx) CategoryStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new category.
 func (s CategoryStorage) Create(c *category.Category) error {
 	s.setContext()
@@ -26,8 +34,17 @@ func (s CategoryStorage) Create(c *category.Category) error {
 		return ErrRequiredField
 	}
 
+	if !c.IsValidStation() {
+		return ErrBadRequest
+	}
+
+	err := references(s.tx, tenant.Dishes, c.ClientID, c.Suggestions()...)
+	if err != nil {
+		return err
+	}
+
 	c.Active = true
-	err := s.db.Create(c).Error
+	err = s.db.Create(c).Error
 	if err != nil {
 		return ErrNotInsert
 	}
@@ -35,22 +52,34 @@ func (s CategoryStorage) Create(c *category.Category) error {
 	return nil
 }
 
-// CreateMany create multiple categories to a client.
+// CreateMany create multiple categories to a client, all of them or none.
 func (s CategoryStorage) CreateMany(clientID uint, categories []category.Category) error {
-	s.setContext()
-
 	for i := 0; i < len(categories); i++ {
+		if !categories[i].IsValidStation() {
+			return ErrBadRequest
+		}
+
 		categories[i].ClientID = clientID
 	}
 
-	for _, nc := range categories {
-		err := s.db.Create(&nc).Error
-		if err != nil {
-			return ErrNotInsert
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		for _, nc := range categories {
+			err := references(tx, tenant.Dishes, clientID, nc.Suggestions()...)
+			if err != nil {
+				return err
+			}
+
+			err = s.db.Create(&nc).Error
+			if err != nil {
+				return ErrNotInsert
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 // Update update category by ID.
@@ -61,6 +90,10 @@ func (s CategoryStorage) Update(id uint, c *category.Category) error {
 		return ErrRequiredField
 	}
 
+	if !c.IsValidStation() {
+		return ErrBadRequest
+	}
+
 	updates := map[string]interface{}{
 		"title":      c.Title,
 		"picture":    c.Picture,
@@ -68,9 +101,15 @@ func (s CategoryStorage) Update(id uint, c *category.Category) error {
 		"suggested2": c.Suggested2,
 		"suggested3": c.Suggested3,
 		"priority":   c.Priority,
+		"station":    c.GetStation(),
 	}
 
-	err := s.db.Model(&category.Category{}).Where("id = ?", id).
+	err := referencesOf(s.tx, tenant.Categories, id, tenant.Dishes, c.Suggestions()...)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Model(&category.Category{}).Where("id = ?", id).
 		Updates(updates).Error
 	if err != nil {
 		return ErrNotUpdate
@@ -85,7 +124,31 @@ func (s CategoryStorage) Patch(id uint, updates map[string]interface{}) error {
 
 	delete(updates, "client_id")
 
-	err := s.db.Model(&category.Category{}).Where("id = ?", id).
+	station, ok := updates["station"]
+	if ok {
+		c := category.Category{}
+		c.Station, ok = station.(string)
+		if !ok || !c.IsValidStation() {
+			return ErrBadRequest
+		}
+	}
+
+	suggestions := []uint{}
+	for _, column := range []string{"suggested1", "suggested2", "suggested3"} {
+		dishID, err := updatedID(updates, column)
+		if err != nil {
+			return err
+		}
+
+		suggestions = append(suggestions, dishID)
+	}
+
+	err := referencesOf(s.tx, tenant.Categories, id, tenant.Dishes, suggestions...)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Model(&category.Category{}).Where("id = ?", id).
 		Updates(updates).Error
 	if err != nil {
 		return ErrNotUpdate
@@ -94,19 +157,22 @@ func (s CategoryStorage) Patch(id uint, updates map[string]interface{}) error {
 	return nil
 }
 
-// UpdatePositions update positions to categories.
+// UpdatePositions update positions to categories, all of them or none.
 func (s CategoryStorage) UpdatePositions(categories []category.Category) error {
-	s.setContext()
-
-	for _, c := range categories {
-		err := s.db.Model(&category.Category{}).Where("id = ?", c.ID).
-			Update("position", c.Position).Error
-		if err != nil {
-			return ErrNotUpdate
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		for _, c := range categories {
+			err := s.db.Model(&category.Category{}).Where("id = ?", c.ID).
+				Update("position", c.Position).Error
+			if err != nil {
+				return ErrNotUpdate
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 // Delete remove a category by ID.
@@ -155,7 +221,7 @@ func (s CategoryStorage) GetAllBackup(clientID uint) (
 
 	categories := []category.BaseCategory{}
 	err := s.db.Model(&category.Category{}).Select(
-		"title, priority, active, picture, suggested1, suggested2, suggested3, position",
+		"title, priority, active, picture, suggested1, suggested2, suggested3, position, station",
 	).Where("client_id = ?", clientID).Scan(&categories).Error
 	if err != nil {
 		return []category.BaseCategory{}, ErrNotFound
@@ -3,6 +3,7 @@ package storage
 import (
 	"github.com/jinzhu/gorm"
 	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/money"
 	"gitlab.com/menuxd/api-rest/pkg/question"
 )
 
@@ -10,14 +11,21 @@ import (
 type ClientStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to ClientStorage.
 func (s *ClientStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the ClientStorage joined to a unit of work.
+func (s ClientStorage) WithTx(tx *Tx) ClientStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new client.
 func (s ClientStorage) Create(c *client.Client) error {
 	s.setContext()
@@ -30,12 +38,16 @@ func (s ClientStorage) Create(c *client.Client) error {
 		return ErrRequiredField
 	}
 
+	if !c.ValidRules() {
+		return ErrBadRequest
+	}
+
 	err := s.db.Create(c).Error
 	if err != nil {
 		return ErrNotInsert
 	}
 
-	var qs QuestionStorage
+	qs := QuestionStorage{}.WithTx(s.tx)
 
 	q := question.Question{}
 	q.ClientID = c.ID
@@ -58,11 +70,22 @@ func (s ClientStorage) Update(id uint, c *client.Client) error {
 		return ErrInvalidExpiration
 	}
 
+	if !c.ValidRules() {
+		return ErrBadRequest
+	}
+
+	if c.RoundingMode == "" {
+		c.RoundingMode = money.Nearest
+	}
+
 	updates := map[string]interface{}{
-		"name":      c.Name,
-		"picture":   c.Picture,
-		"active":    c.Active,
-		"expire_at": c.ExpireAt,
+		"name":           c.Name,
+		"picture":        c.Picture,
+		"active":         c.Active,
+		"expire_at":      c.ExpireAt,
+		"service_charge": c.ServiceCharge,
+		"rounding_unit":  c.RoundingUnit,
+		"rounding_mode":  c.RoundingMode,
 	}
 
 	err := s.db.Model(&client.Client{}).Where("id = ?", id).Updates(updates).Error
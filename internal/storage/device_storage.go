@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/device"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+)
+
+// DeviceStorage storage to the device model.
+type DeviceStorage struct {
+	session *Session
+	db      *gorm.DB
+	tx      *Tx
+}
+
+// setContext initialize the context to DeviceStorage.
+func (s *DeviceStorage) setContext() {
+	s.session = sessionOf(s.tx)
+	s.db = s.session.Client
+}
+
+// WithTx returns the DeviceStorage joined to a unit of work.
+func (s DeviceStorage) WithTx(tx *Tx) DeviceStorage {
+	s.tx = tx
+	return s
+}
+
+// Create stores a device of a table with a new pairing code.
+func (s DeviceStorage) Create(d *device.Device) error {
+	s.setContext()
+
+	t := table.Table{}
+	err := s.db.First(&t, "id = ?", d.TableID).Error
+	if err != nil {
+		return ErrNotFound
+	}
+
+	d.ClientID = t.ClientID
+	d.PairedAt = nil
+	d.RevokedAt = nil
+
+	err = d.SetCode(time.Now())
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	err = s.db.Create(d).Error
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// Pair marks as paired the device of a code, that can't be used again.
+func (s DeviceStorage) Pair(code string) (device.Device, error) {
+	s.setContext()
+
+	d := device.Device{}
+	err := s.db.First(&d, "code_hash = ?", device.HashCode(code)).Error
+	if err != nil {
+		return device.Device{}, device.ErrInvalidCode
+	}
+
+	now := time.Now()
+	if !d.CanPair(now) {
+		return device.Device{}, device.ErrInvalidCode
+	}
+
+	result := s.db.Model(&device.Device{}).
+		Where("id = ? AND paired_at IS NULL", d.ID).
+		Updates(map[string]interface{}{
+			"paired_at": now,
+			"code_hash": "",
+		})
+	if result.Error != nil {
+		return device.Device{}, ErrNotUpdate
+	}
+
+	if result.RowsAffected == 0 {
+		return device.Device{}, device.ErrInvalidCode
+	}
+
+	d.PairedAt = &now
+	d.CodeHash = ""
+
+	return d, nil
+}
+
+// Revoke stops a device from using its token.
+func (s DeviceStorage) Revoke(id uint) error {
+	s.setContext()
+
+	err := s.db.Model(&device.Device{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
+
+// GetAll returns the devices of a client.
+func (s DeviceStorage) GetAll(clientID uint) (device.Devices, error) {
+	s.setContext()
+
+	devices := device.Devices{}
+	err := s.db.Order("table_id").Find(&devices, "client_id = ?", clientID).Error
+	if err != nil {
+		return device.Devices{}, ErrNotFound
+	}
+
+	return devices, nil
+}
+
+// GetByID returns a device by ID.
+func (s DeviceStorage) GetByID(id uint) (device.Device, error) {
+	s.setContext()
+
+	d := device.Device{}
+	err := s.db.First(&d, "id = ?", id).Error
+	if err != nil {
+		return device.Device{}, ErrNotFound
+	}
+
+	return d, nil
+}
@@ -0,0 +1,37 @@
+package storage
+
+import "strings"
+
+// Dialects of gorm supported.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite3"
+)
+
+// sqliteSchemes are the schemes of the URLs of SQLite databases.
+var sqliteSchemes = []string{"sqlite://", "sqlite3://"}
+
+// parseURL returns the dialect and the data source of a database URL. The
+// scheme sqlite:// opens the file of the path, sqlite://:memory: a database
+// in memory, and any other URL is of PostgreSQL.
+func parseURL(url string) (dialect, source string) {
+	for _, scheme := range sqliteSchemes {
+		if strings.HasPrefix(url, scheme) {
+			return SQLite, strings.TrimPrefix(url, scheme)
+		}
+	}
+
+	return Postgres, url
+}
+
+// isUniqueViolation checks that an error is the violation of a unique index
+// on any of the dialects.
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "duplicate key value violates unique constraint") ||
+		strings.Contains(msg, "UNIQUE constraint failed")
+}
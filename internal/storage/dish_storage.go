@@ -2,81 +2,134 @@ package storage
 
 import (
 	"github.com/jinzhu/gorm"
+	"gitlab.com/menuxd/api-rest/pkg/category"
 	"gitlab.com/menuxd/api-rest/pkg/click"
 	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/tax"
 )
 
 // DishStorage storage to the dish model.
 type DishStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to DishStorage.
 func (s *DishStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
-// Create create a new dish.
-func (s DishStorage) Create(d *dish.Dish) error {
-	s.setContext()
+// WithTx returns the DishStorage joined to a unit of work.
+func (s DishStorage) WithTx(tx *Tx) DishStorage {
+	s.tx = tx
+	return s
+}
 
+// Create create a new dish with its ingredients, all of them or none.
+func (s DishStorage) Create(d *dish.Dish) error {
 	if d.Name == "" || d.Pictures[0] == "" || d.Price < 0 {
 		return ErrRequiredField
 	}
 
+	if !tax.IsValid(d.TaxRate) {
+		return ErrBadRequest
+	}
+	d.TaxRate = tax.Normalize(d.TaxRate)
+
 	d.PicturesString = dish.SetString(d.Pictures)
 
 	d.Available = true
 
-	err := s.db.Create(d).Error
-	if err != nil {
-		return ErrNotInsert
-	}
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		err := references(tx, tenant.Categories, d.ClientID, d.CategoryID)
+		if err != nil {
+			return err
+		}
 
-	for _, i := range d.Ingredients {
-		i.DishID = d.ID
-		err = s.db.Create(&i).Error
+		err = s.db.Create(d).Error
 		if err != nil {
 			return ErrNotInsert
 		}
-	}
 
-	return nil
+		for _, i := range d.Ingredients {
+			i.DishID = d.ID
+			err = s.db.Create(&i).Error
+			if err != nil {
+				return ErrNotInsert
+			}
+		}
+
+		return nil
+	})
 }
 
-// CreateMany create multiple dishes to a client.
+// CreateMany create multiple dishes to a client, all of them or none.
 func (s DishStorage) CreateMany(clientID uint, d dish.Dishes) error {
-	s.setContext()
-
 	dishes := d.SetClientID(clientID)
 	for _, nd := range dishes {
-		nd.PicturesString = dish.SetString(nd.Pictures)
-		err := s.db.Create(&nd).Error
-		if err != nil {
-			return ErrNotInsert
+		if !tax.IsValid(nd.TaxRate) {
+			return ErrBadRequest
 		}
+	}
+
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
 
-		for _, ni := range nd.Ingredients {
-			ni.DishID = nd.ID
-			ni.ID = 0
-			err := s.db.Create(&ni).Error
+		for _, nd := range dishes {
+			err := references(tx, tenant.Categories, clientID, nd.CategoryID)
+			if err != nil {
+				return err
+			}
+
+			nd.PicturesString = dish.SetString(nd.Pictures)
+			nd.TaxRate = tax.Normalize(nd.TaxRate)
+			err = s.db.Create(&nd).Error
 			if err != nil {
 				return ErrNotInsert
 			}
+
+			for _, ni := range nd.Ingredients {
+				ni.DishID = nd.ID
+				ni.ID = 0
+				err := s.db.Create(&ni).Error
+				if err != nil {
+					return ErrNotInsert
+				}
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
-// Update update a dish by ID.
+// Update update a dish by ID. The ingredients given replace the stored
+// ones in the same unit of work.
 func (s DishStorage) Update(id uint, updates map[string]interface{}) error {
-	s.setContext()
-
 	delete(updates, "client_id")
 
+	if rate, ok := updates["tax_rate"]; ok {
+		r, ok := rate.(string)
+		if !ok || !tax.IsValid(r) {
+			return ErrBadRequest
+		}
+		updates["tax_rate"] = tax.Normalize(r)
+	}
+
+	// Prices come as decimals, the amounts are kept in cents.
+	for _, column := range []string{"price", "half_price"} {
+		if price, ok := updates[column].(float64); ok {
+			updates[column] = money.FromFloat(price)
+		}
+	}
+
 	iPictures, ok := updates["pictures"]
 	if ok {
 		i, ok := iPictures.([]interface{})
@@ -92,29 +145,51 @@ func (s DishStorage) Update(id uint, updates map[string]interface{}) error {
 		}
 	}
 
-	err := s.db.Model(&dish.Dish{}).Where("id = ?", id).Updates(updates).Error
+	categoryID, err := updatedID(updates, "category_id")
 	if err != nil {
-		return ErrNotUpdate
+		return err
 	}
 
-	ingredients, ok := updates["ingredients"]
-	if ok {
-		ings, ok := ingredients.([]interface{})
-		if ok {
-			s.db.Delete(&dish.Ingredient{}, "dish_id = ?", id)
-			for _, i := range ings {
-				ing := dish.Ingredient{}
-				newIng := i.(map[string]interface{})
-				ing.Active = newIng["active"].(bool)
-				ing.Name = newIng["name"].(string)
-				ing.Price = newIng["price"].(float64)
-				ing.DishID = id
-				s.db.Create(&ing)
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		err := referencesOf(tx, tenant.Dishes, id, tenant.Categories, categoryID)
+		if err != nil {
+			return err
+		}
+
+		err = s.db.Model(&dish.Dish{}).Where("id = ?", id).Updates(updates).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		ings, ok := updates["ingredients"].([]interface{})
+		if !ok {
+			return nil
+		}
+
+		err = s.db.Delete(&dish.Ingredient{}, "dish_id = ?", id).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		for _, i := range ings {
+			ing := dish.Ingredient{}
+			newIng, _ := i.(map[string]interface{})
+			ing.Active, _ = newIng["active"].(bool)
+			ing.Name, _ = newIng["name"].(string)
+			price, _ := newIng["price"].(float64)
+			ing.Price = money.FromFloat(price)
+			ing.DishID = id
+			err = s.db.Create(&ing).Error
+			if err != nil {
+				return ErrNotUpdate
 			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 // Delete remove a dish by ID.
@@ -140,9 +215,9 @@ func (s DishStorage) GetAll(clientID uint) ([]dish.Dish, error) {
 		return []dish.Dish{}, ErrNotFound
 	}
 
-	for i := 0; i < len(dishes); i++ {
-		dishes[i].Pictures = dish.SetSlice(dishes[i].PicturesString)
-		s.db.Model(&dishes[i]).Related(&dishes[i].Ingredients)
+	err = s.loadDishes(dishes, false)
+	if err != nil {
+		return []dish.Dish{}, err
 	}
 
 	return dishes, nil
@@ -159,12 +234,14 @@ func (s DishStorage) GetAllBackup(clientID uint) ([]dish.BaseDish, error) {
 		return []dish.BaseDish{}, ErrNotFound
 	}
 
+	err = s.loadDishes(dishes, false)
+	if err != nil {
+		return []dish.BaseDish{}, err
+	}
+
 	result := []dish.BaseDish{}
 	bd := dish.BaseDish{}
 	for i := 0; i < len(dishes); i++ {
-		dishes[i].Pictures = dish.SetSlice(dishes[i].PicturesString)
-		s.db.Model(&dishes[i]).Related(&dishes[i].Ingredients)
-
 		bd.Name = dishes[i].Name
 		bd.Description = dishes[i].Description
 		bd.Available = dishes[i].Available
@@ -174,6 +251,7 @@ func (s DishStorage) GetAllBackup(clientID uint) ([]dish.BaseDish, error) {
 		bd.Suggested = dishes[i].Suggested
 		bd.IsHalf = dishes[i].IsHalf
 		bd.HalfPrice = dishes[i].HalfPrice
+		bd.TaxRate = dishes[i].TaxRate
 
 		result = append(result, bd)
 	}
@@ -198,15 +276,9 @@ func (s DishStorage) GetAllWithPagination(clientID uint, page int64) (dish.Dishe
 		return dish.Dishes{}, 0, ErrNotFound
 	}
 
-	var cs CategoryStorage
-	for i := 0; i < len(dishes); i++ {
-		dishes[i].Pictures = dish.SetSlice(dishes[i].PicturesString)
-		s.db.Model(&dishes[i]).Related(&dishes[i].Ingredients)
-		storedCategory, err := cs.GetByID(dishes[i].CategoryID)
-		if err != nil {
-			continue
-		}
-		dishes[i].Category = &storedCategory
+	err = s.loadDishes(dishes, true)
+	if err != nil {
+		return dish.Dishes{}, 0, err
 	}
 
 	var total int
@@ -229,15 +301,9 @@ func (s DishStorage) GetAllByCategory(categoryID uint) (dish.Dishes, error) {
 		return []dish.Dish{}, ErrNotFound
 	}
 
-	var cs CategoryStorage
-	for i := 0; i < len(dishes); i++ {
-		dishes[i].Pictures = dish.SetSlice(dishes[i].PicturesString)
-		s.db.Model(&dishes[i]).Related(&dishes[i].Ingredients)
-		storedCategory, err := cs.GetByID(dishes[i].CategoryID)
-		if err != nil {
-			continue
-		}
-		dishes[i].Category = &storedCategory
+	err = s.loadDishes(dishes, true)
+	if err != nil {
+		return []dish.Dish{}, err
 	}
 
 	return dishes, nil
@@ -253,15 +319,9 @@ func (s DishStorage) GetAllActiveByCategory(categoryID uint) (dish.Dishes, error
 		return []dish.Dish{}, ErrNotFound
 	}
 
-	var cs CategoryStorage
-	for i := 0; i < len(dishes); i++ {
-		dishes[i].Pictures = dish.SetSlice(dishes[i].PicturesString)
-		s.db.Model(&dishes[i]).Related(&dishes[i].Ingredients)
-		storedCategory, err := cs.GetByID(dishes[i].CategoryID)
-		if err != nil {
-			continue
-		}
-		dishes[i].Category = &storedCategory
+	err = s.loadDishes(dishes, true)
+	if err != nil {
+		return []dish.Dish{}, err
 	}
 
 	return dishes, nil
@@ -277,7 +337,7 @@ func (s DishStorage) GetByID(id uint) (dish.Dish, error) {
 		return dish.Dish{}, ErrNotFound
 
 	}
-	var cs CategoryStorage
+	cs := CategoryStorage{}.WithTx(s.tx)
 	d.Pictures = dish.SetSlice(d.PicturesString)
 	d.PicturesString = ""
 	storedCategory, err := cs.GetByID(d.CategoryID)
@@ -298,15 +358,9 @@ func (s DishStorage) GetSuggested(categoryID uint) (dish.Dishes, error) {
 		return []dish.Dish{}, ErrNotFound
 	}
 
-	var cs CategoryStorage
-	for i := 0; i < len(dishes); i++ {
-		dishes[i].Pictures = dish.SetSlice(dishes[i].PicturesString)
-		s.db.Model(&dishes[i]).Related(&dishes[i].Ingredients)
-		storedCategory, err := cs.GetByID(dishes[i].CategoryID)
-		if err != nil {
-			continue
-		}
-		dishes[i].Category = &storedCategory
+	err = s.loadDishes(dishes, true)
+	if err != nil {
+		return []dish.Dish{}, err
 	}
 
 	return dishes, nil
@@ -335,11 +389,97 @@ func (s DishStorage) GetClicks(clientID uint) ([]click.Click, error) {
 	clicks := []click.Click{}
 
 	err := s.db.Model(&click.Click{}).
-	Where("type = ?", click.Suggested).
-	Find(&clicks, "type_id = ?", clientID).Error
+		Where("type = ?", click.Suggested).
+		Find(&clicks, "type_id = ?", clientID).Error
 	if err != nil {
 		return []click.Click{}, ErrNotFound
 	}
 
 	return clicks, nil
 }
+
+// dishesByID returns the dishes of the IDs by ID, loaded as GetByID does.
+func (s DishStorage) dishesByID(ids []uint) (map[uint]dish.Dish, error) {
+	s.setContext()
+
+	dishes := []dish.Dish{}
+	err := inBatches(ids, func(batch []uint) error {
+		found := []dish.Dish{}
+		err := s.db.Find(&found, "id IN (?)", batch).Error
+		dishes = append(dishes, found...)
+		return err
+	})
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	err = s.loadDishes(dishes, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[uint]dish.Dish{}
+	for _, d := range dishes {
+		d.PicturesString = ""
+		if d.Category == nil {
+			d.Category = &category.Category{}
+		}
+		result[d.ID] = d
+	}
+
+	return result, nil
+}
+
+// loadDishes sets the pictures and ingredients of dishes, and their category
+// if it is asked and found, with a query for each kind of row whatever the
+// number of dishes.
+func (s DishStorage) loadDishes(dishes []dish.Dish, withCategory bool) error {
+	dishIDs := []uint{}
+	categoryIDs := []uint{}
+	for i := range dishes {
+		dishes[i].Pictures = dish.SetSlice(dishes[i].PicturesString)
+		dishes[i].Ingredients = []dish.Ingredient{}
+		dishIDs = append(dishIDs, dishes[i].ID)
+		categoryIDs = append(categoryIDs, dishes[i].CategoryID)
+	}
+
+	ingredients := map[uint][]dish.Ingredient{}
+	err := inBatches(dishIDs, func(ids []uint) error {
+		batch := []dish.Ingredient{}
+		err := s.db.Order("id ASC").Find(&batch, "dish_id IN (?)", ids).Error
+		for _, ing := range batch {
+			ingredients[ing.DishID] = append(ingredients[ing.DishID], ing)
+		}
+		return err
+	})
+	if err != nil {
+		return ErrNotFound
+	}
+
+	categories := map[uint]category.Category{}
+	if withCategory {
+		err = inBatches(categoryIDs, func(ids []uint) error {
+			batch := []category.Category{}
+			err := s.db.Find(&batch, "id IN (?)", ids).Error
+			for _, c := range batch {
+				categories[c.ID] = c
+			}
+			return err
+		})
+		if err != nil {
+			return ErrNotFound
+		}
+	}
+
+	for i := range dishes {
+		if ings, ok := ingredients[dishes[i].ID]; ok {
+			dishes[i].Ingredients = ings
+		}
+
+		if c, ok := categories[dishes[i].CategoryID]; ok {
+			dishes[i].Category = &c
+		}
+	}
+
+	return nil
+}
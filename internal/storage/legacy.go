@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"gitlab.com/menuxd/api-rest/pkg/ad"
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/click"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/device"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/promotion"
+	"gitlab.com/menuxd/api-rest/pkg/question"
+	"gitlab.com/menuxd/api-rest/pkg/rating"
+	"gitlab.com/menuxd/api-rest/pkg/register"
+	"gitlab.com/menuxd/api-rest/pkg/stay"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+	"gitlab.com/menuxd/api-rest/pkg/waiter"
+)
+
+// models are the models stored, as the baseline migration creates them.
+var models = []interface{}{
+	&ad.Ad{},
+	&bill.Bill{},
+	&bill.Line{},
+	&bill.Part{},
+	&bill.TaxLine{},
+	&payment.Payment{},
+	&register.Session{},
+	&category.Category{},
+	&client.Client{},
+	&dish.Dish{},
+	&dish.Ingredient{},
+	&promotion.Promotion{},
+	&table.Table{},
+	&user.User{},
+	&waiter.Waiter{},
+	&order.Order{},
+	&order.Item{},
+	&order.IngredientSelected{},
+	&click.Click{},
+	&stay.Stay{},
+	&question.Question{},
+	&rating.Rating{},
+	&notification.Notification{},
+	&device.Device{},
+	&apikey.Key{},
+	&login.Session{},
+	&login.Lockout{},
+	&user.Reset{},
+	&user.RecoveryCode{},
+	&user.TwoFactorPolicy{},
+}
+
+// legacyMigration brings a database migrated by AutoMigrate, as it was done
+// on boot before the versioned migrations, to the schema of the baseline.
+// Its steps can run again if it fails halfway.
+func legacyMigration() error {
+	err := conn.AutoMigrate(models...).Error
+	if err != nil {
+		return err
+	}
+
+	// SQLite can't alter columns, but it was never used before the
+	// changes these steps migrate.
+	if conn.Dialect().GetName() == SQLite {
+		return nil
+	}
+
+	err = moneyColumns()
+	if err != nil {
+		return err
+	}
+
+	return hashPINs()
+}
+
+// moneyColumns changes the columns of money values to decimals, as
+// AutoMigrate does not alter the type of existing columns.
+func moneyColumns() error {
+	columns := []struct {
+		model  interface{}
+		column string
+	}{
+		{&dish.Dish{}, "price"},
+		{&dish.Dish{}, "half_price"},
+		{&dish.Ingredient{}, "price"},
+		{&bill.Bill{}, "value"},
+		{&bill.Line{}, "unit_price"},
+		{&bill.Line{}, "extras_unit"},
+		{&bill.Line{}, "total"},
+		{&bill.Part{}, "value"},
+		{&payment.Payment{}, "amount"},
+		{&payment.Payment{}, "tendered"},
+		{&payment.Payment{}, "change"},
+		{&payment.Payment{}, "tip"},
+	}
+
+	for _, c := range columns {
+		err := conn.Model(c.model).ModifyColumn(c.column, "numeric(14,2)").Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hashPINs replaces the PINs of the waiters stored in plain text by their
+// hashes, and drops the old column.
+func hashPINs() error {
+	if !conn.Dialect().HasColumn("waiters", "pin") {
+		return nil
+	}
+
+	rows, err := conn.Table("waiters").Select("id, client_id, COALESCE(pin, '')").Rows()
+	if err != nil {
+		return err
+	}
+
+	waiters := []waiter.Waiter{}
+	for rows.Next() {
+		w := waiter.Waiter{}
+		err = rows.Scan(&w.ID, &w.ClientID, &w.PIN)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		waiters = append(waiters, w)
+	}
+	rows.Close()
+
+	for _, w := range waiters {
+		err = conn.Table("waiters").Where("id = ?", w.ID).
+			Update("pin_hash", waiter.HashPIN(PINSecret(), w.ClientID, w.PIN)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return conn.Model(&waiter.Waiter{}).DropColumn("pin").Error
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/login"
+)
+
+// LoginStorage storage to the login session model.
+type LoginStorage struct {
+	session *Session
+	db      *gorm.DB
+	tx      *Tx
+}
+
+// setContext initialize the context to LoginStorage.
+func (s *LoginStorage) setContext() {
+	s.session = sessionOf(s.tx)
+	s.db = s.session.Client
+}
+
+// WithTx returns the LoginStorage joined to a unit of work.
+func (s LoginStorage) WithTx(tx *Tx) LoginStorage {
+	s.tx = tx
+	return s
+}
+
+// Create stores a new login session.
+func (s LoginStorage) Create(ls *login.Session) error {
+	s.setContext()
+
+	err := s.db.Create(ls).Error
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// Rotate revokes a login session and stores the next one. It fails with
+// login.ErrReused if the session was already revoked.
+func (s LoginStorage) Rotate(old login.Session, next *login.Session) error {
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		result := s.db.Model(&login.Session{}).
+			Where("id = ? AND revoked_at IS NULL", old.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return ErrNotUpdate
+		}
+
+		if result.RowsAffected == 0 {
+			return login.ErrReused
+		}
+
+		err := s.db.Create(next).Error
+		if err != nil {
+			return ErrNotInsert
+		}
+
+		return nil
+	})
+}
+
+// Revoke ends a login session.
+func (s LoginStorage) Revoke(id uint) error {
+	s.setContext()
+
+	err := s.db.Model(&login.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
+
+// RevokeFamily ends every login session of a family.
+func (s LoginStorage) RevokeFamily(family string) error {
+	s.setContext()
+
+	err := s.db.Model(&login.Session{}).
+		Where("family = ? AND revoked_at IS NULL", family).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
+
+// RevokeAll ends every login session of a user.
+func (s LoginStorage) RevokeAll(userID uint) error {
+	s.setContext()
+
+	err := s.db.Model(&login.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
+
+// GetByID returns a login session by ID.
+func (s LoginStorage) GetByID(id uint) (login.Session, error) {
+	s.setContext()
+
+	ls := login.Session{}
+	err := s.db.First(&ls, "id = ?", id).Error
+	if err != nil {
+		return login.Session{}, ErrNotFound
+	}
+
+	return ls, nil
+}
+
+// GetByToken returns the login session of a refresh token.
+func (s LoginStorage) GetByToken(token string) (login.Session, error) {
+	s.setContext()
+
+	ls := login.Session{}
+	err := s.db.First(&ls, "token_hash = ?", login.HashToken(token)).Error
+	if err != nil {
+		return login.Session{}, ErrNotFound
+	}
+
+	return ls, nil
+}
+
+// GetActive returns the login sessions of a user not revoked nor expired.
+func (s LoginStorage) GetActive(userID uint) (login.Sessions, error) {
+	s.setContext()
+
+	sessions := login.Sessions{}
+	err := s.db.Order("created_at desc").
+		Find(&sessions, "user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).Error
+	if err != nil {
+		return login.Sessions{}, ErrNotFound
+	}
+
+	return sessions, nil
+}
+
+// RecordLockout stores a lockout after failed logins.
+func (s LoginStorage) RecordLockout(l *login.Lockout) error {
+	s.setContext()
+
+	err := s.db.Create(l).Error
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// GetLockouts returns the lockouts since a time, the newest first.
+func (s LoginStorage) GetLockouts(since time.Time) (login.Lockouts, error) {
+	s.setContext()
+
+	lockouts := login.Lockouts{}
+	err := s.db.Order("created_at desc").
+		Find(&lockouts, "created_at >= ?", since).Error
+	if err != nil {
+		return login.Lockouts{}, ErrNotFound
+	}
+
+	return lockouts, nil
+}
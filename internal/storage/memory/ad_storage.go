@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/ad"
+	"gitlab.com/menuxd/api-rest/pkg/click"
+)
+
+// AdStorage storage to the ad model.
+type AdStorage struct {
+	db *database
+}
+
+// Create create a new ad.
+func (s AdStorage) Create(a *ad.Ad) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	a.Active = true
+	if a.Picture == "" {
+		return storage.ErrRequiredField
+	}
+
+	err := s.db.insert(s.db.ads, a)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// AddClick create a new click.
+func (s AdStorage) AddClick(adID uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.addClick(click.Ad, adID)
+}
+
+// Update update ad by ID.
+func (s AdStorage) Update(id uint, a *ad.Ad) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if a.Picture == "" {
+		return storage.ErrRequiredField
+	}
+
+	a.ID = id
+	s.db.update(s.db.ads, id, map[string]interface{}{
+		"picture": a.Picture,
+		"title":   a.Title,
+	})
+
+	return nil
+}
+
+// Patch update ad by ID.
+func (s AdStorage) Patch(id uint, updates map[string]interface{}) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(updates, "client_id")
+	s.db.update(s.db.ads, id, updates)
+
+	return nil
+}
+
+// Delete remove an ad by ID.
+func (s AdStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if a := s.db.ads[id]; live(a.Model) {
+		a.DeletedAt = now()
+		s.db.ads[id] = a
+	}
+
+	return nil
+}
+
+// GetAll returns all stored ads.
+func (s AdStorage) GetAll(clientID uint) (ad.Ads, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	ads := ad.Ads{}
+	for _, id := range ids(s.db.ads) {
+		a := s.db.ads[id]
+		if !live(a.Model) || a.ClientID != clientID {
+			continue
+		}
+
+		a.Clicks = s.db.getClicks(click.Ad, a.ID)
+		ads = append(ads, a)
+	}
+
+	detach(&ads)
+	return ads, nil
+}
+
+// GetByID returns an ad by ID.
+func (s AdStorage) GetByID(id uint) (ad.Ad, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	a := s.db.ads[id]
+	if !live(a.Model) {
+		return ad.Ad{}, storage.ErrNotFound
+	}
+
+	detach(&a)
+	return a, nil
+}
+
+// addClick stores a click of the type given.
+func (db *database) addClick(kind, typeID uint) error {
+	c := click.Click{Type: kind, TypeID: typeID}
+
+	err := db.insert(db.clicks, &c)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// getClicks returns the clicks of the type and type ID given.
+func (db *database) getClicks(kind, typeID uint) []click.Click {
+	clicks := []click.Click{}
+	for _, id := range ids(db.clicks) {
+		c := db.clicks[id]
+		if live(c.Model) && c.Type == kind && c.TypeID == typeID {
+			clicks = append(clicks, c)
+		}
+	}
+
+	return clicks
+}
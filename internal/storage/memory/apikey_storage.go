@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+)
+
+// APIKeyStorage storage to the API key model.
+type APIKeyStorage struct {
+	db *database
+}
+
+// Create stores a new API key.
+func (s APIKeyStorage) Create(k *apikey.Key) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	k.LastUsedAt = nil
+	k.RevokedAt = nil
+
+	for _, stored := range s.db.apiKeys {
+		if stored.KeyHash == k.KeyHash {
+			return storage.ErrNotInsert
+		}
+	}
+
+	err := s.db.insert(s.db.apiKeys, k)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// Revoke stops an API key from being accepted.
+func (s APIKeyStorage) Revoke(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if k := s.db.apiKeys[id]; k.RevokedAt == nil {
+		s.db.update(s.db.apiKeys, id, map[string]interface{}{"revoked_at": now()})
+	}
+
+	return nil
+}
+
+// GetAll returns the API keys of a client.
+func (s APIKeyStorage) GetAll(clientID uint) (apikey.Keys, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	keys := apikey.Keys{}
+	for _, id := range ids(s.db.apiKeys) {
+		k := s.db.apiKeys[id]
+		if live(k.Model) && k.ClientID == clientID {
+			keys = append(keys, k)
+		}
+	}
+
+	detach(&keys)
+	return keys, nil
+}
+
+// GetByKey returns the API key with the hash of the key.
+func (s APIKeyStorage) GetByKey(key string) (apikey.Key, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	hash := apikey.HashKey(key)
+	for _, id := range ids(s.db.apiKeys) {
+		k := s.db.apiKeys[id]
+		if live(k.Model) && k.KeyHash == hash {
+			detach(&k)
+			return k, nil
+		}
+	}
+
+	return apikey.Key{}, storage.ErrNotFound
+}
+
+// Touch records the use of an API key, at most once each TouchEvery.
+func (s APIKeyStorage) Touch(id uint, at time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	k := s.db.apiKeys[id]
+	if k.LastUsedAt == nil || k.LastUsedAt.Before(at.Add(-apikey.TouchEvery)) {
+		s.db.update(s.db.apiKeys, id, map[string]interface{}{"last_used_at": at})
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package memory
+
+import (
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+)
+
+// BillStorage storage to the bill model.
+type BillStorage struct {
+	db *database
+}
+
+// Create create a new bill with its lines and links the billed orders to it.
+// It fails with bill.ErrAlreadyBilled, storing nothing, if any of the orders
+// is billed.
+func (s BillStorage) Create(b *bill.Bill) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, o := range b.Orders {
+		if s.db.orders[o.ID].BillID != nil {
+			return bill.ErrAlreadyBilled
+		}
+	}
+
+	err := s.db.insert(s.db.bills, b)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	for i := range b.Lines {
+		b.Lines[i].BillID = b.ID
+		err = s.db.insert(s.db.lines, &b.Lines[i])
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+	}
+
+	for i := range b.Taxes {
+		b.Taxes[i].BillID = b.ID
+		err = s.db.insert(s.db.taxLines, &b.Taxes[i])
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+	}
+
+	for i := range b.Parts {
+		b.Parts[i].BillID = b.ID
+		err = s.db.insert(s.db.parts, &b.Parts[i])
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+	}
+
+	for _, o := range b.Orders {
+		s.db.update(s.db.orders, o.ID, map[string]interface{}{"bill_id": b.ID})
+	}
+
+	return nil
+}
+
+// Split replaces the parts of a bill by ID.
+func (s BillStorage) Split(id uint, parts []bill.Part) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	b := s.db.bills[id]
+	if !live(b.Model) {
+		return storage.ErrNotFound
+	}
+
+	if b.Paid {
+		return bill.ErrAlreadyPaid
+	}
+
+	if len(s.db.billPayments(id)) > 0 {
+		return bill.ErrHasPayments
+	}
+
+	for _, pid := range ids(s.db.parts) {
+		if s.db.parts[pid].BillID == id {
+			delete(s.db.parts, pid)
+		}
+	}
+
+	for _, lid := range ids(s.db.lines) {
+		if s.db.lines[lid].BillID == id {
+			s.db.update(s.db.lines, lid, map[string]interface{}{"part_id": nil})
+		}
+	}
+
+	for _, p := range parts {
+		p.ID = 0
+		p.BillID = id
+		err := s.db.insert(s.db.parts, &p)
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+
+		for _, l := range p.Lines {
+			if s.db.lines[l.ID].BillID == id {
+				s.db.update(s.db.lines, l.ID, map[string]interface{}{"part_id": p.ID})
+			}
+		}
+	}
+
+	return nil
+}
+
+// reconcile persists whether a bill and its parts are paid, the served
+// orders of a bill that just got paid are moved to paid.
+func (db *database) reconcile(b bill.Bill, wasPaid bool) error {
+	for _, p := range b.Parts {
+		db.update(db.parts, p.ID, map[string]interface{}{"paid": p.Paid})
+	}
+
+	db.update(db.bills, b.ID, map[string]interface{}{"paid": b.Paid})
+
+	if !b.Paid || wasPaid {
+		return nil
+	}
+
+	for _, id := range ids(db.orders) {
+		o := db.orders[id]
+		if !live(o.Model) || o.BillID == nil || *o.BillID != b.ID {
+			continue
+		}
+
+		if !o.CanTransition(order.Paid) {
+			continue
+		}
+
+		_, err := db.transitionOrder(o.ID, order.Paid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete remove a bill by ID and releases its orders, a bill with payments
+// can't be removed.
+func (s BillStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if len(s.db.billPayments(id)) > 0 {
+		return bill.ErrHasPayments
+	}
+
+	if b := s.db.bills[id]; live(b.Model) {
+		b.DeletedAt = now()
+		s.db.bills[id] = b
+	}
+
+	for _, pid := range ids(s.db.parts) {
+		if p := s.db.parts[pid]; live(p.Model) && p.BillID == id {
+			p.DeletedAt = now()
+			s.db.parts[pid] = p
+		}
+	}
+
+	for _, oid := range ids(s.db.orders) {
+		if o := s.db.orders[oid]; o.BillID != nil && *o.BillID == id {
+			s.db.update(s.db.orders, oid, map[string]interface{}{"bill_id": nil})
+		}
+	}
+
+	return nil
+}
+
+// GetAll returns all stored bills.
+func (s BillStorage) GetAll(clientID uint) (bill.Bills, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	bills := bill.Bills{}
+	for _, id := range ids(s.db.bills) {
+		b := s.db.bills[id]
+		if live(b.Model) && b.ClientID == clientID {
+			bills = append(bills, b)
+		}
+	}
+
+	detach(&bills)
+	return bills, nil
+}
+
+// GetByID returns a bill by ID with its lines.
+func (s BillStorage) GetByID(id uint) (bill.Bill, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	b, err := s.db.bill(id)
+	if err != nil {
+		return bill.Bill{}, err
+	}
+
+	detach(&b)
+	return b, nil
+}
+
+// bill returns a bill by ID with its lines, parts and payments.
+func (db *database) bill(id uint) (bill.Bill, error) {
+	b := db.bills[id]
+	if !live(b.Model) {
+		return bill.Bill{}, storage.ErrNotFound
+	}
+
+	b.Lines = []bill.Line{}
+	for _, lid := range ids(db.lines) {
+		if l := db.lines[lid]; live(l.Model) && l.BillID == id {
+			b.Lines = append(b.Lines, l)
+		}
+	}
+
+	b.Parts = []bill.Part{}
+	for _, pid := range ids(db.parts) {
+		if p := db.parts[pid]; live(p.Model) && p.BillID == id {
+			b.Parts = append(b.Parts, p)
+		}
+	}
+
+	b.Payments = db.billPayments(id)
+
+	return b, nil
+}
+
+// billPayments returns the payments of a bill.
+func (db *database) billPayments(billID uint) payment.Payments {
+	payments := payment.Payments{}
+	for _, id := range ids(db.payments) {
+		if p := db.payments[id]; live(p.Model) && p.BillID == billID {
+			payments = append(payments, p)
+		}
+	}
+
+	return payments
+}
@@ -0,0 +1,242 @@
+package memory
+
+import (
+	"sort"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+)
+
+// CategoryStorage storage to the category model.
+type CategoryStorage struct {
+	db *database
+}
+
+// Create create a new category.
+func (s CategoryStorage) Create(c *category.Category) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if c.Title == "" || c.Picture == "" {
+		return storage.ErrRequiredField
+	}
+
+	if !c.IsValidStation() {
+		return storage.ErrBadRequest
+	}
+
+	err := s.db.references(tenant.Dishes, c.ClientID, c.Suggestions()...)
+	if err != nil {
+		return err
+	}
+
+	c.Active = true
+	err = s.db.insert(s.db.categories, c)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// CreateMany create multiple categories to a client.
+func (s CategoryStorage) CreateMany(clientID uint, categories []category.Category) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for i := 0; i < len(categories); i++ {
+		if !categories[i].IsValidStation() {
+			return storage.ErrBadRequest
+		}
+
+		categories[i].ClientID = clientID
+
+		err := s.db.references(tenant.Dishes, clientID, categories[i].Suggestions()...)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, nc := range categories {
+		err := s.db.insert(s.db.categories, &nc)
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+	}
+
+	return nil
+}
+
+// Update update category by ID.
+func (s CategoryStorage) Update(id uint, c *category.Category) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if c.Title == "" || c.Picture == "" {
+		return storage.ErrRequiredField
+	}
+
+	if !c.IsValidStation() {
+		return storage.ErrBadRequest
+	}
+
+	err := s.db.referencesOf(tenant.Categories, id, tenant.Dishes, c.Suggestions()...)
+	if err != nil {
+		return err
+	}
+
+	s.db.update(s.db.categories, id, map[string]interface{}{
+		"title":      c.Title,
+		"picture":    c.Picture,
+		"suggested1": c.Suggested1,
+		"suggested2": c.Suggested2,
+		"suggested3": c.Suggested3,
+		"priority":   c.Priority,
+		"station":    c.GetStation(),
+	})
+
+	return nil
+}
+
+// Patch update part of the category by ID.
+func (s CategoryStorage) Patch(id uint, updates map[string]interface{}) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(updates, "client_id")
+
+	station, ok := updates["station"]
+	if ok {
+		c := category.Category{}
+		c.Station, ok = station.(string)
+		if !ok || !c.IsValidStation() {
+			return storage.ErrBadRequest
+		}
+	}
+
+	suggestions := []uint{}
+	for _, column := range []string{"suggested1", "suggested2", "suggested3"} {
+		dishID, err := updatedID(updates, column)
+		if err != nil {
+			return err
+		}
+
+		suggestions = append(suggestions, dishID)
+	}
+
+	err := s.db.referencesOf(tenant.Categories, id, tenant.Dishes, suggestions...)
+	if err != nil {
+		return err
+	}
+
+	s.db.update(s.db.categories, id, updates)
+
+	return nil
+}
+
+// UpdatePositions update positions to categories.
+func (s CategoryStorage) UpdatePositions(categories []category.Category) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, c := range categories {
+		s.db.update(s.db.categories, c.ID, map[string]interface{}{"position": c.Position})
+	}
+
+	return nil
+}
+
+// Delete remove a category by ID.
+func (s CategoryStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if c := s.db.categories[id]; live(c.Model) {
+		c.DeletedAt = now()
+		s.db.categories[id] = c
+	}
+
+	return nil
+}
+
+// GetAll returns all stored categories.
+func (s CategoryStorage) GetAll(clientID uint) (category.Categories, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.clientCategories(clientID, false), nil
+}
+
+// GetAllActive returns all active categories.
+func (s CategoryStorage) GetAllActive(clientID uint) (category.Categories, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.clientCategories(clientID, true), nil
+}
+
+// GetAllBackup returns all stored dishes.
+func (s CategoryStorage) GetAllBackup(clientID uint) ([]category.BaseCategory, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	categories := []category.BaseCategory{}
+	for _, id := range ids(s.db.categories) {
+		if c := s.db.categories[id]; live(c.Model) && c.ClientID == clientID {
+			categories = append(categories, c.BaseCategory)
+		}
+	}
+
+	detach(&categories)
+	return categories, nil
+}
+
+// GetByID returns a category by ID.
+func (s CategoryStorage) GetByID(id uint) (category.Category, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	c, err := s.db.category(id)
+	if err != nil {
+		return category.Category{}, err
+	}
+
+	detach(&c)
+	return c, nil
+}
+
+// category returns a category by ID.
+func (db *database) category(id uint) (category.Category, error) {
+	c := db.categories[id]
+	if !live(c.Model) {
+		return category.Category{}, storage.ErrNotFound
+	}
+
+	return c, nil
+}
+
+// clientCategories returns the categories of a client by position and
+// title, only the active ones if asked.
+func (db *database) clientCategories(clientID uint, active bool) category.Categories {
+	categories := category.Categories{}
+	for _, id := range ids(db.categories) {
+		c := db.categories[id]
+		if !live(c.Model) || c.ClientID != clientID || (active && !c.Active) {
+			continue
+		}
+
+		categories = append(categories, c)
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Position != categories[j].Position {
+			return categories[i].Position < categories[j].Position
+		}
+
+		return categories[i].Title < categories[j].Title
+	})
+
+	detach(&categories)
+	return categories
+}
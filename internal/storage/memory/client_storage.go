@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/question"
+)
+
+// ClientStorage storage to the client model.
+type ClientStorage struct {
+	db *database
+}
+
+// Create create a new client.
+func (s ClientStorage) Create(c *client.Client) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if !c.ValidDate() {
+		return storage.ErrInvalidExpiration
+	}
+
+	if c.Name == "" {
+		return storage.ErrRequiredField
+	}
+
+	if !c.ValidRules() {
+		return storage.ErrBadRequest
+	}
+
+	err := s.db.insert(s.db.clients, c)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	q := question.Question{}
+	q.ClientID = c.ID
+	q.Text = "¿Qué le pareció la experiencia del Menu Digital?"
+	q.Main = true
+
+	return s.db.createQuestion(&q)
+}
+
+// Update update client by ID.
+func (s ClientStorage) Update(id uint, c *client.Client) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if !c.ValidDate() {
+		return storage.ErrInvalidExpiration
+	}
+
+	if !c.ValidRules() {
+		return storage.ErrBadRequest
+	}
+
+	if c.RoundingMode == "" {
+		c.RoundingMode = money.Nearest
+	}
+
+	s.db.update(s.db.clients, id, map[string]interface{}{
+		"name":           c.Name,
+		"picture":        c.Picture,
+		"active":         c.Active,
+		"expire_at":      c.ExpireAt,
+		"service_charge": c.ServiceCharge,
+		"rounding_unit":  c.RoundingUnit,
+		"rounding_mode":  c.RoundingMode,
+	})
+
+	return nil
+}
+
+// Delete remove a client by ID.
+func (s ClientStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if c := s.db.clients[id]; live(c.Model) {
+		c.DeletedAt = now()
+		s.db.clients[id] = c
+	}
+
+	return nil
+}
+
+// GetAll returns all stored clients.
+func (s ClientStorage) GetAll(userID uint) (client.Clients, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	clients := client.Clients{}
+	for _, id := range ids(s.db.clients) {
+		if c := s.db.clients[id]; live(c.Model) && c.UserID == userID {
+			clients = append(clients, c)
+		}
+	}
+
+	detach(&clients)
+	return clients, nil
+}
+
+// GetByID returns a client by ID.
+func (s ClientStorage) GetByID(id uint) (client.Client, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	c, err := s.db.client(id)
+	if err != nil {
+		return client.Client{}, err
+	}
+
+	detach(&c)
+	return c, nil
+}
+
+// client returns a client by ID.
+func (db *database) client(id uint) (client.Client, error) {
+	c := db.clients[id]
+	if !live(c.Model) {
+		return client.Client{}, storage.ErrNotFound
+	}
+
+	return c, nil
+}
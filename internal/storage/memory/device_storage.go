@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/device"
+)
+
+// DeviceStorage storage to the device model.
+type DeviceStorage struct {
+	db *database
+}
+
+// Create stores a device of a table with a new pairing code.
+func (s DeviceStorage) Create(d *device.Device) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	t := s.db.tables[d.TableID]
+	if !live(t.Model) {
+		return storage.ErrNotFound
+	}
+
+	d.ClientID = t.ClientID
+	d.PairedAt = nil
+	d.RevokedAt = nil
+
+	err := d.SetCode(time.Now())
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	err = s.db.insert(s.db.devices, d)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// Pair marks as paired the device of a code, that can't be used again.
+func (s DeviceStorage) Pair(code string) (device.Device, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	hash := device.HashCode(code)
+	for _, id := range ids(s.db.devices) {
+		d := s.db.devices[id]
+		if !live(d.Model) || d.CodeHash != hash {
+			continue
+		}
+
+		now := time.Now()
+		if !d.CanPair(now) {
+			return device.Device{}, device.ErrInvalidCode
+		}
+
+		s.db.update(s.db.devices, id, map[string]interface{}{
+			"paired_at": now,
+			"code_hash": "",
+		})
+
+		d.PairedAt = &now
+		d.CodeHash = ""
+
+		detach(&d)
+		return d, nil
+	}
+
+	return device.Device{}, device.ErrInvalidCode
+}
+
+// Revoke stops a device from using its token.
+func (s DeviceStorage) Revoke(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if d := s.db.devices[id]; d.RevokedAt == nil {
+		s.db.update(s.db.devices, id, map[string]interface{}{"revoked_at": now()})
+	}
+
+	return nil
+}
+
+// GetAll returns the devices of a client.
+func (s DeviceStorage) GetAll(clientID uint) (device.Devices, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	devices := device.Devices{}
+	for _, id := range ids(s.db.devices) {
+		if d := s.db.devices[id]; live(d.Model) && d.ClientID == clientID {
+			devices = append(devices, d)
+		}
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].TableID < devices[j].TableID
+	})
+
+	detach(&devices)
+	return devices, nil
+}
+
+// GetByID returns a device by ID.
+func (s DeviceStorage) GetByID(id uint) (device.Device, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	d := s.db.devices[id]
+	if !live(d.Model) {
+		return device.Device{}, storage.ErrNotFound
+	}
+
+	detach(&d)
+	return d, nil
+}
@@ -0,0 +1,348 @@
+package memory
+
+import (
+	"sort"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/click"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/tax"
+)
+
+// pageSize is the number of dishes of each page.
+const pageSize = 12
+
+// DishStorage storage to the dish model.
+type DishStorage struct {
+	db *database
+}
+
+// Create create a new dish.
+func (s DishStorage) Create(d *dish.Dish) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if d.Name == "" || len(d.Pictures) == 0 || d.Pictures[0] == "" || d.Price < 0 {
+		return storage.ErrRequiredField
+	}
+
+	if !tax.IsValid(d.TaxRate) {
+		return storage.ErrBadRequest
+	}
+	d.TaxRate = tax.Normalize(d.TaxRate)
+
+	d.PicturesString = dish.SetString(d.Pictures)
+
+	err := s.db.references(tenant.Categories, d.ClientID, d.CategoryID)
+	if err != nil {
+		return err
+	}
+
+	d.Available = true
+
+	err = s.db.insert(s.db.dishes, d)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	for _, i := range d.Ingredients {
+		i.DishID = d.ID
+		err = s.db.insert(s.db.ingredients, &i)
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+	}
+
+	return nil
+}
+
+// CreateMany create multiple dishes to a client.
+func (s DishStorage) CreateMany(clientID uint, d dish.Dishes) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	dishes := d.SetClientID(clientID)
+	for _, nd := range dishes {
+		if !tax.IsValid(nd.TaxRate) {
+			return storage.ErrBadRequest
+		}
+
+		err := s.db.references(tenant.Categories, clientID, nd.CategoryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, nd := range dishes {
+		nd.PicturesString = dish.SetString(nd.Pictures)
+		nd.TaxRate = tax.Normalize(nd.TaxRate)
+		err := s.db.insert(s.db.dishes, &nd)
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+
+		for _, ni := range nd.Ingredients {
+			ni.DishID = nd.ID
+			ni.ID = 0
+			err = s.db.insert(s.db.ingredients, &ni)
+			if err != nil {
+				return storage.ErrNotInsert
+			}
+		}
+	}
+
+	return nil
+}
+
+// Update update a dish by ID, replacing its ingredients if given.
+func (s DishStorage) Update(id uint, updates map[string]interface{}) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(updates, "client_id")
+
+	categoryID, err := updatedID(updates, "category_id")
+	if err != nil {
+		return err
+	}
+
+	err = s.db.referencesOf(tenant.Dishes, id, tenant.Categories, categoryID)
+	if err != nil {
+		return err
+	}
+
+	if rate, ok := updates["tax_rate"]; ok {
+		r, ok := rate.(string)
+		if !ok || !tax.IsValid(r) {
+			return storage.ErrBadRequest
+		}
+		updates["tax_rate"] = tax.Normalize(r)
+	}
+
+	// Prices come as decimals, the amounts are kept in cents.
+	for _, column := range []string{"price", "half_price"} {
+		if price, ok := updates[column].(float64); ok {
+			updates[column] = money.FromFloat(price)
+		}
+	}
+
+	iPictures, ok := updates["pictures"]
+	if ok {
+		i, ok := iPictures.([]interface{})
+		if !ok {
+			delete(updates, "PicturesString")
+			delete(updates, "pictures")
+		} else {
+			pictures := []string{}
+			for _, p := range i {
+				picture, _ := p.(string)
+				pictures = append(pictures, picture)
+			}
+			updates["PicturesString"] = dish.SetString(pictures)
+		}
+	}
+
+	s.db.update(s.db.dishes, id, updates)
+
+	ings, ok := updates["ingredients"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, iid := range ids(s.db.ingredients) {
+		if i := s.db.ingredients[iid]; live(i.Model) && i.DishID == id {
+			i.DeletedAt = now()
+			s.db.ingredients[iid] = i
+		}
+	}
+
+	for _, i := range ings {
+		newIng, _ := i.(map[string]interface{})
+		ing := dish.Ingredient{DishID: id}
+		ing.Active, _ = newIng["active"].(bool)
+		ing.Name, _ = newIng["name"].(string)
+		price, _ := newIng["price"].(float64)
+		ing.Price = money.FromFloat(price)
+		s.db.insert(s.db.ingredients, &ing)
+	}
+
+	return nil
+}
+
+// Delete remove a dish by ID.
+func (s DishStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if d := s.db.dishes[id]; live(d.Model) {
+		d.DeletedAt = now()
+		s.db.dishes[id] = d
+	}
+
+	return nil
+}
+
+// GetAll returns all stored dishes.
+func (s DishStorage) GetAll(clientID uint) ([]dish.Dish, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	dishes := s.db.getDishes(func(d dish.Dish) bool { return d.ClientID == clientID })
+	for i := range dishes {
+		dishes[i].Category = nil
+	}
+
+	return dishes, nil
+}
+
+// GetAllBackup returns all stored dishes.
+func (s DishStorage) GetAllBackup(clientID uint) ([]dish.BaseDish, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	result := []dish.BaseDish{}
+	for _, d := range s.db.getDishes(func(d dish.Dish) bool { return d.ClientID == clientID }) {
+		bd := d.BaseDish
+		bd.PicturesString = ""
+		result = append(result, bd)
+	}
+
+	return result, nil
+}
+
+// GetAllWithPagination returns a page of the dishes of a client and the
+// total of its dishes.
+func (s DishStorage) GetAllWithPagination(clientID uint, page int64) (dish.Dishes, int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	dishes := s.db.getDishes(func(d dish.Dish) bool { return d.ClientID == clientID })
+	total := len(dishes)
+
+	offset := (page - 1) * pageSize
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(total) {
+		offset = int64(total)
+	}
+
+	end := offset + pageSize
+	if end > int64(total) {
+		end = int64(total)
+	}
+
+	return dishes[offset:end], total, nil
+}
+
+// GetAllByCategory returns dishes by Category ID.
+func (s DishStorage) GetAllByCategory(categoryID uint) (dish.Dishes, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.getDishes(func(d dish.Dish) bool {
+		return d.CategoryID == categoryID
+	}), nil
+}
+
+// GetAllActiveByCategory returns active dishes by Category ID.
+func (s DishStorage) GetAllActiveByCategory(categoryID uint) (dish.Dishes, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.getDishes(func(d dish.Dish) bool {
+		return d.CategoryID == categoryID && d.Available
+	}), nil
+}
+
+// GetByID returns a dish by ID.
+func (s DishStorage) GetByID(id uint) (dish.Dish, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.dish(id)
+}
+
+// GetSuggested returns suggested drinks.
+func (s DishStorage) GetSuggested(categoryID uint) (dish.Dishes, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.getDishes(func(d dish.Dish) bool {
+		return d.CategoryID == categoryID && d.Suggested
+	}), nil
+}
+
+// AddClick create a new click.
+func (s DishStorage) AddClick(suggestedID uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.addClick(click.Suggested, suggestedID)
+}
+
+// GetClicks get all clicks by client id.
+func (s DishStorage) GetClicks(clientID uint) ([]click.Click, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.getClicks(click.Suggested, clientID), nil
+}
+
+// dish returns a dish by ID with its pictures, category and ingredients.
+func (db *database) dish(id uint) (dish.Dish, error) {
+	d := db.dishes[id]
+	if !live(d.Model) {
+		return dish.Dish{}, storage.ErrNotFound
+	}
+
+	d.Pictures = dish.SetSlice(d.PicturesString)
+	d.PicturesString = ""
+	c, _ := db.category(d.CategoryID)
+	d.Category = &c
+	d.Ingredients = db.dishIngredients(d.ID)
+
+	detach(&d)
+	return d, nil
+}
+
+// getDishes returns the dishes that match by name, with their pictures,
+// ingredients and category.
+func (db *database) getDishes(match func(d dish.Dish) bool) dish.Dishes {
+	dishes := dish.Dishes{}
+	for _, id := range ids(db.dishes) {
+		d := db.dishes[id]
+		if !live(d.Model) || !match(d) {
+			continue
+		}
+
+		d.Pictures = dish.SetSlice(d.PicturesString)
+		d.Ingredients = db.dishIngredients(d.ID)
+		if c, err := db.category(d.CategoryID); err == nil {
+			d.Category = &c
+		}
+
+		dishes = append(dishes, d)
+	}
+
+	sort.SliceStable(dishes, func(i, j int) bool {
+		return dishes[i].Name < dishes[j].Name
+	})
+
+	detach(&dishes)
+	return dishes
+}
+
+// dishIngredients returns the ingredients of a dish.
+func (db *database) dishIngredients(dishID uint) []dish.Ingredient {
+	ingredients := []dish.Ingredient{}
+	for _, id := range ids(db.ingredients) {
+		if i := db.ingredients[id]; live(i.Model) && i.DishID == dishID {
+			ingredients = append(ingredients, i)
+		}
+	}
+
+	return ingredients
+}
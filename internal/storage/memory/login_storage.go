@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+)
+
+// LoginStorage storage to the login session model.
+type LoginStorage struct {
+	db *database
+}
+
+// Create stores a new login session.
+func (s LoginStorage) Create(ls *login.Session) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.createLogin(ls)
+}
+
+// Rotate revokes a login session and stores the next one. It fails with
+// login.ErrReused if the session was already revoked.
+func (s LoginStorage) Rotate(old login.Session, next *login.Session) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored := s.db.sessions[old.ID]
+	if !live(stored.Model) || stored.RevokedAt != nil {
+		return login.ErrReused
+	}
+
+	s.db.update(s.db.sessions, old.ID, map[string]interface{}{"revoked_at": now()})
+
+	return s.db.createLogin(next)
+}
+
+// Revoke ends a login session.
+func (s LoginStorage) Revoke(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.revokeLogins(func(ls login.Session) bool { return ls.ID == id })
+
+	return nil
+}
+
+// RevokeFamily ends every login session of a family.
+func (s LoginStorage) RevokeFamily(family string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.revokeLogins(func(ls login.Session) bool { return ls.Family == family })
+
+	return nil
+}
+
+// RevokeAll ends every login session of a user.
+func (s LoginStorage) RevokeAll(userID uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.revokeLogins(func(ls login.Session) bool { return ls.UserID == userID })
+
+	return nil
+}
+
+// GetByID returns a login session by ID.
+func (s LoginStorage) GetByID(id uint) (login.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	ls := s.db.sessions[id]
+	if !live(ls.Model) {
+		return login.Session{}, storage.ErrNotFound
+	}
+
+	return ls, nil
+}
+
+// GetByToken returns the login session of a refresh token.
+func (s LoginStorage) GetByToken(token string) (login.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	hash := login.HashToken(token)
+	for _, id := range ids(s.db.sessions) {
+		if ls := s.db.sessions[id]; live(ls.Model) && ls.TokenHash == hash {
+			detach(&ls)
+			return ls, nil
+		}
+	}
+
+	return login.Session{}, storage.ErrNotFound
+}
+
+// GetActive returns the login sessions of a user not revoked nor expired.
+func (s LoginStorage) GetActive(userID uint) (login.Sessions, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	now := time.Now()
+	sessions := login.Sessions{}
+	for _, id := range ids(s.db.sessions) {
+		ls := s.db.sessions[id]
+		if live(ls.Model) && ls.UserID == userID && ls.RevokedAt == nil && ls.ExpiresAt.After(now) {
+			sessions = append(sessions, ls)
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	detach(&sessions)
+	return sessions, nil
+}
+
+// RecordLockout stores a lockout after failed logins.
+func (s LoginStorage) RecordLockout(l *login.Lockout) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	err := s.db.insert(s.db.lockouts, l)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// GetLockouts returns the lockouts since a time, the newest first.
+func (s LoginStorage) GetLockouts(since time.Time) (login.Lockouts, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	lockouts := login.Lockouts{}
+	for _, id := range ids(s.db.lockouts) {
+		if l := s.db.lockouts[id]; live(l.Model) && !l.CreatedAt.Before(since) {
+			lockouts = append(lockouts, l)
+		}
+	}
+
+	sort.SliceStable(lockouts, func(i, j int) bool {
+		return lockouts[i].CreatedAt.After(lockouts[j].CreatedAt)
+	})
+
+	return lockouts, nil
+}
+
+// createLogin stores a login session, its token hash is unique.
+func (db *database) createLogin(ls *login.Session) error {
+	for _, stored := range db.sessions {
+		if stored.TokenHash == ls.TokenHash {
+			return storage.ErrNotInsert
+		}
+	}
+
+	err := db.insert(db.sessions, ls)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// revokeLogins ends the login sessions not revoked yet that match.
+func (db *database) revokeLogins(match func(ls login.Session) bool) {
+	for _, id := range ids(db.sessions) {
+		ls := db.sessions[id]
+		if ls.RevokedAt == nil && match(ls) {
+			db.update(db.sessions, id, map[string]interface{}{"revoked_at": now()})
+		}
+	}
+}
@@ -0,0 +1,476 @@
+// Package memory keeps every model in memory with the same behavior as the
+// database storage, so the API and its tests can run without a database.
+package memory
+
+import (
+	"database/sql"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/ad"
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/click"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/device"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+	"gitlab.com/menuxd/api-rest/pkg/model"
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/promotion"
+	"gitlab.com/menuxd/api-rest/pkg/question"
+	"gitlab.com/menuxd/api-rest/pkg/rating"
+	"gitlab.com/menuxd/api-rest/pkg/register"
+	"gitlab.com/menuxd/api-rest/pkg/stay"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+	"gitlab.com/menuxd/api-rest/pkg/waiter"
+)
+
+// database is the tables of the models by ID. A single lock guards them
+// all, so every operation is atomic as in a transaction.
+type database struct {
+	mu  sync.RWMutex
+	seq map[reflect.Type]uint
+
+	ads           map[uint]ad.Ad
+	apiKeys       map[uint]apikey.Key
+	bills         map[uint]bill.Bill
+	lines         map[uint]bill.Line
+	parts         map[uint]bill.Part
+	taxLines      map[uint]bill.TaxLine
+	categories    map[uint]category.Category
+	clicks        map[uint]click.Click
+	clients       map[uint]client.Client
+	devices       map[uint]device.Device
+	dishes        map[uint]dish.Dish
+	ingredients   map[uint]dish.Ingredient
+	sessions      map[uint]login.Session
+	lockouts      map[uint]login.Lockout
+	notifications map[uint]notification.Notification
+	orders        map[uint]order.Order
+	items         map[uint]order.Item
+	selected      map[uint]order.IngredientSelected
+	payments      map[uint]payment.Payment
+	promotions    map[uint]promotion.Promotion
+	questions     map[uint]question.Question
+	ratings       map[uint]rating.Rating
+	registers     map[uint]register.Session
+	stays         map[uint]stay.Stay
+	tables        map[uint]table.Table
+	users         map[uint]user.User
+	resets        map[uint]user.Reset
+	recoveryCodes map[uint]user.RecoveryCode
+	policies      map[string]user.TwoFactorPolicy
+	totpSteps     map[uint]int64
+	challenges    map[string]user.UsedChallenge
+	waiters       map[uint]waiter.Waiter
+}
+
+// newDatabase returns an empty database.
+func newDatabase() *database {
+	return &database{
+		seq:           make(map[reflect.Type]uint),
+		ads:           make(map[uint]ad.Ad),
+		apiKeys:       make(map[uint]apikey.Key),
+		bills:         make(map[uint]bill.Bill),
+		lines:         make(map[uint]bill.Line),
+		parts:         make(map[uint]bill.Part),
+		taxLines:      make(map[uint]bill.TaxLine),
+		categories:    make(map[uint]category.Category),
+		clicks:        make(map[uint]click.Click),
+		clients:       make(map[uint]client.Client),
+		devices:       make(map[uint]device.Device),
+		dishes:        make(map[uint]dish.Dish),
+		ingredients:   make(map[uint]dish.Ingredient),
+		sessions:      make(map[uint]login.Session),
+		lockouts:      make(map[uint]login.Lockout),
+		notifications: make(map[uint]notification.Notification),
+		orders:        make(map[uint]order.Order),
+		items:         make(map[uint]order.Item),
+		selected:      make(map[uint]order.IngredientSelected),
+		payments:      make(map[uint]payment.Payment),
+		promotions:    make(map[uint]promotion.Promotion),
+		questions:     make(map[uint]question.Question),
+		ratings:       make(map[uint]rating.Rating),
+		registers:     make(map[uint]register.Session),
+		stays:         make(map[uint]stay.Stay),
+		tables:        make(map[uint]table.Table),
+		users:         make(map[uint]user.User),
+		resets:        make(map[uint]user.Reset),
+		recoveryCodes: make(map[uint]user.RecoveryCode),
+		policies:      make(map[string]user.TwoFactorPolicy),
+		totpSteps:     make(map[uint]int64),
+		challenges:    make(map[string]user.UsedChallenge),
+		waiters:       make(map[uint]waiter.Waiter),
+	}
+}
+
+// New returns the storage of every model on a new empty database.
+func New() storage.Backend {
+	db := newDatabase()
+
+	return storage.Backend{
+		Ads:           AdStorage{db},
+		APIKeys:       APIKeyStorage{db},
+		Bills:         BillStorage{db},
+		Categories:    CategoryStorage{db},
+		Clients:       ClientStorage{db},
+		Devices:       DeviceStorage{db},
+		Dishes:        DishStorage{db},
+		Logins:        LoginStorage{db},
+		Notifications: NotificationStorage{db},
+		Orders:        OrderStorage{db},
+		Payments:      PaymentStorage{db},
+		Promotions:    PromotionStorage{db},
+		Questions:     QuestionStorage{db},
+		Ratings:       RatingStorage{db},
+		Registers:     RegisterStorage{db},
+		Stays:         StayStorage{db},
+		Tables:        TableStorage{db},
+		Tenants:       TenantStorage{db},
+		Users:         UserStorage{db},
+		Waiters:       WaiterStorage{db},
+	}
+}
+
+// live checks that a row exists and was not deleted.
+func live(m model.Model) bool {
+	return m.ID != 0 && m.DeletedAt == nil
+}
+
+// now returns a pointer to the current time.
+func now() *time.Time {
+	t := time.Now()
+	return &t
+}
+
+// ids returns the IDs of a table in order.
+func ids(table interface{}) []uint {
+	keys := reflect.ValueOf(table).MapKeys()
+	result := make([]uint, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, uint(k.Uint()))
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return result
+}
+
+// insert stores a row in a table with a new ID, unless it has one that is
+// free. As the database does, the row given gets its ID, timestamps and
+// defaults, and only its columns are stored.
+func (db *database) insert(table interface{}, row interface{}) error {
+	if h, ok := row.(interface{ BeforeSave() error }); ok {
+		err := h.BeforeSave()
+		if err != nil {
+			return err
+		}
+	}
+
+	v := reflect.ValueOf(row).Elem()
+	t := reflect.ValueOf(table)
+
+	id := v.FieldByName("ID")
+	if id.Uint() == 0 {
+		db.seq[v.Type()]++
+		for t.MapIndex(reflect.ValueOf(db.seq[v.Type()])).IsValid() {
+			db.seq[v.Type()]++
+		}
+		id.SetUint(uint64(db.seq[v.Type()]))
+	} else if t.MapIndex(reflect.ValueOf(uint(id.Uint()))).IsValid() {
+		return storage.ErrNotInsert
+	} else if uint(id.Uint()) > db.seq[v.Type()] {
+		db.seq[v.Type()] = uint(id.Uint())
+	}
+
+	at := time.Now()
+	for _, name := range []string{"CreatedAt", "UpdatedAt"} {
+		f := v.FieldByName(name)
+		if f.IsValid() && f.Interface().(time.Time).IsZero() {
+			f.Set(reflect.ValueOf(at))
+		}
+	}
+
+	defaults(v)
+	t.SetMapIndex(reflect.ValueOf(uint(id.Uint())), columns(v))
+
+	return nil
+}
+
+// update sets the columns of a row not deleted, by column or field name as
+// gorm does, and its UpdatedAt. Unknown columns and values that don't fit
+// are left out. It returns whether there was a row to update.
+func (db *database) update(table interface{}, id uint, updates map[string]interface{}) bool {
+	t := reflect.ValueOf(table)
+	stored := t.MapIndex(reflect.ValueOf(id))
+	if !stored.IsValid() {
+		return false
+	}
+
+	v := reflect.New(stored.Type()).Elem()
+	v.Set(stored)
+	if d := v.FieldByName("DeletedAt"); d.IsValid() && !d.IsNil() {
+		return false
+	}
+
+	for key, value := range updates {
+		f, ok := column(v, key)
+		if ok {
+			set(f, value)
+		}
+	}
+
+	if f := v.FieldByName("UpdatedAt"); f.IsValid() {
+		f.Set(reflect.ValueOf(time.Now()))
+	}
+
+	t.SetMapIndex(reflect.ValueOf(id), columns(v))
+
+	return true
+}
+
+// detach replaces a value by a deep copy, so the values returned never
+// share memory with the rows stored.
+func detach(ptr interface{}) {
+	v := reflect.ValueOf(ptr).Elem()
+	v.Set(clone(v))
+}
+
+// clone returns a deep copy of a value.
+func clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(clone(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(clone(v.Index(i)))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(clone(v.Field(i)))
+			}
+		}
+		return c
+	}
+
+	return v
+}
+
+// columns returns a copy of a row with only its columns, without the
+// related models and the fields that aren't stored.
+func columns(v reflect.Value) reflect.Value {
+	c := clone(v)
+	eachField(c, func(f reflect.StructField, fv reflect.Value) {
+		if !isColumn(f) {
+			fv.Set(reflect.Zero(f.Type))
+		}
+	})
+
+	return c
+}
+
+// eachField calls fn with each field of a struct, and those of the structs
+// embedded in it.
+func eachField(v reflect.Value, fn func(f reflect.StructField, fv reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			eachField(v.Field(i), fn)
+			continue
+		}
+
+		fn(f, v.Field(i))
+	}
+}
+
+// isColumn checks that a field is stored: it isn't ignored nor a related
+// model.
+func isColumn(f reflect.StructField) bool {
+	if _, ok := gormTag(f, "-"); ok {
+		return false
+	}
+
+	t := f.Type
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	return t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{})
+}
+
+// gormTag returns a setting of the gorm tag of a field.
+func gormTag(f reflect.StructField, key string) (string, bool) {
+	for _, setting := range strings.Split(f.Tag.Get("gorm"), ";") {
+		kv := strings.SplitN(setting, ":", 2)
+		if strings.TrimSpace(strings.ToUpper(kv[0])) != strings.ToUpper(key) {
+			continue
+		}
+
+		if len(kv) == 1 {
+			return "", true
+		}
+
+		return strings.TrimSpace(kv[1]), true
+	}
+
+	return "", false
+}
+
+// columnName returns the name of the column of a field.
+func columnName(f reflect.StructField) string {
+	if name, ok := gormTag(f, "column"); ok {
+		return name
+	}
+
+	return gorm.ToColumnName(f.Name)
+}
+
+// column returns the column of a row by column or field name, preferring
+// an exact match as gorm does.
+func column(v reflect.Value, key string) (reflect.Value, bool) {
+	var match reflect.Value
+	found := false
+	eachField(v, func(f reflect.StructField, fv reflect.Value) {
+		if found || !isColumn(f) {
+			return
+		}
+
+		if f.Name == key || columnName(f) == key {
+			match, found = fv, true
+			return
+		}
+
+		if !match.IsValid() && columnName(f) == gorm.ToColumnName(key) {
+			match = fv
+		}
+	})
+
+	return match, match.IsValid()
+}
+
+// defaults sets the default of the blank columns of a row.
+func defaults(v reflect.Value) {
+	eachField(v, func(f reflect.StructField, fv reflect.Value) {
+		value, ok := gormTag(f, "default")
+		if !ok || !isColumn(f) || !isZero(fv) || value == "null" {
+			return
+		}
+
+		value = strings.Trim(value, "'")
+		switch fv.Kind() {
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err == nil {
+				fv.SetBool(b)
+			}
+		case reflect.String:
+			fv.SetString(value)
+		default:
+			n, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				set(fv, n)
+			}
+		}
+	})
+}
+
+// isZero checks that a value is the zero value of its type.
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// set stores a value in a column, converting it as the database would. It
+// returns whether the value fits.
+func set(f reflect.Value, value interface{}) bool {
+	v := reflect.ValueOf(value)
+	for v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		f.Set(reflect.Zero(f.Type()))
+		return true
+	}
+
+	if f.Kind() == reflect.Ptr {
+		p := reflect.New(f.Type().Elem())
+		if !set(p.Elem(), v.Interface()) {
+			return false
+		}
+
+		f.Set(p)
+		return true
+	}
+
+	if v.Type() == f.Type() {
+		f.Set(clone(v))
+		return true
+	}
+
+	if s, ok := f.Addr().Interface().(sql.Scanner); ok {
+		src := v.Interface()
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			src = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			src = int64(v.Uint())
+		}
+
+		return s.Scan(src) == nil
+	}
+
+	if kind(v.Kind()) != kind(f.Kind()) || !v.Type().ConvertibleTo(f.Type()) {
+		return false
+	}
+
+	f.Set(v.Convert(f.Type()))
+	return true
+}
+
+// kind groups the kinds that convert into each other without changing
+// their meaning.
+func kind(k reflect.Kind) reflect.Kind {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+
+	return k
+}
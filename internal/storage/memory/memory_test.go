@@ -0,0 +1,11 @@
+package memory
+
+import (
+	"testing"
+
+	"gitlab.com/menuxd/api-rest/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, New())
+}
@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+)
+
+// pendingLimit is the most notifications replayed at once.
+const pendingLimit = 200
+
+// NotificationStorage storage to the notification model.
+type NotificationStorage struct {
+	db *database
+}
+
+// Create stores a notification, pending until a waiter acknowledges it.
+func (s NotificationStorage) Create(n *notification.Notification) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if n.Table != nil {
+		n.TableID = n.Table.ID
+	}
+	n.Active = true
+	n.AckedAt = nil
+	n.AckedBy = nil
+
+	err := s.db.insert(s.db.notifications, n)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// Ack marks a notification as handled by a waiter, none when waiterID is 0.
+func (s NotificationStorage) Ack(id, waiterID uint) (notification.Notification, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	n, err := s.db.notification(id)
+	if err != nil {
+		return notification.Notification{}, err
+	}
+
+	if !n.Active {
+		return notification.Notification{}, notification.ErrAlreadyAcked
+	}
+
+	now := time.Now()
+	n.Active = false
+	n.AckedAt = &now
+	n.AckedBy = nil
+	if waiterID != 0 {
+		n.AckedBy = &waiterID
+	}
+
+	s.db.update(s.db.notifications, id, map[string]interface{}{
+		"active":   false,
+		"acked_at": n.AckedAt,
+		"acked_by": n.AckedBy,
+	})
+
+	return n, nil
+}
+
+// GetByID returns a notification by ID with its table.
+func (s NotificationStorage) GetByID(id uint) (notification.Notification, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.notification(id)
+}
+
+// GetPending returns the notifications of a client not acknowledged yet,
+// after the ID given as cursor, the oldest first.
+func (s NotificationStorage) GetPending(clientID, since uint) ([]notification.Notification, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	notifications := []notification.Notification{}
+	for _, id := range ids(s.db.notifications) {
+		if len(notifications) == pendingLimit {
+			break
+		}
+
+		n := s.db.notifications[id]
+		if n.ClientID != clientID || n.ID <= since || !n.Active {
+			continue
+		}
+
+		s.db.setTable(&n)
+		notifications = append(notifications, n)
+	}
+
+	detach(&notifications)
+	return notifications, nil
+}
+
+// notification returns a notification by ID with its table.
+func (db *database) notification(id uint) (notification.Notification, error) {
+	n, ok := db.notifications[id]
+	if !ok {
+		return notification.Notification{}, storage.ErrNotFound
+	}
+
+	db.setTable(&n)
+
+	detach(&n)
+	return n, nil
+}
+
+// setTable loads the table of a notification.
+func (db *database) setTable(n *notification.Notification) {
+	if n.TableID == 0 {
+		return
+	}
+
+	if t, err := db.table(n.TableID); err == nil {
+		n.Table = &t
+	}
+}
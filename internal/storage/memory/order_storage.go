@@ -0,0 +1,433 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+)
+
+// OrderStorage storage to the order model.
+type OrderStorage struct {
+	db *database
+}
+
+// Create create a new order.
+func (s OrderStorage) Create(o *order.Order) (order.Order, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	o.Items = []order.Item{}
+	o.Canceled = false
+	o.BillID = nil
+	o.Lifecycle = order.NewLifecycle()
+	if o.Table != nil {
+		o.TableID = o.Table.ID
+	}
+	o.Table = nil
+
+	err := s.db.insert(s.db.orders, o)
+	if err != nil {
+		return order.Order{}, storage.ErrNotInsert
+	}
+
+	return *o, nil
+}
+
+// Add adds items to an order by ID, with the ingredients selected. Orders
+// paid, cancelled or rejected take no more items and fail with
+// order.ErrInvalidTransition.
+func (s OrderStorage) Add(id uint, items []order.Item) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	o, ok := s.db.orders[id]
+	if !ok || !live(o.Model) {
+		return storage.ErrNotFound
+	}
+
+	if o.IsFinal() {
+		return order.ErrInvalidTransition
+	}
+
+	err := s.db.references(tenant.Dishes, o.ClientID, order.DishIDs(items)...)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range items {
+		if i.Dish != nil {
+			i.DishID = i.Dish.ID
+		}
+		ingredients := i.Ingredients
+		i.Dish = nil
+		i.OrderID = id
+		i.Active = true
+		i.Ready = false
+		i.Lifecycle = order.NewLifecycle()
+		i.Ingredients = nil
+		i.SelectedIngredients = nil
+
+		err = s.db.insert(s.db.items, &i)
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+
+		for _, ing := range ingredients {
+			is := order.IngredientSelected{}
+			is.ItemID = i.ID
+			is.Active = ing.Active
+			is.IngredientID = ing.ID
+
+			err = s.db.insert(s.db.selected, &is)
+			if err != nil {
+				return storage.ErrNotInsert
+			}
+		}
+	}
+
+	return nil
+}
+
+// PatchItem applies a patch to an item, that can only cancel it.
+func (s OrderStorage) PatchItem(id uint, updates map[string]interface{}) error {
+	cancels, err := order.PatchCancels(updates)
+	if err != nil || !cancels {
+		return err
+	}
+
+	_, err = s.TransitionItem(id, order.Cancelled)
+	return err
+}
+
+// Update set order's canceled.
+func (s OrderStorage) Update(id uint, o *order.Order) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored := s.db.orders[id]
+	if !live(stored.Model) {
+		return storage.ErrNotFound
+	}
+
+	if stored.Canceled == o.Canceled {
+		return nil
+	}
+
+	if !o.Canceled {
+		return order.ErrInvalidTransition
+	}
+
+	_, err := s.db.transitionOrder(id, order.Cancelled)
+	return err
+}
+
+// TransitionItem moves an item to the status given.
+func (s OrderStorage) TransitionItem(id uint, to order.Status) (order.Item, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.changeItem(id, func(i *order.Item) error {
+		return i.Transition(to, time.Now())
+	})
+}
+
+// AdvanceItem moves an item forward along the happy path up to the status
+// given.
+func (s OrderStorage) AdvanceItem(id uint, to order.Status) (order.Item, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.changeItem(id, func(i *order.Item) error {
+		return i.Advance(to, time.Now())
+	})
+}
+
+// AdvanceItems moves items forward along the happy path up to the status
+// given, all of them or none.
+func (s OrderStorage) AdvanceItems(ids []uint, to order.Status) ([]order.Item, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		i := s.db.items[id]
+		if !live(i.Model) {
+			return []order.Item{}, storage.ErrNotFound
+		}
+
+		err := i.Advance(to, now)
+		if err != nil {
+			return []order.Item{}, err
+		}
+	}
+
+	items := []order.Item{}
+	for _, id := range ids {
+		i, err := s.db.changeItem(id, func(i *order.Item) error {
+			return i.Advance(to, now)
+		})
+		if err != nil {
+			return []order.Item{}, err
+		}
+
+		items = append(items, i)
+	}
+
+	return items, nil
+}
+
+// TransitionOrder moves an order to the status given. Every item of the
+// order that can follow it is moved too.
+func (s OrderStorage) TransitionOrder(id uint, to order.Status) (order.Order, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.transitionOrder(id, to)
+}
+
+// GetAll returns all stored orders.
+func (s OrderStorage) GetAll(clientID uint) ([]order.Order, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	result := []order.Order{}
+	for _, id := range ids(s.db.orders) {
+		o := s.db.orders[id]
+		if !live(o.Model) || o.ClientID != clientID {
+			continue
+		}
+
+		result = append(result, s.db.loadOrder(o))
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	detach(&result)
+	return result, nil
+}
+
+// GetAllActive returns the orders not canceled of a client, by table.
+func (s OrderStorage) GetAllActive(clientID uint) ([][]order.Order, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	tables := [][]order.Order{}
+	index := make(map[uint]int)
+	for _, id := range ids(s.db.orders) {
+		o := s.db.orders[id]
+		if !live(o.Model) || o.ClientID != clientID || o.Canceled {
+			continue
+		}
+
+		i, ok := index[o.TableID]
+		if !ok {
+			i = len(tables)
+			index[o.TableID] = i
+			tables = append(tables, []order.Order{})
+		}
+
+		tables[i] = append(tables[i], s.db.loadOrder(o))
+	}
+
+	detach(&tables)
+	return tables, nil
+}
+
+// GetUnbilled returns the orders of a table that are not canceled and have
+// not been billed yet.
+func (s OrderStorage) GetUnbilled(tableID uint) ([]order.Order, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	orders := []order.Order{}
+	for _, id := range ids(s.db.orders) {
+		o := s.db.orders[id]
+		if !live(o.Model) || o.Canceled || o.BillID != nil || o.TableID != tableID {
+			continue
+		}
+
+		orders = append(orders, s.db.loadOrder(o))
+	}
+
+	detach(&orders)
+	return orders, nil
+}
+
+// GetByID returns an order by ID with its table and items.
+func (s OrderStorage) GetByID(id uint) (order.Order, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.order(id)
+}
+
+// GetByIDs returns orders by ID in the order given, failing with
+// ErrNotFound if any of them is missing.
+func (s OrderStorage) GetByIDs(ids []uint) ([]order.Order, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	orders := []order.Order{}
+	for _, id := range ids {
+		o := s.db.orders[id]
+		if !live(o.Model) {
+			return []order.Order{}, storage.ErrNotFound
+		}
+
+		orders = append(orders, s.db.loadOrder(o))
+	}
+
+	detach(&orders)
+	return orders, nil
+}
+
+// order returns an order by ID with its items, the table is required.
+func (db *database) order(id uint) (order.Order, error) {
+	o := db.orders[id]
+	if !live(o.Model) {
+		return order.Order{}, storage.ErrNotFound
+	}
+
+	if _, err := db.table(o.TableID); err != nil {
+		return order.Order{}, storage.ErrNotFound
+	}
+
+	o = db.loadOrder(o)
+
+	detach(&o)
+	return o, nil
+}
+
+// loadOrder returns an order with its table and items, each one with its
+// dish and the ingredients selected. A missing table keeps only its ID.
+func (db *database) loadOrder(o order.Order) order.Order {
+	t, err := db.table(o.TableID)
+	if err != nil {
+		t.ID = o.TableID
+	}
+	o.Table = &t
+
+	o.Items = []order.Item{}
+	for _, id := range ids(db.items) {
+		i := db.items[id]
+		if !live(i.Model) || i.OrderID != o.ID {
+			continue
+		}
+
+		i.SelectedIngredients = db.selectedIngredients(i.ID)
+		d, err := db.dish(i.DishID)
+		if err != nil {
+			d = dish.Dish{}
+		}
+		i.Dish = &d
+
+		o.Items = append(o.Items, i)
+	}
+
+	return o
+}
+
+// selectedIngredients returns the ingredients selected of an item, with
+// those ingredients that still exist.
+func (db *database) selectedIngredients(itemID uint) []order.IngredientSelected {
+	result := []order.IngredientSelected{}
+	for _, id := range ids(db.selected) {
+		is := db.selected[id]
+		if !live(is.Model) || is.ItemID != itemID {
+			continue
+		}
+
+		ing := db.ingredients[is.IngredientID]
+		if !live(ing.Model) {
+			continue
+		}
+
+		is.Ingredient = &ing
+		result = append(result, is)
+	}
+
+	return result
+}
+
+// changeItem applies a status change to an item and persists it.
+func (db *database) changeItem(id uint, change func(i *order.Item) error) (order.Item, error) {
+	i := db.items[id]
+	if !live(i.Model) {
+		return order.Item{}, storage.ErrNotFound
+	}
+
+	err := change(&i)
+	if err != nil {
+		return order.Item{}, err
+	}
+
+	db.saveItemLifecycle(&i)
+
+	detach(&i)
+	return i, nil
+}
+
+// transitionOrder moves an order and the items that can follow it to the
+// status given.
+func (db *database) transitionOrder(id uint, to order.Status) (order.Order, error) {
+	o := db.orders[id]
+	if !live(o.Model) {
+		return order.Order{}, storage.ErrNotFound
+	}
+
+	now := time.Now()
+	err := o.Transition(to, now)
+	if err != nil {
+		return order.Order{}, err
+	}
+
+	updates := lifecycleUpdates(o.Lifecycle)
+	updates["canceled"] = o.Canceled
+	db.update(db.orders, id, updates)
+
+	o.Items = []order.Item{}
+	for _, iid := range ids(db.items) {
+		i := db.items[iid]
+		if !live(i.Model) || i.OrderID != o.ID {
+			continue
+		}
+
+		if i.CanTransition(to) {
+			i.Transition(to, now)
+			db.saveItemLifecycle(&i)
+		}
+
+		o.Items = append(o.Items, i)
+	}
+
+	detach(&o)
+	return o, nil
+}
+
+// saveItemLifecycle persists the status of an item.
+func (db *database) saveItemLifecycle(i *order.Item) {
+	updates := lifecycleUpdates(i.Lifecycle)
+	updates["active"] = i.Active
+	updates["ready"] = i.Ready
+	db.update(db.items, i.ID, updates)
+}
+
+// lifecycleUpdates returns the columns of a lifecycle to update.
+func lifecycleUpdates(l order.Lifecycle) map[string]interface{} {
+	return map[string]interface{}{
+		"status":       l.Status,
+		"accepted_at":  l.AcceptedAt,
+		"preparing_at": l.PreparingAt,
+		"ready_at":     l.ReadyAt,
+		"served_at":    l.ServedAt,
+		"paid_at":      l.PaidAt,
+		"cancelled_at": l.CancelledAt,
+		"rejected_at":  l.RejectedAt,
+	}
+}
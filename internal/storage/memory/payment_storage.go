@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+)
+
+// PaymentStorage storage to the payment model.
+type PaymentStorage struct {
+	db *database
+}
+
+// Create records a payment against its bill and updates whether the bill is
+// paid from the resulting balance.
+func (s PaymentStorage) Create(p *payment.Payment) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	b, err := s.db.bill(p.BillID)
+	if err != nil {
+		return err
+	}
+
+	wasPaid := b.Paid
+	err = b.Apply(p)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.insert(s.db.payments, p)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return s.db.reconcile(b, wasPaid)
+}
+
+// GetByBill returns the payments of a bill.
+func (s PaymentStorage) GetByBill(billID uint) (payment.Payments, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	payments := s.db.billPayments(billID)
+
+	detach(&payments)
+	return payments, nil
+}
+
+// GetByID returns a payment by ID.
+func (s PaymentStorage) GetByID(id uint) (payment.Payment, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	p := s.db.payments[id]
+	if !live(p.Model) {
+		return payment.Payment{}, storage.ErrNotFound
+	}
+
+	detach(&p)
+	return p, nil
+}
@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/click"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/promotion"
+)
+
+// PromotionStorage storage to the promotion model.
+type PromotionStorage struct {
+	db *database
+}
+
+// Create create a new Promotion.
+func (s PromotionStorage) Create(p *promotion.Promotion) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if p.Title == "" ||
+		p.Picture == "" ||
+		p.DishID == 0 ||
+		p.StartAt == "" ||
+		p.EndAt == "" {
+		return storage.ErrRequiredField
+	}
+
+	err := s.db.references(tenant.Dishes, p.ClientID, p.DishID)
+	if err != nil {
+		return err
+	}
+
+	p.DaysString = promotion.SetDaysString(p.Days)
+	err = s.db.insert(s.db.promotions, p)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// Update update a promotion by ID.
+func (s PromotionStorage) Update(id uint, p *promotion.Promotion) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if p.Title == "" ||
+		p.Picture == "" ||
+		p.DishID == 0 ||
+		p.StartAt == "" ||
+		p.EndAt == "" {
+		return storage.ErrRequiredField
+	}
+
+	err := s.db.referencesOf(tenant.Promotions, id, tenant.Dishes, p.DishID)
+	if err != nil {
+		return err
+	}
+
+	p.DaysString = promotion.SetDaysString(p.Days)
+
+	s.db.update(s.db.promotions, id, map[string]interface{}{
+		"title":      p.Title,
+		"picture":    p.Picture,
+		"DaysString": p.DaysString,
+		"dish_id":    p.DishID,
+		"start_at":   p.StartAt,
+		"end_at":     p.EndAt,
+	})
+
+	return nil
+}
+
+// Delete remove a promotion by ID.
+func (s PromotionStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if p := s.db.promotions[id]; live(p.Model) {
+		p.DeletedAt = now()
+		s.db.promotions[id] = p
+	}
+
+	return nil
+}
+
+// AddClick create a new click.
+func (s PromotionStorage) AddClick(promotionID uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.addClick(click.Promotion, promotionID)
+}
+
+// GetAll returns all stored promotions.
+func (s PromotionStorage) GetAll(clientID uint) (promotion.Promotions, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.clientPromotions(clientID), nil
+}
+
+// GetAllActive returns the promotions of a client active now.
+func (s PromotionStorage) GetAllActive(clientID uint) (promotion.Promotions, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	promotions := s.db.clientPromotions(clientID)
+
+	c, err := s.db.client(clientID)
+	if err != nil {
+		return []promotion.Promotion{}, err
+	}
+
+	result := promotion.Promotions{}
+	now := time.Now()
+	for _, p := range promotions {
+		p.DaysString = promotion.SetDaysString(p.Days)
+		if p.IsActive(now, c) {
+			p.DaysString = ""
+			result = append(result, p)
+		}
+	}
+
+	return result, nil
+}
+
+// GetByID returns a promotion by ID.
+func (s PromotionStorage) GetByID(id uint) (promotion.Promotion, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	p := s.db.promotions[id]
+	if !live(p.Model) {
+		return promotion.Promotion{}, storage.ErrNotFound
+	}
+
+	p.Days = promotion.SetDays(p.DaysString)
+	p.DaysString = ""
+	p.Clicks = s.db.getClicks(click.Promotion, p.ID)
+	p.Dish, _ = s.db.dish(p.DishID)
+
+	detach(&p)
+	return p, nil
+}
+
+// clientPromotions returns the promotions of a client with their days,
+// clicks and dish.
+func (db *database) clientPromotions(clientID uint) promotion.Promotions {
+	promotions := promotion.Promotions{}
+	for _, id := range ids(db.promotions) {
+		p := db.promotions[id]
+		if !live(p.Model) || p.ClientID != clientID {
+			continue
+		}
+
+		p.Days = promotion.SetDays(p.DaysString)
+		p.DaysString = ""
+		p.Clicks = db.getClicks(click.Promotion, p.ID)
+		if d, err := db.dish(p.DishID); err == nil {
+			p.Dish = d
+		}
+
+		promotions = append(promotions, p)
+	}
+
+	detach(&promotions)
+	return promotions
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"sort"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/question"
+)
+
+// QuestionStorage storage to the question model.
+type QuestionStorage struct {
+	db *database
+}
+
+// Create create a new question.
+func (s QuestionStorage) Create(q *question.Question) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.createQuestion(q)
+}
+
+// Update update a question by ID.
+func (s QuestionStorage) Update(id uint, q *question.Question) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	q.ID = id
+
+	if q.Main {
+		return storage.ErrNotInsert
+	}
+
+	s.db.update(s.db.questions, id, map[string]interface{}{"text": q.Text})
+
+	return nil
+}
+
+// Delete remove a question by ID.
+func (s QuestionStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if q := s.db.questions[id]; live(q.Model) {
+		q.DeletedAt = now()
+		s.db.questions[id] = q
+	}
+
+	return nil
+}
+
+// GetAll returns all stored questions.
+func (s QuestionStorage) GetAll(clientID uint) ([]question.Question, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	questions := []question.Question{}
+	for _, id := range ids(s.db.questions) {
+		q := s.db.questions[id]
+		if !live(q.Model) || q.ClientID != clientID {
+			continue
+		}
+
+		q.Rating = s.db.questionRatings(q.ID)
+		questions = append(questions, q)
+	}
+
+	sort.SliceStable(questions, func(i, j int) bool {
+		return questions[i].Text < questions[j].Text
+	})
+
+	detach(&questions)
+	return questions, nil
+}
+
+// GetByID returns a question by ID.
+func (s QuestionStorage) GetByID(id uint) (question.Question, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	q := s.db.questions[id]
+	if !live(q.Model) {
+		return question.Question{}, storage.ErrNotFound
+	}
+
+	q.Rating = s.db.questionRatings(q.ID)
+
+	detach(&q)
+	return q, nil
+}
+
+// createQuestion stores a question, that is never the main one.
+func (db *database) createQuestion(q *question.Question) error {
+	if q.Text == "" {
+		return storage.ErrNotInsert
+	}
+
+	q.Main = false
+
+	err := db.insert(db.questions, q)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/rating"
+)
+
+// RatingStorage storage to the rating model.
+type RatingStorage struct {
+	db *database
+}
+
+// Create create a new rating.
+func (s RatingStorage) Create(r *rating.Rating) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	err := s.db.insert(s.db.ratings, r)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// GetAll returns all stored ratings.
+func (s RatingStorage) GetAll() ([]rating.Rating, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	ratings := []rating.Rating{}
+	for _, id := range ids(s.db.ratings) {
+		if r := s.db.ratings[id]; live(r.Model) {
+			ratings = append(ratings, r)
+		}
+	}
+
+	detach(&ratings)
+	return ratings, nil
+}
+
+// GetAllByQuestion returns all stored ratings by question.
+func (s RatingStorage) GetAllByQuestion(questionID uint) ([]rating.Rating, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	ratings := s.db.questionRatings(questionID)
+
+	detach(&ratings)
+	return ratings, nil
+}
+
+// GetByID returns a rating by ID.
+func (s RatingStorage) GetByID(id uint) (rating.Rating, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	r := s.db.ratings[id]
+	if !live(r.Model) {
+		return rating.Rating{}, storage.ErrNotFound
+	}
+
+	detach(&r)
+	return r, nil
+}
+
+// questionRatings returns the ratings of a question.
+func (db *database) questionRatings(questionID uint) []rating.Rating {
+	ratings := []rating.Rating{}
+	for _, id := range ids(db.ratings) {
+		if r := db.ratings[id]; live(r.Model) && r.QuestionID == questionID {
+			ratings = append(ratings, r)
+		}
+	}
+
+	return ratings
+}
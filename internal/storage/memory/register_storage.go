@@ -0,0 +1,244 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/register"
+)
+
+// RegisterStorage storage to the register session model.
+type RegisterStorage struct {
+	db *database
+}
+
+// Open starts a register session, only one at a time by client.
+func (s RegisterStorage) Open(rs *register.Session) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if rs.ClientID == 0 || rs.OpeningCash < 0 {
+		return storage.ErrRequiredField
+	}
+
+	if _, err := s.db.openRegister(rs.ClientID); err == nil {
+		return register.ErrAlreadyOpen
+	}
+
+	rs.OpenedAt = time.Now()
+	rs.ClosedAt = nil
+	rs.ExpectedCash = 0
+	rs.CountedCash = 0
+	rs.Difference = 0
+
+	err := s.db.insert(s.db.registers, rs)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// Close ends a register session with the cash counted and returns its Z
+// report.
+func (s RegisterStorage) Close(id uint, counted money.Amount, note string) (register.Report, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	rs, err := s.db.register(id)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	if !rs.IsOpen() {
+		return register.Report{}, register.ErrAlreadyClosed
+	}
+
+	if counted < 0 {
+		return register.Report{}, storage.ErrBadRequest
+	}
+
+	closedAt := time.Now()
+	rs.ClosedAt = &closedAt
+	rs.CountedCash = counted
+	if note != "" {
+		rs.Note = note
+	}
+
+	r, err := s.db.report(rs)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	s.db.update(s.db.registers, id, map[string]interface{}{
+		"closed_at":     rs.ClosedAt,
+		"counted_cash":  rs.CountedCash,
+		"expected_cash": r.ExpectedCash,
+		"difference":    r.Difference,
+		"note":          rs.Note,
+	})
+
+	return r, nil
+}
+
+// GetAll returns the register sessions of a client, the last first.
+func (s RegisterStorage) GetAll(clientID uint) (register.Sessions, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	sessions := register.Sessions{}
+	for _, id := range ids(s.db.registers) {
+		if rs := s.db.registers[id]; live(rs.Model) && rs.ClientID == clientID {
+			sessions = append(sessions, rs)
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].OpenedAt.After(sessions[j].OpenedAt)
+	})
+
+	detach(&sessions)
+	return sessions, nil
+}
+
+// GetByID returns a register session by ID.
+func (s RegisterStorage) GetByID(id uint) (register.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.register(id)
+}
+
+// GetOpen returns the open register session of a client.
+func (s RegisterStorage) GetOpen(clientID uint) (register.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.openRegister(clientID)
+}
+
+// Report returns the Z report of a register session, up to now while it is
+// open.
+func (s RegisterStorage) Report(id uint) (register.Report, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	rs, err := s.db.register(id)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	return s.db.report(rs)
+}
+
+// register returns a register session by ID.
+func (db *database) register(id uint) (register.Session, error) {
+	rs := db.registers[id]
+	if !live(rs.Model) {
+		return register.Session{}, storage.ErrNotFound
+	}
+
+	detach(&rs)
+	return rs, nil
+}
+
+// openRegister returns the open register session of a client.
+func (db *database) openRegister(clientID uint) (register.Session, error) {
+	for _, id := range ids(db.registers) {
+		if rs := db.registers[id]; live(rs.Model) && rs.ClientID == clientID && rs.IsOpen() {
+			detach(&rs)
+			return rs, nil
+		}
+	}
+
+	return register.Session{}, storage.ErrNotFound
+}
+
+// report builds the Z report of a register session from the bills,
+// payments and cancelled orders of its client within the session.
+func (db *database) report(rs register.Session) (register.Report, error) {
+	now := time.Now()
+	from, to := rs.Window(now)
+	within := func(t time.Time) bool {
+		return !t.Before(from) && !t.After(to)
+	}
+
+	c, err := db.client(rs.ClientID)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	bills := bill.Bills{}
+	for _, id := range ids(db.bills) {
+		b := db.bills[id]
+		if live(b.Model) && b.ClientID == rs.ClientID && within(b.CreatedAt) {
+			bills = append(bills, b)
+		}
+	}
+
+	payments := payment.Payments{}
+	for _, id := range ids(db.payments) {
+		p := db.payments[id]
+		if live(p.Model) && p.ClientID == rs.ClientID && within(p.CreatedAt) {
+			payments = append(payments, p)
+		}
+	}
+
+	voided := make(map[uint]bool)
+	for _, id := range ids(db.items) {
+		i := db.items[id]
+		if !live(i.Model) || (i.Status != order.Cancelled && i.Status != order.Rejected) {
+			continue
+		}
+
+		if within(cancelledAt(i.Lifecycle, i.UpdatedAt)) {
+			voided[i.OrderID] = true
+		}
+	}
+
+	orders := []order.Order{}
+	for _, id := range ids(db.orders) {
+		o := db.orders[id]
+		if !live(o.Model) || o.ClientID != rs.ClientID {
+			continue
+		}
+
+		if !(o.Canceled && within(cancelledAt(o.Lifecycle, o.UpdatedAt))) && !voided[id] {
+			continue
+		}
+
+		o, err := db.order(id)
+		if err != nil {
+			continue
+		}
+
+		orders = append(orders, o)
+	}
+
+	detach(&bills)
+	detach(&payments)
+	return register.Build(rs, loc, bills, payments, orders, now), nil
+}
+
+// cancelledAt returns when an order or item was cancelled or rejected, the
+// last update for those cancelled before the lifecycle was recorded.
+func cancelledAt(l order.Lifecycle, updatedAt time.Time) time.Time {
+	switch {
+	case l.CancelledAt != nil:
+		return *l.CancelledAt
+	case l.RejectedAt != nil:
+		return *l.RejectedAt
+	}
+
+	return updatedAt
+}
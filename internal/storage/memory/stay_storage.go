@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"sort"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/stay"
+)
+
+// StayStorage storage to the stay model.
+type StayStorage struct {
+	db *database
+}
+
+// Create create a new stay.
+func (s StayStorage) Create(st *stay.Stay) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	err := s.db.insert(s.db.stays, st)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// GetAll returns all stored stay.
+func (s StayStorage) GetAll() ([]stay.Stay, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.getStays(func(st stay.Stay) bool { return true }), nil
+}
+
+// GetByClient returns all stored stay by client ID.
+func (s StayStorage) GetByClient(clientID uint) ([]stay.Stay, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.getStays(func(st stay.Stay) bool { return st.ClientID == clientID }), nil
+}
+
+// GetByID returns a stay by ID.
+func (s StayStorage) GetByID(id uint) (stay.Stay, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	st := s.db.stays[id]
+	if !live(st.Model) {
+		return stay.Stay{}, storage.ErrNotFound
+	}
+
+	return st, nil
+}
+
+// getStays returns the stays that match, the newest first.
+func (db *database) getStays(match func(st stay.Stay) bool) []stay.Stay {
+	result := []stay.Stay{}
+	for _, id := range ids(db.stays) {
+		if st := db.stays[id]; live(st.Model) && match(st) {
+			result = append(result, st)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	detach(&result)
+	return result
+}
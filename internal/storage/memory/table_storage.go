@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"sort"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+)
+
+// TableStorage storage to the table model.
+type TableStorage struct {
+	db *database
+}
+
+// Create create a new table.
+func (s TableStorage) Create(t *table.Table) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	t.Available = true
+
+	err := s.db.insert(s.db.tables, t)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// Update update a table by ID.
+func (s TableStorage) Update(id uint, t *table.Table) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	t.ID = id
+	s.db.update(s.db.tables, id, map[string]interface{}{"available": t.Available})
+
+	return nil
+}
+
+// Delete remove a table by ID.
+func (s TableStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if t := s.db.tables[id]; live(t.Model) {
+		t.DeletedAt = now()
+		s.db.tables[id] = t
+	}
+
+	return nil
+}
+
+// GetAll returns all stored tables.
+func (s TableStorage) GetAll(clientID uint) (table.Tables, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	tables := table.Tables{}
+	for _, id := range ids(s.db.tables) {
+		if t := s.db.tables[id]; live(t.Model) && t.ClientID == clientID {
+			tables = append(tables, t)
+		}
+	}
+
+	sort.SliceStable(tables, func(i, j int) bool {
+		if tables[i].Type != tables[j].Type {
+			return tables[i].Type > tables[j].Type
+		}
+
+		return tables[i].Number < tables[j].Number
+	})
+
+	return tables, nil
+}
+
+// GetByID returns a table by ID.
+func (s TableStorage) GetByID(id uint) (table.Table, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.table(id)
+}
+
+// table returns a table by ID.
+func (db *database) table(id uint) (table.Table, error) {
+	t := db.tables[id]
+	if !live(t.Model) {
+		return table.Table{}, storage.ErrNotFound
+	}
+
+	detach(&t)
+	return t, nil
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"time"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+)
+
+// TenantStorage resolves which client owns each resource.
+type TenantStorage struct {
+	db *database
+}
+
+// Clients returns the IDs of the clients of a user.
+func (s TenantStorage) Clients(userID uint) ([]uint, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	result := []uint{}
+	for _, id := range ids(s.db.clients) {
+		if c := s.db.clients[id]; live(c.Model) && c.UserID == userID {
+			result = append(result, id)
+		}
+	}
+
+	return result, nil
+}
+
+// Owner returns the client and table owning a resource. Items and ratings
+// belong to the client of their order and question. Deleted resources, or
+// those whose order or question was deleted, are not found.
+func (s TenantStorage) Owner(res tenant.Resource, id uint) (tenant.Ownership, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.owner(res, id)
+}
+
+// owner returns the client and table owning a resource.
+func (db *database) owner(res tenant.Resource, id uint) (tenant.Ownership, error) {
+	var (
+		o  tenant.Ownership
+		ok bool
+	)
+
+	switch res {
+	case tenant.Ads:
+		v, found := db.ads[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.APIKeys:
+		v, found := db.apiKeys[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Bills:
+		v, found := db.bills[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID, TableID: v.TableID}, found && live(v.Model)
+	case tenant.Categories:
+		v, found := db.categories[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Devices:
+		v, found := db.devices[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID, TableID: v.TableID}, found && live(v.Model)
+	case tenant.Dishes:
+		v, found := db.dishes[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Items:
+		i, found := db.items[id]
+		v, joined := db.orders[i.OrderID]
+		o, ok = tenant.Ownership{ClientID: v.ClientID, TableID: v.TableID}, found && live(i.Model) && joined && live(v.Model)
+	case tenant.Notifications:
+		v, found := db.notifications[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID, TableID: v.TableID}, found
+	case tenant.Orders:
+		v, found := db.orders[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID, TableID: v.TableID}, found && live(v.Model)
+	case tenant.Payments:
+		v, found := db.payments[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Promotions:
+		v, found := db.promotions[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Questions:
+		v, found := db.questions[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Ratings:
+		r, found := db.ratings[id]
+		v, joined := db.questions[r.QuestionID]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(r.Model) && joined && live(v.Model)
+	case tenant.Registers:
+		v, found := db.registers[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Stays:
+		v, found := db.stays[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	case tenant.Tables:
+		v, found := db.tables[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID, TableID: v.ID}, found && live(v.Model)
+	case tenant.Waiters:
+		v, found := db.waiters[id]
+		o, ok = tenant.Ownership{ClientID: v.ClientID}, found && live(v.Model)
+	}
+
+	if !ok {
+		return tenant.Ownership{}, storage.ErrNotFound
+	}
+
+	return o, nil
+}
+
+// references checks that the resources by ID, but the zero ones, are of the
+// client given. Those missing or of another client fail with
+// storage.ErrForeignReference.
+func (db *database) references(res tenant.Resource, clientID uint, ids ...uint) error {
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+
+		o, err := db.owner(res, id)
+		if err != nil || o.ClientID != clientID {
+			return storage.ErrForeignReference
+		}
+	}
+
+	return nil
+}
+
+// referencesOf checks the resources as references does, for the client of
+// the resource that references them.
+func (db *database) referencesOf(parent tenant.Resource, id uint, res tenant.Resource, ids ...uint) error {
+	o, err := db.owner(parent, id)
+	if err != nil {
+		return storage.ErrNotFound
+	}
+
+	return db.references(res, o.ClientID, ids...)
+}
+
+// updatedID returns the ID of a reference in the updates of a patch, 0 when
+// it is not given or is null.
+func updatedID(updates map[string]interface{}, column string) (uint, error) {
+	v, ok := updates[column]
+	if !ok || v == nil {
+		return 0, nil
+	}
+
+	id, ok := v.(float64)
+	if !ok || id < 0 {
+		return 0, storage.ErrBadRequest
+	}
+
+	return uint(id), nil
+}
+
+// Device returns the client and table of an active device.
+func (s TenantStorage) Device(id uint) (tenant.Ownership, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	d := s.db.devices[id]
+	if !live(d.Model) || !d.IsActive() {
+		return tenant.Ownership{}, tenant.ErrRevoked
+	}
+
+	return tenant.Ownership{ClientID: d.ClientID, TableID: d.TableID}, nil
+}
+
+// Session checks that a login session is active.
+func (s TenantStorage) Session(id uint) error {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	ls := s.db.sessions[id]
+	if !live(ls.Model) || !ls.IsActive(time.Now()) {
+		return tenant.ErrRevoked
+	}
+
+	return nil
+}
@@ -0,0 +1,467 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sethvargo/go-password/password"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/email"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// UserStorage storage to the user model.
+type UserStorage struct {
+	db *database
+}
+
+// Create create a new user.
+func (s UserStorage) Create(u *user.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	pass, err := password.Generate(10, 3, 0, false, false)
+	if err != nil {
+		fmt.Println(err)
+		return storage.ErrPasswordGeneration
+	}
+
+	u.Password = pass
+	u.PreparePass()
+
+	if u.ImageURL == "" {
+		u.SetGravatar()
+	}
+
+	if ok := u.ValidateEmail(); !ok {
+		fmt.Println("Email invalid")
+		return storage.ErrNotInsert
+	}
+
+	err = s.db.createUser(u)
+	if err != nil {
+		return err
+	}
+
+	e := email.NewUser(u.ID, u.Email, pass)
+	if err = e.Send(); err != nil {
+		fmt.Println(err)
+		return storage.ErrSendEmail
+	}
+
+	return nil
+}
+
+// Admin stores a confirmed admin with the password given, without sending
+// any email, so there is someone to log in with on a new database.
+func (s UserStorage) Admin(address, pass string) (user.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if pass == "" {
+		return user.User{}, storage.ErrRequiredField
+	}
+
+	u := user.New()
+	u.Email = address
+	u.Password = pass
+	u.Role = "admin"
+	u.Confirmed = true
+	u.SetGravatar()
+
+	if ok := u.ValidateEmail(); !ok {
+		return user.User{}, storage.ErrNotInsert
+	}
+
+	err := u.PreparePass()
+	if err != nil {
+		return user.User{}, err
+	}
+
+	err = s.db.createUser(u)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	u.CleanPass()
+
+	return *u, nil
+}
+
+// Update update user by ID.
+func (s UserStorage) Update(id uint, u *user.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	u.ID = id
+	s.db.update(s.db.users, id, map[string]interface{}{
+		"role":      u.Role,
+		"image_url": u.ImageURL,
+	})
+
+	return nil
+}
+
+// Delete remove a user by ID.
+func (s UserStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if u := s.db.users[id]; live(u.Model) {
+		u.DeletedAt = now()
+		s.db.users[id] = u
+	}
+
+	return nil
+}
+
+// GetAll returns all stored users.
+func (s UserStorage) GetAll() (user.Users, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.getUsers(func(u user.User) bool { return true }), nil
+}
+
+// GetByClient returns the staff of a client.
+func (s UserStorage) GetByClient(clientID uint) (user.Users, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	users := s.db.getUsers(func(u user.User) bool { return u.ClientID == clientID })
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].Email < users[j].Email
+	})
+
+	return users, nil
+}
+
+// GetByID returns a user by ID.
+func (s UserStorage) GetByID(id uint) (user.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	u := s.db.users[id]
+	if !live(u.Model) {
+		return user.User{}, storage.ErrNotFound
+	}
+	u.CleanPass()
+
+	return u, nil
+}
+
+// GetByEmail returns a user by email address.
+func (s UserStorage) GetByEmail(address string) (user.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.userByEmail(address)
+}
+
+// Confirm change user state confirmed to true and set the new password.
+func (s UserStorage) Confirm(id uint, u *user.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if confirmed := u.ConfirmPass(); !confirmed {
+		return storage.ErrNotMatch
+	}
+
+	err := u.PreparePass()
+	if err != nil {
+		return err
+	}
+
+	u.ID = id
+	s.db.update(s.db.users, id, map[string]interface{}{
+		"confirmed":    true,
+		"HashPassword": u.HashPassword,
+	})
+
+	return nil
+}
+
+// RequestReset stores a reset of the password of the user of the address,
+// replacing the pending ones, and emails the link to the user. The password
+// is kept until the reset is done.
+func (s UserStorage) RequestReset(address string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	u, err := s.db.userByEmail(address)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	r, token, err := user.NewReset(u.ID, now)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	for _, id := range ids(s.db.resets) {
+		if stored := s.db.resets[id]; stored.UserID == u.ID && stored.UsedAt == nil {
+			s.db.update(s.db.resets, id, map[string]interface{}{"used_at": now})
+		}
+	}
+
+	err = s.db.insert(s.db.resets, r)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	e := email.ResetPassword(address, token)
+	if err = e.Send(); err != nil {
+		fmt.Println(err)
+		return storage.ErrSendEmail
+	}
+
+	return nil
+}
+
+// ResetPassword sets the new password of the user of a reset token, that
+// can't be used again. It returns the ID of the user.
+func (s UserStorage) ResetPassword(p user.NewPassword) (uint, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	err := p.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	hash := user.HashResetToken(p.Token)
+	r := user.Reset{}
+	for _, id := range ids(s.db.resets) {
+		if stored := s.db.resets[id]; live(stored.Model) && stored.TokenHash == hash {
+			r = stored
+			break
+		}
+	}
+
+	now := time.Now()
+	if r.ID == 0 || !r.IsValid(now) {
+		return 0, user.ErrInvalidReset
+	}
+
+	u := user.New()
+	u.Password = p.Password
+	err = u.PreparePass()
+	if err != nil {
+		return 0, err
+	}
+
+	s.db.update(s.db.resets, r.ID, map[string]interface{}{"used_at": now})
+	s.db.update(s.db.users, r.UserID, map[string]interface{}{"HashPassword": u.HashPassword})
+
+	return r.UserID, nil
+}
+
+// SetTOTPSecret stores the secret of a user enrolling in two factor
+// authentication, that is enabled once a code is verified.
+func (s UserStorage) SetTOTPSecret(id uint, secret string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if u := s.db.users[id]; !live(u.Model) || u.TOTPEnabled {
+		return user.ErrTwoFactorEnabled
+	}
+
+	s.db.update(s.db.users, id, map[string]interface{}{"totp_secret": secret})
+
+	return nil
+}
+
+// EnableTOTP enables two factor authentication for a user, replacing the
+// recovery codes with the given ones.
+func (s UserStorage) EnableTOTP(id uint, codes []string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.deleteRecoveryCodes(id)
+
+	for _, c := range codes {
+		rc := user.RecoveryCode{UserID: id, CodeHash: user.HashRecoveryCode(c)}
+		err := s.db.insert(s.db.recoveryCodes, &rc)
+		if err != nil {
+			return storage.ErrNotInsert
+		}
+	}
+
+	s.db.update(s.db.users, id, map[string]interface{}{"totp_enabled": true})
+
+	return nil
+}
+
+// DisableTOTP disables two factor authentication for a user and removes its
+// secret and recovery codes.
+func (s UserStorage) DisableTOTP(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.update(s.db.users, id, map[string]interface{}{
+		"totp_secret":  "",
+		"totp_enabled": false,
+	})
+
+	s.db.deleteRecoveryCodes(id)
+
+	return nil
+}
+
+// UseRecoveryCode marks as used a recovery code of a user, that can't be
+// used again.
+func (s UserStorage) UseRecoveryCode(id uint, code string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	hash := user.HashRecoveryCode(code)
+	for _, rid := range ids(s.db.recoveryCodes) {
+		rc := s.db.recoveryCodes[rid]
+		if rc.UserID == id && rc.CodeHash == hash && rc.UsedAt == nil &&
+			s.db.update(s.db.recoveryCodes, rid, map[string]interface{}{"used_at": now()}) {
+			return nil
+		}
+	}
+
+	return user.ErrInvalidCode
+}
+
+// TOTPStep returns the time step of the last code accepted of a user, 0 if
+// there is none.
+func (s UserStorage) TOTPStep(id uint) (int64, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.totpSteps[id], nil
+}
+
+// UseTOTPStep records the time step of a code accepted of a user. Only one
+// of the codes of a step, or of the steps before, is recorded, the rest
+// fail with user.ErrCodeUsed.
+func (s UserStorage) UseTOTPStep(id uint, step int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if step <= s.db.totpSteps[id] {
+		return user.ErrCodeUsed
+	}
+
+	s.db.totpSteps[id] = step
+
+	return nil
+}
+
+// UseChallenge records a login challenge as used, user.ErrChallengeUsed if
+// it already was. The challenges expired are forgotten.
+func (s UserStorage) UseChallenge(c user.UsedChallenge) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for id, used := range s.db.challenges {
+		if used.ExpiresAt.Before(time.Now()) {
+			delete(s.db.challenges, id)
+		}
+	}
+
+	if _, ok := s.db.challenges[c.ID]; ok {
+		return user.ErrChallengeUsed
+	}
+
+	s.db.challenges[c.ID] = c
+
+	return nil
+}
+
+// TwoFactorRequired checks whether the users of a role must use two factor
+// authentication.
+func (s UserStorage) TwoFactorRequired(role string) (bool, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.policies[role].Required, nil
+}
+
+// SetTwoFactorPolicy stores the two factor policy of a role.
+func (s UserStorage) SetTwoFactorPolicy(p user.TwoFactorPolicy) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.policies[p.Role] = p
+
+	return nil
+}
+
+// GetTwoFactorPolicies returns the two factor policies of the roles.
+func (s UserStorage) GetTwoFactorPolicies() (user.TwoFactorPolicies, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	policies := user.TwoFactorPolicies{}
+	for _, p := range s.db.policies {
+		policies = append(policies, p)
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Role < policies[j].Role
+	})
+
+	return policies, nil
+}
+
+// createUser stores a user, its email is unique even among the deleted.
+func (db *database) createUser(u *user.User) error {
+	for _, stored := range db.users {
+		if stored.Email == u.Email {
+			fmt.Println("Email taken")
+			return storage.ErrNotInsert
+		}
+	}
+
+	err := db.insert(db.users, u)
+	if err != nil {
+		fmt.Println(err)
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// userByEmail returns a user by email address.
+func (db *database) userByEmail(address string) (user.User, error) {
+	for _, id := range ids(db.users) {
+		if u := db.users[id]; live(u.Model) && u.Email == address {
+			return u, nil
+		}
+	}
+
+	return user.User{}, storage.ErrNotFound
+}
+
+// getUsers returns the users that match, without their passwords.
+func (db *database) getUsers(match func(u user.User) bool) user.Users {
+	users := user.Users{}
+	for _, id := range ids(db.users) {
+		u := db.users[id]
+		if !live(u.Model) || !match(u) {
+			continue
+		}
+
+		u.CleanPass()
+		users = append(users, u)
+	}
+
+	return users
+}
+
+// deleteRecoveryCodes removes the recovery codes of a user.
+func (db *database) deleteRecoveryCodes(userID uint) {
+	for _, id := range ids(db.recoveryCodes) {
+		if rc := db.recoveryCodes[id]; live(rc.Model) && rc.UserID == userID {
+			rc.DeletedAt = now()
+			db.recoveryCodes[id] = rc
+		}
+	}
+}
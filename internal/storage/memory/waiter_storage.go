@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"sort"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/waiter"
+)
+
+// WaiterStorage storage to the waiter model.
+type WaiterStorage struct {
+	db *database
+}
+
+// Create create a new waiter.
+func (s WaiterStorage) Create(w *waiter.Waiter) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if w.Name == "" {
+		return storage.ErrRequiredField
+	}
+
+	err := s.db.setPIN(w)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.insert(s.db.waiters, w)
+	if err != nil {
+		return storage.ErrNotInsert
+	}
+
+	return nil
+}
+
+// Update update a waiter by ID, and its PIN if a new one is given.
+func (s WaiterStorage) Update(id uint, w *waiter.Waiter) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored := s.db.waiters[id]
+	if !live(stored.Model) {
+		return storage.ErrNotFound
+	}
+
+	w.ID = id
+	w.ClientID = stored.ClientID
+
+	updates := map[string]interface{}{
+		"name": w.Name,
+	}
+
+	if w.PIN != "" {
+		err := s.db.setPIN(w)
+		if err != nil {
+			return err
+		}
+		updates["pin_hash"] = w.PINHash
+	}
+
+	s.db.update(s.db.waiters, id, updates)
+
+	return nil
+}
+
+// Delete remove a waiter by ID.
+func (s WaiterStorage) Delete(id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if w := s.db.waiters[id]; live(w.Model) {
+		w.DeletedAt = now()
+		s.db.waiters[id] = w
+	}
+
+	return nil
+}
+
+// GetAll returns all stored waiters.
+func (s WaiterStorage) GetAll(clientID uint) (waiter.Waiters, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	waiters := waiter.Waiters{}
+	for _, id := range ids(s.db.waiters) {
+		if w := s.db.waiters[id]; live(w.Model) && w.ClientID == clientID {
+			waiters = append(waiters, w)
+		}
+	}
+
+	sort.SliceStable(waiters, func(i, j int) bool {
+		return waiters[i].Name < waiters[j].Name
+	})
+
+	return waiters, nil
+}
+
+// GetByID returns a waiter by ID.
+func (s WaiterStorage) GetByID(id uint) (waiter.Waiter, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	w := s.db.waiters[id]
+	if !live(w.Model) {
+		return waiter.Waiter{}, storage.ErrNotFound
+	}
+
+	return w, nil
+}
+
+// GetByPIN returns the waiter of a client with the PIN.
+func (s WaiterStorage) GetByPIN(clientID uint, pin string) (waiter.Waiter, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	hash := waiter.HashPIN(storage.PINSecret(), clientID, pin)
+	for _, id := range ids(s.db.waiters) {
+		w := s.db.waiters[id]
+		if live(w.Model) && w.ClientID == clientID && w.PINHash == hash {
+			return w, nil
+		}
+	}
+
+	return waiter.Waiter{}, storage.ErrNotFound
+}
+
+// setPIN validates the PIN of a waiter and hashes it, checking that no other
+// waiter of the client has it.
+func (db *database) setPIN(w *waiter.Waiter) error {
+	if !w.VerifyPIN() {
+		return storage.ErrInvalidPIN
+	}
+
+	hash := waiter.HashPIN(storage.PINSecret(), w.ClientID, w.PIN)
+	for _, stored := range db.waiters {
+		if live(stored.Model) && stored.ClientID == w.ClientID &&
+			stored.PINHash == hash && stored.ID != w.ID {
+			return waiter.ErrPINTaken
+		}
+	}
+
+	w.PINHash = hash
+	w.PIN = ""
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors of the migrations.
+var (
+	ErrNotConnected      = errors.New("not connected to the database")
+	ErrPendingMigrations = errors.New("there are pending migrations, run menuxd migrate up")
+	ErrNoMigrations      = errors.New("there are no migrations to revert")
+)
+
+// schemaTable keeps the versions of the migrations applied.
+const schemaTable = "schema_migrations"
+
+// schemaTableSQL creates the schema table on each dialect.
+var schemaTableSQL = map[string]string{
+	Postgres: `CREATE TABLE schema_migrations (
+	version integer PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamp with time zone NOT NULL
+)`,
+	SQLite: `CREATE TABLE schema_migrations (
+	version integer PRIMARY KEY,
+	name text NOT NULL,
+	applied_at datetime NOT NULL
+)`,
+}
+
+// Migration is a versioned change of the schema, with the SQL that applies
+// and reverts it on each dialect.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      map[string]string
+	Down    map[string]string
+}
+
+// MigrationState is a migration and when it was applied, nil if pending.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrations are the changes of the schema, in order. A migration released
+// is never edited, a new one changes what it did.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      map[string]string{Postgres: baselinePostgres, SQLite: baselineSQLite},
+		Down:    map[string]string{Postgres: baselineDown, SQLite: baselineDown},
+	},
+	{
+		Version: 2,
+		Name:    "one open register session by client",
+		Up:      map[string]string{Postgres: openRegisterUp, SQLite: openRegisterUp},
+		Down:    map[string]string{Postgres: openRegisterDown, SQLite: openRegisterDown},
+	},
+	{
+		Version: 3,
+		Name:    "unique PIN of the waiters by client",
+		Up:      map[string]string{Postgres: waiterPINUp, SQLite: waiterPINUp},
+		Down:    map[string]string{Postgres: waiterPINDown, SQLite: waiterPINDown},
+	},
+	{
+		Version: 4,
+		Name:    "used two factor codes and challenges",
+		Up:      map[string]string{Postgres: twoFactorUsesPostgres, SQLite: twoFactorUsesSQLite},
+		Down:    map[string]string{Postgres: twoFactorUsesDown, SQLite: twoFactorUsesDown},
+	},
+}
+
+// openRegisterUp allows a single open register session by client.
+const openRegisterUp = `
+CREATE UNIQUE INDEX uix_register_sessions_open ON "register_sessions"(client_id)
+WHERE closed_at IS NULL AND deleted_at IS NULL;
+`
+
+// openRegisterDown reverts openRegisterUp.
+const openRegisterDown = `
+DROP INDEX uix_register_sessions_open;
+`
+
+// waiterPINUp allows a PIN to a single waiter of each client.
+const waiterPINUp = `
+CREATE UNIQUE INDEX uix_waiters_pin ON "waiters"(client_id, pin_hash)
+WHERE pin_hash <> '' AND deleted_at IS NULL;
+`
+
+// waiterPINDown reverts waiterPINUp.
+const waiterPINDown = `
+DROP INDEX uix_waiters_pin;
+`
+
+// twoFactorUsesPostgres keeps the last step of the codes of each user and
+// the login challenges used.
+const twoFactorUsesPostgres = `
+CREATE TABLE "totp_steps" (
+	"user_id" integer,
+	"step" bigint,
+	PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE "used_challenges" (
+	"id" text,
+	"expires_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_used_challenges_expires_at ON "used_challenges"(expires_at);
+`
+
+// twoFactorUsesSQLite is twoFactorUsesPostgres on SQLite.
+const twoFactorUsesSQLite = `
+CREATE TABLE "totp_steps" (
+	"user_id" integer,
+	"step" bigint,
+	PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE "used_challenges" (
+	"id" varchar(255),
+	"expires_at" datetime,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_used_challenges_expires_at ON "used_challenges"(expires_at);
+`
+
+// twoFactorUsesDown reverts twoFactorUsesPostgres and twoFactorUsesSQLite.
+const twoFactorUsesDown = `
+DROP TABLE "used_challenges";
+DROP TABLE "totp_steps";
+`
+
+// MigrationStatus returns every migration with when it was applied.
+func MigrationStatus() ([]MigrationState, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	states := []MigrationState{}
+	for _, m := range migrations {
+		s := MigrationState{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			s.AppliedAt = &at
+		}
+		states = append(states, s)
+	}
+
+	return states, nil
+}
+
+// PendingMigrations returns the migrations not applied yet, in order.
+func PendingMigrations() ([]Migration, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// MigrateUp applies the pending migrations in order and returns the ones
+// applied. A database migrated by AutoMigrate before is first brought to the
+// schema of the baseline, that is recorded as applied.
+func MigrateUp() ([]Migration, error) {
+	err := createSchemaTable()
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range pending {
+		err = runMigration(m, true)
+		if err != nil {
+			return pending[:i], err
+		}
+	}
+
+	return pending, nil
+}
+
+// MigrateDown reverts the last migration applied and returns it.
+func MigrateDown() (Migration, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; ok {
+			return m, runMigration(m, false)
+		}
+	}
+
+	return Migration{}, ErrNoMigrations
+}
+
+// appliedMigrations returns when each version was applied.
+func appliedMigrations() (map[uint]time.Time, error) {
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	applied := map[uint]time.Time{}
+	if !conn.HasTable(schemaTable) {
+		return applied, nil
+	}
+
+	rows, err := conn.Table(schemaTable).Select("version, applied_at").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			version uint
+			at      time.Time
+		)
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// createSchemaTable creates the schema table if it is missing. A database
+// with tables but without it was migrated by AutoMigrate, so the legacy
+// migration runs and the baseline is recorded.
+func createSchemaTable() error {
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	if conn.HasTable(schemaTable) {
+		return nil
+	}
+
+	legacy := conn.HasTable("users")
+	if legacy {
+		err := legacyMigration()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := conn.Exec(schemaTableSQL[conn.Dialect().GetName()]).Error
+	if err != nil {
+		return err
+	}
+
+	if !legacy {
+		return nil
+	}
+
+	baseline := migrations[0]
+	return conn.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		baseline.Version, baseline.Name, time.Now()).Error
+}
+
+// runMigration applies or reverts a migration and records it in the schema
+// table, in a transaction.
+func runMigration(m Migration, up bool) error {
+	statements := m.Down
+	if up {
+		statements = m.Up
+	}
+
+	dialect := conn.Dialect().GetName()
+	sql, ok := statements[dialect]
+	if !ok {
+		return fmt.Errorf("migration %d %s has no SQL for %s", m.Version, m.Name, dialect)
+	}
+
+	tx := conn.Begin()
+	err := tx.Exec(sql).Error
+	if err == nil && up {
+		err = tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			m.Version, m.Name, time.Now()).Error
+	} else if err == nil {
+		err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version).Error
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %d %s: %v", m.Version, m.Name, err)
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/menuxd/api-rest/pkg/register"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+	"gitlab.com/menuxd/api-rest/pkg/waiter"
+)
+
+func TestMigrations(t *testing.T) {
+	err := Connect("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	assert := assert.New(t)
+
+	_, err = NewBackend()
+	assert.Equal(ErrPendingMigrations, err)
+
+	applied, err := MigrateUp()
+	assert.Nil(err)
+	assert.Len(applied, len(migrations))
+
+	_, err = NewBackend()
+	assert.Nil(err)
+
+	states, err := MigrationStatus()
+	assert.Nil(err)
+	for _, s := range states {
+		assert.NotNil(s.AppliedAt, s.Name)
+	}
+
+	applied, err = MigrateUp()
+	assert.Nil(err)
+	assert.Empty(applied)
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m, err := MigrateDown()
+		assert.Nil(err)
+		assert.Equal(migrations[i].Version, m.Version)
+	}
+
+	_, err = MigrateDown()
+	assert.Equal(ErrNoMigrations, err)
+
+	for _, m := range models {
+		assert.False(conn.HasTable(m))
+	}
+
+	_, err = NewBackend()
+	assert.Equal(ErrPendingMigrations, err)
+
+	applied, err = MigrateUp()
+	assert.Nil(err)
+	assert.Len(applied, len(migrations))
+}
+
+// TestMigrationsModels checks that the migrations create every column of
+// the models, so a change of a model comes with its migration.
+func TestMigrationsModels(t *testing.T) {
+	err := Connect("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	_, err = MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range models {
+		scope := conn.NewScope(m)
+		table := scope.TableName()
+		if !assert.True(t, conn.HasTable(table), table) {
+			continue
+		}
+
+		for _, f := range scope.GetModelStruct().StructFields {
+			if f.IsNormal && !f.IsIgnored {
+				assert.True(t, conn.Dialect().HasColumn(table, f.DBName), table+"."+f.DBName)
+			}
+		}
+	}
+}
+
+// TestMigrationsLegacy migrates a database that AutoMigrate created before
+// the versioned migrations, keeping its data.
+func TestMigrationsLegacy(t *testing.T) {
+	err := Connect("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	err = conn.AutoMigrate(&user.User{}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := user.New()
+	u.Email = "admin@example.com"
+	err = conn.Create(u).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	applied, err := MigrateUp()
+	assert.Nil(err)
+	assert.Len(applied, len(migrations)-1)
+
+	states, err := MigrationStatus()
+	assert.Nil(err)
+	assert.NotNil(states[0].AppliedAt)
+
+	stored := user.User{}
+	assert.Nil(conn.First(&stored, "email = ?", u.Email).Error)
+	assert.Equal(u.ID, stored.ID)
+
+	_, err = NewBackend()
+	assert.Nil(err)
+}
+
+// TestMigrationsOpenRegister checks that the schema keeps a single open
+// register session by client, whatever checks the storage skips.
+func TestMigrationsOpenRegister(t *testing.T) {
+	connectSQLite(t)
+	defer Close()
+
+	assert := assert.New(t)
+
+	assert.Nil(conn.Create(&register.Session{ClientID: 1}).Error)
+	err := conn.Create(&register.Session{ClientID: 1}).Error
+	assert.True(isUniqueViolation(err), err)
+
+	assert.Nil(conn.Create(&register.Session{ClientID: 2}).Error)
+
+	assert.Nil(conn.Model(&register.Session{}).Where("client_id = ?", 1).
+		Update("closed_at", time.Now()).Error)
+	assert.Nil(conn.Create(&register.Session{ClientID: 1}).Error)
+}
+
+func TestMigrationsWaiterPIN(t *testing.T) {
+	connectSQLite(t)
+	defer Close()
+
+	assert := assert.New(t)
+
+	assert.Nil(conn.Create(&waiter.Waiter{ClientID: 1, Name: "Ana", PINHash: "a"}).Error)
+	err := conn.Create(&waiter.Waiter{ClientID: 1, Name: "Bea", PINHash: "a"}).Error
+	assert.True(isUniqueViolation(err), err)
+
+	assert.Nil(conn.Create(&waiter.Waiter{ClientID: 2, Name: "Bea", PINHash: "a"}).Error)
+
+	assert.Nil(conn.Delete(&waiter.Waiter{}, "client_id = ?", 1).Error)
+	assert.Nil(conn.Create(&waiter.Waiter{ClientID: 1, Name: "Bea", PINHash: "a"}).Error)
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+)
+
+// pendingLimit is the most notifications replayed at once.
+const pendingLimit = 200
+
+// NotificationStorage storage to the notification model.
+type NotificationStorage struct {
+	session *Session
+	db      *gorm.DB
+	tx      *Tx
+}
+
+// setContext initialize the context to NotificationStorage.
+func (s *NotificationStorage) setContext() {
+	s.session = sessionOf(s.tx)
+	s.db = s.session.Client
+}
+
+// WithTx returns the NotificationStorage joined to a unit of work.
+func (s NotificationStorage) WithTx(tx *Tx) NotificationStorage {
+	s.tx = tx
+	return s
+}
+
+// Create stores a notification, pending until a waiter acknowledges it.
+func (s NotificationStorage) Create(n *notification.Notification) error {
+	s.setContext()
+
+	if n.Table != nil {
+		n.TableID = n.Table.ID
+	}
+	n.Active = true
+	n.AckedAt = nil
+	n.AckedBy = nil
+
+	err := s.db.Create(n).Error
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// Ack marks a notification as handled by a waiter, none when waiterID is 0.
+// Only one of the acknowledgements made at once succeeds, the rest fail with
+// notification.ErrAlreadyAcked.
+func (s NotificationStorage) Ack(id, waiterID uint) (notification.Notification, error) {
+	n, err := s.GetByID(id)
+	if err != nil {
+		return notification.Notification{}, err
+	}
+
+	if !n.Active {
+		return notification.Notification{}, notification.ErrAlreadyAcked
+	}
+
+	s.setContext()
+
+	now := time.Now()
+	n.Active = false
+	n.AckedAt = &now
+	n.AckedBy = nil
+	if waiterID != 0 {
+		n.AckedBy = &waiterID
+	}
+
+	acked := s.db.Model(&notification.Notification{}).
+		Where("id = ? AND active = ?", id, true).
+		Updates(map[string]interface{}{
+			"active":   false,
+			"acked_at": n.AckedAt,
+			"acked_by": n.AckedBy,
+		})
+	if acked.Error != nil {
+		return notification.Notification{}, ErrNotUpdate
+	}
+
+	if acked.RowsAffected == 0 {
+		return notification.Notification{}, notification.ErrAlreadyAcked
+	}
+
+	return n, nil
+}
+
+// GetByID returns a notification by ID with its table.
+func (s NotificationStorage) GetByID(id uint) (notification.Notification, error) {
+	s.setContext()
+
+	n := notification.Notification{}
+	err := s.db.First(&n, "id = ?", id).Error
+	if err != nil {
+		return notification.Notification{}, ErrNotFound
+	}
+
+	s.setTable(&n)
+
+	return n, nil
+}
+
+// GetPending returns the notifications of a client not acknowledged yet,
+// after the ID given as cursor, the oldest first.
+func (s NotificationStorage) GetPending(clientID, since uint) ([]notification.Notification, error) {
+	s.setContext()
+
+	notifications := []notification.Notification{}
+	err := s.db.Order("id ASC").Limit(pendingLimit).Find(
+		&notifications,
+		"client_id = ? AND id > ? AND active = ?",
+		clientID,
+		since,
+		true,
+	).Error
+	if err != nil {
+		return []notification.Notification{}, ErrNotFound
+	}
+
+	for i := range notifications {
+		s.setTable(&notifications[i])
+	}
+
+	return notifications, nil
+}
+
+// setTable loads the table of a notification.
+func (s NotificationStorage) setTable(n *notification.Notification) {
+	if n.TableID == 0 {
+		return
+	}
+
+	t := table.Table{}
+	err := s.db.First(&t, "id = ?", n.TableID).Error
+	if err == nil {
+		n.Table = &t
+	}
+}
@@ -1,8 +1,11 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/jinzhu/gorm"
 	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/order"
 	"gitlab.com/menuxd/api-rest/pkg/table"
 )
@@ -11,19 +14,29 @@ import (
 type OrderStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to OrderStorage.
 func (s *OrderStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the OrderStorage joined to a unit of work.
+func (s OrderStorage) WithTx(tx *Tx) OrderStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new order.
 func (s OrderStorage) Create(o *order.Order) (order.Order, error) {
 	s.setContext()
 
 	o.Items = []order.Item{}
+	o.Canceled = false
+	o.BillID = nil
+	o.Lifecycle = order.NewLifecycle()
 	if o.Table != nil {
 		o.TableID = o.Table.ID
 	}
@@ -37,98 +50,260 @@ func (s OrderStorage) Create(o *order.Order) (order.Order, error) {
 	return *o, nil
 }
 
-// Add update a dish by ID.
+// Add stores the items of an order with their ingredients, all of them or
+// none. Orders paid, cancelled or rejected take no more items and fail with
+// order.ErrInvalidTransition.
 func (s OrderStorage) Add(id uint, items []order.Item) error {
-	s.setContext()
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
 
-	for _, i := range items {
-		if i.Dish != nil {
-			i.DishID = i.Dish.ID
-		}
-		ingredients := []dish.Ingredient{}
-		i.Dish = nil
-		i.OrderID = id
-		i.Active = true
-		ingredients = i.Ingredients[:]
-
-		i.Ingredients = nil
-		i.SelectedIngredients = nil
-		err := s.db.Create(&i).Error
+		o, err := s.lock(id)
 		if err != nil {
-			return ErrNotInsert
+			return err
 		}
 
-		for _, ing := range ingredients {
-			is := order.IngredientSelected{}
-			is.ItemID = i.ID
-			is.Active = ing.Active
-			is.IngredientID = ing.ID
+		if o.IsFinal() {
+			return order.ErrInvalidTransition
+		}
 
-			err = s.db.Create(&is).Error
+		err = references(tx, tenant.Dishes, o.ClientID, order.DishIDs(items)...)
+		if err != nil {
+			return err
+		}
+
+		for _, i := range items {
+			if i.Dish != nil {
+				i.DishID = i.Dish.ID
+			}
+			ingredients := []dish.Ingredient{}
+			i.Dish = nil
+			i.OrderID = id
+			i.Active = true
+			i.Ready = false
+			i.Lifecycle = order.NewLifecycle()
+			ingredients = i.Ingredients[:]
+
+			i.Ingredients = nil
+			i.SelectedIngredients = nil
+			err := s.db.Create(&i).Error
 			if err != nil {
 				return ErrNotInsert
 			}
+
+			for _, ing := range ingredients {
+				is := order.IngredientSelected{}
+				is.ItemID = i.ID
+				is.Active = ing.Active
+				is.IngredientID = ing.ID
+
+				err = s.db.Create(&is).Error
+				if err != nil {
+					return ErrNotInsert
+				}
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
-// PatchItem set item's status.
+// PatchItem applies a patch to an item, that can only cancel it.
 func (s OrderStorage) PatchItem(id uint, updates map[string]interface{}) error {
-	s.setContext()
+	cancels, err := order.PatchCancels(updates)
+	if err != nil || !cancels {
+		return err
+	}
 
-	delete(updates, "order_id")
-	delete(updates, "dish_id")
-	delete(updates, "takeaway")
-	delete(updates, "mount")
+	_, err = s.TransitionItem(id, order.Cancelled)
+	return err
+}
 
-	err := s.db.Model(&order.Item{}).Where("id = ?", id).
-		Updates(updates).Error
+// Update set order's canceled.
+func (s OrderStorage) Update(id uint, o *order.Order) error {
+	s.setContext()
+
+	storedOrder := order.Order{}
+	err := s.db.First(&storedOrder, "id = ?", id).Error
 	if err != nil {
-		return ErrNotUpdate
+		return ErrNotFound
 	}
 
-	return nil
+	if storedOrder.Canceled == o.Canceled {
+		return nil
+	}
+
+	if !o.Canceled {
+		return order.ErrInvalidTransition
+	}
+
+	_, err = s.TransitionOrder(id, order.Cancelled)
+	return err
 }
 
-// Update set order's canceled.
-func (s OrderStorage) Update(id uint, o *order.Order) error {
+// TransitionItem moves an item to the status given.
+func (s OrderStorage) TransitionItem(id uint, to order.Status) (order.Item, error) {
+	return s.changeItem(id, func(i *order.Item) error {
+		return i.Transition(to, time.Now())
+	})
+}
+
+// AdvanceItem moves an item forward along the happy path up to the status
+// given.
+func (s OrderStorage) AdvanceItem(id uint, to order.Status) (order.Item, error) {
+	return s.changeItem(id, func(i *order.Item) error {
+		return i.Advance(to, time.Now())
+	})
+}
+
+// changeItem applies a status change to an item and persists it.
+func (s OrderStorage) changeItem(id uint, change func(i *order.Item) error) (order.Item, error) {
 	s.setContext()
 
-	o.ID = id
-	err := s.db.Model(o).Update("canceled", o.Canceled).Error
+	i := order.Item{}
+	err := s.db.First(&i, "id = ?", id).Error
 	if err != nil {
-		return ErrNotUpdate
+		return order.Item{}, ErrNotFound
 	}
 
-	return nil
+	err = change(&i)
+	if err != nil {
+		return order.Item{}, err
+	}
+
+	err = s.saveItemLifecycle(&i)
+	if err != nil {
+		return order.Item{}, err
+	}
+
+	return i, nil
 }
 
-func (s OrderStorage) getIngredientsByItem(itemID uint) ([]order.IngredientSelected, error) {
-	ingredients := []order.IngredientSelected{}
-	err := s.db.Model(&order.IngredientSelected{}).
-		Find(&ingredients, "item_id = ?", itemID).Error
+// AdvanceItems moves items forward along the happy path up to the status
+// given, all of them or none.
+func (s OrderStorage) AdvanceItems(ids []uint, to order.Status) ([]order.Item, error) {
+	items := []order.Item{}
+	err := inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
 
+		for _, id := range ids {
+			i, err := s.AdvanceItem(id, to)
+			if err != nil {
+				return err
+			}
+
+			items = append(items, i)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return []order.IngredientSelected{}, ErrNotInsert
+		return []order.Item{}, err
 	}
 
-	result := []order.IngredientSelected{}
-	for _, ing := range ingredients {
-		storedIngredient := dish.Ingredient{}
-		err := s.db.Model(&dish.Ingredient{}).
-			First(&storedIngredient, "id = ?", ing.IngredientID).Error
+	return items, nil
+}
+
+// lock loads an order and locks its row until the unit of work ends, so the
+// items added to it and its transitions run one at a time. SQLite has a
+// single connection, that already runs the units of work one at a time.
+func (s OrderStorage) lock(id uint) (order.Order, error) {
+	q := s.db
+	if s.db.Dialect().GetName() == Postgres {
+		q = q.Set("gorm:query_option", "FOR UPDATE")
+	}
+
+	o := order.Order{}
+	err := q.First(&o, "id = ?", id).Error
+	if err != nil {
+		return order.Order{}, ErrNotFound
+	}
+
+	return o, nil
+}
+
+// TransitionOrder moves an order to the status given. Every item of the
+// order that can follow it is moved too, in the same unit of work.
+func (s OrderStorage) TransitionOrder(id uint, to order.Status) (order.Order, error) {
+	o := order.Order{}
+	err := inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		var err error
+		o, err = s.lock(id)
 		if err != nil {
-			continue
+			return err
 		}
 
-		ing.Ingredient = &storedIngredient
+		now := time.Now()
+		err = o.Transition(to, now)
+		if err != nil {
+			return err
+		}
 
-		result = append(result, ing)
+		updates := lifecycleUpdates(o.Lifecycle)
+		updates["canceled"] = o.Canceled
+		err = s.db.Model(&order.Order{}).Where("id = ?", id).Updates(updates).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		err = s.db.Model(&order.Item{}).Order("id ASC").
+			Find(&o.Items, "order_id = ?", o.ID).Error
+		if err != nil {
+			return ErrNotFound
+		}
+
+		for idx := range o.Items {
+			i := &o.Items[idx]
+			if !i.CanTransition(to) {
+				continue
+			}
+
+			i.Transition(to, now)
+			err = s.saveItemLifecycle(i)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return order.Order{}, err
 	}
 
-	return result, nil
+	return o, nil
+}
+
+// saveItemLifecycle persists the status of an item.
+func (s OrderStorage) saveItemLifecycle(i *order.Item) error {
+	updates := lifecycleUpdates(i.Lifecycle)
+	updates["active"] = i.Active
+	updates["ready"] = i.Ready
+
+	err := s.db.Model(&order.Item{}).Where("id = ?", i.ID).
+		Updates(updates).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
+
+// lifecycleUpdates returns the columns of a lifecycle to update.
+func lifecycleUpdates(l order.Lifecycle) map[string]interface{} {
+	return map[string]interface{}{
+		"status":       l.Status,
+		"accepted_at":  l.AcceptedAt,
+		"preparing_at": l.PreparingAt,
+		"ready_at":     l.ReadyAt,
+		"served_at":    l.ServedAt,
+		"paid_at":      l.PaidAt,
+		"cancelled_at": l.CancelledAt,
+		"rejected_at":  l.RejectedAt,
+	}
 }
 
 // GetAll returns all stored orders.
@@ -142,33 +317,12 @@ func (s OrderStorage) GetAll(clientID uint) ([]order.Order, error) {
 		return []order.Order{}, ErrNotFound
 	}
 
-	result := []order.Order{}
-	var ds DishStorage
-
-	for _, o := range orders {
-		t := table.Table{}
-		t.ID = o.TableID
-		s.db.Model(&table.Table{}).First(&t, "id = ?", o.TableID)
-		o.Table = &t
-		s.db.Model(&order.Item{}).Find(&o.Items, "order_id = ?", o.ID)
-		items := []order.Item{}
-		for _, i := range o.Items {
-			i.SelectedIngredients, _ = s.getIngredientsByItem(i.ID)
-			storedDish, err := ds.GetByID(i.DishID)
-			if err != nil {
-				i.Dish = &dish.Dish{}
-			} else {
-				i.Dish = &storedDish
-			}
-
-			items = append(items, i)
-		}
-
-		o.Items = items
-		result = append(result, o)
+	err = s.loadOrders(orders)
+	if err != nil {
+		return []order.Order{}, err
 	}
 
-	return result, nil
+	return orders, nil
 }
 
 // GetAllActive returns active orders.
@@ -176,41 +330,20 @@ func (s OrderStorage) GetAllActive(clientID uint) ([][]order.Order, error) {
 	s.setContext()
 
 	orders := []order.Order{}
-	err := s.db.Model(&order.Order{}).Where("canceled = false").
+	err := s.db.Model(&order.Order{}).Where("canceled = ?", false).
 		Order("id ASC").
 		Find(&orders, "client_id = ?", clientID).Error
 	if err != nil {
 		return [][]order.Order{}, ErrNotFound
 	}
 
-	result := []order.Order{}
-	var ds DishStorage
-
-	for _, o := range orders {
-		t := table.Table{}
-		t.ID = o.TableID
-		s.db.Model(&table.Table{}).First(&t, "id = ?", o.TableID)
-		o.Table = &t
-		s.db.Model(&order.Item{}).Order("id ASC").Find(&o.Items, "order_id = ?", o.ID)
-		items := []order.Item{}
-		for _, i := range o.Items {
-			i.SelectedIngredients, _ = s.getIngredientsByItem(i.ID)
-			storedDish, err := ds.GetByID(i.DishID)
-			if err != nil {
-				i.Dish = &dish.Dish{}
-			} else {
-				i.Dish = &storedDish
-			}
-
-			items = append(items, i)
-		}
-
-		o.Items = items
-		result = append(result, o)
+	err = s.loadOrders(orders)
+	if err != nil {
+		return [][]order.Order{}, err
 	}
 
 	m := make(map[uint][]order.Order)
-	for _, r := range result {
+	for _, r := range orders {
 		m[r.TableID] = append(m[r.TableID], r)
 	}
 
@@ -222,6 +355,27 @@ func (s OrderStorage) GetAllActive(clientID uint) ([][]order.Order, error) {
 	return tables, nil
 }
 
+// GetUnbilled returns the orders of a table that are not canceled and have
+// not been billed yet.
+func (s OrderStorage) GetUnbilled(tableID uint) ([]order.Order, error) {
+	s.setContext()
+
+	orders := []order.Order{}
+	err := s.db.Model(&order.Order{}).Where("canceled = ?", false).
+		Where("bill_id IS NULL").Order("id ASC").
+		Find(&orders, "table_id = ?", tableID).Error
+	if err != nil {
+		return []order.Order{}, ErrNotFound
+	}
+
+	err = s.loadOrders(orders)
+	if err != nil {
+		return []order.Order{}, err
+	}
+
+	return orders, nil
+}
+
 // GetByID returns a dish by ID.
 func (s OrderStorage) GetByID(id uint) (order.Order, error) {
 	s.setContext()
@@ -232,34 +386,195 @@ func (s OrderStorage) GetByID(id uint) (order.Order, error) {
 		return order.Order{}, ErrNotFound
 	}
 
-	err = s.db.Model(&order.Item{}).Find(&o.Items, "order_id = ?", o.ID).Error
+	t := table.Table{}
+	err = s.db.Model(&table.Table{}).First(&t, "id = ?", o.TableID).Error
 	if err != nil {
 		return order.Order{}, ErrNotFound
 	}
 
-	var ds DishStorage
+	orders := []order.Order{o}
+	err = s.loadItems(orders)
+	if err != nil {
+		return order.Order{}, err
+	}
+
+	o = orders[0]
+	o.Table = &t
+
+	return o, nil
+}
+
+// GetByIDs returns orders by ID in the order given, failing with
+// ErrNotFound if any of them is missing.
+func (s OrderStorage) GetByIDs(ids []uint) ([]order.Order, error) {
+	s.setContext()
+
+	byID := map[uint]order.Order{}
+	err := inBatches(ids, func(batch []uint) error {
+		orders := []order.Order{}
+		err := s.db.Find(&orders, "id IN (?)", batch).Error
+		for _, o := range orders {
+			byID[o.ID] = o
+		}
+		return err
+	})
+	if err != nil {
+		return []order.Order{}, ErrNotFound
+	}
+
+	orders := []order.Order{}
+	for _, id := range ids {
+		o, ok := byID[id]
+		if !ok {
+			return []order.Order{}, ErrNotFound
+		}
+		orders = append(orders, o)
+	}
+
+	err = s.loadOrders(orders)
+	if err != nil {
+		return []order.Order{}, err
+	}
+
+	return orders, nil
+}
+
+// loadOrders sets the table and the items of orders. A missing table keeps
+// only its ID.
+func (s OrderStorage) loadOrders(orders []order.Order) error {
+	tableIDs := []uint{}
+	for _, o := range orders {
+		tableIDs = append(tableIDs, o.TableID)
+	}
+
+	tables := map[uint]table.Table{}
+	err := inBatches(tableIDs, func(ids []uint) error {
+		batch := []table.Table{}
+		err := s.db.Find(&batch, "id IN (?)", ids).Error
+		for _, t := range batch {
+			tables[t.ID] = t
+		}
+		return err
+	})
+	if err != nil {
+		return ErrNotFound
+	}
+
+	for idx := range orders {
+		o := &orders[idx]
+		t, ok := tables[o.TableID]
+		if !ok {
+			t.ID = o.TableID
+		}
+		o.Table = &t
+	}
+
+	return s.loadItems(orders)
+}
+
+// loadItems sets the items of orders, each one with its dish and the
+// ingredients selected, with a query for each kind of row whatever the
+// number of orders.
+func (s OrderStorage) loadItems(orders []order.Order) error {
+	orderIDs := []uint{}
+	for _, o := range orders {
+		orderIDs = append(orderIDs, o.ID)
+	}
+
 	items := []order.Item{}
-	for _, i := range o.Items {
-		i.SelectedIngredients, _ = s.getIngredientsByItem(i.ID)
-		storedDish, err := ds.GetByID(i.DishID)
-		if err != nil {
-			i.Dish = &dish.Dish{}
-		} else {
-			i.Dish = &storedDish
+	err := inBatches(orderIDs, func(ids []uint) error {
+		batch := []order.Item{}
+		err := s.db.Order("id ASC").Find(&batch, "order_id IN (?)", ids).Error
+		items = append(items, batch...)
+		return err
+	})
+	if err != nil {
+		return ErrNotFound
+	}
+
+	itemIDs := []uint{}
+	dishIDs := []uint{}
+	for _, i := range items {
+		itemIDs = append(itemIDs, i.ID)
+		dishIDs = append(dishIDs, i.DishID)
+	}
+
+	selected, err := s.selectedIngredients(itemIDs)
+	if err != nil {
+		return err
+	}
+
+	dishes, err := DishStorage{}.WithTx(s.tx).dishesByID(dishIDs)
+	if err != nil {
+		return err
+	}
+
+	byOrder := map[uint][]order.Item{}
+	for _, i := range items {
+		i.SelectedIngredients = selected[i.ID]
+		if i.SelectedIngredients == nil {
+			i.SelectedIngredients = []order.IngredientSelected{}
 		}
 
-		items = append(items, i)
+		d := dishes[i.DishID]
+		i.Dish = &d
+
+		byOrder[i.OrderID] = append(byOrder[i.OrderID], i)
 	}
 
-	o.Items = items
-	t := table.Table{}
+	for idx := range orders {
+		o := &orders[idx]
+		o.Items = byOrder[o.ID]
+		if o.Items == nil {
+			o.Items = []order.Item{}
+		}
+	}
 
-	err = s.db.Model(&table.Table{}).First(&t, "id = ?", o.TableID).Error
+	return nil
+}
+
+// selectedIngredients returns the ingredients selected of items by item,
+// with those ingredients that still exist.
+func (s OrderStorage) selectedIngredients(itemIDs []uint) (map[uint][]order.IngredientSelected, error) {
+	selected := []order.IngredientSelected{}
+	err := inBatches(itemIDs, func(ids []uint) error {
+		batch := []order.IngredientSelected{}
+		err := s.db.Order("id ASC").Find(&batch, "item_id IN (?)", ids).Error
+		selected = append(selected, batch...)
+		return err
+	})
 	if err != nil {
-		return order.Order{}, ErrNotFound
+		return nil, ErrNotFound
 	}
 
-	o.Table = &t
+	ingredientIDs := []uint{}
+	for _, is := range selected {
+		ingredientIDs = append(ingredientIDs, is.IngredientID)
+	}
 
-	return o, nil
+	ingredients := map[uint]dish.Ingredient{}
+	err = inBatches(ingredientIDs, func(ids []uint) error {
+		batch := []dish.Ingredient{}
+		err := s.db.Find(&batch, "id IN (?)", ids).Error
+		for _, ing := range batch {
+			ingredients[ing.ID] = ing
+		}
+		return err
+	})
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	result := map[uint][]order.IngredientSelected{}
+	for _, is := range selected {
+		ing, ok := ingredients[is.IngredientID]
+		if !ok {
+			continue
+		}
+
+		is.Ingredient = &ing
+		result[is.ItemID] = append(result[is.ItemID], is)
+	}
+
+	return result, nil
 }
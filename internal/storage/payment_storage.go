@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+)
+
+// PaymentStorage storage to the payment model.
+type PaymentStorage struct {
+	session *Session
+	db      *gorm.DB
+	tx      *Tx
+}
+
+// setContext initialize the context to PaymentStorage.
+func (s *PaymentStorage) setContext() {
+	s.session = sessionOf(s.tx)
+	s.db = s.session.Client
+}
+
+// WithTx returns the PaymentStorage joined to a unit of work.
+func (s PaymentStorage) WithTx(tx *Tx) PaymentStorage {
+	s.tx = tx
+	return s
+}
+
+// Create records a payment against its bill and updates whether the bill is
+// paid from the resulting balance, in the same unit of work. The bill is
+// locked first, so concurrent payments check the balance one at a time and
+// can't overpay it.
+func (s PaymentStorage) Create(p *payment.Payment) error {
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		bs := BillStorage{}.WithTx(tx)
+		bs.setContext()
+		err := bs.lock(p.BillID)
+		if err != nil {
+			return err
+		}
+
+		b, err := bs.GetByID(p.BillID)
+		if err != nil {
+			return err
+		}
+
+		wasPaid := b.Paid
+		err = b.Apply(p)
+		if err != nil {
+			return err
+		}
+
+		err = s.db.Create(p).Error
+		if err != nil {
+			return ErrNotInsert
+		}
+
+		return bs.reconcile(b, wasPaid)
+	})
+}
+
+// GetByBill returns the payments of a bill.
+func (s PaymentStorage) GetByBill(billID uint) (payment.Payments, error) {
+	s.setContext()
+
+	payments := payment.Payments{}
+	err := s.db.Order("id ASC").Find(&payments, "bill_id = ?", billID).Error
+	if err != nil {
+		return payment.Payments{}, ErrNotFound
+	}
+
+	return payments, nil
+}
+
+// GetByID returns a payment by ID.
+func (s PaymentStorage) GetByID(id uint) (payment.Payment, error) {
+	s.setContext()
+
+	p := payment.Payment{}
+	err := s.db.First(&p, "id = ?", id).Error
+	if err != nil {
+		return payment.Payment{}, ErrNotFound
+	}
+
+	return p, nil
+}
@@ -4,20 +4,6 @@ import (
 	"os"
 
 	"github.com/jinzhu/gorm"
-	"gitlab.com/menuxd/api-rest/pkg/ad"
-	"gitlab.com/menuxd/api-rest/pkg/bill"
-	"gitlab.com/menuxd/api-rest/pkg/category"
-	"gitlab.com/menuxd/api-rest/pkg/click"
-	"gitlab.com/menuxd/api-rest/pkg/client"
-	"gitlab.com/menuxd/api-rest/pkg/dish"
-	"gitlab.com/menuxd/api-rest/pkg/order"
-	"gitlab.com/menuxd/api-rest/pkg/promotion"
-	"gitlab.com/menuxd/api-rest/pkg/question"
-	"gitlab.com/menuxd/api-rest/pkg/rating"
-	"gitlab.com/menuxd/api-rest/pkg/stay"
-	"gitlab.com/menuxd/api-rest/pkg/table"
-	"gitlab.com/menuxd/api-rest/pkg/user"
-	"gitlab.com/menuxd/api-rest/pkg/waiter"
 )
 
 // DBName Database name.
@@ -34,10 +20,18 @@ func createDBSession() error {
 	// pg_con_string := fmt.Sprintf("port=%d host=%s user=%s "+
 	// 	"password=%s dbname=%s sslmode=disable",
 	// 	5432, "localhost", "postgres", "superadmin", "menuxd")
-	conn, err = gorm.Open("postgres", connString)
+	dialect, source := parseURL(connString)
+	conn, err = gorm.Open(dialect, source)
 	if err != nil {
 		return err
 	}
+
+	// SQLite allows a single writer, and each connection to an in-memory
+	// database opens a new one.
+	if dialect == SQLite {
+		conn.DB().SetMaxOpenConns(1)
+	}
+
 	return nil
 }
 
@@ -49,46 +43,27 @@ func getSession() *gorm.DB {
 	return conn
 }
 
-func migration() error {
-	return conn.AutoMigrate(
-		&ad.Ad{},
-		&bill.Bill{},
-		&category.Category{},
-		&client.Client{},
-		&dish.Dish{},
-		&dish.Ingredient{},
-		&promotion.Promotion{},
-		&table.Table{},
-		&user.User{},
-		&waiter.Waiter{},
-		&order.Order{},
-		&order.Item{},
-		&order.IngredientSelected{},
-		&click.Click{},
-		&stay.Stay{},
-		&question.Question{},
-		&rating.Rating{},
-	).Error
-}
-
-// InitData initialize the conn.
-func InitData() error {
-	var err error
-	err = createDBSession()
+// Connect connects to the database of the URL, closing the previous conn.
+func Connect(url string) error {
+	err := Close()
 	if err != nil {
 		return err
 	}
 
-	err = migration()
-	if err != nil {
-		return err
-	}
-	return nil
+	connString = url
+	return createDBSession()
 }
 
-// Close the gorm conn.
+// Close the gorm conn, if there is one.
 func Close() error {
-	return conn.Close()
+	if conn == nil {
+		return nil
+	}
+
+	err := conn.Close()
+	conn = nil
+
+	return err
 }
 
 func init() {
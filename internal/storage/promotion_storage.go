@@ -5,6 +5,7 @@ import (
 
 	"github.com/jinzhu/gorm"
 	"gitlab.com/menuxd/api-rest/pkg/click"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
 	"gitlab.com/menuxd/api-rest/pkg/promotion"
 )
 
@@ -12,14 +13,21 @@ import (
 type PromotionStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to PromotionStorage
 func (s *PromotionStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the PromotionStorage joined to a unit of work
+func (s PromotionStorage) WithTx(tx *Tx) PromotionStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new Promotion
 func (s PromotionStorage) Create(p *promotion.Promotion) error {
 	s.setContext()
@@ -32,8 +40,13 @@ func (s PromotionStorage) Create(p *promotion.Promotion) error {
 		return ErrRequiredField
 	}
 
+	err := references(s.tx, tenant.Dishes, p.ClientID, p.DishID)
+	if err != nil {
+		return err
+	}
+
 	p.DaysString = promotion.SetDaysString(p.Days)
-	err := s.db.Create(p).Error
+	err = s.db.Create(p).Error
 	if err != nil {
 		return ErrNotInsert
 	}
@@ -64,7 +77,12 @@ func (s PromotionStorage) Update(id uint, p *promotion.Promotion) error {
 		"end_at":     p.EndAt,
 	}
 
-	err := s.db.Model(&promotion.Promotion{}).Where("id = ?", id).Updates(updates).Error
+	err := referencesOf(s.tx, tenant.Promotions, id, tenant.Dishes, p.DishID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Model(&promotion.Promotion{}).Where("id = ?", id).Updates(updates).Error
 	if err != nil {
 		return ErrNotUpdate
 	}
@@ -119,7 +137,7 @@ func (s PromotionStorage) GetAll(clientID uint) (promotion.Promotions, error) {
 			return []promotion.Promotion{}, ErrNotFound
 		}
 
-		var ds DishStorage
+		ds := DishStorage{}.WithTx(s.tx)
 		storedDish, err := ds.GetByID(promotions[i].DishID)
 		if err != nil {
 			continue
@@ -141,7 +159,7 @@ func (s PromotionStorage) GetAllActive(clientID uint) (promotion.Promotions, err
 
 	result := promotion.Promotions{}
 	now := time.Now()
-	var cs ClientStorage
+	cs := ClientStorage{}.WithTx(s.tx)
 	c, err := cs.GetByID(clientID)
 	if err != nil {
 		return []promotion.Promotion{}, err
@@ -177,7 +195,7 @@ func (s PromotionStorage) GetByID(id uint) (promotion.Promotion, error) {
 		return promotion.Promotion{}, ErrNotFound
 	}
 
-	var ds DishStorage
+	ds := DishStorage{}.WithTx(s.tx)
 	storedDish, _ := ds.GetByID(p.DishID)
 	p.Dish = storedDish
 
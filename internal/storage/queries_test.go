@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+)
+
+// queryCounter counts the queries gorm logs.
+type queryCounter struct {
+	queries int
+}
+
+// Print counts the log of a query.
+func (c *queryCounter) Print(v ...interface{}) {
+	if len(v) > 0 && v[0] == "sql" {
+		c.queries++
+	}
+}
+
+// countQueries returns the number of queries fn runs.
+func countQueries(fn func()) int {
+	c := &queryCounter{}
+	conn.SetLogger(c)
+	conn.LogMode(true)
+	defer conn.LogMode(false)
+
+	fn()
+
+	return c.queries
+}
+
+// seedOrders stores the orders of a client, one by table, each one with an
+// item of each dish with an ingredient selected.
+func seedOrders(tb testing.TB, clientID uint, orders, dishes int) {
+	c := category.Category{ClientID: clientID}
+	c.Title = "Pizzas"
+	c.Picture = "pizzas.png"
+	err := CategoryStorage{}.Create(&c)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	items := []order.Item{}
+	for n := 0; n < dishes; n++ {
+		d := dish.Dish{ClientID: clientID, CategoryID: c.ID}
+		d.Name = fmt.Sprintf("Pizza %d", n)
+		d.Pictures = []string{"pizza.png"}
+		d.Ingredients = []dish.Ingredient{{Name: "Queso", Active: true}, {Name: "Tomate", Active: true}}
+		err = DishStorage{}.Create(&d)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		d, err = DishStorage{}.GetByID(d.ID)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		i := order.Item{DishID: d.ID, Mount: 1}
+		i.Ingredients = d.Ingredients[:1]
+		items = append(items, i)
+	}
+
+	for n := 0; n < orders; n++ {
+		t := table.Table{ClientID: clientID, Number: uint(n + 1)}
+		err = TableStorage{}.Create(&t)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		o, err := OrderStorage{}.Create(&order.Order{ClientID: clientID, TableID: t.ID})
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		err = OrderStorage{}.Add(o.ID, items)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// TestQueries checks that loading orders and dishes takes the same number
+// of queries whatever the number of rows.
+func TestQueries(t *testing.T) {
+	connectSQLite(t)
+	defer Close()
+
+	seedOrders(t, 1, 2, 2)
+	seedOrders(t, 2, 30, 6)
+
+	// Orders take a query for the orders, tables, items, ingredients
+	// selected and their ingredients, and for the dishes, their ingredients
+	// and categories.
+	loaders := []struct {
+		name    string
+		queries int
+		load    func(clientID uint)
+	}{
+		{"GetAll", 8, func(clientID uint) { OrderStorage{}.GetAll(clientID) }},
+		{"GetAllActive", 8, func(clientID uint) { OrderStorage{}.GetAllActive(clientID) }},
+		{"Dishes", 2, func(clientID uint) { DishStorage{}.GetAll(clientID) }},
+		{"DishesWithPagination", 4, func(clientID uint) {
+			DishStorage{}.GetAllWithPagination(clientID, 1)
+		}},
+	}
+
+	for _, l := range loaders {
+		few := countQueries(func() { l.load(1) })
+		many := countQueries(func() { l.load(2) })
+
+		assert.Equal(t, l.queries, few, l.name)
+		assert.Equal(t, l.queries, many, l.name)
+	}
+}
+
+func BenchmarkOrderGetAll(b *testing.B) {
+	err := Connect("sqlite://:memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer Close()
+
+	_, err = MigrateUp()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	seedOrders(b, 1, 100, 5)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		orders, err := OrderStorage{}.GetAll(1)
+		if err != nil || len(orders) != 100 {
+			b.Fatal(len(orders), err)
+		}
+	}
+}
+
+func BenchmarkDishGetAllByCategory(b *testing.B) {
+	err := Connect("sqlite://:memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer Close()
+
+	_, err = MigrateUp()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	seedOrders(b, 1, 1, 100)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		dishes, err := DishStorage{}.GetAllByCategory(1)
+		if err != nil || len(dishes) != 100 {
+			b.Fatal(len(dishes), err)
+		}
+	}
+}
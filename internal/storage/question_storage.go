@@ -10,14 +10,21 @@ import (
 type QuestionStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to QuestionStorage.
 func (s *QuestionStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the QuestionStorage joined to a unit of work.
+func (s QuestionStorage) WithTx(tx *Tx) QuestionStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new question.
 func (s QuestionStorage) Create(q *question.Question) error {
 	s.setContext()
@@ -75,7 +82,7 @@ func (s QuestionStorage) GetAll(clientID uint) ([]question.Question, error) {
 		return []question.Question{}, ErrNotFound
 	}
 
-	var rs RatingStorage
+	rs := RatingStorage{}.WithTx(s.tx)
 	result := []question.Question{}
 	for _, q := range questions {
 		ratings, _ := rs.GetAllByQuestion(q.ID)
@@ -95,7 +102,7 @@ func (s QuestionStorage) GetByID(id uint) (question.Question, error) {
 	if err != nil {
 		return question.Question{}, ErrNotFound
 	}
-	var rs RatingStorage
+	rs := RatingStorage{}.WithTx(s.tx)
 	ratings, _ := rs.GetAllByQuestion(q.ID)
 	q.Rating = ratings
 
@@ -9,14 +9,21 @@ import (
 type RatingStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to RatingStorage.
 func (s *RatingStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the RatingStorage joined to a unit of work.
+func (s RatingStorage) WithTx(tx *Tx) RatingStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new rating.
 func (s RatingStorage) Create(r *rating.Rating) error {
 	s.setContext()
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/register"
+)
+
+// RegisterStorage storage to the register session model.
+type RegisterStorage struct {
+	session *Session
+	db      *gorm.DB
+	tx      *Tx
+}
+
+// setContext initialize the context to RegisterStorage.
+func (s *RegisterStorage) setContext() {
+	s.session = sessionOf(s.tx)
+	s.db = s.session.Client
+}
+
+// WithTx returns the RegisterStorage joined to a unit of work.
+func (s RegisterStorage) WithTx(tx *Tx) RegisterStorage {
+	s.tx = tx
+	return s
+}
+
+// Open starts a register session, only one at a time by client. The count
+// answers the common case, and a unique index on the open sessions rejects
+// the one that loses a race.
+func (s RegisterStorage) Open(rs *register.Session) error {
+	s.setContext()
+
+	if rs.ClientID == 0 || rs.OpeningCash < 0 {
+		return ErrRequiredField
+	}
+
+	count := 0
+	err := s.db.Model(&register.Session{}).
+		Where("client_id = ? AND closed_at IS NULL", rs.ClientID).
+		Count(&count).Error
+	if err != nil {
+		return ErrNotFound
+	}
+
+	if count > 0 {
+		return register.ErrAlreadyOpen
+	}
+
+	rs.OpenedAt = time.Now()
+	rs.ClosedAt = nil
+	rs.ExpectedCash = 0
+	rs.CountedCash = 0
+	rs.Difference = 0
+
+	err = s.db.Create(rs).Error
+	if isUniqueViolation(err) {
+		return register.ErrAlreadyOpen
+	}
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// Close ends a register session with the cash counted and returns its Z
+// report.
+func (s RegisterStorage) Close(id uint, counted money.Amount, note string) (register.Report, error) {
+	rs, err := s.GetByID(id)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	if !rs.IsOpen() {
+		return register.Report{}, register.ErrAlreadyClosed
+	}
+
+	if counted < 0 {
+		return register.Report{}, ErrBadRequest
+	}
+
+	closedAt := time.Now()
+	rs.ClosedAt = &closedAt
+	rs.CountedCash = counted
+	if note != "" {
+		rs.Note = note
+	}
+
+	r, err := s.report(rs)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	// Only the first of the closes at once finds the session open.
+	s.setContext()
+	result := s.db.Model(&register.Session{}).
+		Where("id = ? AND closed_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"closed_at":     rs.ClosedAt,
+			"counted_cash":  rs.CountedCash,
+			"expected_cash": r.ExpectedCash,
+			"difference":    r.Difference,
+			"note":          rs.Note,
+		})
+	if result.Error != nil {
+		return register.Report{}, ErrNotUpdate
+	}
+
+	if result.RowsAffected == 0 {
+		return register.Report{}, register.ErrAlreadyClosed
+	}
+
+	return r, nil
+}
+
+// GetAll returns the register sessions of a client, the last first.
+func (s RegisterStorage) GetAll(clientID uint) (register.Sessions, error) {
+	s.setContext()
+
+	sessions := register.Sessions{}
+	err := s.db.Order("opened_at DESC").Find(&sessions, "client_id = ?", clientID).Error
+	if err != nil {
+		return register.Sessions{}, ErrNotFound
+	}
+
+	return sessions, nil
+}
+
+// GetByID returns a register session by ID.
+func (s RegisterStorage) GetByID(id uint) (register.Session, error) {
+	s.setContext()
+
+	rs := register.Session{}
+	err := s.db.First(&rs, "id = ?", id).Error
+	if err != nil {
+		return register.Session{}, ErrNotFound
+	}
+
+	return rs, nil
+}
+
+// GetOpen returns the open register session of a client.
+func (s RegisterStorage) GetOpen(clientID uint) (register.Session, error) {
+	s.setContext()
+
+	rs := register.Session{}
+	err := s.db.First(&rs, "client_id = ? AND closed_at IS NULL", clientID).Error
+	if err != nil {
+		return register.Session{}, ErrNotFound
+	}
+
+	return rs, nil
+}
+
+// Report returns the Z report of a register session, up to now while it is
+// open.
+func (s RegisterStorage) Report(id uint) (register.Report, error) {
+	rs, err := s.GetByID(id)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	return s.report(rs)
+}
+
+// report builds the Z report of a register session from the bills,
+// payments and cancelled orders of its client within the session.
+func (s RegisterStorage) report(rs register.Session) (register.Report, error) {
+	s.setContext()
+
+	now := time.Now()
+	from, to := rs.Window(now)
+
+	cs := ClientStorage{}.WithTx(s.tx)
+	c, err := cs.GetByID(rs.ClientID)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	bills := bill.Bills{}
+	err = s.db.Find(&bills, "client_id = ? AND created_at BETWEEN ? AND ?", rs.ClientID, from, to).Error
+	if err != nil {
+		return register.Report{}, ErrNotFound
+	}
+
+	payments := payment.Payments{}
+	err = s.db.Find(&payments, "client_id = ? AND created_at BETWEEN ? AND ?", rs.ClientID, from, to).Error
+	if err != nil {
+		return register.Report{}, ErrNotFound
+	}
+
+	cancelled := []string{string(order.Cancelled), string(order.Rejected)}
+	ids := []uint{}
+	err = s.db.Model(&order.Order{}).
+		Where("client_id = ?", rs.ClientID).
+		Where(
+			"(canceled = ? AND COALESCE(cancelled_at, rejected_at, updated_at) BETWEEN ? AND ?) OR id IN (?)",
+			true,
+			from,
+			to,
+			s.db.Model(&order.Item{}).Select("order_id").
+				Where("status IN (?)", cancelled).
+				Where("COALESCE(cancelled_at, rejected_at, updated_at) BETWEEN ? AND ?", from, to).
+				QueryExpr(),
+		).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return register.Report{}, ErrNotFound
+	}
+
+	orders, err := OrderStorage{}.WithTx(s.tx).GetByIDs(ids)
+	if err != nil {
+		return register.Report{}, err
+	}
+
+	return register.Build(rs, loc, bills, payments, orders, now), nil
+}
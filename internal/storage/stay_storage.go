@@ -10,14 +10,21 @@ import (
 type StayStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to StayStorage.
 func (s *StayStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the StayStorage joined to a unit of work.
+func (s StayStorage) WithTx(tx *Tx) StayStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new stay.
 func (s StayStorage) Create(st *stay.Stay) error {
 	s.setContext()
@@ -4,6 +4,27 @@ import (
 	"errors"
 
 	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/ad"
+	"gitlab.com/menuxd/api-rest/pkg/apikey"
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/device"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/promotion"
+	"gitlab.com/menuxd/api-rest/pkg/question"
+	"gitlab.com/menuxd/api-rest/pkg/rating"
+	"gitlab.com/menuxd/api-rest/pkg/register"
+	"gitlab.com/menuxd/api-rest/pkg/stay"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+	"gitlab.com/menuxd/api-rest/pkg/waiter"
 )
 
 // Errors.
@@ -21,6 +42,7 @@ var (
 	ErrInvalidHourRange   = errors.New("invalid hour range")
 	ErrInvalidPIN         = errors.New("pin invalid")
 	ErrRequiredField      = errors.New("required field")
+	ErrForeignReference   = errors.New("it references data of another client")
 )
 
 // Session is the Storage session.
@@ -39,3 +61,94 @@ func NewSession() *Session {
 		Client: getSession(),
 	}
 }
+
+// Backend is the storage of every model the API uses.
+type Backend struct {
+	Ads           ad.Storage
+	APIKeys       apikey.Storage
+	Bills         bill.Storage
+	Categories    category.Storage
+	Clients       client.Storage
+	Devices       device.Storage
+	Dishes        dish.Storage
+	Logins        login.Storage
+	Notifications notification.Storage
+	Orders        order.Storage
+	Payments      payment.Storage
+	Promotions    promotion.Storage
+	Questions     question.Storage
+	Ratings       rating.Storage
+	Registers     register.Storage
+	Stays         stay.Storage
+	Tables        table.Storage
+	Tenants       tenant.Storage
+	Users         user.Storage
+	Waiters       waiter.Storage
+}
+
+// NewBackend returns the storage of every model on the database connected.
+// It fails with ErrPendingMigrations if the schema is not up to date.
+func NewBackend() (Backend, error) {
+	pending, err := PendingMigrations()
+	if err != nil {
+		return Backend{}, err
+	}
+
+	if len(pending) > 0 {
+		return Backend{}, ErrPendingMigrations
+	}
+
+	return Backend{
+		Ads:           AdStorage{},
+		APIKeys:       APIKeyStorage{},
+		Bills:         BillStorage{},
+		Categories:    CategoryStorage{},
+		Clients:       ClientStorage{},
+		Devices:       DeviceStorage{},
+		Dishes:        DishStorage{},
+		Logins:        LoginStorage{},
+		Notifications: NotificationStorage{},
+		Orders:        OrderStorage{},
+		Payments:      PaymentStorage{},
+		Promotions:    PromotionStorage{},
+		Questions:     QuestionStorage{},
+		Ratings:       RatingStorage{},
+		Registers:     RegisterStorage{},
+		Stays:         StayStorage{},
+		Tables:        TableStorage{},
+		Tenants:       TenantStorage{},
+		Users:         UserStorage{},
+		Waiters:       WaiterStorage{},
+	}, nil
+}
+
+// batchSize is the most IDs bound to a query, under the limit of SQLite.
+const batchSize = 500
+
+// inBatches calls fn with the IDs given, without repeating them, in batches
+// of up to batchSize.
+func inBatches(ids []uint, fn func(batch []uint) error) error {
+	seen := map[uint]bool{}
+	unique := []uint{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	for len(unique) > 0 {
+		n := batchSize
+		if len(unique) < n {
+			n = len(unique)
+		}
+
+		err := fn(unique[:n])
+		if err != nil {
+			return err
+		}
+		unique = unique[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package storage_test
+
+import (
+	"os"
+	"testing"
+
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/internal/storage/storagetest"
+)
+
+func TestSQLite(t *testing.T) {
+	b := migrated(t, "sqlite://:memory:")
+	defer storage.Close()
+
+	storagetest.Run(t, b)
+}
+
+// TestPostgres runs on the database of TEST_DATABASE_URL, skipped if unset.
+func TestPostgres(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	b := migrated(t, url)
+	defer storage.Close()
+
+	storagetest.Run(t, b)
+}
+
+// migrated connects to the database of the URL, applies the pending
+// migrations and returns its backend.
+func migrated(t *testing.T, url string) storage.Backend {
+	err := storage.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storage.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := storage.NewBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
@@ -0,0 +1,928 @@
+// Package storagetest checks that a storage backend behaves as the API
+// expects, so that every backend is run through the same tests.
+package storagetest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/internal/storage"
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/device"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/login"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/notification"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/promotion"
+	"gitlab.com/menuxd/api-rest/pkg/rating"
+	"gitlab.com/menuxd/api-rest/pkg/register"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// Run runs every test of the suite on the backend. The tests create their
+// own clients, so the backend doesn't need to be empty.
+func Run(t *testing.T, b storage.Backend) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, b storage.Backend)
+	}{
+		{"SoftDelete", testSoftDelete},
+		{"ClientScoping", testClientScoping},
+		{"Defaults", testDefaults},
+		{"Update", testUpdate},
+		{"ConcurrentOrders", testConcurrentOrders},
+		{"OrderLoading", testOrderLoading},
+		{"ItemPatch", testItemPatch},
+		{"TicketBump", testTicketBump},
+		{"BillPayment", testBillPayment},
+		{"ConcurrentPayments", testConcurrentPayments},
+		{"Notifications", testNotifications},
+		{"Register", testRegister},
+		{"ConcurrentRegisters", testConcurrentRegisters},
+		{"Owners", testOwners},
+		{"ForeignReferences", testForeignReferences},
+		{"Devices", testDevices},
+		{"Logins", testLogins},
+		{"TwoFactorPolicies", testTwoFactorPolicies},
+		{"TwoFactorUses", testTwoFactorUses},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, b)
+		})
+	}
+}
+
+// newClient stores a client with a table and a dish of 100.
+func newClient(t *testing.T, b storage.Backend, name string) (client.Client, table.Table, dish.Dish) {
+	c := client.Client{Name: name, ExpireAt: time.Now().Add(time.Hour)}
+	if err := b.Clients.Create(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	tb := table.Table{ClientID: c.ID, Number: 1}
+	if err := b.Tables.Create(&tb); err != nil {
+		t.Fatal(err)
+	}
+
+	d := dish.Dish{}
+	d.ClientID = c.ID
+	d.Name = "Empanada"
+	d.Price = 10000
+	d.Pictures = []string{"empanada.png"}
+	d.Ingredients = []dish.Ingredient{{Name: "Queso", Active: true}}
+	if err := b.Dishes.Create(&d); err != nil {
+		t.Fatal(err)
+	}
+
+	return c, tb, d
+}
+
+func testSoftDelete(t *testing.T, b storage.Backend) {
+	c, tb, _ := newClient(t, b, "Bar")
+
+	assert := assert.New(t)
+	assert.Nil(b.Tables.Delete(tb.ID))
+
+	_, err := b.Tables.GetByID(tb.ID)
+	assert.Equal(storage.ErrNotFound, err)
+
+	tables, err := b.Tables.GetAll(c.ID)
+	assert.Nil(err)
+	assert.Empty(tables)
+
+	_, err = b.Tenants.Owner(tenant.Tables, tb.ID)
+	assert.Equal(storage.ErrNotFound, err)
+}
+
+func testClientScoping(t *testing.T, b storage.Backend) {
+	c1, _, d1 := newClient(t, b, "Bar")
+	c2, _, d2 := newClient(t, b, "Café")
+
+	assert := assert.New(t)
+
+	dishes, err := b.Dishes.GetAll(c1.ID)
+	assert.Nil(err)
+	assert.Len(dishes, 1)
+	assert.Equal(d1.ID, dishes[0].ID)
+
+	o, err := b.Tenants.Owner(tenant.Dishes, d2.ID)
+	assert.Nil(err)
+	assert.Equal(c2.ID, o.ClientID)
+
+	_, err = b.Tenants.Owner(tenant.Dishes, 1<<30)
+	assert.Equal(storage.ErrNotFound, err)
+}
+
+func testDefaults(t *testing.T, b storage.Backend) {
+	c, _, _ := newClient(t, b, "Bar")
+
+	assert := assert.New(t)
+
+	cat := category.Category{}
+	cat.ClientID = c.ID
+	cat.Title = "Bebidas"
+	cat.Picture = "bebidas.png"
+	assert.Nil(b.Categories.Create(&cat))
+
+	stored, err := b.Categories.GetByID(cat.ID)
+	assert.Nil(err)
+	assert.True(stored.Active)
+	assert.Equal(uint(1), stored.Position)
+	assert.Equal(category.Kitchen, stored.Station)
+	assert.Nil(stored.Suggested1)
+
+	grill := category.Category{}
+	grill.Title = "Parrilla"
+	grill.Picture = "parrilla.png"
+	grill.Station = "grill"
+	assert.Equal(storage.ErrBadRequest, b.Categories.Create(&grill))
+	assert.Equal(storage.ErrBadRequest, b.Categories.CreateMany(c.ID, []category.Category{cat, grill}))
+
+	categories, err := b.Categories.GetAll(c.ID)
+	assert.Nil(err)
+	assert.Len(categories, 1)
+
+	vat21 := dish.Dish{}
+	vat21.Name = "Cerveza"
+	vat21.Pictures = []string{"cerveza.png"}
+	vat21.TaxRate = "vat21"
+	assert.Equal(storage.ErrBadRequest, b.Dishes.CreateMany(c.ID, dish.Dishes{vat21}))
+
+	dishes, err := b.Dishes.GetAll(c.ID)
+	assert.Nil(err)
+	assert.Len(dishes, 1)
+}
+
+func testUpdate(t *testing.T, b storage.Backend) {
+	_, _, d := newClient(t, b, "Bar")
+
+	assert := assert.New(t)
+	assert.Nil(b.Dishes.Update(d.ID, map[string]interface{}{
+		"name":     "Chipa",
+		"price":    12500.0,
+		"pictures": []interface{}{"chipa.png", "chipa2.png"},
+		"ingredients": []interface{}{
+			map[string]interface{}{"name": "Anís", "active": true, "price": 500.0},
+		},
+	}))
+
+	stored, err := b.Dishes.GetByID(d.ID)
+	assert.Nil(err)
+	assert.Equal("Chipa", stored.Name)
+	assert.Equal(money.FromFloat(12500), stored.Price)
+	assert.Equal([]string{"chipa.png", "chipa2.png"}, stored.Pictures)
+	assert.Len(stored.Ingredients, 1)
+	assert.Equal("Anís", stored.Ingredients[0].Name)
+
+	stored.Ingredients[0].Name = "Changed"
+	again, _ := b.Dishes.GetByID(d.ID)
+	assert.Equal("Anís", again.Ingredients[0].Name)
+}
+
+func testConcurrentOrders(t *testing.T, b storage.Backend) {
+	c, tb, d := newClient(t, b, "Bar")
+
+	o := order.Order{ClientID: c.ID, TableID: tb.ID}
+	if _, err := b.Orders.Create(&o); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			b.Orders.Add(o.ID, []order.Item{{DishID: d.ID, Mount: 1}})
+		}()
+		go func() {
+			defer wg.Done()
+			b.Orders.GetAllActive(c.ID)
+		}()
+	}
+	wg.Wait()
+
+	stored, err := b.Orders.GetByID(o.ID)
+	assert.Nil(t, err)
+	assert.Len(t, stored.Items, workers)
+}
+
+func testBillPayment(t *testing.T, b storage.Backend) {
+	c, tb, d := newClient(t, b, "Bar")
+
+	o := order.Order{ClientID: c.ID, TableID: tb.ID}
+	if _, err := b.Orders.Create(&o); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+	assert.Nil(b.Orders.Add(o.ID, []order.Item{{DishID: d.ID, Mount: 2}}))
+
+	for _, s := range []order.Status{order.Accepted, order.Preparing, order.Ready, order.Served} {
+		_, err := b.Orders.TransitionOrder(o.ID, s)
+		assert.Nil(err)
+	}
+
+	orders, err := b.Orders.GetUnbilled(tb.ID)
+	assert.Nil(err)
+	assert.Len(orders, 1)
+
+	bl, err := bill.Compute(orders, c)
+	assert.Nil(err)
+	assert.Nil(b.Bills.Create(bl))
+	assert.Equal(money.FromFloat(200), bl.Value)
+
+	again, err := bill.Compute(orders, c)
+	assert.Nil(err)
+	assert.Equal(bill.ErrAlreadyBilled, b.Bills.Create(again))
+
+	bills, err := b.Bills.GetAll(c.ID)
+	assert.Nil(err)
+	assert.Len(bills, 1)
+
+	byID, err := b.Orders.GetByIDs([]uint{o.ID})
+	assert.Nil(err)
+	assert.Equal(bl.ID, *byID[0].BillID)
+	assert.Len(byID[0].Items, 1)
+
+	_, err = b.Orders.GetByIDs([]uint{o.ID, 1 << 30})
+	assert.Equal(storage.ErrNotFound, err)
+
+	orders, err = b.Orders.GetUnbilled(tb.ID)
+	assert.Nil(err)
+	assert.Empty(orders)
+
+	p := payment.Payment{BillID: bl.ID, Method: payment.Cash, Amount: bl.Value}
+	assert.Nil(b.Payments.Create(&p))
+
+	stored, err := b.Bills.GetByID(bl.ID)
+	assert.Nil(err)
+	assert.True(stored.Paid)
+	assert.Len(stored.Payments, 1)
+
+	paid, err := b.Orders.GetByID(o.ID)
+	assert.Nil(err)
+	assert.Equal(order.Paid, paid.Status)
+	assert.Equal(order.Paid, paid.Items[0].Status)
+
+	assert.Equal(bill.ErrHasPayments, b.Bills.Delete(bl.ID))
+}
+
+// testConcurrentPayments checks that payments made at once can't pay more
+// than the bill.
+func testConcurrentPayments(t *testing.T, b storage.Backend) {
+	c, tb, d := newClient(t, b, "Bar")
+	o := newOrder(t, b, tb, d)
+
+	bl, err := bill.Compute([]order.Order{o}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Bills.Create(bl); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 10
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := payment.Payment{BillID: bl.ID, Method: payment.Card, Amount: bl.Value}
+			err := b.Payments.Create(&p)
+
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	paid := 0
+	for _, err := range errs {
+		if err == nil {
+			paid++
+		}
+	}
+
+	assert := assert.New(t)
+	assert.Equal(1, paid)
+
+	payments, err := b.Payments.GetByBill(bl.ID)
+	assert.Nil(err)
+	assert.Len(payments, 1)
+}
+
+// newOrder stores an order of the table with one item of the dish.
+func newOrder(t *testing.T, b storage.Backend, tb table.Table, d dish.Dish) order.Order {
+	o := order.Order{ClientID: tb.ClientID, TableID: tb.ID}
+	if _, err := b.Orders.Create(&o); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Orders.Add(o.ID, []order.Item{{DishID: d.ID, Mount: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := b.Orders.GetByID(o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return o
+}
+
+// testItemPatch checks that a patch can only cancel an item, and that Ready
+// follows its status.
+func testItemPatch(t *testing.T, b storage.Backend) {
+	_, tb, d := newClient(t, b, "Bar")
+	o := newOrder(t, b, tb, d)
+	id := o.Items[0].ID
+
+	assert := assert.New(t)
+	assert.False(o.Items[0].Ready)
+
+	assert.Equal(order.ErrNotPatchable, b.Orders.PatchItem(id, map[string]interface{}{"ready": true}))
+
+	i, err := b.Orders.AdvanceItem(id, order.Ready)
+	assert.Nil(err)
+	assert.True(i.Ready)
+
+	assert.Nil(b.Orders.PatchItem(id, map[string]interface{}{"active": false}))
+	assert.Equal(order.ErrInvalidTransition, b.Orders.PatchItem(id, map[string]interface{}{"active": true}))
+
+	stored, err := b.Orders.GetByID(o.ID)
+	assert.Nil(err)
+	assert.Equal(order.Cancelled, stored.Items[0].Status)
+	assert.False(stored.Items[0].Active)
+	assert.False(stored.Items[0].Ready)
+
+	// Closed orders take no more items.
+	_, err = b.Orders.TransitionOrder(o.ID, order.Cancelled)
+	assert.Nil(err)
+	assert.Equal(order.ErrInvalidTransition, b.Orders.Add(o.ID, []order.Item{{DishID: d.ID}}))
+
+	stored, err = b.Orders.GetByID(o.ID)
+	assert.Nil(err)
+	assert.Len(stored.Items, len(o.Items))
+}
+
+// testTicketBump checks that the items of a ticket are bumped all of them or
+// none.
+func testTicketBump(t *testing.T, b storage.Backend) {
+	_, tb, d := newClient(t, b, "Bar")
+	o := newOrder(t, b, tb, d)
+	if err := b.Orders.Add(o.ID, []order.Item{{DishID: d.ID, Mount: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := b.Orders.GetByID(o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := o.Items[0].ID, o.Items[1].ID
+	if _, err := b.Orders.TransitionItem(second, order.Cancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	_, err = b.Orders.AdvanceItems([]uint{first, second}, order.Ready)
+	assert.Equal(order.ErrInvalidTransition, err)
+
+	stored, err := b.Orders.GetByID(o.ID)
+	assert.Nil(err)
+	assert.False(stored.Items[0].Ready)
+	assert.Equal(order.Received, stored.Items[0].Status)
+
+	items, err := b.Orders.AdvanceItems([]uint{first}, order.Ready)
+	assert.Nil(err)
+	assert.Len(items, 1)
+	assert.True(items[0].Ready)
+}
+
+// testOrderLoading checks the orders are loaded with their table and items,
+// each one with its dish and the ingredients selected.
+func testOrderLoading(t *testing.T, b storage.Backend) {
+	c, tb, plain := newClient(t, b, "Bar")
+
+	cat := category.Category{}
+	cat.ClientID = c.ID
+	cat.Title = "Pizzas"
+	cat.Picture = "pizzas.png"
+	if err := b.Categories.Create(&cat); err != nil {
+		t.Fatal(err)
+	}
+
+	d := dish.Dish{}
+	d.ClientID = c.ID
+	d.CategoryID = cat.ID
+	d.Name = "Muzzarella"
+	d.Price = 50000
+	d.Pictures = []string{"muzzarella.png"}
+	d.Ingredients = []dish.Ingredient{{Name: "Queso", Active: true}, {Name: "Aceitunas", Active: true}}
+	if err := b.Dishes.Create(&d); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := b.Dishes.GetByID(d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := []order.Order{}
+	for range []int{1, 2} {
+		o := order.Order{ClientID: c.ID, TableID: tb.ID}
+		if _, err := b.Orders.Create(&o); err != nil {
+			t.Fatal(err)
+		}
+
+		items := []order.Item{{DishID: d.ID, Mount: 2}, {DishID: plain.ID, Mount: 1}}
+		items[0].Ingredients = []dish.Ingredient{d.Ingredients[1]}
+		items[0].Ingredients[0].Active = false
+		if err := b.Orders.Add(o.ID, items); err != nil {
+			t.Fatal(err)
+		}
+
+		orders = append(orders, o)
+	}
+
+	assert := assert.New(t)
+
+	all, err := b.Orders.GetAll(c.ID)
+	assert.Nil(err)
+	if !assert.Len(all, 2) {
+		return
+	}
+
+	for _, o := range all {
+		assert.Equal(tb.Number, o.Table.Number)
+		if !assert.Len(o.Items, 2) {
+			continue
+		}
+
+		i := o.Items[0]
+		assert.Equal("Muzzarella", i.Dish.Name)
+		assert.Equal([]string{"muzzarella.png"}, i.Dish.Pictures)
+		assert.Equal("Pizzas", i.Dish.Category.Title)
+		assert.Len(i.Dish.Ingredients, 2)
+		if assert.Len(i.SelectedIngredients, 1) {
+			assert.False(i.SelectedIngredients[0].Active)
+			assert.Equal("Aceitunas", i.SelectedIngredients[0].Ingredient.Name)
+		}
+
+		i = o.Items[1]
+		assert.Equal("Empanada", i.Dish.Name)
+		assert.NotNil(i.Dish.Category)
+		assert.Empty(i.SelectedIngredients)
+	}
+
+	active, err := b.Orders.GetAllActive(c.ID)
+	assert.Nil(err)
+	if assert.Len(active, 1) && assert.Len(active[0], 2) {
+		assert.Len(active[0][1].Items, 2)
+	}
+
+	unbilled, err := b.Orders.GetUnbilled(tb.ID)
+	assert.Nil(err)
+	if assert.Len(unbilled, 2) {
+		assert.Equal(orders[0].ID, unbilled[0].ID)
+		assert.Len(unbilled[0].Items, 2)
+	}
+
+	dishes, err := b.Dishes.GetAllByCategory(cat.ID)
+	assert.Nil(err)
+	if assert.Len(dishes, 1) {
+		assert.Equal("Pizzas", dishes[0].Category.Title)
+		assert.Len(dishes[0].Ingredients, 2)
+	}
+
+	// Replacing the ingredients of the dish drops those selected.
+	err = b.Dishes.Update(d.ID, map[string]interface{}{
+		"ingredients": []interface{}{map[string]interface{}{"name": "Orégano", "active": true}},
+	})
+	assert.Nil(err)
+
+	o, err := b.Orders.GetByID(orders[0].ID)
+	assert.Nil(err)
+	if assert.Len(o.Items, 2) {
+		assert.Empty(o.Items[0].SelectedIngredients)
+		assert.Len(o.Items[0].Dish.Ingredients, 1)
+	}
+}
+
+func testNotifications(t *testing.T, b storage.Backend) {
+	c, tb, _ := newClient(t, b, "Bar")
+
+	n := notification.Notification{Type: notification.CallWaiter, ClientID: c.ID, TableID: tb.ID}
+	if err := b.Notifications.Create(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+
+	pending, err := b.Notifications.GetPending(c.ID, 0)
+	assert.Nil(err)
+	assert.Len(pending, 1)
+	assert.Equal(tb.ID, pending[0].Table.ID)
+
+	acked, err := b.Notifications.Ack(n.ID, 7)
+	assert.Nil(err)
+	assert.False(acked.Active)
+
+	_, err = b.Notifications.Ack(n.ID, 7)
+	assert.Equal(notification.ErrAlreadyAcked, err)
+
+	pending, err = b.Notifications.GetPending(c.ID, 0)
+	assert.Nil(err)
+	assert.Empty(pending)
+
+	staff := notification.Notification{Type: notification.GetCheck, ClientID: c.ID, TableID: tb.ID}
+	if err := b.Notifications.Create(&staff); err != nil {
+		t.Fatal(err)
+	}
+
+	acked, err = b.Notifications.Ack(staff.ID, 0)
+	assert.Nil(err)
+	assert.Nil(acked.AckedBy)
+
+	raced := notification.Notification{Type: notification.CallWaiter, ClientID: c.ID, TableID: tb.ID}
+	if err := b.Notifications.Create(&raced); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		winner notification.Notification
+		wins   int
+	)
+	for waiterID := uint(1); waiterID <= 10; waiterID++ {
+		wg.Add(1)
+		go func(waiterID uint) {
+			defer wg.Done()
+			n, err := b.Notifications.Ack(raced.ID, waiterID)
+			if err != nil && err != notification.ErrAlreadyAcked {
+				t.Error(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				winner = n
+				wins++
+			}
+		}(waiterID)
+	}
+	wg.Wait()
+
+	assert.Equal(1, wins)
+
+	stored, err := b.Notifications.GetByID(raced.ID)
+	assert.Nil(err)
+	if assert.NotNil(stored.AckedBy) && assert.NotNil(winner.AckedBy) {
+		assert.Equal(*winner.AckedBy, *stored.AckedBy)
+	}
+}
+
+func testRegister(t *testing.T, b storage.Backend) {
+	c, tb, d := newClient(t, b, "Bar")
+
+	assert := assert.New(t)
+
+	rs := register.Session{ClientID: c.ID, OpeningCash: money.FromUnits(100)}
+	assert.Nil(b.Registers.Open(&rs))
+	assert.Equal(register.ErrAlreadyOpen, b.Registers.Open(&register.Session{ClientID: c.ID}))
+
+	o := newOrder(t, b, tb, d)
+	_, err := b.Orders.TransitionOrder(o.ID, order.Cancelled)
+	assert.Nil(err)
+
+	voided := newOrder(t, b, tb, d)
+	_, err = b.Orders.TransitionItem(voided.Items[0].ID, order.Cancelled)
+	assert.Nil(err)
+
+	r, err := b.Registers.Report(rs.ID)
+	assert.Nil(err)
+	assert.Equal(1, r.Cancellations)
+	assert.Equal(d.Price, r.CancellationsValue)
+	assert.Equal(1, r.Voids)
+	assert.Equal(d.Price, r.VoidsValue)
+
+	r, err = b.Registers.Close(rs.ID, money.FromUnits(90), "")
+	assert.Nil(err)
+	assert.Equal(money.FromUnits(-10), r.Difference)
+
+	_, err = b.Registers.GetOpen(c.ID)
+	assert.Equal(storage.ErrNotFound, err)
+
+	_, err = b.Registers.Close(rs.ID, 0, "")
+	assert.Equal(register.ErrAlreadyClosed, err)
+}
+
+// testConcurrentRegisters checks that registers opened at once leave a
+// single session open, and that closed at once it is closed a single time.
+func testConcurrentRegisters(t *testing.T, b storage.Backend) {
+	c, _, _ := newClient(t, b, "Bar")
+
+	const workers = 10
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		opened int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := b.Registers.Open(&register.Session{ClientID: c.ID})
+			if err != nil && err != register.ErrAlreadyOpen {
+				t.Error(err)
+			}
+
+			mu.Lock()
+			if err == nil {
+				opened++
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert := assert.New(t)
+	assert.Equal(1, opened)
+
+	sessions, err := b.Registers.GetAll(c.ID)
+	assert.Nil(err)
+	assert.Len(sessions, 1)
+
+	closed := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := b.Registers.Close(sessions[0].ID, money.FromUnits(10), "")
+			if err != nil && err != register.ErrAlreadyClosed {
+				t.Error(err)
+			}
+
+			mu.Lock()
+			if err == nil {
+				closed++
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(1, closed)
+}
+
+func testOwners(t *testing.T, b storage.Backend) {
+	c, tb, d := newClient(t, b, "Bar")
+	o := newOrder(t, b, tb, d)
+
+	assert := assert.New(t)
+
+	owner, err := b.Tenants.Owner(tenant.Items, o.Items[0].ID)
+	assert.Nil(err)
+	assert.Equal(tenant.Ownership{ClientID: c.ID, TableID: tb.ID}, owner)
+
+	questions, err := b.Questions.GetAll(c.ID)
+	assert.Nil(err)
+	assert.Len(questions, 1)
+
+	r := rating.Rating{QuestionID: questions[0].ID, Score: 5}
+	assert.Nil(b.Ratings.Create(&r))
+
+	owner, err = b.Tenants.Owner(tenant.Ratings, r.ID)
+	assert.Nil(err)
+	assert.Equal(tenant.Ownership{ClientID: c.ID}, owner)
+
+	ids, err := b.Tenants.Clients(c.UserID)
+	assert.Nil(err)
+	assert.Contains(ids, c.ID)
+}
+
+// testForeignReferences checks that menus and orders can't reference the
+// dishes and categories of another client.
+func testForeignReferences(t *testing.T, b storage.Backend) {
+	c, tb, d := newClient(t, b, "Bar")
+	other, _, foreign := newClient(t, b, "Café")
+
+	newCategory := func(clientID uint) category.Category {
+		cat := category.Category{}
+		cat.ClientID = clientID
+		cat.Title = "Pizzas"
+		cat.Picture = "pizzas.png"
+		if err := b.Categories.Create(&cat); err != nil {
+			t.Fatal(err)
+		}
+
+		return cat
+	}
+	cat, foreignCat := newCategory(c.ID), newCategory(other.ID)
+
+	assert := assert.New(t)
+
+	nd := dish.Dish{}
+	nd.ClientID = c.ID
+	nd.CategoryID = foreignCat.ID
+	nd.Name = "Fugazza"
+	nd.Pictures = []string{"fugazza.png"}
+	assert.Equal(storage.ErrForeignReference, b.Dishes.Create(&nd))
+
+	update := map[string]interface{}{"category_id": float64(foreignCat.ID)}
+	assert.Equal(storage.ErrForeignReference, b.Dishes.Update(d.ID, update))
+	assert.Nil(b.Dishes.Update(d.ID, map[string]interface{}{"category_id": float64(cat.ID)}))
+
+	cat.Suggested1 = &foreign.ID
+	assert.Equal(storage.ErrForeignReference, b.Categories.Update(cat.ID, &cat))
+	patch := map[string]interface{}{"suggested2": float64(foreign.ID)}
+	assert.Equal(storage.ErrForeignReference, b.Categories.Patch(cat.ID, patch))
+	cat.Suggested1 = &d.ID
+	assert.Nil(b.Categories.Update(cat.ID, &cat))
+
+	p := promotion.Promotion{ClientID: c.ID, DishID: foreign.ID, StartAt: "10:00", EndAt: "12:00"}
+	p.Title = "2x1"
+	p.Picture = "2x1.png"
+	assert.Equal(storage.ErrForeignReference, b.Promotions.Create(&p))
+	p.DishID = d.ID
+	assert.Nil(b.Promotions.Create(&p))
+	p.DishID = foreign.ID
+	assert.Equal(storage.ErrForeignReference, b.Promotions.Update(p.ID, &p))
+
+	o := newOrder(t, b, tb, d)
+	items := []order.Item{{DishID: d.ID, Mount: 1}, {DishID: foreign.ID, Mount: 1}}
+	assert.Equal(storage.ErrForeignReference, b.Orders.Add(o.ID, items))
+
+	stored, err := b.Orders.GetByID(o.ID)
+	assert.Nil(err)
+	assert.Len(stored.Items, 1)
+
+	sd, err := b.Dishes.GetByID(d.ID)
+	assert.Nil(err)
+	assert.Equal(cat.ID, sd.CategoryID)
+
+	sp, err := b.Promotions.GetByID(p.ID)
+	assert.Nil(err)
+	assert.Equal(d.ID, sp.DishID)
+}
+
+func testDevices(t *testing.T, b storage.Backend) {
+	c, tb, _ := newClient(t, b, "Bar")
+
+	d := device.Device{TableID: tb.ID}
+	if err := b.Devices.Create(&d); err != nil {
+		t.Fatal(err)
+	}
+
+	assert := assert.New(t)
+	assert.Equal(c.ID, d.ClientID)
+	assert.NotEmpty(d.Code)
+
+	_, err := b.Tenants.Device(d.ID)
+	assert.Equal(tenant.ErrRevoked, err)
+
+	paired, err := b.Devices.Pair(d.Code)
+	assert.Nil(err)
+	assert.NotNil(paired.PairedAt)
+
+	_, err = b.Devices.Pair(d.Code)
+	assert.Equal(device.ErrInvalidCode, err)
+
+	owner, err := b.Tenants.Device(d.ID)
+	assert.Nil(err)
+	assert.Equal(tenant.Ownership{ClientID: c.ID, TableID: tb.ID}, owner)
+
+	assert.Nil(b.Devices.Revoke(d.ID))
+	_, err = b.Tenants.Device(d.ID)
+	assert.Equal(tenant.ErrRevoked, err)
+}
+
+func testLogins(t *testing.T, b storage.Backend) {
+	c, _, _ := newClient(t, b, "Bar")
+	userID := c.ID
+
+	now := time.Now()
+	first, _ := login.New(userID, "", now)
+	next, _ := login.New(userID, first.Family, now)
+	again, _ := login.New(userID, first.Family, now)
+
+	assert := assert.New(t)
+	assert.Nil(b.Logins.Create(first))
+	assert.Nil(b.Logins.Rotate(*first, next))
+	assert.Equal(login.ErrReused, b.Logins.Rotate(*first, again))
+
+	stored, err := b.Logins.GetByToken(next.Token)
+	assert.Nil(err)
+	assert.Equal(next.ID, stored.ID)
+
+	active, err := b.Logins.GetActive(userID)
+	assert.Nil(err)
+	assert.Len(active, 1)
+	assert.Equal(next.ID, active[0].ID)
+
+	assert.Nil(b.Tenants.Session(next.ID))
+	assert.Nil(b.Logins.RevokeFamily(first.Family))
+	assert.Equal(tenant.ErrRevoked, b.Tenants.Session(next.ID))
+
+	l := login.Lockout{Email: "bar@example.com", Until: now.Add(time.Minute)}
+	assert.Nil(b.Logins.RecordLockout(&l))
+
+	lockouts, err := b.Logins.GetLockouts(now.Add(-time.Minute))
+	assert.Nil(err)
+	assert.NotEmpty(lockouts)
+	assert.Equal(l.ID, lockouts[0].ID)
+
+	lockouts, err = b.Logins.GetLockouts(now.Add(time.Minute))
+	assert.Nil(err)
+	assert.Empty(lockouts)
+}
+
+func testTwoFactorPolicies(t *testing.T, b storage.Backend) {
+	assert := assert.New(t)
+
+	assert.Nil(b.Users.SetTwoFactorPolicy(user.TwoFactorPolicy{Role: user.Cashier, Required: true}))
+	required, err := b.Users.TwoFactorRequired(user.Cashier)
+	assert.Nil(err)
+	assert.True(required)
+
+	assert.Nil(b.Users.SetTwoFactorPolicy(user.TwoFactorPolicy{Role: user.Cashier}))
+	required, err = b.Users.TwoFactorRequired(user.Cashier)
+	assert.Nil(err)
+	assert.False(required)
+
+	policies, err := b.Users.GetTwoFactorPolicies()
+	assert.Nil(err)
+
+	count := 0
+	for _, p := range policies {
+		if p.Role == user.Cashier {
+			count++
+		}
+	}
+	assert.Equal(1, count)
+}
+
+func testTwoFactorUses(t *testing.T, b storage.Backend) {
+	c, _, _ := newClient(t, b, "Bar")
+	userID := c.ID
+
+	assert := assert.New(t)
+
+	step, err := b.Users.TOTPStep(userID)
+	assert.Nil(err)
+	assert.Equal(int64(0), step)
+
+	assert.Nil(b.Users.UseTOTPStep(userID, 100))
+	assert.Equal(user.ErrCodeUsed, b.Users.UseTOTPStep(userID, 100))
+	assert.Equal(user.ErrCodeUsed, b.Users.UseTOTPStep(userID, 99))
+	assert.Nil(b.Users.UseTOTPStep(userID, 101))
+
+	step, err = b.Users.TOTPStep(userID)
+	assert.Nil(err)
+	assert.Equal(int64(101), step)
+
+	id, err := user.NewChallengeID()
+	assert.Nil(err)
+	used := user.UsedChallenge{ID: id, ExpiresAt: time.Now().Add(time.Minute)}
+	assert.Nil(b.Users.UseChallenge(used))
+	assert.Equal(user.ErrChallengeUsed, b.Users.UseChallenge(used))
+
+	// Only one of the uses made at once of a step succeeds.
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		taken int
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Users.UseTOTPStep(userID, 102) == nil {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(1, taken)
+}
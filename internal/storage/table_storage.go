@@ -10,14 +10,21 @@ import (
 type TableStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to TableStorage
 func (s *TableStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the TableStorage joined to a unit of work
+func (s TableStorage) WithTx(tx *Tx) TableStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new table
 func (s TableStorage) Create(t *table.Table) error {
 	s.setContext()
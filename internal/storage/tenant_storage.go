@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/tenant"
+)
+
+// TenantStorage resolves which client owns each resource.
+type TenantStorage struct {
+	session *Session
+	db      *gorm.DB
+	tx      *Tx
+}
+
+// setContext initialize the context to TenantStorage.
+func (s *TenantStorage) setContext() {
+	s.session = sessionOf(s.tx)
+	s.db = s.session.Client
+}
+
+// WithTx returns the TenantStorage joined to a unit of work.
+func (s TenantStorage) WithTx(tx *Tx) TenantStorage {
+	s.tx = tx
+	return s
+}
+
+// Clients returns the IDs of the clients of a user.
+func (s TenantStorage) Clients(userID uint) ([]uint, error) {
+	s.setContext()
+
+	ids := []uint{}
+	err := s.db.Model(&client.Client{}).Where("user_id = ?", userID).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return []uint{}, ErrNotFound
+	}
+
+	return ids, nil
+}
+
+// Owner returns the client and table owning a resource. Items and ratings
+// belong to the client of their order and question. Deleted resources, or
+// those whose order or question was deleted, are not found.
+func (s TenantStorage) Owner(res tenant.Resource, id uint) (tenant.Ownership, error) {
+	s.setContext()
+
+	q := s.db.Table(string(res)).Where(string(res)+".id = ?", id)
+	columns := "client_id, 0"
+
+	// Notifications are never deleted, they have no deleted_at.
+	if res != tenant.Notifications {
+		q = q.Where(string(res) + ".deleted_at IS NULL")
+	}
+
+	switch res {
+	case tenant.Tables:
+		columns = "client_id, id"
+	case tenant.Bills, tenant.Devices, tenant.Notifications, tenant.Orders:
+		columns = "client_id, COALESCE(table_id, 0)"
+	case tenant.Items:
+		q = q.Joins("JOIN orders ON orders.id = items.order_id AND orders.deleted_at IS NULL")
+		columns = "orders.client_id, COALESCE(orders.table_id, 0)"
+	case tenant.Ratings:
+		q = q.Joins("JOIN questions ON questions.id = ratings.question_id AND questions.deleted_at IS NULL")
+		columns = "questions.client_id, 0"
+	}
+
+	o := tenant.Ownership{}
+	err := q.Select(columns).Row().Scan(&o.ClientID, &o.TableID)
+	if err != nil {
+		return tenant.Ownership{}, ErrNotFound
+	}
+
+	return o, nil
+}
+
+// references checks that the resources by ID, but the zero ones, are of the
+// client given. Those missing or of another client fail with
+// ErrForeignReference.
+func references(tx *Tx, res tenant.Resource, clientID uint, ids ...uint) error {
+	for _, id := range ids {
+		if id == 0 {
+			continue
+		}
+
+		o, err := TenantStorage{}.WithTx(tx).Owner(res, id)
+		if err != nil || o.ClientID != clientID {
+			return ErrForeignReference
+		}
+	}
+
+	return nil
+}
+
+// referencesOf checks the resources as references does, for the client of
+// the resource that references them.
+func referencesOf(tx *Tx, parent tenant.Resource, id uint, res tenant.Resource, ids ...uint) error {
+	o, err := TenantStorage{}.WithTx(tx).Owner(parent, id)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	return references(tx, res, o.ClientID, ids...)
+}
+
+// updatedID returns the ID of a reference in the updates of a patch, 0 when
+// it is not given or is null.
+func updatedID(updates map[string]interface{}, column string) (uint, error) {
+	v, ok := updates[column]
+	if !ok || v == nil {
+		return 0, nil
+	}
+
+	id, ok := v.(float64)
+	if !ok || id < 0 {
+		return 0, ErrBadRequest
+	}
+
+	return uint(id), nil
+}
+
+// Device returns the client and table of an active device.
+func (s TenantStorage) Device(id uint) (tenant.Ownership, error) {
+	d, err := DeviceStorage{}.WithTx(s.tx).GetByID(id)
+	if err != nil {
+		return tenant.Ownership{}, tenant.ErrRevoked
+	}
+
+	if !d.IsActive() {
+		return tenant.Ownership{}, tenant.ErrRevoked
+	}
+
+	return tenant.Ownership{ClientID: d.ClientID, TableID: d.TableID}, nil
+}
+
+// Session checks that a login session is active.
+func (s TenantStorage) Session(id uint) error {
+	ls, err := LoginStorage{}.WithTx(s.tx).GetByID(id)
+	if err != nil || !ls.IsActive(time.Now()) {
+		return tenant.ErrRevoked
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+// Tx is a unit of work, the storages joined to it with their WithTx method
+// write in a single transaction, committed or rolled back as a whole.
+type Tx struct {
+	session *Session
+}
+
+// Begin starts a unit of work on the database connected.
+func Begin() (*Tx, error) {
+	db := getSession()
+	if db == nil {
+		return nil, ErrNotConnected
+	}
+
+	db = db.Begin()
+	if db.Error != nil {
+		return nil, db.Error
+	}
+
+	return &Tx{session: &Session{Client: db}}, nil
+}
+
+// Commit writes the changes of the unit of work.
+func (tx *Tx) Commit() error {
+	return tx.session.Client.Commit().Error
+}
+
+// Rollback discards the changes of the unit of work.
+func (tx *Tx) Rollback() error {
+	return tx.session.Client.Rollback().Error
+}
+
+// Atomic runs fn in a new unit of work, committed if fn succeeds and rolled
+// back if it fails or panics.
+func Atomic(fn func(tx *Tx) error) (err error) {
+	tx, err := Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// inTx runs fn in the unit of work joined, that the caller commits, or in a
+// new one if there is none.
+func inTx(tx *Tx, fn func(tx *Tx) error) error {
+	if tx != nil {
+		return fn(tx)
+	}
+
+	return Atomic(fn)
+}
+
+// sessionOf returns the session of a unit of work, or a new one if it is
+// nil.
+func sessionOf(tx *Tx) *Session {
+	if tx == nil {
+		return NewSession()
+	}
+
+	return tx.session
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+)
+
+// connectSQLite connects to a migrated SQLite database in memory.
+func connectSQLite(t *testing.T) {
+	err := Connect("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = MigrateUp()
+	if err != nil {
+		Close()
+		t.Fatal(err)
+	}
+}
+
+func TestAtomic(t *testing.T) {
+	connectSQLite(t)
+	defer Close()
+
+	assert := assert.New(t)
+	failed := errors.New("failed")
+
+	c := category.Category{ClientID: 1}
+	c.Title = "Drinks"
+	c.Picture = "drinks.png"
+	err := Atomic(func(tx *Tx) error {
+		err := CategoryStorage{}.WithTx(tx).Create(&c)
+		if err != nil {
+			return err
+		}
+
+		d := dish.Dish{ClientID: 1, CategoryID: c.ID}
+		d.Name = "Lemonade"
+		d.Pictures = []string{"lemonade.png"}
+		err = DishStorage{}.WithTx(tx).Create(&d)
+		if err != nil {
+			return err
+		}
+
+		return failed
+	})
+	assert.Equal(failed, err)
+
+	_, err = CategoryStorage{}.GetByID(c.ID)
+	assert.Equal(ErrNotFound, err)
+
+	dishes, err := DishStorage{}.GetAllByCategory(c.ID)
+	assert.Nil(err)
+	assert.Empty(dishes)
+
+	assert.Panics(func() {
+		Atomic(func(tx *Tx) error {
+			CategoryStorage{}.WithTx(tx).Create(&c)
+			panic(failed)
+		})
+	})
+
+	categories, err := CategoryStorage{}.GetAll(1)
+	assert.Nil(err)
+	assert.Empty(categories)
+
+	c.ID = 0
+	err = Atomic(func(tx *Tx) error {
+		return CategoryStorage{}.WithTx(tx).Create(&c)
+	})
+	assert.Nil(err)
+
+	stored, err := CategoryStorage{}.GetByID(c.ID)
+	assert.Nil(err)
+	assert.Equal("Drinks", stored.Title)
+}
+
+// TestAtomicWrites checks that the writes of many rows leave nothing behind
+// when one of them fails.
+func TestAtomicWrites(t *testing.T) {
+	connectSQLite(t)
+	defer Close()
+
+	assert := assert.New(t)
+
+	categories := make([]category.Category, 2)
+	for i := range categories {
+		categories[i].ID = 10
+		categories[i].Title = "Drinks"
+	}
+	assert.Equal(ErrNotInsert, CategoryStorage{}.CreateMany(1, categories))
+
+	stored, err := CategoryStorage{}.GetAll(1)
+	assert.Nil(err)
+	assert.Empty(stored)
+
+	dishes := make(dish.Dishes, 2)
+	for i := range dishes {
+		dishes[i].ID = 10
+		dishes[i].Name = "Lemonade"
+	}
+	assert.Equal(ErrNotInsert, DishStorage{}.CreateMany(1, dishes))
+
+	storedDishes, err := DishStorage{}.GetAll(1)
+	assert.Nil(err)
+	assert.Empty(storedDishes)
+
+	d := dish.Dish{ClientID: 1}
+	d.Name = "Lemonade"
+	d.Pictures = []string{"lemonade.png"}
+	assert.Nil(DishStorage{}.Create(&d))
+
+	o, err := OrderStorage{}.Create(&order.Order{ClientID: 1, TableID: 1})
+	assert.Nil(err)
+
+	items := make([]order.Item, 2)
+	for i := range items {
+		items[i].ID = 10
+		items[i].Mount = 1
+		items[i].DishID = d.ID
+	}
+	assert.Equal(ErrNotInsert, OrderStorage{}.Add(o.ID, items))
+
+	var count int
+	assert.Nil(conn.Model(&order.Item{}).Where("order_id = ?", o.ID).Count(&count).Error)
+	assert.Zero(count)
+}
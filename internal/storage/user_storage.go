@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	"github.com/sethvargo/go-password/password"
@@ -13,14 +14,21 @@ import (
 type UserStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to UserStorage
 func (s *UserStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the UserStorage joined to a unit of work
+func (s UserStorage) WithTx(tx *Tx) UserStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new user
 func (s UserStorage) Create(u *user.User) error {
 	s.setContext()
@@ -104,6 +112,23 @@ func (s UserStorage) GetAll() (user.Users, error) {
 	return users, nil
 }
 
+// GetByClient returns the staff of a client.
+func (s UserStorage) GetByClient(clientID uint) (user.Users, error) {
+	s.setContext()
+
+	users := user.Users{}
+	err := s.db.Order("email").Find(&users, "client_id = ?", clientID).Error
+	if err != nil {
+		return []user.User{}, ErrNotFound
+	}
+
+	for i := 0; i < len(users); i++ {
+		users[i].CleanPass()
+	}
+
+	return users, nil
+}
+
 // GetByID returns a user by ID
 func (s UserStorage) GetByID(id uint) (user.User, error) {
 	s.setContext()
@@ -157,34 +182,302 @@ func (s UserStorage) Confirm(id uint, u *user.User) error {
 	return nil
 }
 
-// RecoverPassword generate a temporal password and send a email to the user
-// with the new password
-func (s UserStorage) RecoverPassword(address string) error {
+// RequestReset stores a reset of the password of the user of the address,
+// replacing the pending ones, and emails the link to the user. The password
+// is kept until the reset is done.
+func (s UserStorage) RequestReset(address string) error {
 	s.setContext()
 
-	pass, err := password.Generate(10, 3, 0, false, false)
+	u := user.User{}
+	err := s.db.First(&u, "email = ?", address).Error
 	if err != nil {
-		return ErrPasswordGeneration
+		return ErrNotFound
 	}
 
-	u := user.New()
-
-	u.Password = pass
-	u.PreparePass()
+	now := time.Now()
+	r, token, err := user.NewReset(u.ID, now)
+	if err != nil {
+		return ErrNotInsert
+	}
 
-	err = s.db.Model(&user.User{}).Where("email = ?", address).Update("HashPassword", u.HashPassword).Error
+	err = s.db.Model(&user.Reset{}).
+		Where("user_id = ? AND used_at IS NULL", u.ID).
+		Update("used_at", now).Error
 	if err != nil {
 		return ErrNotUpdate
 	}
 
+	err = s.db.Create(r).Error
 	if err != nil {
-		return ErrNotFound
+		return ErrNotInsert
 	}
 
-	e := email.ChangePassword(address, pass)
+	e := email.ResetPassword(address, token)
 	if err = e.Send(); err != nil {
+		fmt.Println(err)
 		return ErrSendEmail
 	}
 
 	return nil
 }
+
+// ResetPassword sets the new password of the user of a reset token, that
+// can't be used again. It returns the ID of the user.
+func (s UserStorage) ResetPassword(p user.NewPassword) (uint, error) {
+	err := p.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	u := user.New()
+	u.Password = p.Password
+	err = u.PreparePass()
+	if err != nil {
+		return 0, err
+	}
+
+	r := user.Reset{}
+	err = inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		err := s.db.First(&r, "token_hash = ?", user.HashResetToken(p.Token)).Error
+		if err != nil {
+			return user.ErrInvalidReset
+		}
+
+		now := time.Now()
+		if !r.IsValid(now) {
+			return user.ErrInvalidReset
+		}
+
+		result := s.db.Model(&user.Reset{}).
+			Where("id = ? AND used_at IS NULL", r.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return ErrNotUpdate
+		}
+
+		if result.RowsAffected == 0 {
+			return user.ErrInvalidReset
+		}
+
+		err = s.db.Model(&user.User{}).Where("id = ?", r.UserID).
+			Update("HashPassword", u.HashPassword).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return r.UserID, nil
+}
+
+// SetTOTPSecret stores the secret of a user enrolling in two factor
+// authentication, that is enabled once a code is verified.
+func (s UserStorage) SetTOTPSecret(id uint, secret string) error {
+	s.setContext()
+
+	result := s.db.Model(&user.User{}).
+		Where("id = ? AND totp_enabled = ?", id, false).
+		Update("totp_secret", secret)
+	if result.Error != nil {
+		return ErrNotUpdate
+	}
+
+	if result.RowsAffected == 0 {
+		return user.ErrTwoFactorEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP enables two factor authentication for a user, replacing the
+// recovery codes with the given ones.
+func (s UserStorage) EnableTOTP(id uint, codes []string) error {
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		err := s.db.Delete(&user.RecoveryCode{}, "user_id = ?", id).Error
+		if err != nil {
+			return ErrNotDelete
+		}
+
+		for _, c := range codes {
+			rc := user.RecoveryCode{UserID: id, CodeHash: user.HashRecoveryCode(c)}
+			err = s.db.Create(&rc).Error
+			if err != nil {
+				return ErrNotInsert
+			}
+		}
+
+		err = s.db.Model(&user.User{}).Where("id = ?", id).
+			Update("totp_enabled", true).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		return nil
+	})
+}
+
+// DisableTOTP disables two factor authentication for a user and removes its
+// secret and recovery codes.
+func (s UserStorage) DisableTOTP(id uint) error {
+	return inTx(s.tx, func(tx *Tx) error {
+		s := s.WithTx(tx)
+		s.setContext()
+
+		err := s.db.Model(&user.User{}).Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"totp_secret":  "",
+				"totp_enabled": false,
+			}).Error
+		if err != nil {
+			return ErrNotUpdate
+		}
+
+		err = s.db.Delete(&user.RecoveryCode{}, "user_id = ?", id).Error
+		if err != nil {
+			return ErrNotDelete
+		}
+
+		return nil
+	})
+}
+
+// UseRecoveryCode marks as used a recovery code of a user, that can't be
+// used again.
+func (s UserStorage) UseRecoveryCode(id uint, code string) error {
+	s.setContext()
+
+	result := s.db.Model(&user.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", id, user.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return ErrNotUpdate
+	}
+
+	if result.RowsAffected == 0 {
+		return user.ErrInvalidCode
+	}
+
+	return nil
+}
+
+// TOTPStep returns the time step of the last code accepted of a user, 0 if
+// there is none.
+func (s UserStorage) TOTPStep(id uint) (int64, error) {
+	s.setContext()
+
+	ts := user.TOTPStep{}
+	err := s.db.Where("user_id = ?", id).Find(&ts).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, ErrNotFound
+	}
+
+	return ts.Step, nil
+}
+
+// UseTOTPStep records the time step of a code accepted of a user. Only one
+// of the codes of a step, or of the steps before, is recorded, the rest
+// fail with user.ErrCodeUsed.
+func (s UserStorage) UseTOTPStep(id uint, step int64) error {
+	s.setContext()
+
+	result := s.db.Model(&user.TOTPStep{}).
+		Where("user_id = ? AND step < ?", id, step).
+		Update("step", step)
+	if result.Error != nil {
+		return ErrNotUpdate
+	}
+
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	err := s.db.Create(&user.TOTPStep{UserID: id, Step: step}).Error
+	if isUniqueViolation(err) {
+		return user.ErrCodeUsed
+	}
+
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// UseChallenge records a login challenge as used, user.ErrChallengeUsed if
+// it already was. The challenges expired are forgotten.
+func (s UserStorage) UseChallenge(c user.UsedChallenge) error {
+	s.setContext()
+
+	err := s.db.Delete(&user.UsedChallenge{}, "expires_at < ?", time.Now()).Error
+	if err != nil {
+		return ErrNotDelete
+	}
+
+	err = s.db.Create(&c).Error
+	if isUniqueViolation(err) {
+		return user.ErrChallengeUsed
+	}
+
+	if err != nil {
+		return ErrNotInsert
+	}
+
+	return nil
+}
+
+// TwoFactorRequired checks whether the users of a role must use two factor
+// authentication.
+func (s UserStorage) TwoFactorRequired(role string) (bool, error) {
+	s.setContext()
+
+	p := user.TwoFactorPolicy{}
+	err := s.db.Where("role = ?", role).Find(&p).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, ErrNotFound
+	}
+
+	return p.Required, nil
+}
+
+// SetTwoFactorPolicy stores the two factor policy of a role.
+func (s UserStorage) SetTwoFactorPolicy(p user.TwoFactorPolicy) error {
+	s.setContext()
+
+	err := s.db.Save(&p).Error
+	if err != nil {
+		return ErrNotUpdate
+	}
+
+	return nil
+}
+
+// GetTwoFactorPolicies returns the two factor policies of the roles.
+func (s UserStorage) GetTwoFactorPolicies() (user.TwoFactorPolicies, error) {
+	s.setContext()
+
+	policies := user.TwoFactorPolicies{}
+	err := s.db.Order("role").Find(&policies).Error
+	if err != nil {
+		return user.TwoFactorPolicies{}, ErrNotFound
+	}
+
+	return policies, nil
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"os"
+
 	"github.com/jinzhu/gorm"
 
 	"gitlab.com/menuxd/api-rest/pkg/waiter"
@@ -10,14 +12,21 @@ import (
 type WaiterStorage struct {
 	session *Session
 	db      *gorm.DB
+	tx      *Tx
 }
 
 // setContext initialize the context to WaiterStorage.
 func (s *WaiterStorage) setContext() {
-	s.session = NewSession()
+	s.session = sessionOf(s.tx)
 	s.db = s.session.Client
 }
 
+// WithTx returns the WaiterStorage joined to a unit of work.
+func (s WaiterStorage) WithTx(tx *Tx) WaiterStorage {
+	s.tx = tx
+	return s
+}
+
 // Create create a new waiter.
 func (s WaiterStorage) Create(w *waiter.Waiter) error {
 	s.setContext()
@@ -26,32 +35,51 @@ func (s WaiterStorage) Create(w *waiter.Waiter) error {
 		return ErrRequiredField
 	}
 
-	if !w.VerifyPIN() {
-		return ErrInvalidPIN
+	err := s.setPIN(w)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Create(w).Error
+	if isUniqueViolation(err) {
+		return waiter.ErrPINTaken
 	}
 
-	err := s.db.Create(w).Error
 	if err != nil {
 		return ErrNotInsert
 	}
 	return nil
 }
 
-// Update update a waiter by ID.
+// Update update a waiter by ID, and its PIN if a new one is given.
 func (s WaiterStorage) Update(id uint, w *waiter.Waiter) error {
+	stored, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+
 	s.setContext()
 
-	if !w.VerifyPIN() {
-		return ErrInvalidPIN
-	}
+	w.ID = id
+	w.ClientID = stored.ClientID
 
 	updates := map[string]interface{}{
 		"name": w.Name,
-		"pin":  w.PIN,
 	}
 
-	w.ID = id
-	err := s.db.Model(w).Updates(updates).Error
+	if w.PIN != "" {
+		err = s.setPIN(w)
+		if err != nil {
+			return err
+		}
+		updates["pin_hash"] = w.PINHash
+	}
+
+	err = s.db.Model(w).Updates(updates).Error
+	if isUniqueViolation(err) {
+		return waiter.ErrPINTaken
+	}
+
 	if err != nil {
 		return ErrNotUpdate
 	}
@@ -96,3 +124,60 @@ func (s WaiterStorage) GetByID(id uint) (waiter.Waiter, error) {
 
 	return w, nil
 }
+
+// GetByPIN returns the waiter of a client with the PIN.
+func (s WaiterStorage) GetByPIN(clientID uint, pin string) (waiter.Waiter, error) {
+	s.setContext()
+
+	w := waiter.Waiter{}
+	err := s.db.First(
+		&w,
+		"client_id = ? AND pin_hash = ?",
+		clientID,
+		waiter.HashPIN(PINSecret(), clientID, pin),
+	).Error
+	if err != nil {
+		return waiter.Waiter{}, ErrNotFound
+	}
+
+	return w, nil
+}
+
+// setPIN validates the PIN of a waiter and hashes it, checking that no other
+// waiter of the client has it. The unique index on the PIN of the waiters
+// catches the ones stored at once.
+func (s WaiterStorage) setPIN(w *waiter.Waiter) error {
+	if !w.VerifyPIN() {
+		return ErrInvalidPIN
+	}
+
+	hash := waiter.HashPIN(PINSecret(), w.ClientID, w.PIN)
+
+	count := 0
+	err := s.db.Model(&waiter.Waiter{}).
+		Where("client_id = ? AND pin_hash = ? AND id <> ?", w.ClientID, hash, w.ID).
+		Count(&count).Error
+	if err != nil {
+		return ErrNotFound
+	}
+
+	if count > 0 {
+		return waiter.ErrPINTaken
+	}
+
+	w.PINHash = hash
+	w.PIN = ""
+
+	return nil
+}
+
+// PINSecret returns the key of the PIN hashes, the signing string if there
+// is no key of its own.
+func PINSecret() []byte {
+	secret := os.Getenv("XD_PIN_SECRET")
+	if secret == "" {
+		secret = os.Getenv("XD_SIGNING_STRING")
+	}
+
+	return []byte(secret)
+}
@@ -0,0 +1,200 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/model"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// Errors.
+var (
+	ErrInvalidKey   = errors.New("the API key is invalid or revoked")
+	ErrInvalidScope = errors.New("the scope of the API key is unknown")
+	ErrNoScopes     = errors.New("the API key needs at least one scope")
+)
+
+// Key settings.
+const (
+	// Prefix starts every key, so they are easy to tell apart from tokens
+	// and to find when leaked.
+	Prefix = "mxd_"
+	// keyBytes are the random bytes of a key.
+	keyBytes = 32
+	// hintLength are the characters of the key kept to recognize it.
+	hintLength = 8
+	// TouchEvery is how often the last use of a key is recorded.
+	TouchEvery = time.Minute
+)
+
+// Scopes are the permissions each scope of a key gives.
+var Scopes = map[string]auth.Permission{
+	"menu:read":    auth.ReadMenu,
+	"orders:read":  auth.ReadOrders,
+	"orders:write": auth.TakeOrders,
+}
+
+// Storage handle the operations with the API keys.
+type Storage interface {
+	Create(k *Key) error
+	Revoke(id uint) error
+	GetAll(clientID uint) (Keys, error)
+	GetByKey(key string) (Key, error)
+	// Touch records the use of a key, if it wasn't in the last TouchEvery.
+	Touch(id uint, now time.Time) error
+}
+
+// Key lets an integration reach the data of a client, limited to its
+// scopes. Only its hash is stored, the key is shown once when created.
+type Key struct {
+	model.Model
+	Name       string     `json:"name"`
+	ClientID   uint       `gorm:"index" json:"client_id"`
+	Key        string     `gorm:"-" json:"key,omitempty"`
+	KeyHash    string     `gorm:"unique_index" json:"-"`
+	Hint       string     `json:"hint"`
+	Scopes     ScopeList  `gorm:"type:text" json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName keeps the keys apart from the signing keys of the tokens.
+func (Key) TableName() string {
+	return "api_keys"
+}
+
+// New returns a key of the client with the scopes and a new random key.
+func New(clientID uint, name string, scopes ScopeList) (*Key, error) {
+	err := scopes.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, keyBytes)
+	_, err = rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+
+	key := Prefix + base64.RawURLEncoding.EncodeToString(b)
+	return &Key{
+		Name:     name,
+		ClientID: clientID,
+		Key:      key,
+		KeyHash:  HashKey(key),
+		Hint:     key[:len(Prefix)+hintLength],
+		Scopes:   scopes,
+	}, nil
+}
+
+// IsActive checks that the key was not revoked.
+func (k Key) IsActive() bool {
+	return k.RevokedAt == nil
+}
+
+// Claims returns the claims the key stands for: the integration role of
+// its client, limited to the permissions of its scopes.
+func (k Key) Claims() jwtauth.Claims {
+	return jwtauth.Claims{
+		"role":      user.Integration,
+		"client_id": strconv.Itoa(int(k.ClientID)),
+		"key_id":    strconv.Itoa(int(k.ID)),
+		"scopes":    k.Scopes.Permissions(),
+	}
+}
+
+// HashKey returns the hash of a key.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Keys alias for a slice of Keys.
+type Keys []Key
+
+// ScopeList are the scopes of a key, stored separated by spaces.
+type ScopeList []string
+
+// Validate checks that there are scopes and all of them are known.
+func (l ScopeList) Validate() error {
+	if len(l) == 0 {
+		return ErrNoScopes
+	}
+
+	for _, s := range l {
+		if _, ok := Scopes[s]; !ok {
+			return ErrInvalidScope
+		}
+	}
+
+	return nil
+}
+
+// Permissions returns the permissions of the scopes.
+func (l ScopeList) Permissions() []auth.Permission {
+	permissions := []auth.Permission{}
+	for _, s := range l {
+		if p, ok := Scopes[s]; ok {
+			permissions = append(permissions, p)
+		}
+	}
+
+	return permissions
+}
+
+// Value writes the scopes to the database separated by spaces.
+func (l ScopeList) Value() (driver.Value, error) {
+	return strings.Join(l, " "), nil
+}
+
+// Scan reads the scopes separated by spaces.
+func (l *ScopeList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*l = ScopeList{}
+	case []byte:
+		*l = strings.Fields(string(v))
+	case string:
+		*l = strings.Fields(v)
+	default:
+		return ErrInvalidScope
+	}
+
+	return nil
+}
+
+// Resolver resolves the claims of the active keys of a storage, recording
+// their use.
+type Resolver struct {
+	Storage Storage
+}
+
+// Claims returns the claims of a key, ErrInvalidKey if it is unknown or
+// revoked.
+func (r Resolver) Claims(key string) (jwtauth.Claims, error) {
+	if !strings.HasPrefix(key, Prefix) {
+		return nil, ErrInvalidKey
+	}
+
+	k, err := r.Storage.GetByKey(key)
+	if err != nil || !k.IsActive() {
+		return nil, ErrInvalidKey
+	}
+
+	err = r.Storage.Touch(k.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return k.Claims(), nil
+}
@@ -0,0 +1,82 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/middleware/auth"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+func TestNew(t *testing.T) {
+	k, err := New(3, "POS", ScopeList{"menu:read", "orders:write"})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(k.Key, Prefix))
+	assert.True(t, strings.HasPrefix(k.Key, k.Hint))
+	assert.Equal(t, HashKey(k.Key), k.KeyHash)
+	assert.True(t, k.IsActive())
+
+	claims := k.Claims()
+	assert.Equal(t, user.Integration, claims["role"])
+	assert.Equal(t, "3", claims["client_id"])
+	assert.Equal(t, []auth.Permission{auth.ReadMenu, auth.TakeOrders}, claims["scopes"])
+	assert.True(t, auth.Scoped(claims, auth.TakeOrders))
+	assert.False(t, auth.Scoped(claims, auth.ReadOrders))
+
+	_, err = New(3, "POS", ScopeList{})
+	assert.Equal(t, ErrNoScopes, err)
+
+	_, err = New(3, "POS", ScopeList{"menu:manage"})
+	assert.Equal(t, ErrInvalidScope, err)
+}
+
+func TestScopeList(t *testing.T) {
+	l := ScopeList{"menu:read", "orders:read"}
+	v, err := l.Value()
+	assert.Nil(t, err)
+	assert.Equal(t, "menu:read orders:read", v)
+
+	scanned := ScopeList{}
+	assert.Nil(t, scanned.Scan([]byte("menu:read orders:read")))
+	assert.Equal(t, l, scanned)
+}
+
+// fakeStorage has the active key good and the revoked key old.
+type fakeStorage struct {
+	Storage
+	touched int
+}
+
+func (f *fakeStorage) GetByKey(key string) (Key, error) {
+	switch key {
+	case Prefix + "good":
+		return Key{ClientID: 1, Scopes: ScopeList{"menu:read"}}, nil
+	case Prefix + "old":
+		now := time.Now()
+		return Key{ClientID: 1, RevokedAt: &now}, nil
+	}
+
+	return Key{}, ErrInvalidKey
+}
+
+func (f *fakeStorage) Touch(id uint, now time.Time) error {
+	f.touched++
+	return nil
+}
+
+func TestResolver(t *testing.T) {
+	s := &fakeStorage{}
+	r := Resolver{Storage: s}
+
+	claims, err := r.Claims(Prefix + "good")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", claims["client_id"])
+	assert.Equal(t, 1, s.touched)
+
+	for _, key := range []string{Prefix + "old", Prefix + "unknown", "good"} {
+		_, err = r.Claims(key)
+		assert.Equal(t, ErrInvalidKey, err, key)
+	}
+}
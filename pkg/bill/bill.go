@@ -1,26 +1,118 @@
 package bill
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gitlab.com/menuxd/api-rest/pkg/client"
 	"gitlab.com/menuxd/api-rest/pkg/model"
+	"gitlab.com/menuxd/api-rest/pkg/money"
 	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/tax"
+)
+
+// Errors.
+var (
+	ErrNothingToBill = errors.New("nothing to bill")
+	ErrAlreadyBilled = errors.New("order already billed")
+	ErrMixedOrders   = errors.New("orders from different tables")
+	ErrInvalidSplit  = errors.New("invalid split")
+	ErrAlreadyPaid   = errors.New("bill already paid")
+	ErrHasPayments   = errors.New("bill has payments")
+	ErrInvalidTax    = errors.New("invalid tax category")
+)
+
+// Split modes.
+const (
+	ByItems = "items"
+	Even    = "even"
+	Custom  = "custom"
 )
 
 // Storage handle the CRUD operations with Bills.
 type Storage interface {
 	Create(bill *Bill) error
-	Update(id uint, bill *Bill) error
 	Delete(id uint) error
 	GetAll(clientID uint) (Bills, error)
 	GetByID(id uint) (Bill, error)
+	Split(id uint, parts []Part) error
 }
 
 type Bill struct {
 	model.Model
-	Value    uint          `bson:"value" json:"value"`
-	Paid     bool          `bson:"paid" json:"paid"`
-	Orders   []order.Order `bson:"orders" json:"orders"`
-	TableID  uint          `bson:"table_id" json:"table_id"`
-	ClientID uint          `bson:"client_id" json:"client_id"`
+	Subtotal      money.Amount     `gorm:"type:numeric(14,2)" bson:"subtotal" json:"subtotal"`
+	ServiceCharge money.Amount     `gorm:"type:numeric(14,2)" bson:"service_charge" json:"service_charge"`
+	Net           money.Amount     `gorm:"type:numeric(14,2)" bson:"net" json:"net"`
+	Tax           money.Amount     `gorm:"type:numeric(14,2)" bson:"tax" json:"tax"`
+	Gross         money.Amount     `gorm:"type:numeric(14,2)" bson:"gross" json:"gross"`
+	Rounding      money.Amount     `gorm:"type:numeric(14,2)" bson:"rounding" json:"rounding"`
+	Value         money.Amount     `gorm:"type:numeric(14,2)" bson:"value" json:"value"`
+	Taxes         []TaxLine        `bson:"taxes" json:"taxes"`
+	Paid          bool             `bson:"paid" json:"paid"`
+	Orders        []order.Order    `gorm:"save_associations:false" bson:"orders" json:"orders"`
+	TableID       uint             `bson:"table_id" json:"table_id"`
+	ClientID      uint             `bson:"client_id" json:"client_id"`
+	Lines         []Line           `bson:"lines" json:"lines"`
+	Parts         []Part           `bson:"parts" json:"parts,omitempty"`
+	Payments      payment.Payments `gorm:"save_associations:false" bson:"payments" json:"payments,omitempty"`
+}
+
+// Line is a snapshot of an ordered item, with the prices it had when the
+// bill was generated.
+type Line struct {
+	model.Model
+	BillID     uint         `bson:"bill_id" json:"bill_id"`
+	OrderID    uint         `bson:"order_id" json:"order_id"`
+	ItemID     uint         `bson:"item_id" json:"item_id"`
+	DishID     uint         `bson:"dish_id" json:"dish_id"`
+	Name       string       `bson:"name" json:"name"`
+	Half       bool         `bson:"half" json:"half"`
+	Mount      uint         `bson:"mount" json:"mount"`
+	UnitPrice  money.Amount `gorm:"type:numeric(14,2)" bson:"unit_price" json:"unit_price"`
+	Extras     string       `bson:"extras" json:"extras,omitempty"`
+	ExtrasUnit money.Amount `gorm:"type:numeric(14,2)" bson:"extras_unit" json:"extras_unit"`
+	Total      money.Amount `gorm:"type:numeric(14,2)" bson:"total" json:"total"`
+	TaxRate    string       `bson:"tax_rate" json:"tax_rate"`
+	PartID     *uint        `bson:"part_id" json:"part_id,omitempty"`
+}
+
+// TaxLine is the breakdown of a tax category in a bill.
+type TaxLine struct {
+	model.Model
+	BillID uint         `bson:"bill_id" json:"bill_id"`
+	Rate   string       `bson:"rate" json:"rate"`
+	Net    money.Amount `gorm:"type:numeric(14,2)" bson:"net" json:"net"`
+	Tax    money.Amount `gorm:"type:numeric(14,2)" bson:"tax" json:"tax"`
+	Gross  money.Amount `gorm:"type:numeric(14,2)" bson:"gross" json:"gross"`
+}
+
+// Part is a share of a bill paid on its own.
+type Part struct {
+	model.Model
+	BillID uint         `bson:"bill_id" json:"bill_id"`
+	Label  string       `bson:"label" json:"label"`
+	Value  money.Amount `gorm:"type:numeric(14,2)" bson:"value" json:"value"`
+	Paid   bool         `bson:"paid" json:"paid"`
+	Lines  []Line       `gorm:"save_associations:false" bson:"lines" json:"lines,omitempty"`
+}
+
+// SplitRequest is the way to split a bill: by groups of ordered items, evenly
+// among payers or by custom amounts.
+type SplitRequest struct {
+	Mode    string         `json:"mode"`
+	Items   [][]uint       `json:"items,omitempty"`
+	Payers  uint           `json:"payers,omitempty"`
+	Amounts []money.Amount `json:"amounts,omitempty"`
+}
+
+// Request is the data to generate a bill: every unbilled order of a table
+// or the orders given.
+type Request struct {
+	TableID  uint   `json:"table_id"`
+	OrderIDs []uint `json:"order_ids"`
 }
 
 // Bills alias for a slice of Bills.
@@ -30,3 +122,375 @@ type Bills []Bill
 func New() *Bill {
 	return &Bill{}
 }
+
+// IsBillable checks that the item has to be charged.
+func IsBillable(i order.Item) bool {
+	if !i.Active || i.Dish == nil {
+		return false
+	}
+
+	return i.Status != order.Cancelled && i.Status != order.Rejected
+}
+
+// NewLine returns the line of an ordered item. An item without mount counts
+// as one.
+func NewLine(orderID uint, i order.Item) Line {
+	l := Line{
+		OrderID:   orderID,
+		ItemID:    i.ID,
+		DishID:    i.Dish.ID,
+		Name:      i.Dish.Name,
+		Mount:     i.Mount,
+		UnitPrice: i.Dish.Price,
+		TaxRate:   tax.Normalize(i.Dish.TaxRate),
+	}
+
+	if l.Mount == 0 {
+		l.Mount = 1
+	}
+
+	if i.Half && i.Dish.IsHalf && i.Dish.HalfPrice != nil {
+		l.Half = true
+		l.UnitPrice = *i.Dish.HalfPrice
+	}
+
+	extras := []string{}
+	for _, is := range i.SelectedIngredients {
+		if !is.Active || is.Ingredient == nil || is.Ingredient.Price <= 0 {
+			continue
+		}
+
+		extras = append(extras, is.Ingredient.Name)
+		l.ExtrasUnit += is.Ingredient.Price
+	}
+	l.Extras = strings.Join(extras, ", ")
+
+	l.Total = (l.UnitPrice + l.ExtrasUnit).Mul(l.Mount)
+
+	return l
+}
+
+// Compute builds a bill from the orders of a table with the tax, service
+// charge and rounding rules of the client. Canceled orders and inactive items
+// are left out.
+func Compute(orders []order.Order, c client.Client) (*Bill, error) {
+	b := New()
+
+	for _, o := range orders {
+		if b.TableID == 0 {
+			b.TableID = o.TableID
+			b.ClientID = o.ClientID
+		}
+
+		if o.TableID != b.TableID || o.ClientID != b.ClientID {
+			return nil, ErrMixedOrders
+		}
+
+		if o.BillID != nil {
+			return nil, ErrAlreadyBilled
+		}
+
+		if o.Canceled {
+			continue
+		}
+
+		billed := false
+		for _, i := range o.Items {
+			if !IsBillable(i) {
+				continue
+			}
+
+			b.Lines = append(b.Lines, NewLine(o.ID, i))
+			billed = true
+		}
+
+		if billed {
+			o.Items = nil
+			o.Table = nil
+			b.Orders = append(b.Orders, o)
+		}
+	}
+
+	if len(b.Lines) == 0 {
+		return nil, ErrNothingToBill
+	}
+
+	err := b.total(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// total computes the totals of the bill from its lines. The service charge
+// is added to each tax category in proportion, prices already include the
+// tax and the rounding adjustment is left out of the tax breakdown. A line
+// of an unknown tax category fails with ErrInvalidTax, it would be left out
+// of the breakdown and of the value.
+func (b *Bill) total(c client.Client) error {
+	gross := make(map[string]money.Amount)
+	for _, l := range b.Lines {
+		if !tax.IsValid(l.TaxRate) {
+			return ErrInvalidTax
+		}
+
+		b.Subtotal += l.Total
+		gross[l.TaxRate] += l.Total
+	}
+
+	b.Taxes = []TaxLine{}
+	for _, rate := range tax.Rates {
+		subtotal, ok := gross[rate]
+		if !ok {
+			continue
+		}
+
+		service := subtotal.Percent(c.ServiceCharge)
+		b.ServiceCharge += service
+
+		t := TaxLine{Rate: rate, Gross: subtotal + service}
+		t.Net, t.Tax = tax.Split(t.Gross, rate)
+		b.Net += t.Net
+		b.Tax += t.Tax
+		b.Gross += t.Gross
+		b.Taxes = append(b.Taxes, t)
+	}
+
+	b.Value = b.Gross.Round(c.RoundingUnit, c.RoundingMode)
+	b.Rounding = b.Value - b.Gross
+
+	return nil
+}
+
+// Reconcile derives whether the bill, and every part of it, is paid from the
+// recorded payments.
+func (b *Bill) Reconcile() {
+	for i := range b.Parts {
+		b.Parts[i].Paid = b.Balance(&b.Parts[i].ID) >= b.Parts[i].Value
+	}
+
+	b.Paid = b.Balance(nil) >= b.Value
+}
+
+// Balance returns the amount paid of the bill or, when given, of a part.
+func (b Bill) Balance(partID *uint) money.Amount {
+	if partID == nil {
+		return b.Payments.Balance()
+	}
+
+	var balance money.Amount
+	for _, p := range b.Payments {
+		if p.PartID != nil && *p.PartID == *partID {
+			balance += p.Net()
+		}
+	}
+
+	return balance
+}
+
+// Part returns the part of the bill by ID.
+func (b Bill) Part(id uint) (Part, bool) {
+	for _, p := range b.Parts {
+		if p.ID == id {
+			return p, true
+		}
+	}
+
+	return Part{}, false
+}
+
+// Apply validates a payment against the bill and adds it to the payments.
+// Charges can't go over what is owed and refunds can't go over what was paid.
+func (b *Bill) Apply(p *payment.Payment) error {
+	p.BillID = b.ID
+	p.ClientID = b.ClientID
+
+	if p.IsRefund() {
+		if p.RefundOf == nil {
+			return payment.ErrPaymentNotFound
+		}
+
+		var original *payment.Payment
+		for i := range b.Payments {
+			if b.Payments[i].ID == *p.RefundOf && !b.Payments[i].IsRefund() {
+				original = &b.Payments[i]
+			}
+		}
+
+		if original == nil {
+			return payment.ErrPaymentNotFound
+		}
+
+		p.PartID = original.PartID
+		if p.Method == "" {
+			p.Method = original.Method
+		}
+
+		if p.Amount > original.Amount-b.Payments.Refunded(original.ID) {
+			return payment.ErrRefundExceeded
+		}
+	} else {
+		p.RefundOf = nil
+		value := b.Value
+		if len(b.Parts) > 0 {
+			if p.PartID == nil {
+				return payment.ErrPartRequired
+			}
+
+			part, ok := b.Part(*p.PartID)
+			if !ok {
+				return payment.ErrPartNotFound
+			}
+
+			value = part.Value
+		} else {
+			p.PartID = nil
+		}
+
+		if p.Amount > value-b.Balance(p.PartID) {
+			return payment.ErrOverpayment
+		}
+	}
+
+	err := p.Prepare()
+	if err != nil {
+		return err
+	}
+
+	b.Payments = append(b.Payments, *p)
+	b.Reconcile()
+
+	return nil
+}
+
+// Split returns the parts of the bill for the request given.
+func (b Bill) Split(req SplitRequest) ([]Part, error) {
+	if b.Paid {
+		return nil, ErrAlreadyPaid
+	}
+
+	if len(b.Payments) > 0 {
+		return nil, ErrHasPayments
+	}
+
+	var parts []Part
+	var err error
+	switch req.Mode {
+	case ByItems:
+		parts, err = b.splitByItems(req.Items)
+	case Even:
+		parts, err = b.splitEven(req.Payers)
+	case Custom:
+		parts, err = b.splitCustom(req.Amounts)
+	default:
+		err = ErrInvalidSplit
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range parts {
+		parts[i].BillID = b.ID
+		parts[i].Label = fmt.Sprintf("%d/%d", i+1, len(parts))
+	}
+
+	return parts, nil
+}
+
+// splitByItems returns a part per group of items, each one with its share
+// of the service charge and the rounding. Every line of the bill must be in
+// exactly one group.
+func (b Bill) splitByItems(groups [][]uint) ([]Part, error) {
+	lines := make(map[uint]Line)
+	var total money.Amount
+	for _, l := range b.Lines {
+		lines[l.ItemID] = l
+		total += l.Total
+	}
+
+	if len(groups) < 2 || total <= 0 {
+		return nil, ErrInvalidSplit
+	}
+
+	parts := []Part{}
+	var assigned money.Amount
+	for _, g := range groups {
+		if len(g) == 0 {
+			return nil, ErrInvalidSplit
+		}
+
+		p := Part{}
+		var subtotal money.Amount
+		for _, itemID := range g {
+			l, ok := lines[itemID]
+			if !ok {
+				return nil, ErrInvalidSplit
+			}
+			delete(lines, itemID)
+
+			subtotal += l.Total
+			p.Lines = append(p.Lines, l)
+		}
+
+		p.Value = money.Amount(math.Round(float64(b.Value) * float64(subtotal) / float64(total)))
+		assigned += p.Value
+		parts = append(parts, p)
+	}
+
+	if len(lines) > 0 {
+		return nil, ErrInvalidSplit
+	}
+
+	// Rounding differences go to the last part.
+	parts[len(parts)-1].Value += b.Value - assigned
+
+	return parts, nil
+}
+
+// splitEven returns a part per payer, spreading the remainder over the first
+// ones.
+func (b Bill) splitEven(payers uint) ([]Part, error) {
+	if payers < 2 || money.Amount(payers) > b.Value {
+		return nil, ErrInvalidSplit
+	}
+
+	parts := []Part{}
+	share := b.Value / money.Amount(payers)
+	remainder := b.Value % money.Amount(payers)
+	for i := money.Amount(0); i < money.Amount(payers); i++ {
+		p := Part{Value: share}
+		if i < remainder {
+			p.Value++
+		}
+
+		parts = append(parts, p)
+	}
+
+	return parts, nil
+}
+
+// splitCustom returns a part per amount. The amounts must add up to the
+// value of the bill.
+func (b Bill) splitCustom(amounts []money.Amount) ([]Part, error) {
+	if len(amounts) < 2 {
+		return nil, ErrInvalidSplit
+	}
+
+	parts := []Part{}
+	var total money.Amount
+	for _, a := range amounts {
+		if a <= 0 {
+			return nil, ErrInvalidSplit
+		}
+
+		total += a
+		parts = append(parts, Part{Value: a})
+	}
+
+	if total != b.Value {
+		return nil, ErrInvalidSplit
+	}
+
+	return parts, nil
+}
@@ -1 +1,279 @@
 package bill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/client"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+	"gitlab.com/menuxd/api-rest/pkg/tax"
+)
+
+func newDish(id uint, price money.Amount, halfPrice *money.Amount) *dish.Dish {
+	d := &dish.Dish{}
+	d.ID = id
+	d.Name = "Dish"
+	d.Price = price
+	d.IsHalf = halfPrice != nil
+	d.HalfPrice = halfPrice
+
+	return d
+}
+
+func newOrder(id uint, items ...order.Item) order.Order {
+	o := order.Order{ClientID: 1, TableID: 1, Items: items}
+	o.ID = id
+
+	return o
+}
+
+func TestNewLine(t *testing.T) {
+	extra := &dish.Ingredient{Name: "Cheese", Price: 5000}
+	free := &dish.Ingredient{Name: "Onion"}
+	i := order.Item{
+		Mount: 2,
+		Dish:  newDish(1, 30000, nil),
+		SelectedIngredients: []order.IngredientSelected{
+			{Active: true, Ingredient: extra},
+			{Active: true, Ingredient: free},
+			{Active: false, Ingredient: extra},
+		},
+	}
+
+	l := NewLine(1, i)
+
+	assert := assert.New(t)
+	assert.Equal(uint(2), l.Mount)
+	assert.Equal(money.Amount(30000), l.UnitPrice)
+	assert.Equal(money.Amount(5000), l.ExtrasUnit)
+	assert.Equal("Cheese", l.Extras)
+	assert.Equal(money.Amount(70000), l.Total)
+	assert.Equal(tax.VAT10, l.TaxRate)
+}
+
+func TestNewLineHalf(t *testing.T) {
+	half := money.Amount(18000)
+	i := order.Item{Half: true, Dish: newDish(1, 30000, &half)}
+
+	l := NewLine(1, i)
+
+	assert.True(t, l.Half)
+	assert.Equal(t, uint(1), l.Mount)
+	assert.Equal(t, money.Amount(18000), l.Total)
+}
+
+func TestCompute(t *testing.T) {
+	cancelled := order.Item{Active: true, Mount: 1, Dish: newDish(2, 10000, nil)}
+	cancelled.Status = order.Cancelled
+	canceledOrder := newOrder(2, order.Item{Active: true, Mount: 1, Dish: newDish(1, 10000, nil)})
+	canceledOrder.Canceled = true
+
+	orders := []order.Order{
+		newOrder(1,
+			order.Item{Active: true, Mount: 1, Dish: newDish(1, 25000, nil)},
+			order.Item{Active: false, Mount: 1, Dish: newDish(1, 25000, nil)},
+			cancelled,
+		),
+		canceledOrder,
+		newOrder(3, order.Item{Active: true, Mount: 3, Dish: newDish(3, 5000, nil)}),
+	}
+
+	b, err := Compute(orders, client.Client{})
+
+	assert := assert.New(t)
+	assert.Nil(err)
+	assert.Equal(money.Amount(40000), b.Value)
+	assert.Len(b.Lines, 2)
+	assert.Len(b.Orders, 2)
+	assert.Equal(uint(1), b.TableID)
+	assert.Equal(uint(1), b.ClientID)
+}
+
+func TestComputeRules(t *testing.T) {
+	reduced := newDish(2, 10500, nil)
+	reduced.TaxRate = tax.VAT5
+	orders := []order.Order{
+		newOrder(1,
+			order.Item{Active: true, Mount: 2, Dish: newDish(1, 11000, nil)},
+			order.Item{Active: true, Mount: 1, Dish: reduced},
+		),
+	}
+	c := client.Client{ServiceCharge: 10, RoundingUnit: 500, RoundingMode: money.Up}
+
+	b, err := Compute(orders, c)
+
+	assert := assert.New(t)
+	assert.Nil(err)
+	assert.Equal(money.Amount(32500), b.Subtotal)
+	assert.Equal(money.Amount(3250), b.ServiceCharge)
+	assert.Equal(money.Amount(35750), b.Gross)
+	assert.Len(b.Taxes, 2)
+	assert.Equal(tax.VAT10, b.Taxes[0].Rate)
+	assert.Equal(money.Amount(2200), b.Taxes[0].Tax)
+	assert.Equal(tax.VAT5, b.Taxes[1].Rate)
+	assert.Equal(money.Amount(550), b.Taxes[1].Tax)
+	assert.Equal(b.Gross, b.Net+b.Tax)
+	assert.Equal(money.Amount(36000), b.Value)
+	assert.Equal(money.Amount(250), b.Rounding)
+}
+
+func TestComputeFail(t *testing.T) {
+	var billID uint = 1
+	billed := newOrder(1, order.Item{Active: true, Dish: newDish(1, 100, nil)})
+	billed.BillID = &billID
+	other := newOrder(2, order.Item{Active: true, Dish: newDish(1, 100, nil)})
+	other.TableID = 2
+
+	_, err := Compute([]order.Order{}, client.Client{})
+	assert.Equal(t, ErrNothingToBill, err)
+
+	_, err = Compute([]order.Order{billed}, client.Client{})
+	assert.Equal(t, ErrAlreadyBilled, err)
+
+	_, err = Compute([]order.Order{newOrder(1), other}, client.Client{})
+	assert.Equal(t, ErrMixedOrders, err)
+
+	vat21 := newDish(1, 100, nil)
+	vat21.TaxRate = "vat21"
+	_, err = Compute([]order.Order{newOrder(1, order.Item{Active: true, Dish: vat21})}, client.Client{})
+	assert.Equal(t, ErrInvalidTax, err)
+}
+
+func newBill(value money.Amount, lines ...Line) Bill {
+	return Bill{Value: value, Lines: lines}
+}
+
+func TestSplitEven(t *testing.T) {
+	b := newBill(100)
+
+	parts, err := b.Split(SplitRequest{Mode: Even, Payers: 3})
+
+	assert := assert.New(t)
+	assert.Nil(err)
+	assert.Len(parts, 3)
+	assert.Equal(money.Amount(34), parts[0].Value)
+	assert.Equal(money.Amount(33), parts[1].Value)
+	assert.Equal(money.Amount(33), parts[2].Value)
+	assert.Equal("1/3", parts[0].Label)
+}
+
+func TestSplitCustom(t *testing.T) {
+	b := newBill(100)
+
+	parts, err := b.Split(SplitRequest{Mode: Custom, Amounts: []money.Amount{60, 40}})
+
+	assert.Nil(t, err)
+	assert.Len(t, parts, 2)
+	assert.Equal(t, money.Amount(40), parts[1].Value)
+}
+
+func TestSplitByItems(t *testing.T) {
+	b := newBill(110,
+		Line{ItemID: 1, Total: 30},
+		Line{ItemID: 2, Total: 40},
+		Line{ItemID: 3, Total: 30},
+	)
+
+	parts, err := b.Split(SplitRequest{Mode: ByItems, Items: [][]uint{{1, 3}, {2}}})
+
+	assert := assert.New(t)
+	assert.Nil(err)
+	assert.Len(parts, 2)
+	assert.Len(parts[0].Lines, 2)
+	assert.Equal(money.Amount(66), parts[0].Value)
+	assert.Equal(money.Amount(44), parts[1].Value)
+}
+
+func TestSplitFail(t *testing.T) {
+	b := newBill(100, Line{ItemID: 1, Total: 50}, Line{ItemID: 2, Total: 50})
+	requests := []SplitRequest{
+		{Mode: "random"},
+		{Mode: Even, Payers: 1},
+		{Mode: Custom, Amounts: []money.Amount{50, 40}},
+		{Mode: Custom, Amounts: []money.Amount{100, 0}},
+		{Mode: ByItems, Items: [][]uint{{1}}},
+		{Mode: ByItems, Items: [][]uint{{1}, {3}}},
+		{Mode: ByItems, Items: [][]uint{{1, 2}, {2}}},
+	}
+
+	for _, req := range requests {
+		_, err := b.Split(req)
+		assert.Equal(t, ErrInvalidSplit, err)
+	}
+
+	b.Paid = true
+	_, err := b.Split(SplitRequest{Mode: Even, Payers: 2})
+	assert.Equal(t, ErrAlreadyPaid, err)
+}
+
+func TestApply(t *testing.T) {
+	b := newBill(100)
+
+	p := payment.Payment{Method: payment.Cash, Amount: 60, Tendered: 100, Tip: 10}
+	err := b.Apply(&p)
+
+	assert := assert.New(t)
+	assert.Nil(err)
+	assert.Equal(money.Amount(30), p.Change)
+	assert.False(b.Paid)
+
+	p = payment.Payment{Method: payment.Card, Amount: 40}
+	err = b.Apply(&p)
+
+	assert.Nil(err)
+	assert.True(b.Paid)
+}
+
+func TestApplyRefund(t *testing.T) {
+	b := newBill(100)
+	charge := payment.Payment{Method: payment.Card, Amount: 100}
+	charge.ID = 1
+	b.Payments = payment.Payments{charge}
+	b.Reconcile()
+	assert.True(t, b.Paid)
+
+	var refundOf uint = 1
+	refund := payment.Payment{Kind: payment.Refund, Amount: 30, RefundOf: &refundOf}
+	err := b.Apply(&refund)
+
+	assert.Nil(t, err)
+	assert.Equal(t, payment.Card, refund.Method)
+	assert.False(t, b.Paid)
+	assert.Equal(t, money.Amount(70), b.Balance(nil))
+
+	refund = payment.Payment{Kind: payment.Refund, Amount: 80, RefundOf: &refundOf}
+	assert.Equal(t, payment.ErrRefundExceeded, b.Apply(&refund))
+}
+
+func TestApplyParts(t *testing.T) {
+	b := newBill(100)
+	b.Parts = []Part{{Value: 50}, {Value: 50}}
+	b.Parts[0].ID = 1
+	b.Parts[1].ID = 2
+
+	var first, second, missing uint = 1, 2, 3
+
+	assert := assert.New(t)
+	assert.Equal(payment.ErrPartRequired, b.Apply(&payment.Payment{Method: payment.Cash, Amount: 50}))
+	assert.Equal(payment.ErrPartNotFound, b.Apply(&payment.Payment{Method: payment.Cash, Amount: 50, PartID: &missing}))
+	assert.Equal(payment.ErrOverpayment, b.Apply(&payment.Payment{Method: payment.Cash, Amount: 60, PartID: &first}))
+
+	assert.Nil(b.Apply(&payment.Payment{Method: payment.Cash, Amount: 50, PartID: &first}))
+	assert.True(b.Parts[0].Paid)
+	assert.False(b.Paid)
+
+	assert.Nil(b.Apply(&payment.Payment{Method: payment.Voucher, Amount: 50, PartID: &second}))
+	assert.True(b.Parts[1].Paid)
+	assert.True(b.Paid)
+}
+
+func TestSplitWithPayments(t *testing.T) {
+	b := newBill(100)
+	b.Payments = payment.Payments{{Amount: 10}}
+
+	_, err := b.Split(SplitRequest{Mode: Even, Payers: 2})
+	assert.Equal(t, ErrHasPayments, err)
+}
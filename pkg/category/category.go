@@ -20,6 +20,13 @@ type Storage interface {
 	UpdatePositions(categories []Category) error
 }
 
+// Preparation stations.
+const (
+	Kitchen = "kitchen"
+	Bar     = "bar"
+	Dessert = "dessert"
+)
+
 // BaseCategory is a lite category for a dish.
 type BaseCategory struct {
 	Title      string `bson:"title" json:"title"`
@@ -30,6 +37,7 @@ type BaseCategory struct {
 	Suggested2 *uint  `gorm:"default:null" bson:"suggested2,omitempty" json:"suggested2,omitempty"`
 	Suggested3 *uint  `gorm:"default:null" bson:"suggested3,omitempty" json:"suggested3,omitempty"`
 	Position   uint   `gorm:"default:1" bson:"position" json:"position,omitempty"`
+	Station    string `gorm:"default:'kitchen'" bson:"station" json:"station"`
 }
 
 // Category for a dish.
@@ -39,8 +47,47 @@ type Category struct {
 	ClientID uint `bson:"client_id" json:"client_id"`
 }
 
-// IsValid checks that the suggested IDs are unique.
+// Suggestions returns the IDs of the dishes suggested, 0 for those not set.
+func (c Category) Suggestions() []uint {
+	ids := []uint{}
+	for _, id := range []*uint{c.Suggested1, c.Suggested2, c.Suggested3} {
+		if id == nil {
+			ids = append(ids, 0)
+			continue
+		}
+
+		ids = append(ids, *id)
+	}
+
+	return ids
+}
+
+// IsValidStation checks that the station is a known one. An empty station
+// means the kitchen.
+func (c Category) IsValidStation() bool {
+	switch c.Station {
+	case "", Kitchen, Bar, Dessert:
+		return true
+	}
+
+	return false
+}
+
+// GetStation returns the station where the category is prepared.
+func (c Category) GetStation() string {
+	if c.Station == "" {
+		return Kitchen
+	}
+
+	return c.Station
+}
+
+// IsValid checks that the suggested IDs are unique and the station is known.
 func (c Category) IsValid() bool {
+	if !c.IsValidStation() {
+		return false
+	}
+
 	var suggested1 uint
 	var suggested2 uint
 	var suggested3 uint
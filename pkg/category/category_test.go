@@ -55,3 +55,31 @@ func TestIsValidFail(t *testing.T) {
 
 	assert.False(t, c.IsValid())
 }
+
+func TestIsValidStation(t *testing.T) {
+	stations := []string{"", Kitchen, Bar, Dessert}
+
+	assert := assert.New(t)
+
+	for _, s := range stations {
+		c := Category{}
+		c.Station = s
+		assert.True(c.IsValid())
+	}
+}
+
+func TestIsValidStationFail(t *testing.T) {
+	c := Category{}
+	c.Station = "grill"
+
+	assert.False(t, c.IsValid())
+}
+
+func TestGetStation(t *testing.T) {
+	c := Category{}
+
+	assert.Equal(t, Kitchen, c.GetStation())
+
+	c.Station = Bar
+	assert.Equal(t, Bar, c.GetStation())
+}
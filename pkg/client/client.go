@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"gitlab.com/menuxd/api-rest/pkg/model"
+	"gitlab.com/menuxd/api-rest/pkg/money"
 )
 
 // Storage handle the CRUD operations with Clients.
@@ -24,6 +25,27 @@ type Client struct {
 	UserID   uint      `bson:"user_id" json:"user_id"`
 	Timezone string    `gorm:"default:'America/Asuncion'" bson:"timezone" json:"timezone"`
 	ExpireAt time.Time `bson:"expire_at" json:"expire_at,omitempty"`
+
+	// ServiceCharge is the percentage added to the bills.
+	ServiceCharge uint `gorm:"default:0" bson:"service_charge" json:"service_charge"`
+	// RoundingUnit is the multiple the bills are rounded to, none when zero.
+	RoundingUnit money.Amount `gorm:"type:numeric(14,2);default:0" bson:"rounding_unit" json:"rounding_unit"`
+	// RoundingMode is nearest, up or down.
+	RoundingMode string `gorm:"default:'nearest'" bson:"rounding_mode" json:"rounding_mode"`
+}
+
+// ValidRules confirm the service charge and rounding rules.
+func (c Client) ValidRules() bool {
+	if c.ServiceCharge > 100 || c.RoundingUnit < 0 {
+		return false
+	}
+
+	switch c.RoundingMode {
+	case "", money.Nearest, money.Up, money.Down:
+		return true
+	}
+
+	return false
 }
 
 // ValidDate confirm the date to expire the client.
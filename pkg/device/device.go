@@ -0,0 +1,94 @@
+package device
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/pkg/model"
+)
+
+// ErrInvalidCode is returned when a code can't pair a device.
+var ErrInvalidCode = errors.New("the pairing code is invalid or expired")
+
+// Pairing settings.
+const (
+	// CodeTTL is how long a pairing code can be used.
+	CodeTTL = 15 * time.Minute
+	// TokenTTL is how long the token of a paired device lasts.
+	TokenTTL = 365 * 24 * time.Hour
+	// codeLength is the characters of a pairing code.
+	codeLength = 8
+	// codeAlphabet leaves out the characters easy to mistake.
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// Storage handle the operations with Devices.
+type Storage interface {
+	Create(d *Device) error
+	Pair(code string) (Device, error)
+	Revoke(id uint) error
+	GetAll(clientID uint) (Devices, error)
+	GetByID(id uint) (Device, error)
+}
+
+// Device is a customer tablet paired to a table. It is created with a one
+// time code, that the tablet exchanges for its token.
+type Device struct {
+	model.Model
+	Name          string     `json:"name"`
+	ClientID      uint       `json:"client_id"`
+	TableID       uint       `json:"table_id"`
+	Code          string     `gorm:"-" json:"code,omitempty"`
+	CodeHash      string     `gorm:"index" json:"-"`
+	CodeExpiresAt *time.Time `json:"code_expires_at,omitempty"`
+	PairedAt      *time.Time `json:"paired_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive checks that the device is paired and not revoked.
+func (d Device) IsActive() bool {
+	return d.PairedAt != nil && d.RevokedAt == nil
+}
+
+// CanPair checks that the code of the device can still be used.
+func (d Device) CanPair(now time.Time) bool {
+	return d.PairedAt == nil &&
+		d.RevokedAt == nil &&
+		d.CodeExpiresAt != nil &&
+		now.Before(*d.CodeExpiresAt)
+}
+
+// SetCode generates a new pairing code for the device, keeping only its
+// hash.
+func (d *Device) SetCode(now time.Time) error {
+	b := make([]byte, codeLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+
+	expiresAt := now.Add(CodeTTL)
+	d.Code = string(b)
+	d.CodeHash = HashCode(d.Code)
+	d.CodeExpiresAt = &expiresAt
+
+	return nil
+}
+
+// HashCode returns the hash of a pairing code, ignoring its case and
+// surrounding spaces.
+func HashCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Devices alias for a slice of Devices.
+type Devices []Device
@@ -0,0 +1,34 @@
+package device
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCode(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	d := Device{}
+
+	assert.Nil(t, d.SetCode(now))
+	assert.Len(t, d.Code, codeLength)
+	assert.Equal(t, HashCode(d.Code), d.CodeHash)
+	assert.NotEqual(t, d.Code, d.CodeHash)
+	assert.Equal(t, d.CodeHash, HashCode(" "+strings.ToLower(d.Code)+"\n"))
+
+	assert.True(t, d.CanPair(now.Add(CodeTTL-time.Second)))
+	assert.False(t, d.CanPair(now.Add(CodeTTL)))
+	assert.False(t, d.IsActive())
+}
+
+func TestIsActive(t *testing.T) {
+	now := time.Now()
+	d := Device{PairedAt: &now}
+	assert.True(t, d.IsActive())
+	assert.False(t, d.CanPair(now))
+
+	d.RevokedAt = &now
+	assert.False(t, d.IsActive())
+}
@@ -6,6 +6,7 @@ import (
 	"gitlab.com/menuxd/api-rest/pkg/category"
 	"gitlab.com/menuxd/api-rest/pkg/click"
 	"gitlab.com/menuxd/api-rest/pkg/model"
+	"gitlab.com/menuxd/api-rest/pkg/money"
 )
 
 // Storage handle the CRUD operations with Dishes.
@@ -27,26 +28,27 @@ type Storage interface {
 
 // BaseDish lite version of a dish.
 type BaseDish struct {
-	Name           string       `bson:"name" json:"name,omitempty"`
-	Description    string       `bson:"description,omitempty" json:"description,omitempty"`
-	Available      bool         `gorm:"default:true" bson:"available" json:"available"`
-	Price          float64      `bson:"price" json:"price"`
-	IsHalf         bool         `gorm:"default:false" bson:"is_half" json:"is_half"`
-	HalfPrice      *float64     `gorm:"default:null" bson:"half_price,omitempty" json:"half_price,omitempty"`
-	Pictures       []string     `gorm:"-" bson:"pictures" json:"pictures,omitempty"`
-	PicturesString string       `gorm:"column:pictures" bson:"pictures" json:"pictures_string,omitempty"`
-	Ingredients    []Ingredient `gorm:"-" json:"ingredients" json:"ingredients"`
-	Suggested      bool         `gorm:"default:false" bson:"suggested" json:"suggested"`
+	Name           string        `bson:"name" json:"name,omitempty"`
+	Description    string        `bson:"description,omitempty" json:"description,omitempty"`
+	Available      bool          `gorm:"default:true" bson:"available" json:"available"`
+	Price          money.Amount  `gorm:"type:numeric(14,2)" bson:"price" json:"price"`
+	IsHalf         bool          `gorm:"default:false" bson:"is_half" json:"is_half"`
+	HalfPrice      *money.Amount `gorm:"type:numeric(14,2);default:null" bson:"half_price,omitempty" json:"half_price,omitempty"`
+	Pictures       []string      `gorm:"-" bson:"pictures" json:"pictures,omitempty"`
+	PicturesString string        `gorm:"column:pictures" bson:"pictures" json:"pictures_string,omitempty"`
+	Ingredients    []Ingredient  `gorm:"-" json:"ingredients" json:"ingredients"`
+	Suggested      bool          `gorm:"default:false" bson:"suggested" json:"suggested"`
+	TaxRate        string        `gorm:"default:'vat10'" bson:"tax_rate" json:"tax_rate"`
 }
 
 // Ingredient to the dishes.
 type Ingredient struct {
 	model.Model
-	DishID  uint    `json:"dish_id"`
-	Name    string  `json:"name"`
-	OrderID *uint   `json:"order_id"`
-	Active  bool    `json:"active"`
-	Price   float64 `json:"price"`
+	DishID  uint         `json:"dish_id"`
+	Name    string       `json:"name"`
+	OrderID *uint        `json:"order_id"`
+	Active  bool         `json:"active"`
+	Price   money.Amount `gorm:"type:numeric(14,2)" json:"price"`
 }
 
 // Dish meal from a restaurant.
@@ -78,17 +78,16 @@ func NewUser(uid uint, addr string, tpass string) Email {
 	return e
 }
 
-// ChangePassword returns a Email with settings to change password
-func ChangePassword(addr string, tpass string) Email {
-	url := os.Getenv("BASE_URL_CLIENT")
+// ResetPassword returns a Email with the link to reset the password
+func ResetPassword(addr string, token string) Email {
+	url := os.Getenv("BASE_URL_CLIENT") + "/reset-password?token=" + token
 	e := Email{
-		From:         address,
-		Subject:      "Contraseña temporal",
-		BaseURL:      url,
-		Addr:         addr,
-		Body:         "¡Si tienes dudas o consultas no dudes en escribirnos!",
-		TempPassword: tpass,
-		Template:     basePath + "template/email/email.html",
+		From:     address,
+		Subject:  "Restablecer contraseña",
+		BaseURL:  url,
+		Addr:     addr,
+		Body:     "Recibimos una solicitud para restablecer tu contraseña.",
+		Template: basePath + "template/email/reset.html",
 	}
 	return e
 }
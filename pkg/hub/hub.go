@@ -0,0 +1,245 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	melody "gopkg.in/olahol/melody.v1"
+)
+
+// Connection settings.
+const (
+	// QueueSize is the most messages waiting to be sent to a connection.
+	// A connection that reaches it is evicted.
+	QueueSize = 256
+	// PingPeriod is the time between heartbeat pings.
+	PingPeriod = 30 * time.Second
+	// PongWait is the time to wait for the pong before dropping a connection.
+	PongWait = 45 * time.Second
+)
+
+// Conn is a websocket session of a client, with the role and waiter of its
+// token.
+type Conn struct {
+	ClientID  uint      `json:"client_id"`
+	Role      string    `json:"role,omitempty"`
+	WaiterID  uint      `json:"waiter_id,omitempty"`
+	CanAck    bool      `json:"can_ack,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// IsExpired checks that the token of the connection has expired.
+func (c Conn) IsExpired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && now.After(c.ExpiresAt)
+}
+
+// Metrics are the counters of the hub.
+type Metrics struct {
+	Sessions int          `json:"sessions"`
+	Clients  map[uint]int `json:"clients"`
+	Sent     uint64       `json:"sent"`
+	Dropped  uint64       `json:"dropped"`
+	Evicted  uint64       `json:"evicted"`
+}
+
+// conn is a connection with the messages queued and not sent yet.
+type conn struct {
+	Conn
+	pending int
+}
+
+// Hub keeps the websocket sessions by client, so a message is only offered
+// to the sessions of its client.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[uint]map[*melody.Session]*conn
+	index   map[*melody.Session]uint
+	sent    uint64
+	dropped uint64
+	evicted uint64
+	write   func(s *melody.Session, msg []byte) error
+	close   func(s *melody.Session, msg []byte) error
+}
+
+// New returns a hub for the sessions of the melody instance, setting its
+// queues and heartbeat.
+func New(m *melody.Melody) *Hub {
+	h := newHub()
+
+	// One more than the queue, so there is always room to close.
+	m.Config.MessageBufferSize = QueueSize + 1
+	m.Config.PingPeriod = PingPeriod
+	m.Config.PongWait = PongWait
+	m.HandleSentMessage(h.sentHandler)
+
+	return h
+}
+
+// newHub returns an empty hub writing to melody sessions.
+func newHub() *Hub {
+	return &Hub{
+		clients: make(map[uint]map[*melody.Session]*conn),
+		index:   make(map[*melody.Session]uint),
+		write:   (*melody.Session).Write,
+		close:   (*melody.Session).CloseWithMsg,
+	}
+}
+
+// Join adds a session to its client.
+func (h *Hub) Join(s *melody.Session, c Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c.Since.IsZero() {
+		c.Since = time.Now()
+	}
+
+	sessions, ok := h.clients[c.ClientID]
+	if !ok {
+		sessions = make(map[*melody.Session]*conn)
+		h.clients[c.ClientID] = sessions
+	}
+
+	sessions[s] = &conn{Conn: c}
+	h.index[s] = c.ClientID
+}
+
+// Leave removes a session.
+func (h *Hub) Leave(s *melody.Session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.leave(s)
+}
+
+// leave removes a session, the lock taken.
+func (h *Hub) leave(s *melody.Session) bool {
+	clientID, ok := h.index[s]
+	if !ok {
+		return false
+	}
+
+	delete(h.index, s)
+	delete(h.clients[clientID], s)
+	if len(h.clients[clientID]) == 0 {
+		delete(h.clients, clientID)
+	}
+
+	return true
+}
+
+// Conn returns the connection of a session.
+func (h *Hub) Conn(s *melody.Session) (Conn, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c, ok := h.get(s)
+	if !ok {
+		return Conn{}, false
+	}
+
+	return c.Conn, true
+}
+
+// get returns the connection of a session, the lock taken.
+func (h *Hub) get(s *melody.Session) (*conn, bool) {
+	clientID, ok := h.index[s]
+	if !ok {
+		return nil, false
+	}
+
+	c, ok := h.clients[clientID][s]
+	return c, ok
+}
+
+// Send queues a message to a session. A session that has its queue full is
+// evicted, it will replay what it missed when it reconnects.
+func (h *Hub) Send(s *melody.Session, msg []byte) {
+	h.mu.Lock()
+	c, ok := h.get(s)
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+
+	if c.pending >= QueueSize {
+		h.dropped++
+		h.evicted++
+		h.leave(s)
+		h.mu.Unlock()
+
+		h.close(s, melody.FormatCloseMessage(melody.CloseTryAgainLater, "slow consumer"))
+		return
+	}
+
+	c.pending++
+	h.mu.Unlock()
+
+	err := h.write(s, msg)
+	if err != nil {
+		h.mu.Lock()
+		c.pending--
+		h.dropped++
+		h.mu.Unlock()
+	}
+}
+
+// Broadcast sends a message to every session of a client.
+func (h *Hub) Broadcast(clientID uint, msg []byte) {
+	h.BroadcastFilter(clientID, msg, nil)
+}
+
+// BroadcastFilter sends a message to the sessions of a client the filter
+// accepts, every one when it is nil. Sessions with an expired token are
+// skipped.
+func (h *Hub) BroadcastFilter(clientID uint, msg []byte, filter func(Conn) bool) {
+	now := time.Now()
+
+	h.mu.Lock()
+	sessions := []*melody.Session{}
+	for s, c := range h.clients[clientID] {
+		if c.IsExpired(now) || (filter != nil && !filter(c.Conn)) {
+			continue
+		}
+
+		sessions = append(sessions, s)
+	}
+	h.mu.Unlock()
+
+	for _, s := range sessions {
+		h.Send(s, msg)
+	}
+}
+
+// sentHandler counts a message as sent, leaving room in the queue.
+func (h *Hub) sentHandler(s *melody.Session, _ []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sent++
+	c, ok := h.get(s)
+	if ok && c.pending > 0 {
+		c.pending--
+	}
+}
+
+// Metrics returns the counters of the hub and the sessions by client.
+func (h *Hub) Metrics() Metrics {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	m := Metrics{
+		Clients: make(map[uint]int),
+		Sent:    h.sent,
+		Dropped: h.dropped,
+		Evicted: h.evicted,
+	}
+
+	for clientID, sessions := range h.clients {
+		m.Clients[clientID] = len(sessions)
+		m.Sessions += len(sessions)
+	}
+
+	return m
+}
@@ -0,0 +1,91 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	melody "gopkg.in/olahol/melody.v1"
+)
+
+type recorder struct {
+	written map[*melody.Session]int
+	closed  map[*melody.Session]bool
+}
+
+func newTestHub() (*Hub, *recorder) {
+	rec := &recorder{
+		written: make(map[*melody.Session]int),
+		closed:  make(map[*melody.Session]bool),
+	}
+
+	h := newHub()
+	h.write = func(s *melody.Session, _ []byte) error {
+		rec.written[s]++
+		return nil
+	}
+	h.close = func(s *melody.Session, _ []byte) error {
+		rec.closed[s] = true
+		return nil
+	}
+
+	return h, rec
+}
+
+func TestBroadcast(t *testing.T) {
+	h, rec := newTestHub()
+	first, second, other := &melody.Session{}, &melody.Session{}, &melody.Session{}
+	expired := &melody.Session{}
+
+	h.Join(first, Conn{ClientID: 1, Role: "waiter"})
+	h.Join(second, Conn{ClientID: 1, Role: "kitchen"})
+	h.Join(other, Conn{ClientID: 2})
+	h.Join(expired, Conn{ClientID: 1, ExpiresAt: time.Now().Add(-time.Minute)})
+
+	h.Broadcast(1, []byte("{}"))
+	h.BroadcastFilter(1, []byte("{}"), func(c Conn) bool { return c.Role == "waiter" })
+
+	assert := assert.New(t)
+	assert.Equal(2, rec.written[first])
+	assert.Equal(1, rec.written[second])
+	assert.Equal(0, rec.written[other])
+	assert.Equal(0, rec.written[expired])
+}
+
+func TestLeave(t *testing.T) {
+	h, _ := newTestHub()
+	s := &melody.Session{}
+
+	h.Join(s, Conn{ClientID: 1})
+	assert.Equal(t, 1, h.Metrics().Sessions)
+
+	h.Leave(s)
+	_, ok := h.Conn(s)
+
+	assert.False(t, ok)
+	assert.Equal(t, 0, h.Metrics().Sessions)
+	assert.Empty(t, h.Metrics().Clients)
+}
+
+func TestSlowConsumer(t *testing.T) {
+	h, rec := newTestHub()
+	slow, fast := &melody.Session{}, &melody.Session{}
+	h.Join(slow, Conn{ClientID: 1})
+	h.Join(fast, Conn{ClientID: 1})
+
+	for i := 0; i < QueueSize+1; i++ {
+		h.Broadcast(1, []byte("{}"))
+		h.sentHandler(fast, nil)
+	}
+
+	m := h.Metrics()
+
+	assert := assert.New(t)
+	assert.True(rec.closed[slow])
+	assert.False(rec.closed[fast])
+	assert.Equal(QueueSize, rec.written[slow])
+	assert.Equal(QueueSize+1, rec.written[fast])
+	assert.Equal(1, m.Sessions)
+	assert.Equal(uint64(1), m.Evicted)
+	assert.Equal(uint64(QueueSize+1), m.Sent)
+}
@@ -0,0 +1,99 @@
+package kds
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/table"
+)
+
+// Ticket is the part of an order that a preparation station has to cook.
+type Ticket struct {
+	OrderID   uint         `json:"order_id"`
+	Station   string       `json:"station"`
+	Table     *table.Table `json:"table"`
+	CreatedAt time.Time    `json:"created_at"`
+	Elapsed   int64        `json:"elapsed"`
+	Items     []order.Item `json:"items"`
+}
+
+// Tickets alias for a slice of Tickets.
+type Tickets []Ticket
+
+// Station returns the preparation station of an item.
+func Station(i order.Item) string {
+	if i.Dish == nil || i.Dish.Category == nil {
+		return category.Kitchen
+	}
+
+	return i.Dish.Category.GetStation()
+}
+
+// IsPending checks that the item still has to be prepared.
+func IsPending(i order.Item) bool {
+	switch i.Status {
+	case "", order.Received, order.Accepted, order.Preparing:
+		return i.Active
+	}
+
+	return false
+}
+
+// Pending returns the items of the order that the station has to prepare.
+// An empty station means every station.
+func Pending(o order.Order, station string) []order.Item {
+	items := []order.Item{}
+	for _, i := range o.Items {
+		if !IsPending(i) {
+			continue
+		}
+
+		if station != "" && Station(i) != station {
+			continue
+		}
+
+		items = append(items, i)
+	}
+
+	return items
+}
+
+// Build groups the pending items of the orders in a ticket per order and
+// station, the oldest first. An empty station means every station.
+func Build(orders []order.Order, station string, now time.Time) Tickets {
+	tickets := Tickets{}
+	for _, o := range orders {
+		if o.Canceled {
+			continue
+		}
+
+		byStation := make(map[string][]order.Item)
+		stations := []string{}
+		for _, i := range Pending(o, station) {
+			s := Station(i)
+			if _, ok := byStation[s]; !ok {
+				stations = append(stations, s)
+			}
+			byStation[s] = append(byStation[s], i)
+		}
+
+		for _, s := range stations {
+			tickets = append(tickets, Ticket{
+				OrderID:   o.ID,
+				Station:   s,
+				Table:     o.Table,
+				CreatedAt: o.CreatedAt,
+				Elapsed:   int64(now.Sub(o.CreatedAt) / time.Second),
+				Items:     byStation[s],
+			})
+		}
+	}
+
+	sort.SliceStable(tickets, func(a, b int) bool {
+		return tickets[a].CreatedAt.Before(tickets[b].CreatedAt)
+	})
+
+	return tickets
+}
@@ -0,0 +1,91 @@
+package kds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/category"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+)
+
+func newItem(id uint, station string, status order.Status) order.Item {
+	c := &category.Category{}
+	c.Station = station
+
+	i := order.Item{Active: true, Dish: &dish.Dish{Category: c}}
+	i.ID = id
+	i.Status = status
+
+	return i
+}
+
+func newOrder(id uint, createdAt time.Time, items ...order.Item) order.Order {
+	o := order.Order{Items: items}
+	o.ID = id
+	o.CreatedAt = createdAt
+
+	return o
+}
+
+func TestStation(t *testing.T) {
+	assert.Equal(t, category.Bar, Station(newItem(1, category.Bar, order.Received)))
+	assert.Equal(t, category.Kitchen, Station(newItem(1, "", order.Received)))
+	assert.Equal(t, category.Kitchen, Station(order.Item{}))
+}
+
+func TestIsPending(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsPending(newItem(1, "", order.Received)))
+	assert.True(IsPending(newItem(1, "", order.Preparing)))
+	assert.False(IsPending(newItem(1, "", order.Ready)))
+	assert.False(IsPending(newItem(1, "", order.Cancelled)))
+
+	i := newItem(1, "", order.Received)
+	i.Active = false
+	assert.False(IsPending(i))
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Now()
+	orders := []order.Order{
+		newOrder(2, now.Add(-time.Minute),
+			newItem(3, category.Kitchen, order.Received),
+		),
+		newOrder(1, now.Add(-5*time.Minute),
+			newItem(1, category.Kitchen, order.Preparing),
+			newItem(2, category.Bar, order.Received),
+			newItem(4, category.Kitchen, order.Ready),
+		),
+	}
+
+	tickets := Build(orders, "", now)
+
+	assert := assert.New(t)
+	assert.Len(tickets, 3)
+	assert.Equal(uint(1), tickets[0].OrderID)
+	assert.Equal(category.Kitchen, tickets[0].Station)
+	assert.Len(tickets[0].Items, 1)
+	assert.Equal(int64(300), tickets[0].Elapsed)
+	assert.Equal(uint(1), tickets[1].OrderID)
+	assert.Equal(category.Bar, tickets[1].Station)
+	assert.Equal(uint(2), tickets[2].OrderID)
+}
+
+func TestBuildStation(t *testing.T) {
+	now := time.Now()
+	orders := []order.Order{
+		newOrder(1, now,
+			newItem(1, category.Kitchen, order.Received),
+			newItem(2, category.Bar, order.Received),
+		),
+		newOrder(2, now, newItem(3, category.Kitchen, order.Received)),
+	}
+
+	tickets := Build(orders, category.Bar, now)
+
+	assert.Len(t, tickets, 1)
+	assert.Equal(t, uint(2), tickets[0].Items[0].ID)
+}
@@ -0,0 +1,126 @@
+package login
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/pkg/model"
+)
+
+// Errors.
+var (
+	ErrInvalidToken = errors.New("the refresh token is invalid or expired")
+	ErrReused       = errors.New("the refresh token was already used")
+)
+
+// Session settings.
+const (
+	// AccessTTL is how long an access token lasts.
+	AccessTTL = 15 * time.Minute
+	// RefreshTTL is how long a session lasts without being refreshed.
+	RefreshTTL = 30 * 24 * time.Hour
+	// tokenBytes are the random bytes of a refresh token.
+	tokenBytes = 32
+)
+
+// Storage handle the operations with the Sessions.
+type Storage interface {
+	Create(s *Session) error
+	// Rotate revokes a session and creates the next one of its family.
+	Rotate(old Session, next *Session) error
+	Revoke(id uint) error
+	RevokeFamily(family string) error
+	RevokeAll(userID uint) error
+	GetByID(id uint) (Session, error)
+	GetByToken(token string) (Session, error)
+	GetActive(userID uint) (Sessions, error)
+	RecordLockout(l *Lockout) error
+	GetLockouts(since time.Time) (Lockouts, error)
+}
+
+// Session is a login of a user, kept alive by a refresh token. Each refresh
+// revokes the session and starts a new one of the same family, so a refresh
+// token used twice reveals that it was stolen.
+type Session struct {
+	model.Model
+	UserID    uint       `gorm:"index" json:"user_id"`
+	Family    string     `gorm:"index" json:"-"`
+	Token     string     `gorm:"-" json:"-"`
+	TokenHash string     `gorm:"unique_index" json:"-"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	IP        string     `json:"ip,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// New returns a session of the user with a new refresh token, of a new
+// family if none is given.
+func New(userID uint, family string, now time.Time) (*Session, error) {
+	token, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	if family == "" {
+		family, err = randomString()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Session{
+		UserID:    userID,
+		Family:    family,
+		Token:     token,
+		TokenHash: HashToken(token),
+		ExpiresAt: now.Add(RefreshTTL),
+	}, nil
+}
+
+// IsActive checks that the session is not revoked nor expired.
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// HashToken returns the hash of a refresh token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString returns a random URL safe string.
+func randomString() (string, error) {
+	b := make([]byte, tokenBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sessions alias for a slice of Sessions.
+type Sessions []Session
+
+// Lockout reasons.
+const (
+	LockedAccount = "account"
+	LockedIP      = "ip"
+)
+
+// Lockout is a lock of an account or an IP after failed logins, kept for
+// the admins to review.
+type Lockout struct {
+	model.Model
+	Email  string    `gorm:"index" json:"email"`
+	IP     string    `json:"ip"`
+	Reason string    `json:"reason"`
+	Until  time.Time `json:"until"`
+}
+
+// Lockouts alias for a slice of Lockouts.
+type Lockouts []Lockout
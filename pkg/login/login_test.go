@@ -0,0 +1,28 @@
+package login
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := New(1, "", now)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, s.Token)
+	assert.NotEmpty(t, s.Family)
+	assert.Equal(t, HashToken(s.Token), s.TokenHash)
+	assert.True(t, s.IsActive(now))
+	assert.False(t, s.IsActive(now.Add(RefreshTTL)))
+
+	next, err := New(1, s.Family, now)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Family, next.Family)
+	assert.NotEqual(t, s.Token, next.Token)
+
+	s.RevokedAt = &now
+	assert.False(t, s.IsActive(now))
+}
@@ -1 +1,85 @@
 package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+func TestCan(t *testing.T) {
+	tt := []struct {
+		role string
+		p    Permission
+		can  bool
+	}{
+		{user.Admin, ManageStaff, true},
+		{user.Client, ManageStaff, true},
+		{user.Owner, RefundPayments, true},
+		{user.Manager, RefundPayments, true},
+		{user.Manager, ManageStaff, false},
+		{user.Cashier, TakePayments, true},
+		{user.Cashier, TakeOrders, false},
+		{user.Waiter, TakeOrders, true},
+		{user.Waiter, ManageMenu, false},
+		{user.Waiter, PrepareOrders, false},
+		{user.Kitchen, PrepareOrders, true},
+		{user.Kitchen, ReadBills, false},
+		{user.Table, CallStaff, true},
+		{user.Table, ReadOrders, false},
+		{user.Table, ReadTables, false},
+		{user.Table, LoginWaiters, false},
+		{user.Owner, ManageKeys, true},
+		{user.Manager, ManageKeys, false},
+		{user.Integration, TakeOrders, true},
+		{user.Integration, ManageMenu, false},
+		{"unknown", ReadMenu, false},
+	}
+
+	for _, tc := range tt {
+		assert.Equal(t, tc.can, Can(tc.role, tc.p), tc.role+" "+string(tc.p))
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, waiter, _ := tokenAuth.Encode(jwtauth.Claims{"id": "2", "role": user.Waiter})
+
+	mux := chi.NewRouter()
+	mux.Use(jwtauth.Verifier(tokenAuth))
+	mux.With(Require(TakeOrders)).Post("/orders", okHandler)
+	mux.With(Require(ManageMenu)).Post("/dishes", okHandler)
+	mux.With(RequireAny(PrepareOrders, ServeTables)).Post("/status", okHandler)
+	mux.With(RequireAny(PrepareOrders, ManageMenu)).Post("/kds", okHandler)
+
+	tt := []struct {
+		token string
+		path  string
+		code  int
+	}{
+		{waiter, "/orders", http.StatusOK},
+		{waiter, "/dishes", http.StatusForbidden},
+		{waiter, "/status", http.StatusOK},
+		{waiter, "/kds", http.StatusForbidden},
+		{"", "/orders", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tt {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, tc.path, nil)
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		mux.ServeHTTP(w, r)
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
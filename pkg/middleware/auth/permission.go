@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/go-chi/jwtauth"
+	"gitlab.com/menuxd/api-rest/pkg/user"
+)
+
+// Permission is an action a route requires.
+type Permission string
+
+// Permissions.
+const (
+	ReadMenu         Permission = "menu:read"
+	ManageMenu       Permission = "menu:manage"
+	ReadTables       Permission = "tables:read"
+	ServeTables      Permission = "tables:serve"
+	ManageTables     Permission = "tables:manage"
+	ReadStaff        Permission = "staff:read"
+	ManageStaff      Permission = "staff:manage"
+	LoginWaiters     Permission = "waiters:login"
+	ReadOrders       Permission = "orders:read"
+	TakeOrders       Permission = "orders:take"
+	PrepareOrders    Permission = "orders:prepare"
+	CallStaff        Permission = "staff:call"
+	AckNotifications Permission = "notifications:ack"
+	ReadBills        Permission = "bills:read"
+	WriteBills       Permission = "bills:write"
+	TakePayments     Permission = "payments:take"
+	RefundPayments   Permission = "payments:refund"
+	ReadRegister     Permission = "register:read"
+	OperateRegister  Permission = "register:operate"
+	SendFeedback     Permission = "feedback:send"
+	ReadReports      Permission = "reports:read"
+	ReadClient       Permission = "client:read"
+	ManageKeys       Permission = "keys:manage"
+)
+
+// owner are the permissions of who has the client.
+var owner = []Permission{
+	ReadMenu, ManageMenu,
+	ReadTables, ServeTables, ManageTables,
+	ReadStaff, ManageStaff, LoginWaiters,
+	ReadOrders, TakeOrders, PrepareOrders, CallStaff, AckNotifications,
+	ReadBills, WriteBills,
+	TakePayments, RefundPayments,
+	ReadRegister, OperateRegister,
+	SendFeedback, ReadReports,
+	ReadClient, ManageKeys,
+}
+
+// Roles are the permissions of each role. Admins have every one.
+var Roles = map[string][]Permission{
+	user.Client:  owner,
+	user.Owner:   owner,
+	user.Manager: without(owner, ManageStaff, ManageKeys),
+	user.Cashier: {
+		ReadMenu, ReadTables, ReadOrders, AckNotifications,
+		ReadBills, WriteBills, TakePayments,
+		ReadRegister, OperateRegister, ReadClient,
+	},
+	user.Waiter: {
+		ReadMenu, ReadTables, ServeTables, ReadStaff,
+		ReadOrders, TakeOrders, AckNotifications,
+		ReadBills, WriteBills, TakePayments, ReadClient,
+	},
+	user.Kitchen: {
+		ReadMenu, ReadOrders, PrepareOrders, ReadClient,
+	},
+	user.Table: {
+		ReadMenu, TakeOrders, CallStaff, SendFeedback, ReadClient,
+	},
+	user.Integration: {
+		ReadMenu, ReadOrders, TakeOrders,
+	},
+}
+
+// without returns the permissions but the given ones.
+func without(permissions []Permission, ps ...Permission) []Permission {
+	result := []Permission{}
+	for _, v := range permissions {
+		if !contains(ps, v) {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// contains checks that the permission is in the list.
+func contains(permissions []Permission, p Permission) bool {
+	for _, v := range permissions {
+		if v == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Can checks that the role has the permission.
+func Can(role string, p Permission) bool {
+	if role == user.Admin {
+		return true
+	}
+
+	return contains(Roles[role], p)
+}
+
+// Scoped checks that the scopes of the claims include the permission.
+// Tokens without scopes, as those of the users, have every permission of
+// their role.
+func Scoped(claims jwtauth.Claims, p Permission) bool {
+	switch scopes := claims["scopes"].(type) {
+	case nil:
+		return true
+	case []Permission:
+		return contains(scopes, p)
+	case []interface{}:
+		for _, v := range scopes {
+			if v == string(p) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Has checks that the token of the request has the permission, by its role
+// and its scopes.
+func Has(r *http.Request, p Permission) bool {
+	token, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil || !token.Valid {
+		return false
+	}
+
+	role, _ := claims["role"].(string)
+	return Can(role, p) && Scoped(claims, p)
+}
+
+// Require allows the request only if the role of its token has the
+// permission, and its scopes if it has them.
+func Require(p Permission) func(next http.Handler) http.Handler {
+	return RequireAny(p)
+}
+
+// RequireAny allows the request only if its token has any of the
+// permissions, for handlers that check which one they need.
+func RequireAny(ps ...Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				http.Error(w, ErrTokenNotFound.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if token == nil || !token.Valid {
+				http.Error(w, ErrTokenNoValid.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			for _, p := range ps {
+				if Has(r, p) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, ErrInsufficientPrivileges.Error(), http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+)
+
+// Errors
+var (
+	ErrForbidden = errors.New("The client is not yours")
+	ErrNoScope   = errors.New("The clients of the token are unknown")
+	ErrRevoked   = errors.New("The session or device was revoked")
+)
+
+// Resource is a table whose rows belong to a client.
+type Resource string
+
+// Resources.
+const (
+	Ads           Resource = "ads"
+	APIKeys       Resource = "api_keys"
+	Bills         Resource = "bills"
+	Categories    Resource = "categories"
+	Devices       Resource = "devices"
+	Dishes        Resource = "dishes"
+	Items         Resource = "items"
+	Notifications Resource = "notifications"
+	Orders        Resource = "orders"
+	Payments      Resource = "payments"
+	Promotions    Resource = "promotions"
+	Questions     Resource = "questions"
+	Ratings       Resource = "ratings"
+	Registers     Resource = "register_sessions"
+	Stays         Resource = "stays"
+	Tables        Resource = "tables"
+	Waiters       Resource = "waiters"
+)
+
+// tableBound are the resources that a device only reaches for its table.
+var tableBound = map[Resource]bool{
+	Bills:         true,
+	Items:         true,
+	Notifications: true,
+	Orders:        true,
+	Tables:        true,
+}
+
+// Ownership is the client and the table a resource or device belongs to.
+type Ownership struct {
+	ClientID uint
+	TableID  uint
+}
+
+// Storage resolves the clients of a user, the owner of a resource and the
+// table of a device.
+type Storage interface {
+	Clients(userID uint) ([]uint, error)
+	Owner(res Resource, id uint) (Ownership, error)
+	// Device returns the ownership of an active device, ErrRevoked if it
+	// was revoked.
+	Device(id uint) (Ownership, error)
+	// Session returns ErrRevoked if the login session is not active.
+	Session(id uint) error
+}
+
+// Scope is what a token gives access to: every client for admins, the
+// clients it lists otherwise. Device tokens also set the table.
+type Scope struct {
+	All     bool
+	UserID  uint
+	Clients []uint
+	Table   uint
+}
+
+// Allows checks that the scope gives access to the client.
+func (s Scope) Allows(clientID uint) bool {
+	if s.All {
+		return true
+	}
+
+	for _, id := range s.Clients {
+		if id == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reaches checks that the scope gives access to a resource with the
+// ownership, which must be of its table when the scope has one.
+func (s Scope) Reaches(res Resource, o Ownership) bool {
+	if !s.Allows(o.ClientID) {
+		return false
+	}
+
+	if s.All || s.Table == 0 || !tableBound[res] {
+		return true
+	}
+
+	return o.TableID == s.Table
+}
+
+// Claim returns a claim as string, whether it was encoded as string or
+// number.
+func Claim(claims jwtauth.Claims, key string) string {
+	switch v := claims[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	}
+
+	return ""
+}
+
+// FromClaims returns the scope of a token: admins reach every client,
+// devices their table, tokens of a client only that one and users the
+// clients they own. Tokens of a revoked session have none, nor those of
+// users without session.
+func FromClaims(claims jwtauth.Claims, s Storage) (Scope, error) {
+	sc := Scope{}
+
+	sid := Claim(claims, "sid")
+	if sid == "" && isUser(claims) {
+		return Scope{}, ErrRevoked
+	}
+
+	if sid != "" {
+		sessionID, err := strconv.Atoi(sid)
+		if err != nil {
+			return Scope{}, ErrNoScope
+		}
+
+		err = s.Session(uint(sessionID))
+		if err != nil {
+			return Scope{}, ErrRevoked
+		}
+	}
+
+	if d := Claim(claims, "device_id"); d != "" {
+		deviceID, err := strconv.Atoi(d)
+		if err != nil {
+			return Scope{}, ErrNoScope
+		}
+
+		o, err := s.Device(uint(deviceID))
+		if err != nil {
+			return Scope{}, err
+		}
+
+		sc.Clients = []uint{o.ClientID}
+		sc.Table = o.TableID
+		return sc, nil
+	}
+
+	userID, err := strconv.Atoi(Claim(claims, "id"))
+	if err == nil {
+		sc.UserID = uint(userID)
+	}
+
+	if Claim(claims, "role") == "admin" {
+		sc.All = true
+		return sc, nil
+	}
+
+	if c := Claim(claims, "client_id"); c != "" {
+		clientID, err := strconv.Atoi(c)
+		if err != nil {
+			return Scope{}, ErrNoScope
+		}
+
+		sc.Clients = []uint{uint(clientID)}
+		return sc, nil
+	}
+
+	if sc.UserID == 0 {
+		return sc, nil
+	}
+
+	sc.Clients, err = s.Clients(sc.UserID)
+	if err != nil {
+		return Scope{}, err
+	}
+
+	return sc, nil
+}
+
+// isUser checks that the claims are of a user login, and not of a device,
+// a waiter or an API key, which are checked by their own claims.
+func isUser(claims jwtauth.Claims) bool {
+	for _, k := range []string{"device_id", "waiter_id", "key_id"} {
+		if Claim(claims, k) != "" {
+			return false
+		}
+	}
+
+	return Claim(claims, "id") != ""
+}
+
+// ctxKey is the key of the scope in the request context.
+type ctxKey struct{}
+
+// scoped is the scope of a request with the storage that resolved it.
+type scoped struct {
+	scope   Scope
+	storage Storage
+}
+
+// NewContext returns a context carrying the scope and its storage.
+func NewContext(ctx context.Context, sc Scope, s Storage) context.Context {
+	return context.WithValue(ctx, ctxKey{}, scoped{scope: sc, storage: s})
+}
+
+// FromContext returns the scope of the request context.
+func FromContext(ctx context.Context) (Scope, bool) {
+	v, ok := ctx.Value(ctxKey{}).(scoped)
+	return v.scope, ok
+}
+
+// Resolve puts in the context the scope of the verified token. Requests
+// without a valid token go through without scope, so public routes keep
+// working and the scoped ones answer 401. Revoked sessions and devices
+// answer 401.
+func Resolve(s Storage) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil || token == nil || !token.Valid {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sc, err := FromClaims(claims, s)
+			if err == ErrRevoked {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), sc, s)))
+		})
+	}
+}
+
+// Allowed checks that the scope of the request gives access to the client.
+func Allowed(r *http.Request, clientID uint) bool {
+	sc, ok := FromContext(r.Context())
+	return ok && sc.Allows(clientID)
+}
+
+// Owns checks that the scope of the request gives access to the client
+// owning the resource.
+func Owns(r *http.Request, res Resource, id uint) bool {
+	v, ok := r.Context().Value(ctxKey{}).(scoped)
+	if !ok {
+		return false
+	}
+
+	if v.scope.All {
+		return true
+	}
+
+	o, err := v.storage.Owner(res, id)
+	if err != nil {
+		return false
+	}
+
+	return v.scope.Reaches(res, o)
+}
+
+// Table returns the table of the device of the request, 0 for the rest.
+func Table(r *http.Request) uint {
+	sc, _ := FromContext(r.Context())
+	return sc.Table
+}
+
+// Client allows the request only if its scope reaches the client of the URL
+// param.
+func Client(param string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sc, ok := FromContext(r.Context())
+			if !ok {
+				http.Error(w, ErrNoScope.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			clientID, err := strconv.Atoi(chi.URLParam(r, param))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if !sc.Allows(uint(clientID)) {
+				http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Owned allows the request only if its scope reaches the client owning the
+// resource of the URL param. Unknown resources answer 404.
+func Owned(param string, res Resource) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v, ok := r.Context().Value(ctxKey{}).(scoped)
+			if !ok {
+				http.Error(w, ErrNoScope.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			id, err := strconv.Atoi(chi.URLParam(r, param))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if v.scope.All {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			o, err := v.storage.Owner(res, uint(id))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			if !v.scope.Reaches(res, o) {
+				http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// User allows the request only to the user of the URL param and admins.
+func User(param string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sc, ok := FromContext(r.Context())
+			if !ok {
+				http.Error(w, ErrNoScope.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			userID, err := strconv.Atoi(chi.URLParam(r, param))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if !sc.All && sc.UserID != uint(userID) {
+				http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStorage gives user 1 the clients 1 and 2, and each resource to the
+// client and table with its same ID, but from 10 on to client 1. Device 1
+// is of table 1 and device 2 was revoked, as the sessions but 1.
+type fakeStorage struct{}
+
+func (fakeStorage) Clients(userID uint) ([]uint, error) {
+	if userID == 1 {
+		return []uint{1, 2}, nil
+	}
+
+	return []uint{}, nil
+}
+
+func (fakeStorage) Owner(res Resource, id uint) (Ownership, error) {
+	if id == 0 {
+		return Ownership{}, errors.New("not found")
+	}
+
+	if id >= 10 {
+		return Ownership{ClientID: 1, TableID: id}, nil
+	}
+
+	return Ownership{ClientID: id, TableID: id}, nil
+}
+
+func (fakeStorage) Session(id uint) error {
+	if id != 1 {
+		return ErrRevoked
+	}
+
+	return nil
+}
+
+func (fakeStorage) Device(id uint) (Ownership, error) {
+	if id == 2 {
+		return Ownership{}, ErrRevoked
+	}
+
+	return Ownership{ClientID: 1, TableID: 1}, nil
+}
+
+func TestFromClaims(t *testing.T) {
+	tt := []struct {
+		name   string
+		claims jwtauth.Claims
+		scope  Scope
+	}{
+		{"admin", jwtauth.Claims{"id": "9", "role": "admin", "sid": "1"}, Scope{All: true, UserID: 9}},
+		{"owner", jwtauth.Claims{"id": "1", "role": "client", "sid": "1"}, Scope{UserID: 1, Clients: []uint{1, 2}}},
+		{"client token", jwtauth.Claims{"client_id": float64(3), "role": "waiter"}, Scope{Clients: []uint{3}}},
+		{"no clients", jwtauth.Claims{"id": "5", "role": "client", "sid": "1"}, Scope{UserID: 5, Clients: []uint{}}},
+		{"session", jwtauth.Claims{"id": "1", "role": "client", "sid": "1"}, Scope{UserID: 1, Clients: []uint{1, 2}}},
+		{"device", jwtauth.Claims{"device_id": float64(1), "client_id": float64(1), "role": "table"}, Scope{Clients: []uint{1}, Table: 1}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, err := FromClaims(tc.claims, fakeStorage{})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.scope, sc)
+		})
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	tokenAuth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, owner, _ := tokenAuth.Encode(jwtauth.Claims{"id": "1", "role": "client", "sid": "1"})
+	_, admin, _ := tokenAuth.Encode(jwtauth.Claims{"id": "9", "role": "admin", "sid": "1"})
+	_, device, _ := tokenAuth.Encode(jwtauth.Claims{"device_id": 1, "role": "table"})
+	_, revoked, _ := tokenAuth.Encode(jwtauth.Claims{"device_id": 2, "role": "table"})
+	_, loggedOut, _ := tokenAuth.Encode(jwtauth.Claims{"id": "1", "role": "client", "sid": "2"})
+	_, sessionless, _ := tokenAuth.Encode(jwtauth.Claims{"id": "1", "role": "client"})
+
+	mux := chi.NewRouter()
+	mux.Use(jwtauth.Verifier(tokenAuth), Resolve(fakeStorage{}))
+	mux.With(Client("clientId")).Get("/client/{clientId}", okHandler)
+	mux.With(Owned("id", Tables)).Get("/tables/{id}", okHandler)
+	mux.With(Owned("id", Questions)).Get("/questions/{id}", okHandler)
+	mux.With(User("id")).Get("/users/{id}", okHandler)
+
+	tt := []struct {
+		name  string
+		token string
+		path  string
+		code  int
+	}{
+		{"own client", owner, "/client/2", http.StatusOK},
+		{"foreign client", owner, "/client/3", http.StatusForbidden},
+		{"admin client", admin, "/client/3", http.StatusOK},
+		{"no token", "", "/client/1", http.StatusUnauthorized},
+		{"bad client", owner, "/client/one", http.StatusBadRequest},
+		{"own resource", owner, "/tables/1", http.StatusOK},
+		{"foreign resource", owner, "/tables/3", http.StatusForbidden},
+		{"unknown resource", owner, "/tables/0", http.StatusNotFound},
+		{"self", owner, "/users/1", http.StatusOK},
+		{"other user", owner, "/users/2", http.StatusForbidden},
+		{"admin user", admin, "/users/2", http.StatusOK},
+		{"device client", device, "/client/1", http.StatusOK},
+		{"device table", device, "/tables/1", http.StatusOK},
+		{"device other table", device, "/tables/10", http.StatusForbidden},
+		{"device question", device, "/questions/10", http.StatusOK},
+		{"revoked device", revoked, "/client/1", http.StatusUnauthorized},
+		{"revoked session", loggedOut, "/client/1", http.StatusUnauthorized},
+		{"no session", sessionless, "/client/1", http.StatusUnauthorized},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, tc.code, w.Code)
+		})
+	}
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
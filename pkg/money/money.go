@@ -0,0 +1,154 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Errors.
+var (
+	ErrInvalidAmount = errors.New("invalid amount")
+)
+
+// Rounding modes.
+const (
+	Nearest = "nearest"
+	Up      = "up"
+	Down    = "down"
+)
+
+// Amount is a money value in minor units, hundredths of the currency unit.
+// It is read and written as a decimal number, both in JSON and in the
+// database, so it can replace float prices without changing their format.
+type Amount int64
+
+// FromFloat returns the amount of a decimal value.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * 100))
+}
+
+// FromUnits returns the amount of a whole number of currency units.
+func FromUnits(units int64) Amount {
+	return Amount(units * 100)
+}
+
+// Parse returns the amount of a decimal string like 1250.5.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrInvalidAmount
+	}
+
+	return FromFloat(f), nil
+}
+
+// Float returns the amount as a decimal value.
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// String returns the amount as a decimal string with two decimals.
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// Mul returns the amount multiplied by a quantity.
+func (a Amount) Mul(quantity uint) Amount {
+	return a * Amount(quantity)
+}
+
+// Percent returns the percentage given of the amount, rounded to the nearest
+// minor unit.
+func (a Amount) Percent(percent uint) Amount {
+	return Amount(math.Round(float64(a) * float64(percent) / 100))
+}
+
+// Round returns the amount rounded to a multiple of the unit in the mode
+// given. A zero unit leaves the amount as it is.
+func (a Amount) Round(unit Amount, mode string) Amount {
+	if unit <= 0 {
+		return a
+	}
+
+	q := float64(a) / float64(unit)
+	switch mode {
+	case Up:
+		q = math.Ceil(q)
+	case Down:
+		q = math.Floor(q)
+	default:
+		q = math.Round(q)
+	}
+
+	return Amount(q) * unit
+}
+
+// MarshalJSON writes the amount as a decimal number.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads the amount from a decimal number or string.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		*a = 0
+		return nil
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = v
+	return nil
+}
+
+// Value writes the amount to the database as a decimal.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads the amount from a decimal, float or integer column.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case float64:
+		*a = FromFloat(v)
+	case int64:
+		*a = FromUnits(v)
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	default:
+		return ErrInvalidAmount
+	}
+
+	return nil
+}
+
+// scanString reads the amount from a decimal string.
+func (a *Amount) scanString(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = v
+	return nil
+}
@@ -0,0 +1,76 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "1250.50", Amount(125050).String())
+	assert.Equal(t, "0.05", Amount(5).String())
+	assert.Equal(t, "-3.10", Amount(-310).String())
+}
+
+func TestParse(t *testing.T) {
+	a, err := Parse("1250.5")
+	assert.Nil(t, err)
+	assert.Equal(t, Amount(125050), a)
+
+	a, err = Parse("0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, Amount(10), a)
+
+	_, err = Parse("ten")
+	assert.Equal(t, ErrInvalidAmount, err)
+}
+
+func TestPercent(t *testing.T) {
+	assert.Equal(t, Amount(1250), Amount(12500).Percent(10))
+	assert.Equal(t, Amount(2), Amount(15).Percent(10))
+	assert.Equal(t, Amount(0), Amount(12500).Percent(0))
+}
+
+func TestRound(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(Amount(35000), Amount(35249).Round(500, Nearest))
+	assert.Equal(Amount(35500), Amount(35250).Round(500, Nearest))
+	assert.Equal(Amount(35500), Amount(35001).Round(500, Up))
+	assert.Equal(Amount(35000), Amount(35499).Round(500, Down))
+	assert.Equal(Amount(35499), Amount(35499).Round(0, Up))
+}
+
+func TestJSON(t *testing.T) {
+	v := struct {
+		Price Amount `json:"price"`
+	}{}
+
+	err := json.Unmarshal([]byte(`{"price": 10.25}`), &v)
+	assert.Nil(t, err)
+	assert.Equal(t, Amount(1025), v.Price)
+
+	err = json.Unmarshal([]byte(`{"price": "7"}`), &v)
+	assert.Nil(t, err)
+	assert.Equal(t, Amount(700), v.Price)
+
+	j, err := json.Marshal(v)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"price":7.00}`, string(j))
+}
+
+func TestScan(t *testing.T) {
+	var a Amount
+
+	assert.Nil(t, a.Scan([]byte("12.34")))
+	assert.Equal(t, Amount(1234), a)
+
+	assert.Nil(t, a.Scan(int64(5)))
+	assert.Equal(t, Amount(500), a)
+
+	assert.Nil(t, a.Scan(2.5))
+	assert.Equal(t, Amount(250), a)
+
+	assert.Equal(t, ErrInvalidAmount, a.Scan(true))
+}
@@ -1,26 +1,66 @@
 package notification
 
 import (
+	"errors"
 	"time"
 
 	"gitlab.com/menuxd/api-rest/pkg/table"
 )
 
+// Errors.
+var (
+	ErrAlreadyAcked = errors.New("notification already acknowledged")
+)
+
 // Notification types.
 const (
 	CallWaiter uint = iota
 	GetCheck
 	MakeOrder
 	Connected
+	StatusChanged
 )
 
-// Notification is a message to send.
+// Storage handle the operations with Notifications.
+type Storage interface {
+	Create(n *Notification) error
+	Ack(id, waiterID uint) (Notification, error)
+	GetByID(id uint) (Notification, error)
+	GetPending(clientID, since uint) ([]Notification, error)
+}
+
+// Notification is a message to send. Those that need a waiter are stored
+// until one acknowledges them, so they can be replayed on reconnection.
 type Notification struct {
+	ID       uint         `gorm:"primary_key" json:"id,omitempty"`
 	Type     uint         `json:"type"`
 	Message  string       `json:"message,omitempty"`
 	Picture  string       `json:"picture"`
 	Date     time.Time    `json:"date"`
 	ClientID uint         `json:"clientId"`
 	Active   bool         `json:"active"`
-	Table    *table.Table `json:"table"`
+	TableID  uint         `json:"table_id,omitempty"`
+	Table    *table.Table `gorm:"save_associations:false" json:"table"`
+	OrderID  uint         `json:"order_id,omitempty"`
+	ItemID   uint         `json:"item_id,omitempty"`
+	Status   string       `json:"status,omitempty"`
+	AckedAt  *time.Time   `json:"acked_at,omitempty"`
+	AckedBy  *uint        `json:"acked_by,omitempty"`
+}
+
+// NeedsAck checks that the notification waits for a waiter to handle it.
+func (n Notification) NeedsAck() bool {
+	switch n.Type {
+	case CallWaiter, GetCheck, MakeOrder:
+		return true
+	}
+
+	return false
+}
+
+// Ack is the acknowledgement of a notification, received by the ack
+// endpoint or as a websocket message. The waiter is the one of the token, a
+// waiter_id sent along is ignored.
+type Ack struct {
+	ID uint `json:"id"`
 }
@@ -0,0 +1,15 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsAck(t *testing.T) {
+	assert.True(t, Notification{Type: CallWaiter}.NeedsAck())
+	assert.True(t, Notification{Type: GetCheck}.NeedsAck())
+	assert.True(t, Notification{Type: MakeOrder}.NeedsAck())
+	assert.False(t, Notification{Type: Connected}.NeedsAck())
+	assert.False(t, Notification{Type: StatusChanged}.NeedsAck())
+}
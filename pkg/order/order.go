@@ -2,6 +2,7 @@ package order
 
 import (
 	"errors"
+	"time"
 
 	"gitlab.com/menuxd/api-rest/pkg/dish"
 	"gitlab.com/menuxd/api-rest/pkg/model"
@@ -10,8 +11,11 @@ import (
 
 // Errors.
 var (
-	ErrParseFailer = errors.New("parse orders failed")
-	ErrInvalidType = errors.New("invalid type")
+	ErrParseFailer       = errors.New("parse orders failed")
+	ErrInvalidType       = errors.New("invalid type")
+	ErrInvalidStatus     = errors.New("invalid status")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrNotPatchable      = errors.New("the field of the item can't be patched")
 )
 
 // Storage handle Order's CRUD.
@@ -22,7 +26,13 @@ type Storage interface {
 	GetAll(clientID uint) ([]Order, error)
 	GetAllActive(clientID uint) ([][]Order, error)
 	GetByID(id uint) (Order, error)
+	GetByIDs(ids []uint) ([]Order, error)
+	GetUnbilled(tableID uint) ([]Order, error)
 	PatchItem(id uint, updates map[string]interface{}) error
+	TransitionItem(id uint, to Status) (Item, error)
+	AdvanceItem(id uint, to Status) (Item, error)
+	AdvanceItems(ids []uint, to Status) ([]Item, error)
+	TransitionOrder(id uint, to Status) (Order, error)
 }
 
 // Order is a Client's request.
@@ -33,6 +43,9 @@ type Order struct {
 	Table    *table.Table `json:"table"`
 	Canceled bool         `json:"canceled"`
 	Items    []Item       `json:"items"`
+	BillID   *uint        `json:"bill_id,omitempty"`
+	WaiterID *uint        `json:"waiter_id,omitempty"`
+	Lifecycle
 }
 
 // Item is a element to order.
@@ -41,13 +54,100 @@ type Item struct {
 	OrderID             uint                 `json:"order_id"`
 	Mount               uint                 `json:"mount"`
 	Active              bool                 `gorm:"default:true" json:"active"`
-	Ready               bool                 `gorm:"default:true" json:"ready"`
+	Ready               bool                 `json:"ready"`
 	DishID              uint                 `json:"dish_id"`
 	Ingredients         []dish.Ingredient    `json:"ingredients"`
 	SelectedIngredients []IngredientSelected `json:"selected_ingredients"`
 	Dish                *dish.Dish           `json:"dish,omitempty"`
 	Takeaway            bool                 `json:"takeaway"`
+	Half                bool                 `gorm:"default:false" json:"half"`
 	Locked              bool                 `gorm:"-" json:"locked,omitempty"`
+	Lifecycle
+}
+
+// Transition moves the order to the status given, keeping Canceled in sync.
+func (o *Order) Transition(to Status, at time.Time) error {
+	err := o.Lifecycle.Transition(to, at)
+	if err != nil {
+		return err
+	}
+
+	o.Canceled = o.Status == Cancelled || o.Status == Rejected
+
+	return nil
+}
+
+// Transition moves the item to the status given, keeping Active and Ready in
+// sync.
+func (i *Item) Transition(to Status, at time.Time) error {
+	err := i.Lifecycle.Transition(to, at)
+	if err != nil {
+		return err
+	}
+
+	i.sync()
+
+	return nil
+}
+
+// Advance moves the item forward along the happy path up to the status given,
+// keeping Active and Ready in sync.
+func (i *Item) Advance(to Status, at time.Time) error {
+	err := i.Lifecycle.Advance(to, at)
+	if err != nil {
+		return err
+	}
+
+	i.sync()
+
+	return nil
+}
+
+// sync derives Active and Ready from the status of the item.
+func (i *Item) sync() {
+	i.Active = i.Status != Cancelled && i.Status != Rejected
+	i.Ready = i.Status == Ready || i.Status == Served || i.Status == Paid
+}
+
+// DishIDs returns the IDs of the dishes of the items, the one of the dish
+// given first.
+func DishIDs(items []Item) []uint {
+	ids := []uint{}
+	for _, i := range items {
+		if i.Dish != nil {
+			ids = append(ids, i.Dish.ID)
+			continue
+		}
+
+		ids = append(ids, i.DishID)
+	}
+
+	return ids
+}
+
+// PatchCancels checks the patch of an item and returns whether it cancels
+// it. Only active can be patched, and only to false; readiness and the rest
+// of the status change through transitions.
+func PatchCancels(patch map[string]interface{}) (bool, error) {
+	cancels := false
+	for column, value := range patch {
+		if column != "active" {
+			return false, ErrNotPatchable
+		}
+
+		active, ok := value.(bool)
+		if !ok {
+			return false, ErrNotPatchable
+		}
+
+		if active {
+			return false, ErrInvalidTransition
+		}
+
+		cancels = true
+	}
+
+	return cancels, nil
 }
 
 // IngredientSelected is an ingredient selected in an order.
@@ -0,0 +1,167 @@
+package order
+
+import "time"
+
+// Status is a stage in the lifecycle of an order or an item.
+type Status string
+
+// Lifecycle statuses.
+const (
+	Received  Status = "received"
+	Accepted  Status = "accepted"
+	Preparing Status = "preparing"
+	Ready     Status = "ready"
+	Served    Status = "served"
+	Paid      Status = "paid"
+	Cancelled Status = "cancelled"
+	Rejected  Status = "rejected"
+)
+
+// flow is the happy path followed by an order, from the table to the bill.
+var flow = []Status{Received, Accepted, Preparing, Ready, Served, Paid}
+
+// transitions are the legal moves from each status.
+var transitions = map[Status][]Status{
+	Received:  {Accepted, Rejected, Cancelled},
+	Accepted:  {Preparing, Ready, Cancelled},
+	Preparing: {Ready, Cancelled},
+	Ready:     {Served, Cancelled},
+	Served:    {Paid},
+}
+
+// IsValid checks that the status is a known one.
+func (s Status) IsValid() bool {
+	switch s {
+	case Received, Accepted, Preparing, Ready, Served, Paid, Cancelled, Rejected:
+		return true
+	}
+
+	return false
+}
+
+// IsFinal checks that no transition is possible from the status.
+func (s Status) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// position returns the index of the status in the happy path or -1.
+func (s Status) position() int {
+	for i, f := range flow {
+		if f == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// CanTransition checks that moving from one status to another is legal.
+func CanTransition(from, to Status) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lifecycle keeps the status of an order or an item and when it got there.
+type Lifecycle struct {
+	Status      Status     `gorm:"default:'received'" json:"status"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	PreparingAt *time.Time `json:"preparing_at,omitempty"`
+	ReadyAt     *time.Time `json:"ready_at,omitempty"`
+	ServedAt    *time.Time `json:"served_at,omitempty"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	RejectedAt  *time.Time `json:"rejected_at,omitempty"`
+}
+
+// NewLifecycle returns a Lifecycle in the received status.
+func NewLifecycle() Lifecycle {
+	return Lifecycle{Status: Received}
+}
+
+// current returns the status, treating the empty one as received.
+func (l Lifecycle) current() Status {
+	if l.Status == "" {
+		return Received
+	}
+
+	return l.Status
+}
+
+// IsFinal checks that the lifecycle reached a status without transitions.
+func (l Lifecycle) IsFinal() bool {
+	return l.current().IsFinal()
+}
+
+// CanTransition checks that the lifecycle can move to the status given.
+func (l Lifecycle) CanTransition(to Status) bool {
+	return CanTransition(l.current(), to)
+}
+
+// Transition moves the lifecycle to the status given and stamps the time.
+func (l *Lifecycle) Transition(to Status, at time.Time) error {
+	if !to.IsValid() {
+		return ErrInvalidStatus
+	}
+
+	if !l.CanTransition(to) {
+		return ErrInvalidTransition
+	}
+
+	l.Status = to
+	l.stamp(to, at)
+
+	return nil
+}
+
+// Advance moves the lifecycle forward along the happy path until it reaches
+// the status given, stamping every intermediate step that is legal.
+func (l *Lifecycle) Advance(to Status, at time.Time) error {
+	from := l.current()
+	if from == to {
+		return nil
+	}
+
+	target := to.position()
+	if from.position() < 0 || target < 0 || target < from.position() {
+		return l.Transition(to, at)
+	}
+
+	for l.current() != to {
+		next := to
+		if !l.CanTransition(to) {
+			next = flow[l.current().position()+1]
+		}
+
+		err := l.Transition(next, at)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stamp records when the status was reached.
+func (l *Lifecycle) stamp(s Status, at time.Time) {
+	switch s {
+	case Accepted:
+		l.AcceptedAt = &at
+	case Preparing:
+		l.PreparingAt = &at
+	case Ready:
+		l.ReadyAt = &at
+	case Served:
+		l.ServedAt = &at
+	case Paid:
+		l.PaidAt = &at
+	case Cancelled:
+		l.CancelledAt = &at
+	case Rejected:
+		l.RejectedAt = &at
+	}
+}
@@ -0,0 +1,141 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	legal := [][2]Status{
+		{Received, Accepted},
+		{Received, Rejected},
+		{Accepted, Preparing},
+		{Preparing, Ready},
+		{Ready, Served},
+		{Served, Paid},
+		{Preparing, Cancelled},
+	}
+
+	assert := assert.New(t)
+
+	for _, tr := range legal {
+		assert.True(CanTransition(tr[0], tr[1]), "%s -> %s", tr[0], tr[1])
+	}
+}
+
+func TestCanTransitionFail(t *testing.T) {
+	illegal := [][2]Status{
+		{Received, Served},
+		{Ready, Preparing},
+		{Served, Cancelled},
+		{Paid, Received},
+		{Cancelled, Accepted},
+		{Rejected, Accepted},
+	}
+
+	assert := assert.New(t)
+
+	for _, tr := range illegal {
+		assert.False(CanTransition(tr[0], tr[1]), "%s -> %s", tr[0], tr[1])
+	}
+}
+
+func TestTransition(t *testing.T) {
+	l := NewLifecycle()
+	now := time.Now()
+
+	err := l.Transition(Accepted, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Accepted, l.Status)
+	assert.Equal(t, now, *l.AcceptedAt)
+}
+
+func TestTransitionFail(t *testing.T) {
+	l := NewLifecycle()
+
+	assert.Equal(t, ErrInvalidTransition, l.Transition(Paid, time.Now()))
+	assert.Equal(t, ErrInvalidStatus, l.Transition(Status("lost"), time.Now()))
+	assert.Equal(t, Received, l.Status)
+}
+
+func TestTransitionEmpty(t *testing.T) {
+	l := Lifecycle{}
+
+	assert.Nil(t, l.Transition(Accepted, time.Now()))
+}
+
+func TestAdvance(t *testing.T) {
+	l := NewLifecycle()
+
+	err := l.Advance(Ready, time.Now())
+
+	assert.Nil(t, err)
+	assert.Equal(t, Ready, l.Status)
+	assert.NotNil(t, l.AcceptedAt)
+	assert.Nil(t, l.PreparingAt)
+	assert.NotNil(t, l.ReadyAt)
+}
+
+func TestAdvanceFail(t *testing.T) {
+	l := Lifecycle{Status: Served}
+
+	assert.Equal(t, ErrInvalidTransition, l.Advance(Ready, time.Now()))
+}
+
+func TestItemTransition(t *testing.T) {
+	i := Item{Active: true}
+
+	err := i.Transition(Cancelled, time.Now())
+
+	assert.Nil(t, err)
+	assert.False(t, i.Active)
+	assert.NotNil(t, i.CancelledAt)
+}
+
+func TestItemReady(t *testing.T) {
+	i := Item{Active: true, Lifecycle: NewLifecycle()}
+
+	assert.Nil(t, i.Advance(Preparing, time.Now()))
+	assert.False(t, i.Ready)
+
+	assert.Nil(t, i.Advance(Ready, time.Now()))
+	assert.True(t, i.Ready)
+
+	assert.Nil(t, i.Transition(Served, time.Now()))
+	assert.True(t, i.Ready)
+	assert.True(t, i.Active)
+}
+
+func TestPatchCancels(t *testing.T) {
+	cancels, err := PatchCancels(map[string]interface{}{"active": false})
+	assert.Nil(t, err)
+	assert.True(t, cancels)
+
+	cancels, err = PatchCancels(map[string]interface{}{})
+	assert.Nil(t, err)
+	assert.False(t, cancels)
+
+	_, err = PatchCancels(map[string]interface{}{"active": true})
+	assert.Equal(t, ErrInvalidTransition, err)
+
+	for _, patch := range []map[string]interface{}{
+		{"ready": true},
+		{"active": "false"},
+		{"active": false, "mount": 2},
+	} {
+		_, err = PatchCancels(patch)
+		assert.Equal(t, ErrNotPatchable, err)
+	}
+}
+
+func TestOrderTransition(t *testing.T) {
+	o := Order{}
+
+	err := o.Transition(Rejected, time.Now())
+
+	assert.Nil(t, err)
+	assert.True(t, o.Canceled)
+}
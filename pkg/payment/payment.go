@@ -0,0 +1,153 @@
+package payment
+
+import (
+	"errors"
+
+	"gitlab.com/menuxd/api-rest/pkg/model"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+)
+
+// Errors.
+var (
+	ErrInvalidMethod      = errors.New("invalid payment method")
+	ErrInvalidAmount      = errors.New("invalid amount")
+	ErrInsufficientTender = errors.New("tendered amount is not enough")
+	ErrOverpayment        = errors.New("amount exceeds the balance")
+	ErrRefundExceeded     = errors.New("amount exceeds the refundable balance")
+	ErrPartRequired       = errors.New("the bill is split, a part is required")
+	ErrPartNotFound       = errors.New("the part does not belong to the bill")
+	ErrPaymentNotFound    = errors.New("the payment does not belong to the bill")
+)
+
+// Payment methods.
+const (
+	Cash     = "cash"
+	Card     = "card"
+	Transfer = "transfer"
+	Voucher  = "voucher"
+)
+
+// Payment kinds.
+const (
+	Charge = "charge"
+	Refund = "refund"
+)
+
+// Storage handle the CRUD operations with Payments.
+type Storage interface {
+	Create(p *Payment) error
+	GetByBill(billID uint) (Payments, error)
+	GetByID(id uint) (Payment, error)
+}
+
+// Payment is money received for a bill, or given back in a refund.
+type Payment struct {
+	model.Model
+	BillID   uint         `bson:"bill_id" json:"bill_id"`
+	PartID   *uint        `bson:"part_id" json:"part_id,omitempty"`
+	ClientID uint         `bson:"client_id" json:"client_id"`
+	Kind     string       `gorm:"default:'charge'" bson:"kind" json:"kind"`
+	Method   string       `bson:"method" json:"method"`
+	Amount   money.Amount `gorm:"type:numeric(14,2)" bson:"amount" json:"amount"`
+	Tendered money.Amount `gorm:"type:numeric(14,2)" bson:"tendered" json:"tendered"`
+	Change   money.Amount `gorm:"type:numeric(14,2)" bson:"change" json:"change"`
+	Tip      money.Amount `gorm:"type:numeric(14,2)" bson:"tip" json:"tip"`
+	WaiterID *uint        `bson:"waiter_id" json:"waiter_id,omitempty"`
+	RefundOf *uint        `bson:"refund_of" json:"refund_of,omitempty"`
+	Note     string       `bson:"note" json:"note,omitempty"`
+}
+
+// IsValidMethod checks that the method is a known one.
+func (p Payment) IsValidMethod() bool {
+	switch p.Method {
+	case Cash, Card, Transfer, Voucher:
+		return true
+	}
+
+	return false
+}
+
+// IsRefund checks that the payment gives money back.
+func (p Payment) IsRefund() bool {
+	return p.Kind == Refund
+}
+
+// Net returns the amount applied to the balance of the bill, negative for
+// refunds.
+func (p Payment) Net() money.Amount {
+	if p.IsRefund() {
+		return -p.Amount
+	}
+
+	return p.Amount
+}
+
+// Prepare validates the payment and computes the change. Only cash can be
+// tendered over the amount and the tip; other methods are charged exactly.
+func (p *Payment) Prepare() error {
+	if p.Kind == "" {
+		p.Kind = Charge
+	}
+
+	if p.Kind != Charge && p.Kind != Refund {
+		return ErrInvalidAmount
+	}
+
+	if !p.IsValidMethod() {
+		return ErrInvalidMethod
+	}
+
+	if p.Amount <= 0 || p.Tip < 0 || p.Tendered < 0 {
+		return ErrInvalidAmount
+	}
+
+	if p.IsRefund() {
+		p.Tip = 0
+		p.Tendered = p.Amount
+		p.Change = 0
+		return nil
+	}
+
+	due := p.Amount + p.Tip
+	if p.Method != Cash || p.Tendered == 0 {
+		p.Tendered = due
+	}
+
+	if p.Tendered < due {
+		return ErrInsufficientTender
+	}
+
+	p.Change = p.Tendered - due
+
+	return nil
+}
+
+// Payments alias for a slice of Payments.
+type Payments []Payment
+
+// Balance returns the amount paid, refunds discounted.
+func (ps Payments) Balance() money.Amount {
+	var balance money.Amount
+	for _, p := range ps {
+		balance += p.Net()
+	}
+
+	return balance
+}
+
+// Refunded returns the amount refunded of the payment by ID.
+func (ps Payments) Refunded(id uint) money.Amount {
+	var refunded money.Amount
+	for _, p := range ps {
+		if p.IsRefund() && p.RefundOf != nil && *p.RefundOf == id {
+			refunded += p.Amount
+		}
+	}
+
+	return refunded
+}
+
+// New returns a instance of Payment with default configuration.
+func New() *Payment {
+	return &Payment{Kind: Charge}
+}
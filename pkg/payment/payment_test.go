@@ -0,0 +1,55 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+)
+
+func TestPrepareCash(t *testing.T) {
+	p := Payment{Method: Cash, Amount: 85000, Tip: 5000, Tendered: 100000}
+
+	err := p.Prepare()
+
+	assert.Nil(t, err)
+	assert.Equal(t, Charge, p.Kind)
+	assert.Equal(t, money.Amount(10000), p.Change)
+}
+
+func TestPrepareCard(t *testing.T) {
+	p := Payment{Method: Card, Amount: 85000, Tip: 5000, Tendered: 100000}
+
+	err := p.Prepare()
+
+	assert.Nil(t, err)
+	assert.Equal(t, money.Amount(90000), p.Tendered)
+	assert.Equal(t, money.Amount(0), p.Change)
+}
+
+func TestPrepareFail(t *testing.T) {
+	payments := map[error]Payment{
+		ErrInvalidMethod:      {Method: "cheque", Amount: 100},
+		ErrInvalidAmount:      {Method: Cash},
+		ErrInsufficientTender: {Method: Cash, Amount: 100, Tendered: 50},
+	}
+
+	for expected, p := range payments {
+		assert.Equal(t, expected, p.Prepare())
+	}
+}
+
+func TestBalance(t *testing.T) {
+	var refundOf uint = 1
+	charge := Payment{Kind: Charge, Amount: 100}
+	charge.ID = 1
+	payments := Payments{
+		charge,
+		{Kind: Charge, Amount: 50},
+		{Kind: Refund, Amount: 30, RefundOf: &refundOf},
+	}
+
+	assert.Equal(t, money.Amount(120), payments.Balance())
+	assert.Equal(t, money.Amount(30), payments.Refunded(1))
+	assert.Equal(t, money.Amount(0), payments.Refunded(2))
+}
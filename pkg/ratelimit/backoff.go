@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Backoff locks a key out once it fails a number of times, for a time that
+// doubles with each failure after that, up to a limit. A key that doesn't
+// fail for the limit is forgotten.
+type Backoff struct {
+	max     int
+	base    time.Duration
+	limit   time.Duration
+	mu      sync.Mutex
+	entries map[string]*failures
+	now     func() time.Time
+}
+
+// failures are the failures of a key and until when it is locked.
+type failures struct {
+	count int
+	last  time.Time
+	until time.Time
+}
+
+// NewBackoff returns a backoff that locks a key for base after max
+// failures, doubling up to limit.
+func NewBackoff(max int, base, limit time.Duration) *Backoff {
+	return &Backoff{
+		max:     max,
+		base:    base,
+		limit:   limit,
+		entries: make(map[string]*failures),
+		now:     time.Now,
+	}
+}
+
+// Locked checks whether the key is locked, and for how long.
+func (b *Backoff) Locked(key string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	f, ok := b.entries[key]
+	if !ok || !now.Before(f.until) {
+		return false, 0
+	}
+
+	return true, f.until.Sub(now)
+}
+
+// Fail counts a failure of the key. It returns whether the failure locks
+// the key, and for how long.
+func (b *Backoff) Fail(key string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+
+	f, ok := b.entries[key]
+	if !ok {
+		f = &failures{}
+		b.entries[key] = f
+	}
+
+	f.count++
+	f.last = now
+	if f.count < b.max {
+		return false, 0
+	}
+
+	wait := b.base
+	for i := b.max; i < f.count && wait < b.limit; i++ {
+		wait *= 2
+	}
+
+	if wait > b.limit {
+		wait = b.limit
+	}
+
+	f.until = now.Add(wait)
+	return true, wait
+}
+
+// Reset forgets the failures of a key, after it succeeds.
+func (b *Backoff) Reset(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, key)
+}
+
+// sweep removes the keys that didn't fail for the limit and are not locked,
+// the lock taken.
+func (b *Backoff) sweep(now time.Time) {
+	for k, f := range b.entries {
+		if now.Sub(f.last) >= b.limit && !now.Before(f.until) {
+			delete(b.entries, k)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBackoff(3, time.Minute, 10*time.Minute)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		locked, _ := b.Fail("account:ana")
+		assert.False(t, locked)
+	}
+
+	locked, _ := b.Locked("account:ana")
+	assert.False(t, locked)
+
+	// The third failure locks for the base, and each one after doubles it.
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute} {
+		locked, wait := b.Fail("account:ana")
+		assert.True(t, locked)
+		assert.Equal(t, want, wait)
+	}
+
+	locked, wait := b.Locked("account:ana")
+	assert.True(t, locked)
+	assert.Equal(t, 10*time.Minute, wait)
+
+	locked, _ = b.Locked("account:bob")
+	assert.False(t, locked)
+
+	now = now.Add(10 * time.Minute)
+	locked, _ = b.Locked("account:ana")
+	assert.False(t, locked)
+
+	// After a quiet limit the failures are forgotten.
+	now = now.Add(time.Second)
+	locked, _ = b.Fail("account:ana")
+	assert.False(t, locked)
+
+	b.Reset("account:ana")
+	locked, _ = b.Locked("account:ana")
+	assert.False(t, locked)
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows a number of attempts by key in a window of time.
+type Limiter struct {
+	max     int
+	window  time.Duration
+	mu      sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+// entry are the attempts of a key since the start of its window.
+type entry struct {
+	count int
+	start time.Time
+}
+
+// New returns a limiter of max attempts by key each window.
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{
+		max:     max,
+		window:  window,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// Allow counts an attempt of the key, and checks that it is under the limit.
+// If not, it returns the time left until the next attempt is allowed.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &entry{start: now}
+		l.entries[key] = e
+	}
+
+	if e.count >= l.max {
+		return false, e.start.Add(l.window).Sub(now)
+	}
+
+	e.count++
+	return true, 0
+}
+
+// Reset forgets the attempts of a key, after one succeeds.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// sweep removes the keys whose window is over, the lock taken.
+func (l *Limiter) sweep(now time.Time) {
+	for k, e := range l.entries {
+		if now.Sub(e.start) >= l.window {
+			delete(l.entries, k)
+		}
+	}
+}
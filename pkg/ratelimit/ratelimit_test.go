@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	l := New(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("client:1")
+		assert.True(t, ok)
+	}
+
+	ok, wait := l.Allow("client:1")
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, wait)
+
+	ok, _ = l.Allow("client:2")
+	assert.True(t, ok)
+
+	now = now.Add(time.Minute)
+	ok, _ = l.Allow("client:1")
+	assert.True(t, ok)
+}
+
+func TestReset(t *testing.T) {
+	l := New(1, time.Minute)
+
+	ok, _ := l.Allow("client:1")
+	assert.True(t, ok)
+
+	l.Reset("client:1")
+	ok, _ = l.Allow("client:1")
+	assert.True(t, ok)
+}
@@ -0,0 +1,302 @@
+package register
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/model"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+)
+
+// Errors.
+var (
+	ErrAlreadyOpen   = errors.New("the register is already open")
+	ErrAlreadyClosed = errors.New("the register is already closed")
+)
+
+// Storage handle the operations with register Sessions.
+type Storage interface {
+	Open(s *Session) error
+	Close(id uint, counted money.Amount, note string) (Report, error)
+	GetAll(clientID uint) (Sessions, error)
+	GetByID(id uint) (Session, error)
+	GetOpen(clientID uint) (Session, error)
+	Report(id uint) (Report, error)
+}
+
+// Session is the time a cash register is open, from the opening cash to the
+// cash counted at the close.
+type Session struct {
+	model.Model
+	ClientID     uint         `bson:"client_id" json:"client_id"`
+	OpenedAt     time.Time    `bson:"opened_at" json:"opened_at"`
+	ClosedAt     *time.Time   `bson:"closed_at" json:"closed_at,omitempty"`
+	OpeningCash  money.Amount `gorm:"type:numeric(14,2)" bson:"opening_cash" json:"opening_cash"`
+	ExpectedCash money.Amount `gorm:"type:numeric(14,2)" bson:"expected_cash" json:"expected_cash"`
+	CountedCash  money.Amount `gorm:"type:numeric(14,2)" bson:"counted_cash" json:"counted_cash"`
+	Difference   money.Amount `gorm:"type:numeric(14,2)" bson:"difference" json:"difference"`
+	Note         string       `bson:"note" json:"note,omitempty"`
+}
+
+// TableName keeps the sessions of the register apart from other sessions.
+func (Session) TableName() string {
+	return "register_sessions"
+}
+
+// IsOpen checks that the session has not been closed.
+func (s Session) IsOpen() bool {
+	return s.ClosedAt == nil
+}
+
+// Window returns the time the session covers, until now while it is open.
+func (s Session) Window(now time.Time) (from, to time.Time) {
+	if s.ClosedAt != nil {
+		return s.OpenedAt, *s.ClosedAt
+	}
+
+	return s.OpenedAt, now
+}
+
+// Sessions alias for a slice of Sessions.
+type Sessions []Session
+
+// MethodTotal is the money taken with a payment method.
+type MethodTotal struct {
+	Method   string       `json:"method"`
+	Count    int          `json:"count"`
+	Charged  money.Amount `json:"charged"`
+	Refunded money.Amount `json:"refunded"`
+	Tips     money.Amount `json:"tips"`
+	Net      money.Amount `json:"net"`
+}
+
+// Report is the Z report of a session.
+type Report struct {
+	SessionID          uint          `json:"session_id"`
+	ClientID           uint          `json:"client_id"`
+	Timezone           string        `json:"timezone"`
+	Date               string        `json:"date"`
+	OpenedAt           time.Time     `json:"opened_at"`
+	ClosedAt           *time.Time    `json:"closed_at,omitempty"`
+	Bills              int           `json:"bills"`
+	Sales              money.Amount  `json:"sales"`
+	ServiceCharge      money.Amount  `json:"service_charge"`
+	Tax                money.Amount  `json:"tax"`
+	Methods            []MethodTotal `json:"methods"`
+	Charged            money.Amount  `json:"charged"`
+	Refunds            int           `json:"refunds"`
+	Refunded           money.Amount  `json:"refunded"`
+	Tips               money.Amount  `json:"tips"`
+	Voids              int           `json:"voids"`
+	VoidsValue         money.Amount  `json:"voids_value"`
+	Cancellations      int           `json:"cancellations"`
+	CancellationsValue money.Amount  `json:"cancellations_value"`
+	OpeningCash        money.Amount  `json:"opening_cash"`
+	ExpectedCash       money.Amount  `json:"expected_cash"`
+	CountedCash        money.Amount  `json:"counted_cash"`
+	Difference         money.Amount  `json:"difference"`
+}
+
+// methods is the order in which payment methods are listed.
+var methods = []string{payment.Cash, payment.Card, payment.Transfer, payment.Voucher}
+
+// Build returns the Z report of a session from the bills, payments and
+// orders of its client, leaving out those outside the session. Dates are
+// shown in the location given.
+func Build(
+	s Session,
+	loc *time.Location,
+	bills bill.Bills,
+	payments payment.Payments,
+	orders []order.Order,
+	now time.Time,
+) Report {
+	from, to := s.Window(now)
+	within := func(t time.Time) bool {
+		return !t.Before(from) && !t.After(to)
+	}
+
+	r := Report{
+		SessionID:   s.ID,
+		ClientID:    s.ClientID,
+		Timezone:    loc.String(),
+		Date:        s.OpenedAt.In(loc).Format("2006-01-02"),
+		OpenedAt:    s.OpenedAt.In(loc),
+		OpeningCash: s.OpeningCash,
+		Methods:     []MethodTotal{},
+	}
+
+	if s.ClosedAt != nil {
+		closedAt := s.ClosedAt.In(loc)
+		r.ClosedAt = &closedAt
+	}
+
+	for _, b := range bills {
+		if !within(b.CreatedAt) {
+			continue
+		}
+
+		r.Bills++
+		r.Sales += b.Value
+		r.ServiceCharge += b.ServiceCharge
+		r.Tax += b.Tax
+	}
+
+	listed := append([]string{}, methods...)
+	totals := make(map[string]*MethodTotal)
+	for _, m := range listed {
+		totals[m] = &MethodTotal{Method: m}
+	}
+
+	for _, p := range payments {
+		if !within(p.CreatedAt) {
+			continue
+		}
+
+		t, ok := totals[p.Method]
+		if !ok {
+			t = &MethodTotal{Method: p.Method}
+			totals[p.Method] = t
+			listed = append(listed, p.Method)
+		}
+
+		if p.IsRefund() {
+			r.Refunds++
+			r.Refunded += p.Amount
+			t.Refunded += p.Amount
+		} else {
+			t.Count++
+			r.Charged += p.Amount
+			r.Tips += p.Tip
+			t.Charged += p.Amount
+			t.Tips += p.Tip
+		}
+		t.Net += p.Net() + p.Tip
+	}
+
+	for _, m := range listed {
+		t := totals[m]
+		if t.Count == 0 && t.Refunded == 0 {
+			continue
+		}
+
+		r.Methods = append(r.Methods, *t)
+	}
+
+	for _, o := range orders {
+		if o.Canceled {
+			if !within(cancelledAt(o.Lifecycle, o.UpdatedAt)) {
+				continue
+			}
+
+			r.Cancellations++
+			for _, i := range o.Items {
+				if i.Dish != nil {
+					r.CancellationsValue += bill.NewLine(o.ID, i).Total
+				}
+			}
+			continue
+		}
+
+		for _, i := range o.Items {
+			if i.Status != order.Cancelled && i.Status != order.Rejected {
+				continue
+			}
+
+			if !within(cancelledAt(i.Lifecycle, i.UpdatedAt)) {
+				continue
+			}
+
+			r.Voids++
+			if i.Dish != nil {
+				r.VoidsValue += bill.NewLine(o.ID, i).Total
+			}
+		}
+	}
+
+	r.ExpectedCash = s.OpeningCash + totals[payment.Cash].Net
+	if !s.IsOpen() {
+		r.CountedCash = s.CountedCash
+		r.Difference = s.CountedCash - r.ExpectedCash
+	}
+
+	return r
+}
+
+// cancelledAt returns when an order or item was cancelled or rejected, the
+// last update for those cancelled before the lifecycle was recorded.
+func cancelledAt(l order.Lifecycle, updatedAt time.Time) time.Time {
+	switch {
+	case l.CancelledAt != nil:
+		return *l.CancelledAt
+	case l.RejectedAt != nil:
+		return *l.RejectedAt
+	}
+
+	return updatedAt
+}
+
+// methodNames are the printed names of the payment methods.
+var methodNames = map[string]string{
+	payment.Cash:     "Efectivo",
+	payment.Card:     "Tarjeta",
+	payment.Transfer: "Transferencia",
+	payment.Voucher:  "Vale",
+}
+
+// Text returns the report as printable text, for a receipt printer.
+func (r Report) Text() string {
+	const width = 40
+
+	var sb strings.Builder
+	line := func(label string, value interface{}) {
+		v := fmt.Sprint(value)
+		pad := width - len([]rune(label)) - len([]rune(v))
+		if pad < 1 {
+			pad = 1
+		}
+		sb.WriteString(label + strings.Repeat(" ", pad) + v + "\n")
+	}
+	rule := func() {
+		sb.WriteString(strings.Repeat("-", width) + "\n")
+	}
+
+	sb.WriteString("CIERRE Z\n")
+	line("Caja", r.SessionID)
+	line("Fecha", r.Date)
+	line("Apertura", r.OpenedAt.Format("15:04"))
+	if r.ClosedAt != nil {
+		line("Cierre", r.ClosedAt.Format("15:04"))
+	}
+	rule()
+	line("Facturas", r.Bills)
+	line("Ventas", r.Sales)
+	line("Servicio", r.ServiceCharge)
+	line("IVA", r.Tax)
+	rule()
+	for _, m := range r.Methods {
+		name, ok := methodNames[m.Method]
+		if !ok {
+			name = m.Method
+		}
+		line(fmt.Sprintf("%s (%d)", name, m.Count), m.Net)
+	}
+	line("Propinas", r.Tips)
+	line(fmt.Sprintf("Devoluciones (%d)", r.Refunds), r.Refunded)
+	line(fmt.Sprintf("Anulaciones (%d)", r.Voids), r.VoidsValue)
+	line(fmt.Sprintf("Cancelaciones (%d)", r.Cancellations), r.CancellationsValue)
+	rule()
+	line("Efectivo inicial", r.OpeningCash)
+	line("Efectivo esperado", r.ExpectedCash)
+	if r.ClosedAt != nil {
+		line("Efectivo contado", r.CountedCash)
+		line("Diferencia", r.Difference)
+	}
+
+	return sb.String()
+}
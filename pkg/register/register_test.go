@@ -0,0 +1,122 @@
+package register
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/bill"
+	"gitlab.com/menuxd/api-rest/pkg/dish"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+	"gitlab.com/menuxd/api-rest/pkg/order"
+	"gitlab.com/menuxd/api-rest/pkg/payment"
+)
+
+func newPayment(at time.Time, kind, method string, amount, tip money.Amount) payment.Payment {
+	p := payment.Payment{Kind: kind, Method: method, Amount: amount, Tip: tip}
+	p.CreatedAt = at
+
+	return p
+}
+
+func newItem(status order.Status, price money.Amount, at time.Time) order.Item {
+	d := &dish.Dish{}
+	d.Price = price
+
+	i := order.Item{Active: true, Mount: 1, Dish: d}
+	i.Status = status
+	if status == order.Cancelled {
+		i.CancelledAt = &at
+	}
+
+	return i
+}
+
+func TestBuild(t *testing.T) {
+	loc, _ := time.LoadLocation("America/Asuncion")
+	openedAt := time.Date(2019, 6, 1, 1, 0, 0, 0, time.UTC)
+	closedAt := openedAt.Add(8 * time.Hour)
+	during := openedAt.Add(time.Hour)
+	before := openedAt.Add(-time.Hour)
+
+	s := Session{OpeningCash: 10000, CountedCash: 54000, ClosedAt: &closedAt}
+	s.OpenedAt = openedAt
+
+	b := bill.Bill{Value: 60000, Tax: 5000}
+	b.CreatedAt = during
+	old := bill.Bill{Value: 1000}
+	old.CreatedAt = before
+
+	payments := payment.Payments{
+		newPayment(during, payment.Charge, payment.Cash, 40000, 2000),
+		newPayment(during, payment.Charge, payment.Card, 20000, 1000),
+		newPayment(during, payment.Refund, payment.Cash, 5000, 0),
+		newPayment(before, payment.Charge, payment.Cash, 9000, 0),
+	}
+
+	cancelled := order.Order{Canceled: true, Items: []order.Item{newItem(order.Cancelled, 15000, during)}}
+	cancelled.CancelledAt = &during
+	orders := []order.Order{
+		cancelled,
+		{Items: []order.Item{
+			newItem(order.Cancelled, 7000, during),
+			newItem(order.Cancelled, 3000, before),
+			newItem(order.Served, 20000, during),
+		}},
+	}
+
+	r := Build(s, loc, bill.Bills{b, old}, payments, orders, time.Now())
+
+	assert := assert.New(t)
+	assert.Equal("2019-05-31", r.Date)
+	assert.Equal(1, r.Bills)
+	assert.Equal(money.Amount(60000), r.Sales)
+	assert.Equal(money.Amount(5000), r.Tax)
+	assert.Equal(money.Amount(60000), r.Charged)
+	assert.Equal(money.Amount(3000), r.Tips)
+	assert.Equal(1, r.Refunds)
+	assert.Equal(money.Amount(5000), r.Refunded)
+	assert.Len(r.Methods, 2)
+	assert.Equal(payment.Cash, r.Methods[0].Method)
+	assert.Equal(money.Amount(37000), r.Methods[0].Net)
+	assert.Equal(1, r.Voids)
+	assert.Equal(money.Amount(7000), r.VoidsValue)
+	assert.Equal(1, r.Cancellations)
+	assert.Equal(money.Amount(15000), r.CancellationsValue)
+	assert.Equal(money.Amount(47000), r.ExpectedCash)
+	assert.Equal(money.Amount(7000), r.Difference)
+}
+
+func TestBuildOpen(t *testing.T) {
+	s := Session{OpeningCash: 10000, CountedCash: 99}
+	s.OpenedAt = time.Now().Add(-time.Hour)
+
+	r := Build(s, time.UTC, nil, nil, nil, time.Now())
+
+	assert.Nil(t, r.ClosedAt)
+	assert.Equal(t, money.Amount(10000), r.ExpectedCash)
+	assert.Equal(t, money.Amount(0), r.Difference)
+}
+
+func TestText(t *testing.T) {
+	closedAt := time.Date(2019, 6, 1, 22, 30, 0, 0, time.UTC)
+	r := Report{
+		SessionID:    3,
+		Date:         "2019-06-01",
+		ClosedAt:     &closedAt,
+		Methods:      []MethodTotal{{Method: payment.Cash, Count: 2, Net: 45000}},
+		ExpectedCash: 55000,
+		CountedCash:  54000,
+		Difference:   -1000,
+	}
+
+	text := r.Text()
+
+	assert := assert.New(t)
+	assert.True(strings.HasPrefix(text, "CIERRE Z\n"))
+	assert.Contains(text, "Efectivo (2)")
+	assert.Contains(text, "450.00\n")
+	assert.Contains(text, "Diferencia")
+	assert.Contains(text, "-10.00\n")
+}
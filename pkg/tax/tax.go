@@ -0,0 +1,62 @@
+package tax
+
+import (
+	"gitlab.com/menuxd/api-rest/pkg/money"
+)
+
+// Tax categories of the Paraguayan VAT.
+const (
+	VAT10  = "vat10"
+	VAT5   = "vat5"
+	Exempt = "exempt"
+)
+
+// Rates is the order in which tax categories are listed.
+var Rates = []string{VAT10, VAT5, Exempt}
+
+// IsValid checks that the tax category is a known one. An empty category
+// means the standard rate.
+func IsValid(rate string) bool {
+	switch rate {
+	case "", VAT10, VAT5, Exempt:
+		return true
+	}
+
+	return false
+}
+
+// Normalize returns the tax category, the standard rate when empty.
+func Normalize(rate string) string {
+	if rate == "" {
+		return VAT10
+	}
+
+	return rate
+}
+
+// Percent returns the percentage of the tax category.
+func Percent(rate string) int64 {
+	switch Normalize(rate) {
+	case VAT10:
+		return 10
+	case VAT5:
+		return 5
+	}
+
+	return 0
+}
+
+// Split returns the net amount and the tax included in a gross amount.
+func Split(gross money.Amount, rate string) (net, tax money.Amount) {
+	p := Percent(rate)
+	if p == 0 {
+		return gross, 0
+	}
+
+	tax = money.Amount((int64(gross)*p*2 + (100 + p)) / ((100 + p) * 2))
+	if gross < 0 {
+		tax = -money.Amount((-int64(gross)*p*2 + (100 + p)) / ((100 + p) * 2))
+	}
+
+	return gross - tax, tax
+}
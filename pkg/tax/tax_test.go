@@ -0,0 +1,34 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/menuxd/api-rest/pkg/money"
+)
+
+func TestIsValid(t *testing.T) {
+	assert.True(t, IsValid(""))
+	assert.True(t, IsValid(VAT5))
+	assert.False(t, IsValid("vat21"))
+}
+
+func TestSplit(t *testing.T) {
+	assert := assert.New(t)
+
+	net, tax := Split(11000, VAT10)
+	assert.Equal(money.Amount(10000), net)
+	assert.Equal(money.Amount(1000), tax)
+
+	net, tax = Split(10500, VAT5)
+	assert.Equal(money.Amount(10000), net)
+	assert.Equal(money.Amount(500), tax)
+
+	net, tax = Split(10000, Exempt)
+	assert.Equal(money.Amount(10000), net)
+	assert.Equal(money.Amount(0), tax)
+
+	net, tax = Split(10000, "")
+	assert.Equal(money.Amount(909), tax)
+	assert.Equal(money.Amount(10000), net+tax)
+}
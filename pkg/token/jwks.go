@@ -0,0 +1,86 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sort"
+)
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a set of JSON Web Keys.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verify the tokens, so other services
+// can validate them. HMAC keys are secret and left out.
+func (s *Service) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range s.keys {
+		jwk := JWK{Use: "sig", Alg: k.Method.Alg(), Kid: k.ID}
+
+		switch pub := k.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeInt(pub.N, 0)
+			jwk.E = encodeInt(big.NewInt(int64(pub.E)), 0)
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = encodeInt(pub.X, size)
+			jwk.Y = encodeInt(pub.Y, size)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+// JWKSHandler responds the JWKS of the service.
+func (s *Service) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	j, err := json.Marshal(s.JWKS())
+	if err != nil {
+		http.Error(w, "Failed to parse keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(j)
+}
+
+// encodeInt returns the base64url encoding of the big-endian bytes of n,
+// left padded to size.
+func encodeInt(n *big.Int, size int) string {
+	b := n.Bytes()
+	if len(b) < size {
+		b = append(make([]byte, size-len(b)), b...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
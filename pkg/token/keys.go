@@ -0,0 +1,136 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ErrUnsupportedKey is returned for keys that are not RSA nor ECDSA P-256.
+var ErrUnsupportedKey = errors.New("the key is not RSA nor ECDSA P-256")
+
+// Load returns a service that signs with the key of the file signing in the
+// directory, and verifies with every key in it. To rotate, add the new key,
+// sign with it and keep the old one, or only its public key, until its
+// tokens expire. The secret, if not empty, verifies the tokens without kid.
+func Load(dir, signing string, secret []byte) (*Service, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{keys: map[string]Key{}}
+	if len(secret) != 0 {
+		s.legacy = &Key{Method: jwt.SigningMethodHS256, verify: secret}
+	}
+
+	found := false
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := ParseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+
+		if _, ok := s.keys[k.ID]; !ok || k.sign != nil {
+			s.keys[k.ID] = k
+		}
+
+		if f.Name() == signing {
+			if k.sign == nil {
+				return nil, fmt.Errorf("%s: not a private key", f.Name())
+			}
+
+			s.signing = k
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("the signing key %s is not in %s", signing, dir)
+	}
+
+	return s, nil
+}
+
+// ParseKey returns the key of a PEM encoded RSA or ECDSA P-256 key, private
+// or public. Its ID is derived from the public key.
+func ParseKey(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+
+	var (
+		priv interface{}
+		pub  interface{}
+		err  error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unknown PEM type %s", block.Type)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		pub = &p.PublicKey
+	case *ecdsa.PrivateKey:
+		pub = &p.PublicKey
+	}
+
+	k := Key{sign: priv, verify: pub}
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		k.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if p.Curve != elliptic.P256() {
+			return Key{}, ErrUnsupportedKey
+		}
+
+		k.Method = jwt.SigningMethodES256
+	default:
+		return Key{}, ErrUnsupportedKey
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return Key{}, err
+	}
+
+	sum := sha256.Sum256(der)
+	k.ID = base64.RawURLEncoding.EncodeToString(sum[:16])
+
+	return k, nil
+}
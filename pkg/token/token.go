@@ -0,0 +1,187 @@
+package token
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+)
+
+// Errors.
+var (
+	ErrUnknownKey    = errors.New("the key of the token is unknown")
+	ErrUnexpectedAlg = errors.New("the token is not signed with the algorithm of its key")
+	ErrNoExpiry      = errors.New("the token has no expiry")
+)
+
+// Key is a key to sign or verify tokens. Keys that only verify have no
+// signing key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// Service signs the tokens of the API with one key, and verifies them with
+// any of the keys it knows by the kid of their header. Tokens without kid
+// are verified with the legacy HMAC key, if there is one.
+type Service struct {
+	signing Key
+	keys    map[string]Key
+	legacy  *Key
+	apiKeys APIKeys
+}
+
+// APIKeys resolves the claims of the API keys, that the service accepts
+// alongside the tokens.
+type APIKeys interface {
+	Claims(key string) (jwtauth.Claims, error)
+}
+
+// APIKeyHeader is the header of the API keys.
+const APIKeyHeader = "X-API-Key"
+
+// NewHMAC returns a service that signs and verifies with HS256 and the
+// secret, without kid.
+func NewHMAC(secret []byte) *Service {
+	k := Key{Method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+	return &Service{signing: k, keys: map[string]Key{}, legacy: &k}
+}
+
+// FromEnv returns the service configured by the environment. XD_SIGNING_KEY
+// names the file of the signing key in the XD_KEYS_DIR directory, where the
+// rest of the keys are kept for verification. Without it, the tokens are
+// signed with HS256 and XD_SIGNING_STRING, which otherwise keeps verifying
+// the tokens without kid.
+func FromEnv() (*Service, error) {
+	secret := []byte(os.Getenv("XD_SIGNING_STRING"))
+
+	signing := os.Getenv("XD_SIGNING_KEY")
+	if signing == "" {
+		return NewHMAC(secret), nil
+	}
+
+	dir := os.Getenv("XD_KEYS_DIR")
+	if dir == "" {
+		dir = os.Getenv("XD_BASE_PATH") + "keys"
+	}
+
+	return Load(dir, signing, secret)
+}
+
+// Encode returns the signed token of the claims.
+func (s *Service) Encode(claims jwtauth.Claims) (string, error) {
+	t := jwt.NewWithClaims(s.signing.Method, jwt.MapClaims(claims))
+	if s.signing.ID != "" {
+		t.Header["kid"] = s.signing.ID
+	}
+
+	return t.SignedString(s.signing.sign)
+}
+
+// Decode parses a token and verifies its signature and expiry. Every token
+// the API signs expires, so those without exp are rejected.
+func (s *Service) Decode(tokenString string) (*jwt.Token, error) {
+	t, err := jwt.Parse(tokenString, s.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, _ := t.Claims.(jwt.MapClaims)
+	if _, ok := claims["exp"]; !ok {
+		return nil, ErrNoExpiry
+	}
+
+	return t, nil
+}
+
+// keyFunc returns the key to verify a token, by the kid of its header.
+func (s *Service) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	var k Key
+	if kid == "" {
+		if s.legacy == nil {
+			return nil, ErrUnknownKey
+		}
+
+		k = *s.legacy
+	} else {
+		var ok bool
+		k, ok = s.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, ErrUnexpectedAlg
+	}
+
+	return k.verify, nil
+}
+
+// AcceptKeys makes the verifier accept the API keys of the resolver.
+func (s *Service) AcceptKeys(k APIKeys) {
+	s.apiKeys = k
+}
+
+// Verifier puts in the context the token of the request, searched as
+// jwtauth.Verifier does, so jwtauth.Authenticator and jwtauth.FromContext
+// keep working.
+func (s *Service) Verifier(next http.Handler) http.Handler {
+	return s.Verify(jwtauth.TokenFromQuery, jwtauth.TokenFromHeader, jwtauth.TokenFromCookie)(next)
+}
+
+// Verify puts in the context the token found by the first function that
+// returns one. Requests with an API key get a token with its claims.
+func (s *Service) Verify(findTokenFns ...func(r *http.Request) string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get(APIKeyHeader); key != "" && s.apiKeys != nil {
+				t, err := s.keyToken(key)
+				next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), t, err)))
+				return
+			}
+
+			var tokenString string
+			for _, fn := range findTokenFns {
+				tokenString = fn(r)
+				if tokenString != "" {
+					break
+				}
+			}
+
+			if tokenString == "" {
+				ctx := jwtauth.NewContext(r.Context(), nil, jwtauth.ErrNoTokenFound)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			t, err := s.Decode(tokenString)
+			if err != nil {
+				t = nil
+			}
+
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), t, err)))
+		})
+	}
+}
+
+// keyToken returns a valid token with the claims of an API key.
+func (s *Service) keyToken(key string) (*jwt.Token, error) {
+	claims, err := s.apiKeys.Claims(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jwt.Token{
+		Header: map[string]interface{}{"alg": "none"},
+		Claims: jwt.MapClaims(claims),
+		Method: jwt.SigningMethodNone,
+		Valid:  true,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+	"github.com/stretchr/testify/assert"
+)
+
+// keysDir returns a directory with the RSA key old.rsa and the ECDSA key
+// new.ec.
+func keysDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.Nil(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	writePEM(t, filepath.Join(dir, "old.rsa"), "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(ecKey)
+	assert.Nil(t, err)
+	writePEM(t, filepath.Join(dir, "new.ec"), "EC PRIVATE KEY", der)
+
+	return dir
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	assert.Nil(t, ioutil.WriteFile(path, data, 0600))
+}
+
+func TestRotation(t *testing.T) {
+	dir := keysDir(t)
+	defer os.RemoveAll(dir)
+
+	old, err := Load(dir, "old.rsa", nil)
+	assert.Nil(t, err)
+	oldToken, err := old.Encode(jwtauth.Claims{"id": "1", "exp": jwtauth.ExpireIn(time.Hour)})
+	assert.Nil(t, err)
+
+	s, err := Load(dir, "new.ec", nil)
+	assert.Nil(t, err)
+	newToken, err := s.Encode(jwtauth.Claims{"id": "1", "exp": jwtauth.ExpireIn(time.Hour)})
+	assert.Nil(t, err)
+
+	tk, err := s.Decode(newToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "ES256", tk.Method.Alg())
+	assert.Equal(t, s.signing.ID, tk.Header["kid"])
+
+	tk, err = s.Decode(oldToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "RS256", tk.Method.Alg())
+
+	jwks := s.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	for _, k := range jwks.Keys {
+		assert.NotEmpty(t, k.Kid)
+		assert.Equal(t, "sig", k.Use)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	dir := keysDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := Load(dir, "old.rsa", []byte("secret"))
+	assert.Nil(t, err)
+
+	// An HMAC token claiming the RSA key must not be verified with its
+	// public key as secret.
+	pub, err := x509.MarshalPKIXPublicKey(s.signing.verify)
+	assert.Nil(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "1"})
+	forged.Header["kid"] = s.signing.ID
+	forgedString, _ := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	_, err = s.Decode(forgedString)
+	assert.NotNil(t, err)
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "1"})
+	unknown.Header["kid"] = "unknown"
+	unknownString, _ := unknown.SignedString([]byte("secret"))
+	_, err = s.Decode(unknownString)
+	assert.NotNil(t, err)
+
+	_, legacy, _ := jwtauth.New("HS256", []byte("secret"), nil).Encode(jwtauth.Claims{"id": "1", "exp": jwtauth.ExpireIn(time.Hour)})
+	_, err = s.Decode(legacy)
+	assert.Nil(t, err)
+
+	unexpiring, _ := s.Encode(jwtauth.Claims{"id": "1"})
+	_, err = s.Decode(unexpiring)
+	assert.Equal(t, ErrNoExpiry, err)
+
+	expired := jwtauth.Claims{"id": "1"}
+	expired.SetExpiry(time.Now().Add(-time.Minute))
+	expiredString, _ := s.Encode(expired)
+	_, err = s.Decode(expiredString)
+	assert.NotNil(t, err)
+}
+
+func TestVerifier(t *testing.T) {
+	s := NewHMAC([]byte("secret"))
+	token, err := s.Encode(jwtauth.Claims{"id": "1", "exp": jwtauth.ExpireIn(time.Hour)})
+	assert.Nil(t, err)
+
+	h := s.Verifier(jwtauth.Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, _ := jwtauth.FromContext(r.Context())
+		assert.Equal(t, "1", claims["id"])
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	for _, tc := range []struct {
+		token string
+		code  int
+	}{
+		{token, http.StatusOK},
+		{"", http.StatusUnauthorized},
+		{"bad", http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tc.code, w.Code)
+	}
+}
+
+// fakeKeys accepts the API key good for the client 1.
+type fakeKeys struct{}
+
+func (fakeKeys) Claims(key string) (jwtauth.Claims, error) {
+	if key != "good" {
+		return nil, errors.New("invalid key")
+	}
+
+	return jwtauth.Claims{"client_id": "1"}, nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	s := NewHMAC([]byte("secret"))
+	s.AcceptKeys(fakeKeys{})
+
+	h := s.Verifier(jwtauth.Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, _ := jwtauth.FromContext(r.Context())
+		assert.Equal(t, "1", claims["client_id"])
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	for _, tc := range []struct {
+		key  string
+		code int
+	}{
+		{"good", http.StatusOK},
+		{"bad", http.StatusUnauthorized},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(APIKeyHeader, tc.key)
+
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tc.code, w.Code)
+	}
+}
+
+func TestRepoKeys(t *testing.T) {
+	s, err := Load("../../keys", "private.rsa", nil)
+	assert.Nil(t, err)
+	assert.Len(t, s.JWKS().Keys, 1)
+}
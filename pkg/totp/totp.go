@@ -0,0 +1,118 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP settings, the defaults of the authenticator apps.
+const (
+	// Period is how long a code lasts.
+	Period = 30 * time.Second
+	// Digits are the digits of a code.
+	Digits = 6
+	// Skew are the periods before and after the current one that are
+	// accepted, for clocks out of sync.
+	Skew = 1
+	// secretBytes are the random bytes of a secret.
+	secretBytes = 20
+)
+
+// encoding is the base32 of the secrets, without padding.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a new random secret encoded in base32.
+func NewSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// Code returns the code of the secret at a time, as RFC 6238 defines it.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	return code(key, uint64(Step(t))), nil
+}
+
+// Step returns the time step of a time, the counter of its code.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// code returns the HOTP code of the key and counter.
+func code(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
+
+// Verify checks the code of the secret at a time, accepting the Skew
+// periods around it that are after the step last, the one of the last code
+// accepted, so no code is accepted twice. It returns the step of the code.
+func Verify(secret, c string, t time.Time, last int64) (int64, bool) {
+	c = strings.Replace(c, " ", "", -1)
+	if len(c) != Digits {
+		return 0, false
+	}
+
+	for i := -Skew; i <= Skew; i++ {
+		at := t.Add(time.Duration(i) * Period)
+		if Step(at) <= last {
+			continue
+		}
+
+		expected, err := Code(secret, at)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(c)) == 1 {
+			return Step(at), true
+		}
+	}
+
+	return 0, false
+}
+
+// URI returns the provisioning URI of the secret, that the authenticator
+// apps read from a QR code.
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors of RFC 6238.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tt := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range tt {
+		c, err := Code(rfcSecret, time.Unix(tc.unix, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, tc.code, c)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret, err := NewSecret()
+	assert.Nil(t, err)
+
+	assert := assert.New(t)
+
+	now := time.Now()
+	c, err := Code(secret, now)
+	assert.Nil(err)
+
+	verify := func(c string, at time.Time, last int64) bool {
+		_, ok := Verify(secret, c, at, last)
+		return ok
+	}
+
+	assert.True(verify(c, now, 0))
+	assert.True(verify(c[:3]+" "+c[3:], now, 0))
+	assert.True(verify(c, now.Add(Period), 0))
+	assert.False(verify(c, now.Add(3*Period), 0))
+	assert.False(verify("", now, 0))
+	_, ok := Verify("not base32!", c, now, 0)
+	assert.False(ok)
+
+	// The step of the code is returned, and once it is the last one the
+	// code and those before it are rejected.
+	step, ok := Verify(secret, c, now, 0)
+	assert.True(ok)
+	assert.Equal(Step(now), step)
+	assert.False(verify(c, now, step))
+	assert.False(verify(c, now.Add(Period), step))
+
+	next, err := Code(secret, now.Add(Period))
+	assert.Nil(err)
+	assert.True(verify(next, now, step))
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("MenuXD", "ana@menuxd.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/MenuXD:ana@menuxd.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=MenuXD")
+}
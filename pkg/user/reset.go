@@ -0,0 +1,86 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/pkg/model"
+)
+
+// Errors.
+var (
+	ErrInvalidReset  = errors.New("the reset link is invalid or expired")
+	ErrShortPassword = errors.New("the password is too short")
+	ErrNotMatch      = errors.New("passwords don't match")
+)
+
+// Reset settings.
+const (
+	// ResetTTL is how long a reset link can be used.
+	ResetTTL = time.Hour
+	// MinPasswordLength is the length a new password needs at least.
+	MinPasswordLength = 8
+)
+
+// Reset is a request to reset the password of a user. Only the hash of its
+// token is stored, and it can be used once.
+type Reset struct {
+	model.Model
+	UserID    uint       `gorm:"index" json:"user_id"`
+	TokenHash string     `gorm:"unique_index" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// NewReset returns a reset of the user with its token.
+func NewReset(userID uint, now time.Time) (*Reset, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	r := &Reset{
+		UserID:    userID,
+		TokenHash: HashResetToken(token),
+		ExpiresAt: now.Add(ResetTTL),
+	}
+
+	return r, token, nil
+}
+
+// IsValid checks that the reset was not used nor expired.
+func (r Reset) IsValid(now time.Time) bool {
+	return r.UsedAt == nil && now.Before(r.ExpiresAt)
+}
+
+// HashResetToken returns the hash of a reset token.
+func HashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewPassword is a new password set with a reset token.
+type NewPassword struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirm_password"`
+}
+
+// Validate checks that the password is long enough and confirmed.
+func (p NewPassword) Validate() error {
+	if len(p.Password) < MinPasswordLength {
+		return ErrShortPassword
+	}
+
+	if p.Password != p.ConfirmPassword {
+		return ErrNotMatch
+	}
+
+	return nil
+}
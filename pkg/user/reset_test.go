@@ -0,0 +1,28 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReset(t *testing.T) {
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	r, token, err := NewReset(1, now)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, HashResetToken(token), r.TokenHash)
+	assert.True(t, r.IsValid(now))
+	assert.False(t, r.IsValid(now.Add(ResetTTL)))
+
+	r.UsedAt = &now
+	assert.False(t, r.IsValid(now))
+}
+
+func TestNewPasswordValidate(t *testing.T) {
+	assert.Nil(t, NewPassword{Password: "Dk123456", ConfirmPassword: "Dk123456"}.Validate())
+	assert.Equal(t, ErrShortPassword, NewPassword{Password: "Dk1", ConfirmPassword: "Dk1"}.Validate())
+	assert.Equal(t, ErrNotMatch, NewPassword{Password: "Dk123456", ConfirmPassword: "Dk123457"}.Validate())
+}
@@ -0,0 +1,27 @@
+package user
+
+// Roles of the users. Owners have the clients, the staff is invited by them
+// to one of those with the rest of the roles. Integration is the role of
+// the API keys of a client.
+const (
+	Admin   = "admin"
+	Client  = "client"
+	Owner   = "owner"
+	Manager = "manager"
+	Cashier = "cashier"
+	Waiter  = "waiter"
+	Kitchen = "kitchen"
+	Table   = "table"
+
+	Integration = "integration"
+)
+
+// IsStaff checks that the role is one an owner can invite to a client.
+func IsStaff(role string) bool {
+	switch role {
+	case Manager, Cashier, Waiter, Kitchen, Table:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,107 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gitlab.com/menuxd/api-rest/pkg/model"
+)
+
+// Errors.
+var (
+	ErrInvalidCode       = errors.New("the code is invalid")
+	ErrTwoFactorEnabled  = errors.New("two factor authentication is already enabled")
+	ErrTwoFactorDisabled = errors.New("two factor authentication is not enabled")
+	ErrCodeUsed          = errors.New("the code was already used")
+	ErrChallengeUsed     = errors.New("the login challenge was already used")
+)
+
+// Two factor settings.
+const (
+	// Issuer is the name the authenticator apps show.
+	Issuer = "MenuXD"
+	// RecoveryCodes are the codes given when two factor is enabled.
+	RecoveryCodes = 10
+	// recoveryLength is the characters of a recovery code.
+	recoveryLength = 10
+	// recoveryAlphabet leaves out l, o, 0 and 1, easy to mistake.
+	recoveryAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+)
+
+// RecoveryCode is a single use code to log in without the authenticator.
+// Only its hash is stored.
+type RecoveryCode struct {
+	model.Model
+	UserID   uint       `gorm:"index" json:"user_id"`
+	CodeHash string     `gorm:"index" json:"-"`
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+}
+
+// TwoFactorPolicy tells whether the users of a role must use two factor
+// authentication.
+type TwoFactorPolicy struct {
+	Role     string `gorm:"primary_key" json:"role"`
+	Required bool   `json:"required"`
+}
+
+// TOTPStep is the time step of the last code of the authenticator of a
+// user that was accepted. The codes of that step and before are rejected.
+type TOTPStep struct {
+	UserID uint  `gorm:"primary_key;auto_increment:false" json:"user_id"`
+	Step   int64 `json:"step"`
+}
+
+// UsedChallenge is a login challenge exchanged for a session, kept until it
+// expires so it can't be exchanged again.
+type UsedChallenge struct {
+	ID        string    `gorm:"primary_key" json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TwoFactorPolicies alias for a slice of TwoFactorPolicy.
+type TwoFactorPolicies []TwoFactorPolicy
+
+// NewRecoveryCodes returns new random recovery codes.
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodes)
+	for i := range codes {
+		b := make([]byte, recoveryLength)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+
+		for j := range b {
+			b[j] = recoveryAlphabet[int(b[j])%len(recoveryAlphabet)]
+		}
+
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+	}
+
+	return codes, nil
+}
+
+// NewChallengeID returns a new random ID of a login challenge.
+func NewChallengeID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code, ignoring its case,
+// spaces and dashes.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,25 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes()
+	assert.Nil(t, err)
+	assert.Len(t, codes, RecoveryCodes)
+
+	seen := map[string]bool{}
+	for _, c := range codes {
+		assert.Len(t, c, recoveryLength+1)
+		assert.False(t, seen[c])
+		seen[c] = true
+	}
+
+	c := codes[0]
+	assert.Equal(t, HashRecoveryCode(c), HashRecoveryCode(strings.ToUpper(strings.Replace(c, "-", " ", 1))))
+	assert.NotEqual(t, HashRecoveryCode(c), HashRecoveryCode(codes[1]))
+}
@@ -19,7 +19,19 @@ type Storage interface {
 	GetAll() (Users, error)
 	GetByID(id uint) (User, error)
 	GetByEmail(email string) (User, error)
-	RecoverPassword(address string) error
+	GetByClient(clientID uint) (Users, error)
+	RequestReset(address string) error
+	ResetPassword(p NewPassword) (uint, error)
+	SetTOTPSecret(id uint, secret string) error
+	EnableTOTP(id uint, codes []string) error
+	DisableTOTP(id uint) error
+	UseRecoveryCode(id uint, code string) error
+	TOTPStep(id uint) (int64, error)
+	UseTOTPStep(id uint, step int64) error
+	UseChallenge(c UsedChallenge) error
+	TwoFactorRequired(role string) (bool, error)
+	SetTwoFactorPolicy(p TwoFactorPolicy) error
+	GetTwoFactorPolicies() (TwoFactorPolicies, error)
 }
 
 // User of the system.
@@ -28,11 +40,14 @@ type User struct {
 	Email           string `gorm:"unique_index" json:"email"`
 	Password        string `gorm:"-" bson:"-" json:"password,omitempty"`
 	Role            string `gorm:"default:'client'" json:"role,omitempty"`
+	ClientID        uint   `bson:"client_id" json:"client_id,omitempty"`
 	ImageURL        string `bson:"image_url" json:"image_url,omitempty"`
 	Confirmed       bool   `gorm:"default:false" json:"confirmed,omitempty"`
 	ConfirmPassword string `gorm:"-" bson:"-" json:"confirm_password,omitempty"`
 	OldPassword     string `gorm:"-" bson:"-" json:"old_password,omitempty"`
 	HashPassword    string `gorm:"column:password" bson:"password" json:"hash_password,omitempty"`
+	TOTPSecret      string `bson:"totp_secret" json:"-"`
+	TOTPEnabled     bool   `gorm:"default:false" bson:"totp_enabled" json:"totp_enabled"`
 }
 
 // ConfirmPass compare Password and ConfirmPassword and return true if they are the same
@@ -1,11 +1,21 @@
 package waiter
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"regexp"
+	"strconv"
 
 	"gitlab.com/menuxd/api-rest/pkg/model"
 )
 
+// Errors.
+var (
+	ErrPINTaken = errors.New("the PIN belongs to another waiter")
+)
+
 // Storage handle the CRUD operations with Waiters.
 type Storage interface {
 	Create(waiter *Waiter) error
@@ -13,19 +23,37 @@ type Storage interface {
 	Delete(id uint) error
 	GetAll(clientID uint) (Waiters, error)
 	GetByID(id uint) (Waiter, error)
+	GetByPIN(clientID uint, pin string) (Waiter, error)
 }
 
+// Waiter of a client. The PIN is only received, it is stored hashed.
 type Waiter struct {
 	model.Model
 	Name     string `bson:"name" json:"name"`
-	PIN      string `gorm:"default:'1234'" bson:"pin" json:"pin"`
+	PIN      string `gorm:"-" bson:"-" json:"pin,omitempty"`
+	PINHash  string `gorm:"index" bson:"pin_hash" json:"-"`
 	ClientID uint   `bson:"client_id" json:"client_id"`
 }
 
+// Login is the PIN of a waiter entered in a device of the client.
+type Login struct {
+	ClientID uint   `json:"client_id"`
+	PIN      string `json:"pin"`
+}
+
 // VerifyPIN validate a PIN, like 1234
 func (w *Waiter) VerifyPIN() bool {
-	re, _ := regexp.Compile("[0-9]{4}")
-	return re.MatchString(w.PIN) && len(w.PIN) == 4
+	re, _ := regexp.Compile("^[0-9]{4}$")
+	return re.MatchString(w.PIN)
+}
+
+// HashPIN returns the keyed hash of a PIN of a client. It is the same for
+// the same PIN, so it can be looked up, but can't be reversed without the
+// secret.
+func HashPIN(secret []byte, clientID uint, pin string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.Itoa(int(clientID)) + ":" + pin))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 // Waiters alias for a slice of Waiters.
@@ -1 +1,29 @@
 package waiter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPIN(t *testing.T) {
+	for pin, ok := range map[string]bool{
+		"1234":  true,
+		"0000":  true,
+		"123":   false,
+		"12345": false,
+		"12a4":  false,
+		"":      false,
+	} {
+		w := Waiter{PIN: pin}
+		assert.Equal(t, ok, w.VerifyPIN(), pin)
+	}
+}
+
+func TestHashPIN(t *testing.T) {
+	secret := []byte("secret")
+
+	assert.Equal(t, HashPIN(secret, 1, "1234"), HashPIN(secret, 1, "1234"))
+	assert.NotEqual(t, HashPIN(secret, 1, "1234"), HashPIN(secret, 2, "1234"))
+	assert.NotEqual(t, HashPIN(secret, 1, "1234"), HashPIN([]byte("other"), 1, "1234"))
+}